@@ -15,6 +15,7 @@ import (
 	"github.com/globocom/tsuru/db"
 	"github.com/globocom/tsuru/errors"
 	"github.com/globocom/tsuru/log"
+	"github.com/globocom/tsuru/provision"
 	"github.com/globocom/tsuru/quota"
 	"github.com/globocom/tsuru/rec"
 	"github.com/globocom/tsuru/repository"
@@ -22,6 +23,7 @@ import (
 	"io"
 	"io/ioutil"
 	"labix.org/v2/mgo/bson"
+	"launchpad.net/goyaml"
 	"net/http"
 	"strconv"
 )
@@ -52,7 +54,7 @@ func cloneRepository(w http.ResponseWriter, r *http.Request, t *auth.Token) erro
 	if err != nil {
 		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", instance.Name)}
 	}
-	return app.DeployApp(instance, version, w)
+	return deployError(app.DeployApp(instance, version, r.PostFormValue("user"), w))
 }
 
 func appIsAvailable(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
@@ -135,29 +137,68 @@ func createApp(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
 	rec.Log(u.Email, "create-app", "name="+a.Name, "platform="+a.Platform)
 	err = app.CreateApp(&a, u)
 	if err != nil {
-		log.Errorf("Got error while creating app: %s", err)
-		if e, ok := err.(*errors.ValidationError); ok {
-			return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+		return createAppError(err)
+	}
+	return writeCreatedApp(w, &a)
+}
+
+// createAppFromManifest creates the app declared in the YAML manifest in the
+// request body. The name in the manifest may be replaced by the "name"
+// parameter, so one manifest creates many similar apps.
+func createAppFromManifest(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	m, err := app.ParseManifest(body)
+	if err != nil {
+		return createAppError(err)
+	}
+	if name := r.URL.Query().Get("name"); name != "" {
+		m.Name = name
+	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	rec.Log(u.Email, "create-app-from-manifest", "name="+m.Name, "platform="+m.Platform)
+	a, err := app.CreateAppFromManifest(m, u)
+	if err != nil {
+		return createAppError(err)
+	}
+	return writeCreatedApp(w, a)
+}
+
+func createAppError(err error) error {
+	log.Errorf("Got error while creating app: %s", err)
+	if e, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
+	if _, ok := err.(app.NoTeamsError); ok {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "In order to create an app, you should be member of at least one team",
 		}
-		if _, ok := err.(app.NoTeamsError); ok {
-			return &errors.HTTP{
-				Code:    http.StatusBadRequest,
-				Message: "In order to create an app, you should be member of at least one team",
-			}
+	}
+	if err == service.ErrAccessNotAllowed {
+		return &errors.HTTP{Code: http.StatusForbidden, Message: err.Error()}
+	}
+	if e, ok := err.(*app.AppCreationError); ok {
+		if e.Err == app.ErrAppAlreadyExists {
+			return &errors.HTTP{Code: http.StatusConflict, Message: e.Error()}
 		}
-		if e, ok := err.(*app.AppCreationError); ok {
-			if e.Err == app.ErrAppAlreadyExists {
-				return &errors.HTTP{Code: http.StatusConflict, Message: e.Error()}
-			}
-			if _, ok := e.Err.(*quota.QuotaExceededError); ok {
-				return &errors.HTTP{
-					Code:    http.StatusForbidden,
-					Message: "Quota exceeded",
-				}
+		if _, ok := e.Err.(*quota.QuotaExceededError); ok {
+			return &errors.HTTP{
+				Code:    http.StatusForbidden,
+				Message: "Quota exceeded",
 			}
 		}
-		return err
 	}
+	return err
+}
+
+func writeCreatedApp(w http.ResponseWriter, a *app.App) error {
 	msg := map[string]string{
 		"status":         "success",
 		"repository_url": repository.ReadWriteURL(a.Name),
@@ -170,6 +211,30 @@ func createApp(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
 	return nil
 }
 
+// exportApp writes the manifest of the app, in YAML, that creates a similar
+// app with createAppFromManifest.
+func exportApp(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	a, err := getApp(r.URL.Query().Get(":app"), u)
+	if err != nil {
+		return err
+	}
+	m, err := a.Manifest()
+	if err != nil {
+		return err
+	}
+	data, err := goyaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/x-yaml")
+	_, err = w.Write(data)
+	return err
+}
+
 func numberOfUnits(r *http.Request) (uint, error) {
 	missingMsg := "You must provide the number of units."
 	if r.Body == nil {
@@ -360,11 +425,22 @@ func runCommand(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
 	}
 	appName := r.URL.Query().Get(":app")
 	once := r.URL.Query().Get("once")
-	rec.Log(u.Email, "run-command", "app="+appName, "command="+string(c))
+	isolated := r.URL.Query().Get("isolated") == "true"
+	if isolated {
+		rec.Log(u.Email, "run-command", "app="+appName, "command="+string(c), "isolated=true")
+	} else {
+		rec.Log(u.Email, "run-command", "app="+appName, "command="+string(c))
+	}
+	if _, ok := app.Provisioner.(provision.IsolatedRunner); isolated && !ok {
+		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: provision.ErrIsolatedRunNotSupported.Error()}
+	}
 	app, err := getApp(appName, u)
 	if err != nil {
 		return err
 	}
+	if isolated {
+		return app.RunIsolated(string(c), w)
+	}
 	return app.Run(string(c), w, once == "true")
 }
 
@@ -461,50 +537,84 @@ func unsetEnv(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
 	return app.UnsetEnvs(variables, true)
 }
 
-func setCName(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+// reencryptEnvs encrypts the values of the private environment variables of
+// all apps with the current key, after the key is rotated.
+func reencryptEnvs(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	rec.Log(u.Email, "env-reencrypt")
+	n, err := app.ReencryptEnvs()
+	if err == app.ErrNoEncryptionKey {
+		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(map[string]int{"reencrypted": n})
+}
+
+// readCName reads the cname from the JSON body of the request.
+func readCName(r *http.Request) (string, error) {
 	msg := "You must provide the cname."
 	if r.Body == nil {
-		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+		return "", &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
 	var v map[string]string
 	err := json.NewDecoder(r.Body).Decode(&v)
 	if err != nil {
-		return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid JSON in request body."}
+		return "", &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid JSON in request body."}
 	}
 	if _, ok := v["cname"]; !ok {
-		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+		return "", &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	return v["cname"], nil
+}
+
+func addCName(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	cname, err := readCName(r)
+	if err != nil {
+		return err
 	}
 	u, err := t.User()
 	if err != nil {
 		return err
 	}
 	appName := r.URL.Query().Get(":app")
-	rec.Log(u.Email, "set-cname", "app="+appName, "cname="+v["cname"])
-	app, err := getApp(appName, u)
+	rec.Log(u.Email, "add-cname", "app="+appName, "cname="+cname)
+	a, err := getApp(appName, u)
 	if err != nil {
 		return err
 	}
-	if err = app.SetCName(v["cname"]); err == nil {
-		return nil
-	}
-	if err.Error() == "Invalid cname" {
+	switch err = a.AddCName(cname); err {
+	case app.ErrInvalidCName:
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case app.ErrCNameExists:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
 	}
 	return err
 }
 
-func unsetCName(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+func removeCName(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	cname, err := readCName(r)
+	if err != nil {
+		return err
+	}
 	u, err := t.User()
 	if err != nil {
 		return err
 	}
 	appName := r.URL.Query().Get(":app")
-	app, err := getApp(appName, u)
+	a, err := getApp(appName, u)
 	if err != nil {
 		return err
 	}
-	rec.Log(u.Email, "unset-cname", "app="+appName)
-	return app.UnsetCName()
+	rec.Log(u.Email, "remove-cname", "app="+appName, "cname="+cname)
+	if err = a.RemoveCName(cname); err == app.ErrCNameNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
 }
 
 func appLog(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
@@ -96,6 +96,35 @@ func (s *S) TestCloneRepositoryHandler(c *gocheck.C) {
 	c.Assert(s.provisioner.Version(&a), gocheck.Equals, "a345f3e")
 }
 
+func (s *S) TestCloneRepositoryRecordsDeploy(c *gocheck.C) {
+	a := app.App{
+		Name:     "otherapp",
+		Platform: "zend",
+		Teams:    []string{s.team.Name},
+		Units:    []app.Unit{{Name: "i-0800", State: "started"}},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	url := fmt.Sprintf("/apps/%s/repository/clone?:appname=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("version=a345f3e&user=fulano"))
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = cloneRepository(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	var d app.Deploy
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name}).One(&d)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(d.Commit, gocheck.Equals, "a345f3e")
+	c.Assert(d.User, gocheck.Equals, "fulano")
+	c.Assert(d.Status, gocheck.Equals, app.DeploySucceeded)
+	c.Assert(d.Log, gocheck.Equals, "Deploy called")
+}
+
 func (s *S) TestCloneRepositoryShouldIncrementDeployNumberOnApp(c *gocheck.C) {
 	a := app.App{
 		Name:     "otherapp",
@@ -416,6 +445,120 @@ func (s *S) TestCreateAppHandler(c *gocheck.C) {
 	c.Assert(action, testing.IsRecorded)
 }
 
+func (s *S) TestCreateAppFromManifestHandler(c *gocheck.C) {
+	h := testHandler{}
+	ts := s.t.StartGandalfTestServer(&h)
+	defer ts.Close()
+	a := app.App{Name: "otherapp"}
+	defer func() {
+		err := a.Get()
+		c.Assert(err, gocheck.IsNil)
+		err = app.Delete(&a)
+		c.Assert(err, gocheck.IsNil)
+	}()
+	b := strings.NewReader("name: someapp\nplatform: zend\nenv:\n  DEBUG: \"0\"\n")
+	request, err := http.NewRequest("POST", "/apps/manifest?name=otherapp", b)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-yaml")
+	recorder := httptest.NewRecorder()
+	err = createAppFromManifest(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Code, gocheck.Equals, http.StatusOK)
+	var obtained map[string]string
+	err = json.NewDecoder(recorder.Body).Decode(&obtained)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(obtained["repository_url"], gocheck.Equals, repository.ReadWriteURL(a.Name))
+	var gotApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "otherapp"}).One(&gotApp)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(gotApp.Platform, gocheck.Equals, "zend")
+	c.Assert(gotApp.Env["DEBUG"].Value, gocheck.Equals, "0")
+	c.Assert(gotApp.Env["DEBUG"].Public, gocheck.Equals, true)
+	n, err := s.conn.Apps().Find(bson.M{"name": "someapp"}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	action := testing.Action{
+		Action: "create-app-from-manifest",
+		User:   s.user.Email,
+		Extra:  []interface{}{"name=otherapp", "platform=zend"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestCreateAppFromManifestHandlerInvalidManifest(c *gocheck.C) {
+	b := strings.NewReader("name: [someapp")
+	request, err := http.NewRequest("POST", "/apps/manifest", b)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = createAppFromManifest(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+	c.Assert(e.Message, gocheck.Matches, "Invalid manifest: .*")
+}
+
+func (s *S) TestCreateAppFromManifestHandlerUnknownInstance(c *gocheck.C) {
+	b := strings.NewReader("name: someapp\nplatform: zend\nservices:\n  - instance: my-mongo\n")
+	request, err := http.NewRequest("POST", "/apps/manifest", b)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = createAppFromManifest(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+	n, err := s.conn.Apps().Find(bson.M{"name": "someapp"}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestExportAppHandler(c *gocheck.C) {
+	a := app.App{
+		Name:     "someapp",
+		Platform: "zend",
+		Teams:    []string{s.team.Name},
+		Units:    []app.Unit{{Name: "someapp/0"}},
+		Env: map[string]bind.EnvVar{
+			"DEBUG":  {Name: "DEBUG", Value: "0", Public: true},
+			"SECRET": {Name: "SECRET", Value: "s3cr3t"},
+		},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("GET", "/apps/someapp/manifest?:app=someapp", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = exportApp(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Header().Get("Content-Type"), gocheck.Equals, "application/x-yaml")
+	m, err := app.ParseManifest(recorder.Body.Bytes())
+	c.Assert(err, gocheck.IsNil)
+	expected := app.Manifest{
+		Name:     "someapp",
+		Platform: "zend",
+		Units:    1,
+		Env:      map[string]string{"DEBUG": "0"},
+	}
+	c.Assert(*m, gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestExportAppHandlerNoAccess(c *gocheck.C) {
+	a := app.App{Name: "someapp", Platform: "zend"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("GET", "/apps/someapp/manifest?:app=someapp", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = exportApp(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestCreateAppQuotaExceeded(c *gocheck.C) {
 	err := quota.Create(s.user.Email, 0)
 	c.Assert(err, gocheck.IsNil)
@@ -1178,6 +1321,58 @@ func (s *S) TestRunHandler(c *gocheck.C) {
 	c.Assert(action, testing.IsRecorded)
 }
 
+func (s *S) TestRunIsolatedHandler(c *gocheck.C) {
+	s.provisioner.PrepareOutput([]byte("migrated"))
+	a := app.App{
+		Name:     "secrets",
+		Platform: "arch enemy",
+		Teams:    []string{s.team.Name},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	url := fmt.Sprintf("/apps/%s/run/?:app=%s&isolated=true", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("python manage.py migrate"))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = runCommand(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Body.String(), gocheck.Equals, "migrated")
+	cmds := s.provisioner.IsolatedCmds(&a)
+	c.Assert(cmds, gocheck.HasLen, 1)
+	c.Assert(cmds[0].Cmd, gocheck.Matches, `.*source /home/application/apprc.*python manage.py migrate$`)
+	c.Assert(s.provisioner.GetCmds("", &a), gocheck.HasLen, 0)
+	action := testing.Action{
+		Action: "run-command",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "command=python manage.py migrate", "isolated=true"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestRunIsolatedHandlerNotSupported(c *gocheck.C) {
+	app.Provisioner = struct{ provision.Provisioner }{s.provisioner}
+	defer func() {
+		app.Provisioner = s.provisioner
+	}()
+	a := app.App{Name: "secrets", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	url := fmt.Sprintf("/apps/%s/run/?:app=%s&isolated=true", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("ls"))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = runCommand(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusPreconditionFailed)
+}
+
 func (s *S) TestRunHandlerReturnsTheOutputOfTheCommandEvenIfItFails(c *gocheck.C) {
 	s.provisioner.PrepareFailure("ExecuteCommand", &errors.HTTP{Code: 500, Message: "something went wrong"})
 	s.provisioner.PrepareOutput([]byte("failure output"))
@@ -1558,6 +1753,46 @@ func (s *S) TestSetEnvHandlerReturnsForbiddenIfTheGivenUserDoesNotHaveAccessToTh
 	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
 }
 
+func (s *S) TestReencryptEnvsHandler(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	a := app.App{
+		Name: "secretive",
+		Env: map[string]bind.EnvVar{
+			"DATABASE_PASSWORD": {Name: "DATABASE_PASSWORD", Value: "s3cr3t"},
+		},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("POST", "/env/reencrypt", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = reencryptEnvs(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	var result map[string]int
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(result["reencrypted"] >= 1, gocheck.Equals, true)
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Env["DATABASE_PASSWORD"].Value, gocheck.Not(gocheck.Equals), "s3cr3t")
+	c.Assert(a.Envs()["DATABASE_PASSWORD"].Value, gocheck.Equals, "s3cr3t")
+	action := testing.Action{Action: "env-reencrypt", User: s.user.Email}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestReencryptEnvsHandlerWithoutKey(c *gocheck.C) {
+	request, err := http.NewRequest("POST", "/env/reencrypt", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = reencryptEnvs(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusPreconditionFailed)
+}
+
 func (s *S) TestUnsetEnvHandlerRemovesTheEnvironmentVariablesFromTheApp(c *gocheck.C) {
 	a := app.App{
 		Name:  "swift",
@@ -1721,7 +1956,7 @@ func (s *S) TestUnsetEnvHandlerReturnsForbiddenIfTheGivenUserDoesNotHaveAccessTo
 	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
 }
 
-func (s *S) TestSetCNameHandler(c *gocheck.C) {
+func (s *S) TestAddCNameHandler(c *gocheck.C) {
 	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
 	err := s.conn.Apps().Insert(a)
 	c.Assert(err, gocheck.IsNil)
@@ -1734,21 +1969,21 @@ func (s *S) TestSetCNameHandler(c *gocheck.C) {
 	request, err := http.NewRequest("POST", url, b)
 	c.Assert(err, gocheck.IsNil)
 	recorder := httptest.NewRecorder()
-	err = setCName(recorder, request, s.token)
+	err = addCName(recorder, request, s.token)
 	c.Assert(err, gocheck.IsNil)
 	err = a.Get()
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(a.CName, gocheck.Equals, "leper.secretcompany.com")
+	c.Assert(a.CName, gocheck.DeepEquals, []string{"leper.secretcompany.com"})
 	action := testing.Action{
-		Action: "set-cname",
+		Action: "add-cname",
 		User:   s.user.Email,
 		Extra:  []interface{}{"app=" + a.Name, "cname=leper.secretcompany.com"},
 	}
 	c.Assert(action, testing.IsRecorded)
 }
 
-func (s *S) TestSetCNameHandlerAcceptsEmptyCName(c *gocheck.C) {
-	a := app.App{Name: "leper", Teams: []string{s.team.Name}, CName: "leper.secretcompany.com"}
+func (s *S) TestAddCNameHandlerKeepsExistingCNames(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}, CName: []string{"leper.secretcompany.com"}}
 	err := s.conn.Apps().Insert(a)
 	c.Assert(err, gocheck.IsNil)
 	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
@@ -1756,34 +1991,53 @@ func (s *S) TestSetCNameHandlerAcceptsEmptyCName(c *gocheck.C) {
 	s.provisioner.Provision(&a)
 	defer s.provisioner.Destroy(&a)
 	url := fmt.Sprintf("/apps/%s/cname?:app=%s", a.Name, a.Name)
-	b := strings.NewReader(`{"cname":""}`)
+	b := strings.NewReader(`{"cname":"www.leper.secretcompany.com"}`)
 	request, err := http.NewRequest("POST", url, b)
 	c.Assert(err, gocheck.IsNil)
 	recorder := httptest.NewRecorder()
-	err = setCName(recorder, request, s.token)
+	err = addCName(recorder, request, s.token)
 	c.Assert(err, gocheck.IsNil)
 	err = a.Get()
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(a.CName, gocheck.Equals, "")
+	c.Assert(a.CName, gocheck.DeepEquals, []string{"leper.secretcompany.com", "www.leper.secretcompany.com"})
 }
 
-func (s *S) TestSetCNameHandlerReturnsInternalErrorIfItFailsToReadTheBody(c *gocheck.C) {
+func (s *S) TestAddCNameHandlerCNameAlreadyExists(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}, CName: []string{"leper.secretcompany.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
+	url := fmt.Sprintf("/apps/%s/cname?:app=%s", a.Name, a.Name)
+	b := strings.NewReader(`{"cname":"leper.secretcompany.com"}`)
+	request, err := http.NewRequest("POST", url, b)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = addCName(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusConflict)
+	c.Assert(e.Message, gocheck.Equals, app.ErrCNameExists.Error())
+}
+
+func (s *S) TestAddCNameHandlerReturnsInternalErrorIfItFailsToReadTheBody(c *gocheck.C) {
 	b := s.getTestData("bodyToBeClosed.txt")
 	request, err := http.NewRequest("POST", "/apps/unkown/cname?:app=unknown", b)
 	c.Assert(err, gocheck.IsNil)
 	request.Body.Close()
 	recorder := httptest.NewRecorder()
-	err = setCName(recorder, request, s.token)
+	err = addCName(recorder, request, s.token)
 	c.Assert(err, gocheck.NotNil)
 }
 
-func (s *S) TestSetCNameHandlerReturnsBadRequestWhenCNameIsMissingFromTheBody(c *gocheck.C) {
+func (s *S) TestAddCNameHandlerReturnsBadRequestWhenCNameIsMissingFromTheBody(c *gocheck.C) {
 	bodies := []io.Reader{nil, strings.NewReader(`{}`), strings.NewReader(`{"name":"something"}`)}
 	for _, b := range bodies {
 		request, err := http.NewRequest("POST", "/apps/unknown/cname?:app=unknown", b)
 		c.Assert(err, gocheck.IsNil)
 		recorder := httptest.NewRecorder()
-		err = setCName(recorder, request, s.token)
+		err = addCName(recorder, request, s.token)
 		c.Check(err, gocheck.NotNil)
 		e, ok := err.(*errors.HTTP)
 		c.Check(ok, gocheck.Equals, true)
@@ -1792,12 +2046,12 @@ func (s *S) TestSetCNameHandlerReturnsBadRequestWhenCNameIsMissingFromTheBody(c
 	}
 }
 
-func (s *S) TestSetCNameHandlerInvalidJSON(c *gocheck.C) {
+func (s *S) TestAddCNameHandlerInvalidJSON(c *gocheck.C) {
 	b := strings.NewReader(`}"I'm invalid json"`)
 	request, err := http.NewRequest("POST", "/apps/unknown/cname?:app=unknown", b)
 	c.Assert(err, gocheck.IsNil)
 	recorder := httptest.NewRecorder()
-	err = setCName(recorder, request, s.token)
+	err = addCName(recorder, request, s.token)
 	c.Assert(err, gocheck.NotNil)
 	e, ok := err.(*errors.HTTP)
 	c.Assert(ok, gocheck.Equals, true)
@@ -1805,19 +2059,19 @@ func (s *S) TestSetCNameHandlerInvalidJSON(c *gocheck.C) {
 	c.Assert(e.Message, gocheck.Equals, "Invalid JSON in request body.")
 }
 
-func (s *S) TestSetCNameHandlerUnknownApp(c *gocheck.C) {
+func (s *S) TestAddCNameHandlerUnknownApp(c *gocheck.C) {
 	b := strings.NewReader(`{"cname": "leper.secretcompany.com"}`)
 	request, err := http.NewRequest("POST", "/apps/unknown/cname?:app=unknown", b)
 	c.Assert(err, gocheck.IsNil)
 	recorder := httptest.NewRecorder()
-	err = setCName(recorder, request, s.token)
+	err = addCName(recorder, request, s.token)
 	c.Assert(err, gocheck.NotNil)
 	e, ok := err.(*errors.HTTP)
 	c.Assert(ok, gocheck.Equals, true)
 	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
 }
 
-func (s *S) TestSetCNameHandlerUserWithoutAccessToTheApp(c *gocheck.C) {
+func (s *S) TestAddCNameHandlerUserWithoutAccessToTheApp(c *gocheck.C) {
 	a := app.App{
 		Name:     "lost",
 		Platform: "vougan",
@@ -1831,14 +2085,14 @@ func (s *S) TestSetCNameHandlerUserWithoutAccessToTheApp(c *gocheck.C) {
 	request, err := http.NewRequest("POST", url, b)
 	c.Assert(err, gocheck.IsNil)
 	recorder := httptest.NewRecorder()
-	err = setCName(recorder, request, s.token)
+	err = addCName(recorder, request, s.token)
 	c.Assert(err, gocheck.NotNil)
 	e, ok := err.(*errors.HTTP)
 	c.Assert(ok, gocheck.Equals, true)
 	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
 }
 
-func (s *S) TestSetCNameHandlerInvalidCName(c *gocheck.C) {
+func (s *S) TestAddCNameHandlerInvalidCName(c *gocheck.C) {
 	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
 	err := s.conn.Apps().Insert(a)
 	c.Assert(err, gocheck.IsNil)
@@ -1849,7 +2103,7 @@ func (s *S) TestSetCNameHandlerInvalidCName(c *gocheck.C) {
 	request, err := http.NewRequest("POST", url, b)
 	c.Assert(err, gocheck.IsNil)
 	recorder := httptest.NewRecorder()
-	err = setCName(recorder, request, s.token)
+	err = addCName(recorder, request, s.token)
 	c.Assert(err, gocheck.NotNil)
 	e, ok := err.(*errors.HTTP)
 	c.Assert(ok, gocheck.Equals, true)
@@ -1857,43 +2111,79 @@ func (s *S) TestSetCNameHandlerInvalidCName(c *gocheck.C) {
 	c.Assert(e.Message, gocheck.Equals, "Invalid cname")
 }
 
-func (s *S) TestUnsetCNameHandler(c *gocheck.C) {
-	a := app.App{Name: "leper", Teams: []string{s.team.Name}, CName: "foo.bar.com"}
+func (s *S) TestRemoveCNameHandler(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
 	err := s.conn.Apps().Insert(a)
 	c.Assert(err, gocheck.IsNil)
 	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
 	defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
 	s.provisioner.Provision(&a)
 	defer s.provisioner.Destroy(&a)
+	err = a.AddCName("foo.bar.com")
+	c.Assert(err, gocheck.IsNil)
+	err = a.AddCName("www.foo.bar.com")
+	c.Assert(err, gocheck.IsNil)
 	url := fmt.Sprintf("/apps/%s/cname?:app=%s", a.Name, a.Name)
-	request, err := http.NewRequest("DELETE", url, nil)
+	b := strings.NewReader(`{"cname": "foo.bar.com"}`)
+	request, err := http.NewRequest("DELETE", url, b)
 	c.Assert(err, gocheck.IsNil)
 	recorder := httptest.NewRecorder()
-	err = unsetCName(recorder, request, s.token)
+	err = removeCName(recorder, request, s.token)
 	c.Assert(err, gocheck.IsNil)
 	err = a.Get()
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(a.CName, gocheck.Equals, "")
+	c.Assert(a.CName, gocheck.DeepEquals, []string{"www.foo.bar.com"})
+	c.Assert(s.provisioner.HasCName(&a, "foo.bar.com"), gocheck.Equals, false)
 	action := testing.Action{
-		Action: "unset-cname",
+		Action: "remove-cname",
 		User:   s.user.Email,
-		Extra:  []interface{}{"app=" + a.Name},
+		Extra:  []interface{}{"app=" + a.Name, "cname=foo.bar.com"},
 	}
 	c.Assert(action, testing.IsRecorded)
 }
 
-func (s *S) TestUnsetCNameHandlerUnknownApp(c *gocheck.C) {
+func (s *S) TestRemoveCNameHandlerCNameNotFound(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
+	url := fmt.Sprintf("/apps/%s/cname?:app=%s", a.Name, a.Name)
+	b := strings.NewReader(`{"cname": "foo.bar.com"}`)
+	request, err := http.NewRequest("DELETE", url, b)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = removeCName(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestRemoveCNameHandlerReturnsBadRequestWhenCNameIsMissingFromTheBody(c *gocheck.C) {
 	request, err := http.NewRequest("DELETE", "/apps/unknown/cname?:app=unknown", nil)
 	c.Assert(err, gocheck.IsNil)
 	recorder := httptest.NewRecorder()
-	err = unsetCName(recorder, request, s.token)
+	err = removeCName(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestRemoveCNameHandlerUnknownApp(c *gocheck.C) {
+	b := strings.NewReader(`{"cname": "foo.bar.com"}`)
+	request, err := http.NewRequest("DELETE", "/apps/unknown/cname?:app=unknown", b)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = removeCName(recorder, request, s.token)
 	c.Assert(err, gocheck.NotNil)
 	e, ok := err.(*errors.HTTP)
 	c.Assert(ok, gocheck.Equals, true)
 	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
 }
 
-func (s *S) TestUnsetCNameHandlerUserWithoutAccessToTheApp(c *gocheck.C) {
+func (s *S) TestRemoveCNameHandlerUserWithoutAccessToTheApp(c *gocheck.C) {
 	a := app.App{
 		Name:     "lost",
 		Platform: "vougan",
@@ -1903,10 +2193,11 @@ func (s *S) TestUnsetCNameHandlerUserWithoutAccessToTheApp(c *gocheck.C) {
 	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
 	defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
 	url := fmt.Sprintf("/apps/%s/cname?:app=%s", a.Name, a.Name)
-	request, err := http.NewRequest("DELETE", url, nil)
+	b := strings.NewReader(`{"cname": "foo.bar.com"}`)
+	request, err := http.NewRequest("DELETE", url, b)
 	c.Assert(err, gocheck.IsNil)
 	recorder := httptest.NewRecorder()
-	err = unsetCName(recorder, request, s.token)
+	err = removeCName(recorder, request, s.token)
 	c.Assert(err, gocheck.NotNil)
 	e, ok := err.(*errors.HTTP)
 	c.Assert(ok, gocheck.Equals, true)
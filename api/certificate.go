@@ -0,0 +1,88 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/auth"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/rec"
+	"net/http"
+)
+
+func listCertificates(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	a, err := getApp(r.URL.Query().Get(":app"), u)
+	if err != nil {
+		return err
+	}
+	certs, err := a.Certificates()
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return json.NewEncoder(w).Encode(certs)
+}
+
+func setCertificate(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	var params map[string]string
+	if r.Body == nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the cname, the certificate and the key."}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid JSON in request body."}
+	}
+	cname, cert, key := params["cname"], params["certificate"], params["key"]
+	if cname == "" || cert == "" || key == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the cname, the certificate and the key."}
+	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getApp(appName, u)
+	if err != nil {
+		return err
+	}
+	rec.Log(u.Email, "set-certificate", "app="+appName, "cname="+cname)
+	err = a.SetCertificate(cname, cert, key)
+	if e, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
+	switch err {
+	case app.ErrCNameNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case provision.ErrTLSNotSupported, app.ErrNoEncryptionKey:
+		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: err.Error()}
+	}
+	return err
+}
+
+func unsetCertificate(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	cname := r.URL.Query().Get(":cname")
+	a, err := getApp(appName, u)
+	if err != nil {
+		return err
+	}
+	rec.Log(u.Email, "unset-certificate", "app="+appName, "cname="+cname)
+	if err = a.RemoveCertificate(cname); err == app.ErrCertificateNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
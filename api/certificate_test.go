@@ -0,0 +1,270 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/testing"
+	"io/ioutil"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (s *S) certificateBody(c *gocheck.C, cname string) *bytes.Buffer {
+	cert, err := ioutil.ReadFile("testdata/myapp.crt")
+	c.Assert(err, gocheck.IsNil)
+	key, err := ioutil.ReadFile("testdata/myapp.key")
+	c.Assert(err, gocheck.IsNil)
+	params := map[string]string{"cname": cname, "certificate": string(cert), "key": string(key)}
+	var body bytes.Buffer
+	err = json.NewEncoder(&body).Encode(params)
+	c.Assert(err, gocheck.IsNil)
+	return &body
+}
+
+func (s *S) TestSetCertificateHandler(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}, CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Certificates().RemoveId("myapp.com")
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	url := fmt.Sprintf("/apps/%s/certificates?:app=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, s.certificateBody(c, "myapp.com"))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = setCertificate(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	var cert app.Certificate
+	err = s.conn.Certificates().FindId("myapp.com").One(&cert)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(cert.App, gocheck.Equals, a.Name)
+	c.Assert(s.provisioner.HasCertificate(&a, "myapp.com", cert.Certificate), gocheck.Equals, true)
+	action := testing.Action{
+		Action: "set-certificate",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "cname=myapp.com"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestSetCertificateHandlerMissingParameters(c *gocheck.C) {
+	body := strings.NewReader(`{"cname":"myapp.com","certificate":"cert"}`)
+	request, err := http.NewRequest("POST", "/apps/leper/certificates?:app=leper", body)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = setCertificate(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+	c.Assert(e.Message, gocheck.Equals, "You must provide the cname, the certificate and the key.")
+}
+
+func (s *S) TestSetCertificateHandlerInvalidJSON(c *gocheck.C) {
+	body := strings.NewReader(`{"cname":"myapp.com"`)
+	request, err := http.NewRequest("POST", "/apps/leper/certificates?:app=leper", body)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = setCertificate(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestSetCertificateHandlerCertificateDoesNotCoverTheCName(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}, CName: []string{"leper.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	url := fmt.Sprintf("/apps/%s/certificates?:app=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, s.certificateBody(c, "leper.com"))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = setCertificate(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestSetCertificateHandlerUnknownCName(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	url := fmt.Sprintf("/apps/%s/certificates?:app=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, s.certificateBody(c, "myapp.com"))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = setCertificate(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+	c.Assert(e.Message, gocheck.Equals, app.ErrCNameNotFound.Error())
+}
+
+func (s *S) TestSetCertificateHandlerTLSNotSupported(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}, CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.PrepareFailure("SetCertificate", provision.ErrTLSNotSupported)
+	url := fmt.Sprintf("/apps/%s/certificates?:app=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, s.certificateBody(c, "myapp.com"))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = setCertificate(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusPreconditionFailed)
+}
+
+func (s *S) TestSetCertificateHandlerWithoutEncryptionKey(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}, CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	url := fmt.Sprintf("/apps/%s/certificates?:app=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, s.certificateBody(c, "myapp.com"))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = setCertificate(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusPreconditionFailed)
+	c.Assert(e.Message, gocheck.Equals, app.ErrNoEncryptionKey.Error())
+}
+
+func (s *S) TestSetCertificateHandlerUserWithoutAccessToTheApp(c *gocheck.C) {
+	a := app.App{Name: "lost", CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	url := fmt.Sprintf("/apps/%s/certificates?:app=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, s.certificateBody(c, "myapp.com"))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = setCertificate(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestListCertificatesHandler(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}, CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	cert := app.Certificate{CName: "myapp.com", App: a.Name, Certificate: "the-cert", Key: "the-key"}
+	err = s.conn.Certificates().Insert(cert)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Certificates().RemoveId("myapp.com")
+	url := fmt.Sprintf("/apps/%s/certificates?:app=%s", a.Name, a.Name)
+	request, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = listCertificates(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Code, gocheck.Equals, http.StatusOK)
+	c.Assert(strings.Contains(recorder.Body.String(), "the-key"), gocheck.Equals, false)
+	var certs []map[string]interface{}
+	err = json.NewDecoder(recorder.Body).Decode(&certs)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(certs, gocheck.HasLen, 1)
+	c.Assert(certs[0]["CName"], gocheck.Equals, "myapp.com")
+	c.Assert(certs[0]["Certificate"], gocheck.Equals, "the-cert")
+}
+
+func (s *S) TestListCertificatesHandlerNoCertificates(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	url := fmt.Sprintf("/apps/%s/certificates?:app=%s", a.Name, a.Name)
+	request, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = listCertificates(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Code, gocheck.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestUnsetCertificateHandler(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}, CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = s.conn.Certificates().Insert(app.Certificate{CName: "myapp.com", App: a.Name})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Certificates().RemoveId("myapp.com")
+	url := fmt.Sprintf("/apps/%s/certificates/myapp.com?:app=%s&:cname=myapp.com", a.Name, a.Name)
+	request, err := http.NewRequest("DELETE", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = unsetCertificate(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	n, err := s.conn.Certificates().FindId("myapp.com").Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	action := testing.Action{
+		Action: "unset-certificate",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "cname=myapp.com"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestUnsetCertificateHandlerNotFound(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	url := fmt.Sprintf("/apps/%s/certificates/myapp.com?:app=%s&:cname=myapp.com", a.Name, a.Name)
+	request, err := http.NewRequest("DELETE", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = unsetCertificate(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestUnsetCertificateHandlerUnknownApp(c *gocheck.C) {
+	request, err := http.NewRequest("DELETE", "/apps/unknown/certificates/myapp.com?:app=unknown&:cname=myapp.com", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = unsetCertificate(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
@@ -0,0 +1,58 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/auth"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/rec"
+	"net/http"
+)
+
+func addDependency(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	depName := r.URL.Query().Get(":dependency")
+	a, err := getApp(appName, u)
+	if err != nil {
+		return err
+	}
+	dep, err := getApp(depName, u)
+	if err != nil {
+		return err
+	}
+	rec.Log(u.Email, "add-dependency", "app="+appName, "dependency="+depName)
+	err = a.AddDependency(&dep)
+	if e, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
+	if err == app.ErrDependencyExists {
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+func removeDependency(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	depName := r.URL.Query().Get(":dependency")
+	a, err := getApp(appName, u)
+	if err != nil {
+		return err
+	}
+	rec.Log(u.Email, "remove-dependency", "app="+appName, "dependency="+depName)
+	err = a.RemoveDependency(depName)
+	if err == app.ErrDependencyNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/app/bind"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/testing"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (s *S) TestAddDependencyHandler(c *gocheck.C) {
+	a := app.App{Name: "frontend", Teams: []string{s.team.Name}}
+	dep := app.App{Name: "backend", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a, dep)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().RemoveAll(bson.M{"name": bson.M{"$in": []string{a.Name, dep.Name}}})
+	s.provisioner.Provision(&dep)
+	defer s.provisioner.Destroy(&dep)
+	url := fmt.Sprintf("/apps/%s/dependencies/%s?:app=%s&:dependency=%s", a.Name, dep.Name, a.Name, dep.Name)
+	request, err := http.NewRequest("POST", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = addDependency(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Dependencies, gocheck.DeepEquals, []string{"backend"})
+	c.Assert(a.Env["BACKEND_INTERNAL_URL"].Value, gocheck.Equals, "http://10.10.10.1:8888")
+	action := testing.Action{
+		Action: "add-dependency",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "dependency=" + dep.Name},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestAddDependencyHandlerAlreadyDepends(c *gocheck.C) {
+	a := app.App{Name: "frontend", Teams: []string{s.team.Name}, Dependencies: []string{"backend"}}
+	dep := app.App{Name: "backend", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a, dep)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().RemoveAll(bson.M{"name": bson.M{"$in": []string{a.Name, dep.Name}}})
+	url := fmt.Sprintf("/apps/%s/dependencies/%s?:app=%s&:dependency=%s", a.Name, dep.Name, a.Name, dep.Name)
+	request, err := http.NewRequest("POST", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = addDependency(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusConflict)
+}
+
+func (s *S) TestAddDependencyHandlerOnItself(c *gocheck.C) {
+	a := app.App{Name: "frontend", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	url := fmt.Sprintf("/apps/%s/dependencies/%s?:app=%s&:dependency=%s", a.Name, a.Name, a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = addDependency(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestAddDependencyHandlerNoAccessToDependency(c *gocheck.C) {
+	a := app.App{Name: "frontend", Teams: []string{s.team.Name}}
+	dep := app.App{Name: "backend"}
+	err := s.conn.Apps().Insert(a, dep)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().RemoveAll(bson.M{"name": bson.M{"$in": []string{a.Name, dep.Name}}})
+	url := fmt.Sprintf("/apps/%s/dependencies/%s?:app=%s&:dependency=%s", a.Name, dep.Name, a.Name, dep.Name)
+	request, err := http.NewRequest("POST", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = addDependency(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestRemoveDependencyHandler(c *gocheck.C) {
+	a := app.App{
+		Name:         "frontend",
+		Teams:        []string{s.team.Name},
+		Dependencies: []string{"backend"},
+		Env: map[string]bind.EnvVar{
+			"BACKEND_INTERNAL_URL":  {Name: "BACKEND_INTERNAL_URL", Value: "http://10.10.10.1:8888"},
+			"BACKEND_INTERNAL_URLS": {Name: "BACKEND_INTERNAL_URLS", Value: "http://10.10.10.1:8888"},
+		},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	url := fmt.Sprintf("/apps/%s/dependencies/backend?:app=%s&:dependency=backend", a.Name, a.Name)
+	request, err := http.NewRequest("DELETE", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = removeDependency(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Dependencies, gocheck.HasLen, 0)
+	c.Assert(a.Env, gocheck.HasLen, 0)
+	action := testing.Action{
+		Action: "remove-dependency",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "dependency=backend"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestRemoveDependencyHandlerNotFound(c *gocheck.C) {
+	a := app.App{Name: "frontend", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	url := fmt.Sprintf("/apps/%s/dependencies/backend?:app=%s&:dependency=backend", a.Name, a.Name)
+	request, err := http.NewRequest("DELETE", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = removeDependency(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
@@ -8,7 +8,14 @@ import (
 	"encoding/json"
 	"github.com/globocom/tsuru/app"
 	"github.com/globocom/tsuru/auth"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/log"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/rec"
+	"io"
+	"labix.org/v2/mgo"
 	"net/http"
+	"net/url"
 )
 
 func deploysList(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
@@ -18,3 +25,181 @@ func deploysList(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
 	}
 	return json.NewEncoder(w).Encode(deploys)
 }
+
+// appDeploysList lists the deploys of the app, newest first, without their
+// logs.
+func appDeploysList(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	a, err := getApp(r.URL.Query().Get(":app"), u)
+	if err != nil {
+		return err
+	}
+	deploys, err := a.ListDeploys()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(deploys)
+}
+
+// deployInfo returns the deploy identified by the given id, including its
+// log. The user must have access to the deployed app.
+func deployInfo(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	d, err := app.GetDeploy(r.URL.Query().Get(":id"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if _, err = getApp(d.App, u); err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(d)
+}
+
+// appDeploy deploys the app without git. The app is either deployed from the
+// prebuilt docker image named in the "image" parameter, or built from a
+// tar.gz archive, uploaded in the "file" field of a multipart form or
+// available in the URL given in the "url" parameter. Archives are built the
+// same way as a git push.
+func appDeploy(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getApp(appName, u)
+	if err != nil {
+		return err
+	}
+	if imageName := r.FormValue("image"); imageName != "" {
+		if _, ok := app.Provisioner.(provision.ImageDeployer); !ok {
+			return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: provision.ErrImageDeployNotSupported.Error()}
+		}
+		rec.Log(u.Email, "app-deploy", "app="+appName, "image="+imageName)
+		w.Header().Set("Content-Type", "text")
+		return deployError(app.DeployImage(&a, imageName, u.Email, w))
+	}
+	if _, ok := app.Provisioner.(provision.ArchiveDeployer); !ok {
+		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: provision.ErrArchiveDeployNotSupported.Error()}
+	}
+	archiveURL := r.FormValue("url")
+	if archiveURL != "" {
+		parsed, err := url.Parse(archiveURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "The URL of the archive must be an HTTP or HTTPS URL."}
+		}
+		rec.Log(u.Email, "app-deploy", "app="+appName, "archive="+archiveURL)
+	} else {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the archive, or the URL of the archive."}
+		}
+		defer file.Close()
+		rec.Log(u.Email, "app-deploy", "app="+appName, "archive=upload")
+		id, err := app.StoreArchive(file)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := app.RemoveArchive(id); err != nil {
+				log.Errorf("Failed to remove the archive %s of the app %q: %s", id, appName, err)
+			}
+		}()
+		if archiveURL, err = app.ArchiveURL(id); err != nil {
+			return err
+		}
+	}
+	w.Header().Set("Content-Type", "text")
+	return deployError(app.DeployArchive(&a, archiveURL, u.Email, w))
+}
+
+// appPromote deploys the app with the build released in the last successful
+// deploy of the app named in the "from" parameter. The user must be able to
+// deploy both apps.
+func appPromote(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	targetName := r.URL.Query().Get(":app")
+	sourceName := r.FormValue("from")
+	if sourceName == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the app to promote from."}
+	}
+	if sourceName == targetName {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: app.ErrPromoteToSelf.Error()}
+	}
+	target, err := getApp(targetName, u)
+	if err != nil {
+		return err
+	}
+	source, err := getApp(sourceName, u)
+	if err != nil {
+		return err
+	}
+	if _, ok := app.Provisioner.(provision.Promoter); !ok {
+		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: provision.ErrPromoteNotSupported.Error()}
+	}
+	rec.Log(u.Email, "app-promote", "app="+targetName, "from="+sourceName)
+	w.Header().Set("Content-Type", "text")
+	err = app.Promote(&source, &target, u.Email, w)
+	if err == app.ErrNothingToPromote {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return deployError(err)
+}
+
+// deployError maps the errors of deploys that happen before the deploy
+// starts writing its output to HTTP errors.
+func deployError(err error) error {
+	if err == app.ErrDeployInProgress {
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+// appDeployCancel cancels the deploy of the app in progress, interrupting its
+// build and rolling it back.
+func appDeployCancel(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getApp(appName, u)
+	if err != nil {
+		return err
+	}
+	if _, ok := app.Provisioner.(provision.DeployCanceler); !ok {
+		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: provision.ErrDeployCancelNotSupported.Error()}
+	}
+	rec.Log(u.Email, "app-deploy-cancel", "app="+appName)
+	err = app.CancelDeploy(&a, u.Email)
+	if err == app.ErrNoDeployRunning {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// serveArchive serves an archive uploaded for a deploy, so units can
+// download it. It doesn't require authentication, as the id of the archive
+// is hard to guess and the archive is removed after the deploy.
+func serveArchive(w http.ResponseWriter, r *http.Request) error {
+	archive, err := app.OpenArchive(r.URL.Query().Get(":id"))
+	if err == mgo.ErrNotFound {
+		http.Error(w, "Archive not found.", http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	w.Header().Set("Content-Type", "application/x-gzip")
+	_, err = io.Copy(w, archive)
+	return err
+}
@@ -5,15 +5,24 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/globocom/config"
 	"github.com/globocom/tsuru/app"
 	"github.com/globocom/tsuru/auth"
 	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/testing"
 	"io/ioutil"
+	"labix.org/v2/mgo/bson"
 	"launchpad.net/gocheck"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -78,3 +87,517 @@ func (s *DeploySuite) TestDeployList(c *gocheck.C) {
 	c.Assert(result[1].App, gocheck.Equals, "ge")
 	c.Assert(result[1].Timestamp.In(time.UTC), gocheck.DeepEquals, timestamp.In(time.UTC))
 }
+
+func (s *S) TestAppDeploysList(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	timestamp := time.Date(2013, time.November, 1, 0, 0, 0, 0, time.Local)
+	err = s.conn.Deploys().Insert(
+		app.Deploy{ID: bson.NewObjectId(), App: "otherapp", Timestamp: timestamp, Commit: "e82nn93", Log: "built"},
+		app.Deploy{ID: bson.NewObjectId(), App: "anotherapp", Timestamp: timestamp},
+	)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Deploys().RemoveAll(nil)
+	request, err := http.NewRequest("GET", "/apps/otherapp/deploys?:app=otherapp", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appDeploysList(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	var result []app.Deploy
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(result, gocheck.HasLen, 1)
+	c.Assert(result[0].App, gocheck.Equals, "otherapp")
+	c.Assert(result[0].Commit, gocheck.Equals, "e82nn93")
+	c.Assert(result[0].Log, gocheck.Equals, "")
+}
+
+func (s *S) TestAppDeploysListNoAccess(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("GET", "/apps/otherapp/deploys?:app=otherapp", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appDeploysList(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestDeployInfo(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	d := app.Deploy{ID: bson.NewObjectId(), App: "otherapp", Timestamp: time.Now(), Status: app.DeploySucceeded, Log: "built"}
+	err = s.conn.Deploys().Insert(d)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Deploys().RemoveId(d.ID)
+	path := fmt.Sprintf("/deploys/%s?:id=%s", d.ID.Hex(), d.ID.Hex())
+	request, err := http.NewRequest("GET", path, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = deployInfo(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	var result app.Deploy
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(result.ID, gocheck.Equals, d.ID)
+	c.Assert(result.Status, gocheck.Equals, app.DeploySucceeded)
+	c.Assert(result.Log, gocheck.Equals, "built")
+}
+
+func (s *S) TestDeployInfoNotFound(c *gocheck.C) {
+	request, err := http.NewRequest("GET", "/deploys/abc?:id=abc", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = deployInfo(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestDeployInfoNoAccess(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	d := app.Deploy{ID: bson.NewObjectId(), App: "otherapp", Timestamp: time.Now()}
+	err = s.conn.Deploys().Insert(d)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Deploys().RemoveId(d.ID)
+	path := fmt.Sprintf("/deploys/%s?:id=%s", d.ID.Hex(), d.ID.Hex())
+	request, err := http.NewRequest("GET", path, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = deployInfo(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestDeployArchiveFromURL(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	archiveURL := "https://s3.amazonaws.com/wat/otherapp.tar.gz"
+	body := strings.NewReader("url=" + url.QueryEscape(archiveURL))
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy?:app=otherapp", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = appDeploy(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Body.String(), gocheck.Equals, "Archive deploy called")
+	c.Assert(recorder.Header().Get("Content-Type"), gocheck.Equals, "text")
+	c.Assert(s.provisioner.ArchiveURL(&a), gocheck.Equals, archiveURL)
+	action := testing.Action{
+		Action: "app-deploy",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "archive=" + archiveURL},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestDeployArchiveUpload(c *gocheck.C) {
+	config.Set("host", "http://tsuru.io:8080")
+	defer config.Unset("host")
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	file, err := writer.CreateFormFile("file", "archive.tar.gz")
+	c.Assert(err, gocheck.IsNil)
+	file.Write([]byte("compressed bytes"))
+	writer.Close()
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy?:app=otherapp", &body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	err = appDeploy(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Body.String(), gocheck.Equals, "Archive deploy called")
+	archiveURL := s.provisioner.ArchiveURL(&a)
+	c.Assert(archiveURL, gocheck.Matches, `http://tsuru.io:8080/archives/[0-9a-f]{32}`)
+	id := archiveURL[strings.LastIndex(archiveURL, "/")+1:]
+	_, err = app.OpenArchive(id)
+	c.Assert(err, gocheck.NotNil)
+	action := testing.Action{
+		Action: "app-deploy",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "archive=upload"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestDeployArchiveWithoutArchive(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy?:app=otherapp", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appDeploy(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestDeployArchiveInvalidURL(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	body := strings.NewReader("url=" + url.QueryEscape("file:///etc/passwd"))
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy?:app=otherapp", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = appDeploy(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestDeployArchiveNoAccess(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy?:app=otherapp", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appDeploy(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestDeployArchiveNotSupported(c *gocheck.C) {
+	app.Provisioner = struct{ provision.Provisioner }{s.provisioner}
+	defer func() {
+		app.Provisioner = s.provisioner
+	}()
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy?:app=otherapp", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appDeploy(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusPreconditionFailed)
+}
+
+func (s *S) TestDeployImage(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	body := strings.NewReader("image=" + url.QueryEscape("registry.tsuru.io/otherapp:1.0"))
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy?:app=otherapp", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = appDeploy(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Body.String(), gocheck.Equals, "Image deploy called")
+	c.Assert(s.provisioner.DeployedImage(&a), gocheck.Equals, "registry.tsuru.io/otherapp:1.0")
+	action := testing.Action{
+		Action: "app-deploy",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "image=registry.tsuru.io/otherapp:1.0"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestDeployImageNotSupported(c *gocheck.C) {
+	app.Provisioner = struct{ provision.Provisioner }{s.provisioner}
+	defer func() {
+		app.Provisioner = s.provisioner
+	}()
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	body := strings.NewReader("image=otherapp")
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy?:app=otherapp", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = appDeploy(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusPreconditionFailed)
+	c.Assert(e.Message, gocheck.Equals, provision.ErrImageDeployNotSupported.Error())
+}
+
+func (s *S) TestServeArchive(c *gocheck.C) {
+	id, err := app.StoreArchive(strings.NewReader("compressed bytes"))
+	c.Assert(err, gocheck.IsNil)
+	defer app.RemoveArchive(id)
+	request, err := http.NewRequest("GET", "/archives/"+id+"?:id="+id, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = serveArchive(recorder, request)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Code, gocheck.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), gocheck.Equals, "application/x-gzip")
+	c.Assert(recorder.Body.String(), gocheck.Equals, "compressed bytes")
+}
+
+func (s *S) TestServeArchiveNotFound(c *gocheck.C) {
+	request, err := http.NewRequest("GET", "/archives/unknown?:id=unknown", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = serveArchive(recorder, request)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Code, gocheck.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestDeployImageWithDeployInProgress(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	running := app.Deploy{ID: bson.NewObjectId(), App: a.Name, Timestamp: time.Now(), Status: app.DeployRunning, Lock: a.Name}
+	err = s.conn.Deploys().Insert(running)
+	c.Assert(err, gocheck.IsNil)
+	body := strings.NewReader("image=" + url.QueryEscape("registry.tsuru.io/otherapp:1.0"))
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy?:app=otherapp", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = appDeploy(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusConflict)
+	c.Assert(e.Message, gocheck.Equals, app.ErrDeployInProgress.Error())
+	c.Assert(s.provisioner.DeployedImage(&a), gocheck.Equals, "")
+}
+
+func (s *S) TestAppDeployCancel(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	running := app.Deploy{ID: bson.NewObjectId(), App: a.Name, Timestamp: time.Now(), Status: app.DeployRunning, Lock: a.Name}
+	err = s.conn.Deploys().Insert(running)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Deploys().RemoveId(running.ID)
+	request, err := http.NewRequest("DELETE", "/apps/otherapp/deploys/current?:app=otherapp", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appDeployCancel(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(s.provisioner.Cancels(&a), gocheck.Equals, 1)
+	var d app.Deploy
+	err = s.conn.Deploys().FindId(running.ID).One(&d)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(d.CanceledBy, gocheck.Equals, s.user.Email)
+	action := testing.Action{
+		Action: "app-deploy-cancel",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestAppDeployCancelWithoutDeployInProgress(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("DELETE", "/apps/otherapp/deploys/current?:app=otherapp", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appDeployCancel(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAppDeployCancelNoAccess(c *gocheck.C) {
+	a := app.App{Name: "otherapp", Platform: "python"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("DELETE", "/apps/otherapp/deploys/current?:app=otherapp", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appDeployCancel(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppDeployCancelNotSupported(c *gocheck.C) {
+	app.Provisioner = struct{ provision.Provisioner }{s.provisioner}
+	defer func() {
+		app.Provisioner = s.provisioner
+	}()
+	a := app.App{Name: "otherapp", Platform: "python", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("DELETE", "/apps/otherapp/deploys/current?:app=otherapp", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appDeployCancel(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusPreconditionFailed)
+}
+
+func (s *S) TestAppPromote(c *gocheck.C) {
+	p := testing.BuilderProvisioner{FakeProvisioner: s.provisioner}
+	app.Provisioner = &p
+	defer func() {
+		app.Provisioner = s.provisioner
+	}()
+	for _, name := range []string{"staging", "production"} {
+		a := app.App{Name: name, Platform: "python", Teams: []string{s.team.Name}}
+		err := s.conn.Apps().Insert(a)
+		c.Assert(err, gocheck.IsNil)
+		defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+		defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
+		defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	}
+	err := s.conn.Deploys().Insert(app.Deploy{App: "staging", Commit: "f1a2b3", Status: app.DeploySucceeded, Timestamp: time.Now(), Build: "staging-1"})
+	c.Assert(err, gocheck.IsNil)
+	body := strings.NewReader("from=staging")
+	request, err := http.NewRequest("POST", "/apps/production/promote?:app=production", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = appPromote(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Body.String(), gocheck.Matches, `(?s).*Promote called.*Release called`)
+	c.Assert(p.Steps(), gocheck.DeepEquals, []string{"promote staging-1", "release"})
+	var d app.Deploy
+	err = s.conn.Deploys().Find(bson.M{"app": "production"}).One(&d)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(d.PromotedFrom, gocheck.Equals, "staging")
+	c.Assert(d.Commit, gocheck.Equals, "f1a2b3")
+	action := testing.Action{
+		Action: "app-promote",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=production", "from=staging"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestAppPromoteWithoutSuccessfulDeploy(c *gocheck.C) {
+	p := testing.BuilderProvisioner{FakeProvisioner: s.provisioner}
+	app.Provisioner = &p
+	defer func() {
+		app.Provisioner = s.provisioner
+	}()
+	for _, name := range []string{"staging", "production"} {
+		a := app.App{Name: name, Platform: "python", Teams: []string{s.team.Name}}
+		err := s.conn.Apps().Insert(a)
+		c.Assert(err, gocheck.IsNil)
+		defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	}
+	body := strings.NewReader("from=staging")
+	request, err := http.NewRequest("POST", "/apps/production/promote?:app=production", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = appPromote(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+	c.Assert(e.Message, gocheck.Equals, app.ErrNothingToPromote.Error())
+}
+
+func (s *S) TestAppPromoteWithoutSource(c *gocheck.C) {
+	request, err := http.NewRequest("POST", "/apps/production/promote?:app=production", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appPromote(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestAppPromoteNoAccessToSource(c *gocheck.C) {
+	source := app.App{Name: "staging", Platform: "python"}
+	target := app.App{Name: "production", Platform: "python", Teams: []string{s.team.Name}}
+	for _, a := range []app.App{source, target} {
+		err := s.conn.Apps().Insert(a)
+		c.Assert(err, gocheck.IsNil)
+		defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	}
+	body := strings.NewReader("from=staging")
+	request, err := http.NewRequest("POST", "/apps/production/promote?:app=production", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = appPromote(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppPromoteNotSupported(c *gocheck.C) {
+	for _, name := range []string{"staging", "production"} {
+		a := app.App{Name: name, Platform: "python", Teams: []string{s.team.Name}}
+		err := s.conn.Apps().Insert(a)
+		c.Assert(err, gocheck.IsNil)
+		defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	}
+	body := strings.NewReader("from=staging")
+	request, err := http.NewRequest("POST", "/apps/production/promote?:app=production", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = appPromote(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusPreconditionFailed)
+	c.Assert(e.Message, gocheck.Equals, provision.ErrPromoteNotSupported.Error())
+}
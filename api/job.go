@@ -0,0 +1,110 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/auth"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/rec"
+	"net/http"
+)
+
+// addJob schedules a command to run periodically in the app. The body is a
+// JSON object with the cron expression in "schedule", and the command in
+// "command".
+func addJob(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	var params map[string]string
+	if r.Body == nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the schedule and the command of the job."}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid JSON in request body."}
+	}
+	schedule, command := params["schedule"], params["command"]
+	if schedule == "" || command == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the schedule and the command of the job."}
+	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getApp(appName, u)
+	if err != nil {
+		return err
+	}
+	rec.Log(u.Email, "add-job", "app="+appName, "schedule="+schedule, "command="+command)
+	job, err := app.AddJob(&a, schedule, command, u.Email)
+	if e, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(job)
+}
+
+func listJobs(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	a, err := getApp(r.URL.Query().Get(":app"), u)
+	if err != nil {
+		return err
+	}
+	jobs, err := a.ListJobs()
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return json.NewEncoder(w).Encode(jobs)
+}
+
+func removeJob(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	id := r.URL.Query().Get(":id")
+	a, err := getApp(appName, u)
+	if err != nil {
+		return err
+	}
+	rec.Log(u.Email, "remove-job", "app="+appName, "id="+id)
+	if err = a.RemoveJob(id); err == app.ErrJobNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// listJobRuns lists the runs of the job kept in its history, newest first,
+// including their output.
+func listJobRuns(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	a, err := getApp(r.URL.Query().Get(":app"), u)
+	if err != nil {
+		return err
+	}
+	job, err := a.GetJob(r.URL.Query().Get(":id"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	runs, err := job.Runs()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(runs)
+}
@@ -0,0 +1,208 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/testing"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+func (s *S) TestAddJobHandler(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Jobs().RemoveAll(bson.M{"app": a.Name})
+	body := strings.NewReader(`{"schedule":"0 3 * * *","command":"python manage.py report"}`)
+	request, err := http.NewRequest("POST", "/apps/leper/jobs?:app=leper", body)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = addJob(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Code, gocheck.Equals, http.StatusCreated)
+	var job app.Job
+	err = json.NewDecoder(recorder.Body).Decode(&job)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(job.Schedule, gocheck.Equals, "0 3 * * *")
+	c.Assert(job.User, gocheck.Equals, s.user.Email)
+	n, err := s.conn.Jobs().Find(bson.M{"app": a.Name}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 1)
+	action := testing.Action{
+		Action: "add-job",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "schedule=0 3 * * *", "command=python manage.py report"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestAddJobHandlerInvalidSchedule(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	body := strings.NewReader(`{"schedule":"every day","command":"python manage.py report"}`)
+	request, err := http.NewRequest("POST", "/apps/leper/jobs?:app=leper", body)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = addJob(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+	c.Assert(e.Message, gocheck.Matches, `Invalid schedule "every day".*`)
+}
+
+func (s *S) TestAddJobHandlerMissingParams(c *gocheck.C) {
+	body := strings.NewReader(`{"schedule":"@daily"}`)
+	request, err := http.NewRequest("POST", "/apps/leper/jobs?:app=leper", body)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = addJob(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestAddJobHandlerNoAccess(c *gocheck.C) {
+	a := app.App{Name: "leper"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	body := strings.NewReader(`{"schedule":"@daily","command":"ls"}`)
+	request, err := http.NewRequest("POST", "/apps/leper/jobs?:app=leper", body)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = addJob(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestListJobsHandler(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	job, err := app.AddJob(&a, "@daily", "ls", s.user.Email)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Jobs().RemoveId(job.ID)
+	request, err := http.NewRequest("GET", "/apps/leper/jobs?:app=leper", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = listJobs(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	var jobs []app.Job
+	err = json.NewDecoder(recorder.Body).Decode(&jobs)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(jobs, gocheck.HasLen, 1)
+	c.Assert(jobs[0].ID, gocheck.Equals, job.ID)
+}
+
+func (s *S) TestListJobsHandlerWithoutJobs(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("GET", "/apps/leper/jobs?:app=leper", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = listJobs(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Code, gocheck.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestRemoveJobHandler(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	job, err := app.AddJob(&a, "@daily", "ls", s.user.Email)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Jobs().RemoveId(job.ID)
+	url := fmt.Sprintf("/apps/leper/jobs/%s?:app=leper&:id=%s", job.ID.Hex(), job.ID.Hex())
+	request, err := http.NewRequest("DELETE", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = removeJob(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	n, err := s.conn.Jobs().FindId(job.ID).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	action := testing.Action{
+		Action: "remove-job",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "id=" + job.ID.Hex()},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestRemoveJobHandlerNotFound(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	id := bson.NewObjectId().Hex()
+	url := fmt.Sprintf("/apps/leper/jobs/%s?:app=leper&:id=%s", id, id)
+	request, err := http.NewRequest("DELETE", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = removeJob(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestListJobRunsHandler(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	job, err := app.AddJob(&a, "@daily", "ls", s.user.Email)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Jobs().RemoveId(job.ID)
+	run := app.JobRun{Job: job.ID, App: a.Name, Command: "ls", Timestamp: time.Now(), Status: app.JobSucceeded, Output: "Procfile"}
+	err = s.conn.JobRuns().Insert(run)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.JobRuns().RemoveAll(bson.M{"job": job.ID})
+	url := fmt.Sprintf("/apps/leper/jobs/%s/runs?:app=leper&:id=%s", job.ID.Hex(), job.ID.Hex())
+	request, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = listJobRuns(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	var runs []app.JobRun
+	err = json.NewDecoder(recorder.Body).Decode(&runs)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(runs, gocheck.HasLen, 1)
+	c.Assert(runs[0].Output, gocheck.Equals, "Procfile")
+}
+
+func (s *S) TestListJobRunsHandlerNotFound(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("GET", "/apps/leper/jobs/abc/runs?:app=leper&:id=abc", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = listJobRuns(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
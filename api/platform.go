@@ -0,0 +1,75 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/auth"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/rec"
+	"net/http"
+)
+
+// platformError maps the errors returned by the platform functions in the
+// app package to HTTP errors.
+func platformError(err error) error {
+	switch err {
+	case app.ErrPlatformNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case app.ErrDuplicatePlatform, app.ErrPlatformInUse:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case provision.ErrPlatformManagementNotSupported:
+		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: err.Error()}
+	}
+	if e, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
+	return err
+}
+
+func platformFromRequest(r *http.Request, name string) app.Platform {
+	return app.Platform{
+		Name:       name,
+		Dockerfile: r.FormValue("dockerfile"),
+		Image:      r.FormValue("image"),
+	}
+}
+
+// platformAdd registers a new platform, with its Dockerfile or base image.
+// The output of the build of the image is streamed in the response.
+func platformAdd(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	p := platformFromRequest(r, r.FormValue("name"))
+	rec.Log(u.Email, "platform-add", "name="+p.Name, "dockerfile="+p.Dockerfile, "image="+p.Image)
+	w.Header().Set("Content-Type", "text")
+	return platformError(app.PlatformAdd(p, w))
+}
+
+// platformUpdate changes the Dockerfile or the base image of a platform,
+// rebuilding its image and the apps that use it.
+func platformUpdate(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	p := platformFromRequest(r, r.URL.Query().Get(":name"))
+	rec.Log(u.Email, "platform-update", "name="+p.Name, "dockerfile="+p.Dockerfile, "image="+p.Image)
+	w.Header().Set("Content-Type", "text")
+	return platformError(app.PlatformUpdate(p, u.Email, w))
+}
+
+func platformRemove(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	name := r.URL.Query().Get(":name")
+	rec.Log(u.Email, "platform-remove", "name="+name)
+	return platformError(app.PlatformRemove(name))
+}
@@ -0,0 +1,152 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/testing"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (s *S) TestPlatformAdd(c *gocheck.C) {
+	body := strings.NewReader("name=ruby&image=tsuru/ruby-base")
+	request, err := http.NewRequest("POST", "/platforms", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = platformAdd(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Platforms().RemoveId("ruby")
+	c.Assert(recorder.Body.String(), gocheck.Equals, "Platform add called")
+	opts, ok := s.provisioner.GetPlatform("ruby")
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(opts.Image, gocheck.Equals, "tsuru/ruby-base")
+	var p app.Platform
+	err = s.conn.Platforms().FindId("ruby").One(&p)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(p.Image, gocheck.Equals, "tsuru/ruby-base")
+	action := testing.Action{
+		Action: "platform-add",
+		User:   s.user.Email,
+		Extra:  []interface{}{"name=ruby", "dockerfile=", "image=tsuru/ruby-base"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestPlatformAddInvalid(c *gocheck.C) {
+	body := strings.NewReader("name=ruby")
+	request, err := http.NewRequest("POST", "/platforms", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = platformAdd(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestPlatformAddDuplicate(c *gocheck.C) {
+	body := strings.NewReader("name=zend&image=tsuru/zend")
+	request, err := http.NewRequest("POST", "/platforms", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = platformAdd(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusConflict)
+}
+
+func (s *S) TestPlatformAddNotSupported(c *gocheck.C) {
+	app.Provisioner = struct{ provision.Provisioner }{s.provisioner}
+	defer func() {
+		app.Provisioner = s.provisioner
+	}()
+	body := strings.NewReader("name=ruby&image=tsuru/ruby-base")
+	request, err := http.NewRequest("POST", "/platforms", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = platformAdd(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusPreconditionFailed)
+}
+
+func (s *S) TestPlatformUpdate(c *gocheck.C) {
+	err := app.PlatformAdd(app.Platform{Name: "ruby", Image: "tsuru/ruby-base"}, &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Platforms().RemoveId("ruby")
+	body := strings.NewReader("dockerfile=http://example.com/ruby/Dockerfile")
+	request, err := http.NewRequest("PUT", "/platforms/ruby?:name=ruby", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = platformUpdate(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Body.String(), gocheck.Equals, "Platform update called")
+	opts, _ := s.provisioner.GetPlatform("ruby")
+	c.Assert(opts.Dockerfile, gocheck.Equals, "http://example.com/ruby/Dockerfile")
+	c.Assert(opts.Image, gocheck.Equals, "")
+	action := testing.Action{
+		Action: "platform-update",
+		User:   s.user.Email,
+		Extra:  []interface{}{"name=ruby", "dockerfile=http://example.com/ruby/Dockerfile", "image="},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestPlatformUpdateNotFound(c *gocheck.C) {
+	body := strings.NewReader("image=tsuru/ruby-base")
+	request, err := http.NewRequest("PUT", "/platforms/ruby?:name=ruby", body)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	err = platformUpdate(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestPlatformRemove(c *gocheck.C) {
+	err := app.PlatformAdd(app.Platform{Name: "ruby", Image: "tsuru/ruby-base"}, &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+	request, err := http.NewRequest("DELETE", "/platforms/ruby?:name=ruby", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = platformRemove(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	n, err := s.conn.Platforms().FindId("ruby").Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	action := testing.Action{Action: "platform-remove", User: s.user.Email, Extra: []interface{}{"name=ruby"}}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestPlatformRemoveInUse(c *gocheck.C) {
+	a := app.App{Name: "someapp", Platform: "zend"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	request, err := http.NewRequest("DELETE", "/platforms/zend?:name=zend", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = platformRemove(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusConflict)
+}
@@ -0,0 +1,51 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/globocom/tsuru/auth"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/queue"
+	"github.com/globocom/tsuru/rec"
+	"net/http"
+)
+
+func deadLettersList(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	letters, err := queue.ListDeadLetters()
+	if err != nil {
+		return err
+	}
+	if len(letters) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return json.NewEncoder(w).Encode(letters)
+}
+
+func deadLetterInfo(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	letter, err := queue.GetDeadLetter(r.URL.Query().Get(":id"))
+	if err == queue.ErrDeadLetterNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(letter)
+}
+
+func deadLetterRequeue(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	id := r.URL.Query().Get(":id")
+	rec.Log(u.Email, "dead-letter-requeue", id)
+	err = queue.RequeueDeadLetter(id)
+	if err == queue.ErrDeadLetterNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
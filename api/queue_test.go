@@ -0,0 +1,135 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/queue"
+	"github.com/globocom/tsuru/testing"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func (s *S) TestDeadLettersList(c *gocheck.C) {
+	letter := queue.DeadLetter{
+		Id:       bson.NewObjectId(),
+		Queue:    "tsuru-app",
+		Action:   "start-app",
+		Args:     []string{"myapp"},
+		Attempts: 10,
+		Died:     time.Now(),
+	}
+	err := s.conn.DeadLetters().Insert(letter)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.DeadLetters().RemoveId(letter.Id)
+	request, err := http.NewRequest("GET", "/queue/dead-letters", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = deadLettersList(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	var letters []queue.DeadLetter
+	err = json.NewDecoder(recorder.Body).Decode(&letters)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(letters, gocheck.HasLen, 1)
+	c.Assert(letters[0].Id, gocheck.Equals, letter.Id)
+	c.Assert(letters[0].Action, gocheck.Equals, "start-app")
+	c.Assert(letters[0].Attempts, gocheck.Equals, uint(10))
+}
+
+func (s *S) TestDeadLettersListEmpty(c *gocheck.C) {
+	request, err := http.NewRequest("GET", "/queue/dead-letters", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = deadLettersList(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Code, gocheck.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestDeadLetterInfo(c *gocheck.C) {
+	letter := queue.DeadLetter{
+		Id:     bson.NewObjectId(),
+		Queue:  "tsuru-app",
+		Action: "start-app",
+		Args:   []string{"myapp"},
+	}
+	err := s.conn.DeadLetters().Insert(letter)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.DeadLetters().RemoveId(letter.Id)
+	id := letter.Id.Hex()
+	request, err := http.NewRequest("GET", "/queue/dead-letters/"+id+"?:id="+id, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = deadLetterInfo(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	var got queue.DeadLetter
+	err = json.NewDecoder(recorder.Body).Decode(&got)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got.Queue, gocheck.Equals, "tsuru-app")
+	c.Assert(got.Action, gocheck.Equals, "start-app")
+	c.Assert(got.Args, gocheck.DeepEquals, []string{"myapp"})
+}
+
+func (s *S) TestDeadLetterInfoNotFound(c *gocheck.C) {
+	id := bson.NewObjectId().Hex()
+	request, err := http.NewRequest("GET", "/queue/dead-letters/"+id+"?:id="+id, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = deadLetterInfo(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestDeadLetterRequeue(c *gocheck.C) {
+	letter := queue.DeadLetter{
+		Id:     bson.NewObjectId(),
+		Queue:  "dead-letter-requeue-test",
+		Action: "start-app",
+		Args:   []string{"myapp"},
+	}
+	err := s.conn.DeadLetters().Insert(letter)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.DeadLetters().RemoveId(letter.Id)
+	id := letter.Id.Hex()
+	request, err := http.NewRequest("POST", "/queue/dead-letters/"+id+"/requeue?:id="+id, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = deadLetterRequeue(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	n, err := s.conn.DeadLetters().FindId(letter.Id).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	factory, err := queue.Factory()
+	c.Assert(err, gocheck.IsNil)
+	q, err := factory.Get("dead-letter-requeue-test")
+	c.Assert(err, gocheck.IsNil)
+	msg, err := q.Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(msg.Action, gocheck.Equals, "start-app")
+	c.Assert(msg.Args, gocheck.DeepEquals, []string{"myapp"})
+	action := testing.Action{
+		Action: "dead-letter-requeue",
+		User:   s.user.Email,
+		Extra:  []interface{}{id},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestDeadLetterRequeueNotFound(c *gocheck.C) {
+	id := bson.NewObjectId().Hex()
+	request, err := http.NewRequest("POST", "/queue/dead-letters/"+id+"/requeue?:id="+id, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = deadLetterRequeue(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
@@ -0,0 +1,47 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/auth"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/rec"
+	"net/http"
+)
+
+func checkRoutes(w http.ResponseWriter, fix bool) error {
+	checker, ok := app.Provisioner.(provision.RouteChecker)
+	if !ok {
+		return &errors.HTTP{
+			Code:    http.StatusNotImplemented,
+			Message: "The provisioner in use is not able to check the router.",
+		}
+	}
+	problems, err := checker.CheckRoutes(fix)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return json.NewEncoder(w).Encode(problems)
+}
+
+func routerCheck(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	return checkRoutes(w, false)
+}
+
+func routerFix(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	rec.Log(u.Email, "router-fix")
+	return checkRoutes(w, true)
+}
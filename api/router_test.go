@@ -0,0 +1,101 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/testing"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+)
+
+type routeCheckerProvisioner struct {
+	*testing.FakeProvisioner
+	problems []provision.RouteProblem
+}
+
+func (p *routeCheckerProvisioner) CheckRoutes(fix bool) ([]provision.RouteProblem, error) {
+	problems := make([]provision.RouteProblem, len(p.problems))
+	for i, problem := range p.problems {
+		problem.Fixed = fix
+		problems[i] = problem
+	}
+	return problems, nil
+}
+
+func (s *S) TestRouterCheck(c *gocheck.C) {
+	p := routeCheckerProvisioner{
+		FakeProvisioner: s.provisioner,
+		problems: []provision.RouteProblem{
+			{App: "myapp", Kind: "missing", Entry: "route http://10.0.0.1:49153"},
+		},
+	}
+	app.Provisioner = &p
+	defer func() {
+		app.Provisioner = s.provisioner
+	}()
+	request, err := http.NewRequest("GET", "/router/check", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = routerCheck(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	var problems []provision.RouteProblem
+	err = json.NewDecoder(recorder.Body).Decode(&problems)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(problems, gocheck.DeepEquals, p.problems)
+}
+
+func (s *S) TestRouterCheckNoProblems(c *gocheck.C) {
+	app.Provisioner = &routeCheckerProvisioner{FakeProvisioner: s.provisioner}
+	defer func() {
+		app.Provisioner = s.provisioner
+	}()
+	request, err := http.NewRequest("GET", "/router/check", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = routerCheck(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(recorder.Code, gocheck.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestRouterCheckProvisionerIsNotARouteChecker(c *gocheck.C) {
+	request, err := http.NewRequest("GET", "/router/check", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = routerCheck(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotImplemented)
+}
+
+func (s *S) TestRouterFix(c *gocheck.C) {
+	p := routeCheckerProvisioner{
+		FakeProvisioner: s.provisioner,
+		problems: []provision.RouteProblem{
+			{App: "myapp", Kind: "stale", Entry: "cname old.myapp.com"},
+		},
+	}
+	app.Provisioner = &p
+	defer func() {
+		app.Provisioner = s.provisioner
+	}()
+	request, err := http.NewRequest("POST", "/router/check", nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = routerFix(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	var problems []provision.RouteProblem
+	err = json.NewDecoder(recorder.Body).Decode(&problems)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(problems, gocheck.HasLen, 1)
+	c.Assert(problems[0].Fixed, gocheck.Equals, true)
+	action := testing.Action{Action: "router-fix", User: s.user.Email}
+	c.Assert(action, testing.IsRecorded)
+}
@@ -60,7 +60,10 @@ func appSchema(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
 				"type": "string",
 			},
 			"cname": {
-				"type": "string",
+				"type": "array",
+				"items": property{
+					"type": "string",
+				},
 			},
 		},
 	}
@@ -51,7 +51,10 @@ func (s *SchemaSuite) TestAppSchema(c *gocheck.C) {
 				"type": "string",
 			},
 			"cname": {
-				"type": "string",
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "string",
+				},
 			},
 		},
 	}
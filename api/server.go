@@ -14,6 +14,7 @@ import (
 	"github.com/globocom/tsuru/provision"
 	"net"
 	"net/http"
+	"time"
 )
 
 func fatal(err error) {
@@ -46,6 +47,7 @@ func RunServer(dry bool) {
 
 	m.Get("/services/instances", authorizationRequiredHandler(serviceInstances))
 	m.Get("/services/instances/:name", authorizationRequiredHandler(serviceInstance))
+	m.Put("/services/instances/:name", authorizationRequiredHandler(updateServiceInstance))
 	m.Del("/services/instances/:name", authorizationRequiredHandler(removeServiceInstance))
 	m.Post("/services/instances", authorizationRequiredHandler(createServiceInstance))
 	m.Put("/services/instances/:instance/:app", authorizationRequiredHandler(bindServiceInstance))
@@ -62,11 +64,28 @@ func RunServer(dry bool) {
 	m.Put("/services/:service/:team", authorizationRequiredHandler(grantServiceAccess))
 	m.Del("/services/:service/:team", authorizationRequiredHandler(revokeServiceAccess))
 
+	m.Post("/apps/manifest", authorizationRequiredHandler(createAppFromManifest))
 	m.Del("/apps/:app", authorizationRequiredHandler(appDelete))
 	m.Get("/apps/:app", authorizationRequiredHandler(appInfo))
-	m.Post("/apps/:app/cname", authorizationRequiredHandler(setCName))
-	m.Del("/apps/:app/cname", authorizationRequiredHandler(unsetCName))
+	m.Post("/apps/:app/cname", authorizationRequiredHandler(addCName))
+	m.Del("/apps/:app/cname", authorizationRequiredHandler(removeCName))
+	m.Get("/apps/:app/certificates", authorizationRequiredHandler(listCertificates))
+	m.Post("/apps/:app/certificates", authorizationRequiredHandler(setCertificate))
+	m.Del("/apps/:app/certificates/:cname", authorizationRequiredHandler(unsetCertificate))
+	m.Post("/apps/:app/traffic", authorizationRequiredHandler(splitTraffic))
+	m.Post("/apps/:app/dependencies/:dependency", authorizationRequiredHandler(addDependency))
+	m.Del("/apps/:app/dependencies/:dependency", authorizationRequiredHandler(removeDependency))
+	m.Get("/apps/:app/jobs", authorizationRequiredHandler(listJobs))
+	m.Post("/apps/:app/jobs", authorizationRequiredHandler(addJob))
+	m.Del("/apps/:app/jobs/:id", authorizationRequiredHandler(removeJob))
+	m.Get("/apps/:app/jobs/:id/runs", authorizationRequiredHandler(listJobRuns))
 	m.Post("/apps/:app/run", authorizationRequiredHandler(runCommand))
+	m.Get("/apps/:app/shell", authorizationRequiredHandler(appShell))
+	m.Post("/apps/:app/deploy", authorizationRequiredHandler(appDeploy))
+	m.Post("/apps/:app/promote", authorizationRequiredHandler(appPromote))
+	m.Get("/apps/:app/manifest", authorizationRequiredHandler(exportApp))
+	m.Get("/apps/:app/deploys", authorizationRequiredHandler(appDeploysList))
+	m.Del("/apps/:app/deploys/current", authorizationRequiredHandler(appDeployCancel))
 	m.Get("/apps/:app/restart", authorizationRequiredHandler(restart))
 	m.Get("/apps/:app/env", authorizationRequiredHandler(getEnv))
 	m.Post("/apps/:app/env", authorizationRequiredHandler(setEnv))
@@ -81,8 +100,13 @@ func RunServer(dry bool) {
 	m.Post("/apps/:app/log", authorizationRequiredHandler(addLog))
 
 	m.Get("/deploys", adminRequiredHandler(deploysList))
+	m.Get("/deploys/:id", authorizationRequiredHandler(deployInfo))
+	m.Get("/archives/:id", handler(serveArchive))
 
 	m.Get("/platforms", authorizationRequiredHandler(platformList))
+	m.Post("/platforms", adminRequiredHandler(platformAdd))
+	m.Put("/platforms/:name", adminRequiredHandler(platformUpdate))
+	m.Del("/platforms/:name", adminRequiredHandler(platformRemove))
 
 	// These handlers don't use :app on purpose. Using :app means that only
 	// the token generate for the given app is valid, but these handlers
@@ -107,6 +131,15 @@ func RunServer(dry bool) {
 
 	m.Del("/logs", adminRequiredHandler(logRemove))
 
+	m.Get("/queue/dead-letters", adminRequiredHandler(deadLettersList))
+	m.Get("/queue/dead-letters/:id", adminRequiredHandler(deadLetterInfo))
+	m.Post("/queue/dead-letters/:id/requeue", adminRequiredHandler(deadLetterRequeue))
+
+	m.Post("/env/reencrypt", adminRequiredHandler(reencryptEnvs))
+
+	m.Get("/router/check", adminRequiredHandler(routerCheck))
+	m.Post("/router/check", adminRequiredHandler(routerFix))
+
 	m.Get("/teams", authorizationRequiredHandler(teamList))
 	m.Post("/teams", authorizationRequiredHandler(createTeam))
 	m.Get("/teams/:name", authorizationRequiredHandler(getTeam))
@@ -132,6 +165,8 @@ func RunServer(dry bool) {
 		}
 		fmt.Printf("Using %q provisioner.\n\n", provisioner)
 
+		go app.RunJobScheduler(time.Tick(10 * time.Second))
+
 		listen, err := config.GetString("listen")
 		if err != nil {
 			fatal(err)
@@ -7,6 +7,8 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/app/bind"
 	"github.com/globocom/tsuru/auth"
 	"github.com/globocom/tsuru/db"
 	"github.com/globocom/tsuru/errors"
@@ -59,6 +61,59 @@ func removeServiceInstance(w http.ResponseWriter, r *http.Request, t *auth.Token
 	return nil
 }
 
+func updateServiceInstance(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	msg := "You must provide the new plan, description or tags in a JSON object"
+	if r.Body == nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	defer r.Body.Close()
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Plan        *string
+		Description *string
+		Tags        *[]string
+	}
+	if err = json.Unmarshal(b, &body); err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	if body.Plan == nil && body.Description == nil && body.Tags == nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	updateData := service.ServiceInstanceUpdate{
+		PlanName:    body.Plan,
+		Description: body.Description,
+		Tags:        body.Tags,
+	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	name := r.URL.Query().Get(":name")
+	rec.Log(u.Email, "update-service-instance", "name="+name, string(b))
+	si, err := getServiceInstanceOrError(name, u)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var apps []app.App
+	err = conn.Apps().Find(bson.M{"name": bson.M{"$in": si.Apps}}).All(&apps)
+	if err != nil {
+		return err
+	}
+	boundApps := make([]bind.App, len(apps))
+	for i := range apps {
+		boundApps[i] = &apps[i]
+	}
+	return si.Update(updateData, boundApps)
+}
+
 func serviceInstances(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
 	u, err := t.User()
 	if err != nil {
@@ -9,6 +9,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/globocom/config"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/app/bind"
 	"github.com/globocom/tsuru/auth"
 	"github.com/globocom/tsuru/db"
 	"github.com/globocom/tsuru/errors"
@@ -19,6 +21,8 @@ import (
 	"launchpad.net/gocheck"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 )
 
 type ConsumptionSuite struct {
@@ -38,6 +42,7 @@ func (s *ConsumptionSuite) SetUpSuite(c *gocheck.C) {
 	s.conn, err = db.Conn()
 	c.Assert(err, gocheck.IsNil)
 	s.createUserAndTeam(c)
+	app.Provisioner = testing.NewFakeProvisioner()
 }
 
 func (s *ConsumptionSuite) TearDownSuite(c *gocheck.C) {
@@ -665,3 +670,136 @@ func (s *ConsumptionSuite) TestGetServiceInstanceOrError(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(rSi.Name, gocheck.Equals, si.Name)
 }
+
+func (s *ConsumptionSuite) TestUpdateServiceInstanceHandler(c *gocheck.C) {
+	var method, path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		w.Write([]byte(`{"DATABASE_HOST":"10.10.10.10"}`))
+	}))
+	defer ts.Close()
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := srvc.Create()
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().Remove(bson.M{"_id": "mysql"})
+	a := app.App{
+		Name:  "painkiller",
+		Teams: []string{s.team.Name},
+		Units: []app.Unit{{Ip: "127.0.0.1", Machine: 1}},
+		Env:   map[string]bind.EnvVar{},
+	}
+	err = s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
+	instance := service.ServiceInstance{
+		Name:        "my-mysql",
+		ServiceName: "mysql",
+		PlanName:    "small",
+		Teams:       []string{s.team.Name},
+		Apps:        []string{a.Name},
+	}
+	err = instance.Create()
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.ServiceInstances().Remove(bson.M{"name": "my-mysql"})
+	body := strings.NewReader(`{"plan":"large","description":"my database","tags":["production"]}`)
+	url := fmt.Sprintf("/services/instances/%s?:name=%s", instance.Name, instance.Name)
+	request, err := http.NewRequest("PUT", url, body)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = updateServiceInstance(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(method, gocheck.Equals, "PUT")
+	c.Assert(path, gocheck.Equals, "/resources/my-mysql")
+	err = s.conn.ServiceInstances().Find(bson.M{"name": instance.Name}).One(&instance)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(instance.PlanName, gocheck.Equals, "large")
+	c.Assert(instance.Description, gocheck.Equals, "my database")
+	c.Assert(instance.Tags, gocheck.DeepEquals, []string{"production"})
+	err = s.conn.Apps().Find(bson.M{"name": a.Name}).One(&a)
+	c.Assert(err, gocheck.IsNil)
+	expected := bind.EnvVar{Name: "DATABASE_HOST", Value: "10.10.10.10", Public: false, InstanceName: instance.Name}
+	c.Assert(a.Env["DATABASE_HOST"], gocheck.DeepEquals, expected)
+	action := testing.Action{
+		Action: "update-service-instance",
+		User:   s.user.Email,
+		Extra:  []interface{}{"name=" + instance.Name, `{"plan":"large","description":"my database","tags":["production"]}`},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *ConsumptionSuite) TestUpdateServiceInstanceHandlerUpdatesOnlyTheGivenFields(c *gocheck.C) {
+	var params url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		params = r.Form
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := srvc.Create()
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().Remove(bson.M{"_id": "mysql"})
+	instance := service.ServiceInstance{
+		Name:        "my-mysql",
+		ServiceName: "mysql",
+		PlanName:    "small",
+		Description: "my database",
+		Tags:        []string{"production"},
+		Teams:       []string{s.team.Name},
+	}
+	err = instance.Create()
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.ServiceInstances().Remove(bson.M{"name": "my-mysql"})
+	u := fmt.Sprintf("/services/instances/%s?:name=%s", instance.Name, instance.Name)
+	request, err := http.NewRequest("PUT", u, strings.NewReader(`{"description":"the new database"}`))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = updateServiceInstance(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(params.Get("plan"), gocheck.Equals, "small")
+	c.Assert(params.Get("description"), gocheck.Equals, "the new database")
+	c.Assert(params["tag"], gocheck.DeepEquals, []string{"production"})
+	err = s.conn.ServiceInstances().Find(bson.M{"name": instance.Name}).One(&instance)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(instance.PlanName, gocheck.Equals, "small")
+	c.Assert(instance.Description, gocheck.Equals, "the new database")
+	c.Assert(instance.Tags, gocheck.DeepEquals, []string{"production"})
+}
+
+func (s *ConsumptionSuite) TestUpdateServiceInstanceHandlerWithoutFields(c *gocheck.C) {
+	url := "/services/instances/my-mysql?:name=my-mysql"
+	request, err := http.NewRequest("PUT", url, strings.NewReader("{}"))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = updateServiceInstance(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *ConsumptionSuite) TestUpdateServiceInstanceHandlerInvalidBody(c *gocheck.C) {
+	url := "/services/instances/my-mysql?:name=my-mysql"
+	request, err := http.NewRequest("PUT", url, strings.NewReader("not json"))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = updateServiceInstance(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *ConsumptionSuite) TestUpdateServiceInstanceHandlerReturns404IfTheInstanceDoesNotExist(c *gocheck.C) {
+	url := "/services/instances/unknown?:name=unknown"
+	request, err := http.NewRequest("PUT", url, strings.NewReader(`{"plan":"large"}`))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = updateServiceInstance(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusNotFound)
+}
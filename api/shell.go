@@ -0,0 +1,64 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/auth"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/log"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/rec"
+	"net/http"
+	"strconv"
+)
+
+// appShell opens an interactive shell in a unit of the app. The connection
+// is hijacked and upgraded to a raw TCP stream, that carries the input and
+// the output of the shell until it exits.
+func appShell(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getApp(appName, u)
+	if err != nil {
+		return err
+	}
+	if _, ok := app.Provisioner.(provision.ShellOpener); !ok {
+		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: provision.ErrShellNotSupported.Error()}
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return &errors.HTTP{Code: http.StatusInternalServerError, Message: "Cannot hijack the connection."}
+	}
+	query := r.URL.Query()
+	unit := query.Get("unit")
+	isolated := query.Get("isolated") == "true"
+	width, _ := strconv.Atoi(query.Get("width"))
+	height, _ := strconv.Atoi(query.Get("height"))
+	rec.Log(u.Email, "app-shell", "app="+appName, "unit="+unit, "isolated="+strconv.FormatBool(isolated))
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	opts := provision.ShellOptions{
+		Conn:     conn,
+		Unit:     unit,
+		Isolated: isolated,
+		Width:    width,
+		Height:   height,
+		Term:     query.Get("term"),
+	}
+	if err := a.Shell(opts); err != nil {
+		log.Errorf("Failed to open shell in app %q: %s", appName, err)
+		fmt.Fprintf(conn, "Error: %s\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/errors"
+	tsuruIo "github.com/globocom/tsuru/io"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/testing"
+	"io/ioutil"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"net"
+	"net/http"
+	"net/http/httptest"
+)
+
+type noShellProvisioner struct {
+	provision.Provisioner
+}
+
+func (s *S) TestAppShellHandler(c *gocheck.C) {
+	a := app.App{Name: "almah", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := appShell(&tsuruIo.FlushingWriter{ResponseWriter: w}, r, s.token)
+		c.Check(err, gocheck.IsNil)
+	}))
+	defer server.Close()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	c.Assert(err, gocheck.IsNil)
+	defer conn.Close()
+	url := fmt.Sprintf("%s/apps/%s/shell?:app=%s&unit=almah/0&width=80&height=24&term=xterm", server.URL, a.Name, a.Name)
+	request, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "tcp")
+	err = request.Write(conn)
+	c.Assert(err, gocheck.IsNil)
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(response.StatusCode, gocheck.Equals, http.StatusSwitchingProtocols)
+	output, err := ioutil.ReadAll(reader)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(output), gocheck.Equals, "shell for almah\n")
+	shells := s.provisioner.Shells(&a)
+	c.Assert(shells, gocheck.HasLen, 1)
+	c.Assert(shells[0].Unit, gocheck.Equals, "almah/0")
+	c.Assert(shells[0].Isolated, gocheck.Equals, false)
+	c.Assert(shells[0].Width, gocheck.Equals, 80)
+	c.Assert(shells[0].Height, gocheck.Equals, 24)
+	c.Assert(shells[0].Term, gocheck.Equals, "xterm")
+	action := testing.Action{
+		Action: "app-shell",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "unit=almah/0", "isolated=false"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestAppShellHandlerNoAccess(c *gocheck.C) {
+	a := app.App{Name: "almah"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	url := fmt.Sprintf("/apps/%s/shell?:app=%s", a.Name, a.Name)
+	request, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appShell(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppShellHandlerNotSupported(c *gocheck.C) {
+	app.Provisioner = noShellProvisioner{s.provisioner}
+	defer func() {
+		app.Provisioner = s.provisioner
+	}()
+	a := app.App{Name: "almah", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	url := fmt.Sprintf("/apps/%s/shell?:app=%s", a.Name, a.Name)
+	request, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = appShell(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusPreconditionFailed)
+}
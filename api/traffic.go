@@ -0,0 +1,62 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/auth"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/rec"
+	"net/http"
+	"strconv"
+)
+
+func splitTraffic(w http.ResponseWriter, r *http.Request, t *auth.Token) error {
+	var params struct {
+		Target string
+		Weight *int
+	}
+	if r.Body == nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the weight."}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid JSON in request body."}
+	}
+	if params.Weight == nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the weight."}
+	}
+	weight := *params.Weight
+	if weight > 0 && params.Target == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the target app."}
+	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getApp(appName, u)
+	if err != nil {
+		return err
+	}
+	var target *app.App
+	if weight > 0 {
+		targetApp, err := getApp(params.Target, u)
+		if err != nil {
+			return err
+		}
+		target = &targetApp
+	}
+	rec.Log(u.Email, "split-traffic", "app="+appName, "target="+params.Target, "weight="+strconv.Itoa(weight))
+	err = a.SplitTraffic(target, weight)
+	if e, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
+	if err == provision.ErrTrafficSplitNotSupported {
+		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: err.Error()}
+	}
+	return err
+}
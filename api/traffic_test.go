@@ -0,0 +1,144 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/testing"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (s *S) TestSplitTrafficHandler(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	target := app.App{Name: "leper-canary", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a, target)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": bson.M{"$in": []string{a.Name, target.Name}}})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	url := fmt.Sprintf("/apps/%s/traffic?:app=%s", a.Name, a.Name)
+	body := strings.NewReader(`{"target":"leper-canary","weight":10}`)
+	request, err := http.NewRequest("POST", url, body)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = splitTraffic(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	name, weight := s.provisioner.Split(&a)
+	c.Assert(name, gocheck.Equals, "leper-canary")
+	c.Assert(weight, gocheck.Equals, 10)
+	action := testing.Action{
+		Action: "split-traffic",
+		User:   s.user.Email,
+		Extra:  []interface{}{"app=" + a.Name, "target=leper-canary", "weight=10"},
+	}
+	c.Assert(action, testing.IsRecorded)
+}
+
+func (s *S) TestSplitTrafficHandlerRemove(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	s.provisioner.SplitTraffic(&a, &app.App{Name: "leper-canary"}, 10)
+	url := fmt.Sprintf("/apps/%s/traffic?:app=%s", a.Name, a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader(`{"weight":0}`))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = splitTraffic(recorder, request, s.token)
+	c.Assert(err, gocheck.IsNil)
+	name, weight := s.provisioner.Split(&a)
+	c.Assert(name, gocheck.Equals, "")
+	c.Assert(weight, gocheck.Equals, 0)
+}
+
+func (s *S) TestSplitTrafficHandlerWithoutWeight(c *gocheck.C) {
+	url := "/apps/leper/traffic?:app=leper"
+	request, err := http.NewRequest("POST", url, strings.NewReader(`{"target":"leper-canary"}`))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = splitTraffic(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+	c.Assert(e.Message, gocheck.Equals, "You must provide the weight.")
+}
+
+func (s *S) TestSplitTrafficHandlerWithoutTarget(c *gocheck.C) {
+	url := "/apps/leper/traffic?:app=leper"
+	request, err := http.NewRequest("POST", url, strings.NewReader(`{"weight":10}`))
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = splitTraffic(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+	c.Assert(e.Message, gocheck.Equals, "You must provide the target app.")
+}
+
+func (s *S) TestSplitTrafficHandlerInvalidWeight(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	target := app.App{Name: "leper-canary", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a, target)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": bson.M{"$in": []string{a.Name, target.Name}}})
+	url := fmt.Sprintf("/apps/%s/traffic?:app=%s", a.Name, a.Name)
+	body := strings.NewReader(`{"target":"leper-canary","weight":120}`)
+	request, err := http.NewRequest("POST", url, body)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = splitTraffic(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestSplitTrafficHandlerTargetWithoutAccess(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	target := app.App{Name: "leper-canary", Teams: []string{"otherteam"}}
+	err := s.conn.Apps().Insert(a, target)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": bson.M{"$in": []string{a.Name, target.Name}}})
+	url := fmt.Sprintf("/apps/%s/traffic?:app=%s", a.Name, a.Name)
+	body := strings.NewReader(`{"target":"leper-canary","weight":10}`)
+	request, err := http.NewRequest("POST", url, body)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = splitTraffic(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestSplitTrafficHandlerNotSupported(c *gocheck.C) {
+	a := app.App{Name: "leper", Teams: []string{s.team.Name}}
+	target := app.App{Name: "leper-canary", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a, target)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": bson.M{"$in": []string{a.Name, target.Name}}})
+	s.provisioner.PrepareFailure("SplitTraffic", provision.ErrTrafficSplitNotSupported)
+	url := fmt.Sprintf("/apps/%s/traffic?:app=%s", a.Name, a.Name)
+	body := strings.NewReader(`{"target":"leper-canary","weight":10}`)
+	request, err := http.NewRequest("POST", url, body)
+	c.Assert(err, gocheck.IsNil)
+	recorder := httptest.NewRecorder()
+	err = splitTraffic(recorder, request, s.token)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusPreconditionFailed)
+}
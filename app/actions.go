@@ -13,15 +13,18 @@ import (
 	"github.com/globocom/tsuru/app/bind"
 	"github.com/globocom/tsuru/auth"
 	"github.com/globocom/tsuru/db"
+	tsuruErrors "github.com/globocom/tsuru/errors"
 	"github.com/globocom/tsuru/log"
 	"github.com/globocom/tsuru/provision"
 	"github.com/globocom/tsuru/queue"
 	"github.com/globocom/tsuru/quota"
 	"github.com/globocom/tsuru/repository"
+	"github.com/globocom/tsuru/service"
 	"io"
 	"labix.org/v2/mgo/bson"
 	"launchpad.net/goamz/aws"
 	"launchpad.net/goamz/iam"
+	"net/http"
 	"strconv"
 	"strings"
 )
@@ -262,7 +265,7 @@ var exportEnvironmentsAction = action.Action{
 	},
 	Backward: func(ctx action.BWContext) {
 		app := ctx.Params[0].(*App)
-		auth.DeleteToken(app.Env["TSURU_APP_TOKEN"].Value)
+		auth.DeleteToken(app.envValue("TSURU_APP_TOKEN"))
 		if app.Get() == nil {
 			s3Env := app.InstanceEnv(s3InstanceName)
 			vars := make([]string, len(s3Env)+3)
@@ -484,7 +487,11 @@ var saveNewUnitsInDatabase = action.Action{
 	MinParams: 1,
 }
 
-// ProvisionerDeploy is an actions that call the Provisioner.Deploy.
+// ProvisionerDeploy is an actions that call the Provisioner.Deploy, or the
+// DeployArchive and DeployImage methods of the provisioner when deploying
+// from an archive or from an image. Provisioners that implement
+// provision.Builder build and release the app in separate steps, running the
+// deploy hooks of the app. Promotions require a provision.Promoter.
 var ProvisionerDeploy = action.Action{
 	Name: "provisioner-deploy",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
@@ -492,15 +499,44 @@ var ProvisionerDeploy = action.Action{
 		if !ok {
 			return nil, errors.New("First parameter must be a *App.")
 		}
-		version, ok := ctx.Params[1].(string)
-		if !ok {
+		version := ctx.Params[1]
+		switch version.(type) {
+		case string, archive, image, promotion:
+		default:
 			return nil, errors.New("Second parameter must be a string.")
 		}
 		logWriter, ok := ctx.Params[2].(io.Writer)
 		if !ok {
 			return nil, errors.New("Third parameter must be a io.Writer.")
 		}
-		err := Provisioner.Deploy(app, version, logWriter)
+		builder, isBuilder := Provisioner.(provision.Builder)
+		switch v := version.(type) {
+		case archive:
+			if isBuilder {
+				return nil, buildAndRelease(app, builder, provision.BuildOptions{ArchiveURL: v.url}, logWriter)
+			}
+			deployer, ok := Provisioner.(provision.ArchiveDeployer)
+			if !ok {
+				return nil, provision.ErrArchiveDeployNotSupported
+			}
+			return nil, deployer.DeployArchive(app, v.url, logWriter)
+		case image:
+			deployer, ok := Provisioner.(provision.ImageDeployer)
+			if !ok {
+				return nil, provision.ErrImageDeployNotSupported
+			}
+			return nil, deployer.DeployImage(app, v.name, logWriter)
+		case promotion:
+			promoter, ok := Provisioner.(provision.Promoter)
+			if !ok {
+				return nil, provision.ErrPromoteNotSupported
+			}
+			return nil, promoteAndRelease(app, promoter, v, logWriter)
+		}
+		if isBuilder {
+			return nil, buildAndRelease(app, builder, provision.BuildOptions{Version: version.(string)}, logWriter)
+		}
+		err := Provisioner.Deploy(app, version.(string), logWriter)
 		return nil, err
 	},
 	Backward: func(ctx action.BWContext) {
@@ -523,3 +559,194 @@ var IncrementDeploy = action.Action{
 	},
 	MinParams: 1,
 }
+
+// createManifestApp creates the app declared in a manifest, removing it in
+// the rollback.
+var createManifestApp = action.Action{
+	Name: "create-manifest-app",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		app := ctx.Params[0].(*App)
+		user := ctx.Params[2].(*auth.User)
+		if err := CreateApp(app, user); err != nil {
+			return nil, err
+		}
+		return app, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		app := ctx.FWResult.(*App)
+		if err := app.Get(); err != nil {
+			log.Errorf("Could not find the app %q to remove it: %s", app.Name, err)
+			return
+		}
+		if err := Provisioner.Destroy(app); err != nil {
+			log.Errorf("Could not destroy the app %q in the provisioner: %s", app.Name, err)
+		}
+		if len(app.Units) > 0 {
+			app.unbind()
+		}
+		// The units were destroyed along with the app, even the ones added
+		// by a failed step that didn't record them.
+		app.Units = nil
+		if err := Delete(app); err != nil {
+			log.Errorf("Could not remove the app %q: %s", app.Name, err)
+		}
+	},
+	MinParams: 4,
+}
+
+// setManifestEnvs sets the environment variables declared in a manifest.
+// There's nothing to roll back, as the app is removed.
+var setManifestEnvs = action.Action{
+	Name: "set-manifest-envs",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		app := ctx.Params[0].(*App)
+		m := ctx.Params[1].(*Manifest)
+		if len(m.Env) == 0 {
+			return nil, nil
+		}
+		return nil, app.SetEnvs(m.envs(), false)
+	},
+	Backward: func(ctx action.BWContext) {
+	},
+	MinParams: 4,
+}
+
+// addManifestCNames adds the cnames declared in a manifest to the app,
+// removing them from the router in the rollback.
+var addManifestCNames = action.Action{
+	Name: "add-manifest-cnames",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		app := ctx.Params[0].(*App)
+		m := ctx.Params[1].(*Manifest)
+		for i, cname := range m.CNames {
+			if err := app.AddCName(cname); err != nil {
+				removeCNames(app, m.CNames[:i])
+				if err == ErrInvalidCName || err == ErrCNameExists {
+					msg := fmt.Sprintf("Failed to add the cname %q: %s.", cname, err)
+					return nil, &tsuruErrors.ValidationError{Message: msg}
+				}
+				return nil, err
+			}
+		}
+		return nil, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		removeCNames(ctx.Params[0].(*App), ctx.Params[1].(*Manifest).CNames)
+	},
+	MinParams: 4,
+}
+
+func removeCNames(app *App, cnames []string) {
+	for _, cname := range cnames {
+		if err := app.RemoveCName(cname); err != nil {
+			log.Errorf("Could not remove the cname %q of the app %q: %s", cname, app.Name, err)
+		}
+	}
+}
+
+// createManifestServiceInstances creates the service instances declared in
+// a manifest that do not exist yet, removing them in the rollback.
+var createManifestServiceInstances = action.Action{
+	Name: "create-manifest-service-instances",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		user := ctx.Params[2].(*auth.User)
+		instances := ctx.Params[3].([]manifestInstance)
+		for i, mi := range instances {
+			if mi.service == nil {
+				continue
+			}
+			if err := service.CreateServiceInstance(mi.instance.Name, mi.service, user); err != nil {
+				removeServiceInstances(instances[:i])
+				return nil, err
+			}
+		}
+		return nil, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		removeServiceInstances(ctx.Params[3].([]manifestInstance))
+	},
+	MinParams: 4,
+}
+
+func removeServiceInstances(instances []manifestInstance) {
+	for _, mi := range instances {
+		if mi.service == nil {
+			continue
+		}
+		if err := service.DeleteInstance(mi.instance); err != nil {
+			log.Errorf("Could not remove the service instance %q: %s", mi.instance.Name, err)
+		}
+	}
+}
+
+// bindManifestServiceInstances binds the service instances declared in a
+// manifest to the app, setting the environment variables returned by the
+// services, and unbinds them in the rollback. It runs after the units are
+// added, so all of them are bound. An app without units is only added to the
+// instances, and its units are bound as they are added.
+var bindManifestServiceInstances = action.Action{
+	Name: "bind-manifest-service-instances",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		app := ctx.Params[0].(*App)
+		instances := ctx.Params[3].([]manifestInstance)
+		for i, mi := range instances {
+			err := mi.instance.BindApp(app)
+			if e, ok := err.(*tsuruErrors.HTTP); ok && e.Code == http.StatusPreconditionFailed && len(app.Units) == 0 {
+				err = nil
+			}
+			if err != nil {
+				unbindServiceInstances(app, instances[:i])
+				return nil, err
+			}
+		}
+		return nil, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		unbindServiceInstances(ctx.Params[0].(*App), ctx.Params[3].([]manifestInstance))
+	},
+	MinParams: 4,
+}
+
+func unbindServiceInstances(app *App, instances []manifestInstance) {
+	conn, err := db.Conn()
+	if err != nil {
+		log.Errorf("Could not connect to the database: %s", err)
+		return
+	}
+	defer conn.Close()
+	for _, mi := range instances {
+		var si service.ServiceInstance
+		err := conn.ServiceInstances().Find(bson.M{"name": mi.instance.Name}).One(&si)
+		if err == nil {
+			err = si.UnbindApp(app)
+		}
+		if err != nil {
+			log.Errorf("Could not unbind the service instance %q from the app %q: %s", mi.instance.Name, app.Name, err)
+		}
+		mi.instance.Apps = si.Apps
+	}
+}
+
+// addManifestUnits adds units to the app until it has the number of units
+// declared in a manifest. There's nothing to roll back: the units are
+// destroyed along with the app by createManifestApp.
+var addManifestUnits = action.Action{
+	Name: "add-manifest-units",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		app := ctx.Params[0].(*App)
+		m := ctx.Params[1].(*Manifest)
+		if err := app.Get(); err != nil {
+			return nil, err
+		}
+		if current := uint(len(app.Units)); m.Units > current {
+			if err := app.AddUnits(m.Units - current); err != nil {
+				return nil, err
+			}
+			return nil, app.Get()
+		}
+		return nil, nil
+	},
+	Backward: func(ctx action.BWContext) {
+	},
+	MinParams: 4,
+}
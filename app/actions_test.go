@@ -22,7 +22,6 @@ import (
 	"launchpad.net/gocheck"
 	"sort"
 	"strings"
-	"time"
 )
 
 func (s *S) TestReserveUserAppName(c *gocheck.C) {
@@ -1194,12 +1193,6 @@ func (s *S) TestIncrementDeployForward(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 	s.conn.Apps().Find(bson.M{"name": a.Name}).One(&a)
 	c.Assert(a.Deploys, gocheck.Equals, uint(1))
-	var result map[string]interface{}
-	s.conn.Deploys().Find(bson.M{"app": a.Name}).One(&result)
-	c.Assert(result["app"], gocheck.Equals, a.Name)
-	now := time.Now()
-	diff := now.Sub(result["timestamp"].(time.Time))
-	c.Assert(diff < 60*time.Second, gocheck.Equals, true)
 }
 
 func (s *S) TestIncrementDeployParams(c *gocheck.C) {
@@ -1207,3 +1200,41 @@ func (s *S) TestIncrementDeployParams(c *gocheck.C) {
 	_, err := IncrementDeploy.Forward(ctx)
 	c.Assert(err.Error(), gocheck.Equals, "First parameter must be a *App.")
 }
+
+func (s *S) TestCreateManifestAppBackwardDestroysUnitsNotRecorded(c *gocheck.C) {
+	config.Unset("bucket-support")
+	defer config.Set("bucket-support", true)
+	ts := s.t.StartGandalfTestServer(&testHandler{})
+	defer ts.Close()
+	app := App{Name: "manifested", Platform: "python"}
+	err := s.conn.Apps().Insert(app)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": app.Name})
+	s.provisioner.Provision(&app)
+	_, err = s.provisioner.AddUnits(&app, 2)
+	c.Assert(err, gocheck.IsNil)
+	ctx := action.BWContext{Params: []interface{}{&app, &Manifest{}, s.user, nil}, FWResult: &app}
+	createManifestApp.Backward(ctx)
+	c.Assert(s.provisioner.Provisioned(&app), gocheck.Equals, false)
+	n, err := s.conn.Apps().Find(bson.M{"name": app.Name}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestCreateManifestAppBackwardWithUnits(c *gocheck.C) {
+	config.Unset("bucket-support")
+	defer config.Set("bucket-support", true)
+	ts := s.t.StartGandalfTestServer(&testHandler{})
+	defer ts.Close()
+	app := App{Name: "manifested", Platform: "python", Units: []Unit{{Name: "manifested/0"}}}
+	err := s.conn.Apps().Insert(app)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": app.Name})
+	s.provisioner.Provision(&app)
+	ctx := action.BWContext{Params: []interface{}{&app, &Manifest{}, s.user, nil}, FWResult: &app}
+	createManifestApp.Backward(ctx)
+	c.Assert(s.provisioner.Provisioned(&app), gocheck.Equals, false)
+	n, err := s.conn.Apps().Find(bson.M{"name": app.Name}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
@@ -38,6 +38,12 @@ var (
 	cnameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][\w-.]+$`)
 )
 
+var (
+	ErrInvalidCName  = stderr.New("Invalid cname")
+	ErrCNameExists   = stderr.New("cname already exists")
+	ErrCNameNotFound = stderr.New("cname not found")
+)
+
 // App is the main type in tsuru. An app represents a real world application.
 // This struct holds information about the app: its name, address, list of
 // teams that have access to it, used platform, etc.
@@ -46,13 +52,17 @@ type App struct {
 	Platform string `bson:"framework"`
 	Name     string
 	Ip       string
-	CName    string
+	CName    []string
 	Units    []Unit
 	Teams    []string
 	Owner    string
 	State    string
 	Deploys  uint
 
+	// Dependencies is the list of apps whose internal addresses are
+	// injected in the environment of the app.
+	Dependencies []string
+
 	hr hookRunner
 }
 
@@ -194,7 +204,7 @@ func Delete(app *App) error {
 		Provisioner.Destroy(app)
 		app.unbind()
 	}
-	token := app.Env["TSURU_APP_TOKEN"].Value
+	token := app.envValue("TSURU_APP_TOKEN")
 	auth.DeleteToken(token)
 	quota.Release(app.Owner, app.Name)
 	conn, err := db.Conn()
@@ -203,6 +213,10 @@ func Delete(app *App) error {
 	}
 	defer conn.Close()
 	quota.Delete(app.Name)
+	conn.Certificates().RemoveAll(bson.M{"app": app.Name})
+	conn.Jobs().RemoveAll(bson.M{"app": app.Name})
+	conn.JobRuns().RemoveAll(bson.M{"app": app.Name})
+	conn.Apps().UpdateAll(bson.M{"dependencies": app.Name}, bson.M{"$pull": bson.M{"dependencies": app.Name}})
 	return conn.Apps().Remove(bson.M{"name": app.Name})
 }
 
@@ -512,10 +526,52 @@ func (app *App) Run(cmd string, w io.Writer, once bool) error {
 	return app.sourced(cmd, w, once)
 }
 
+// Shell opens an interactive shell in a unit of the app, or in a one-off
+// unit, connected to the connection in the given options.
+func (app *App) Shell(opts provision.ShellOptions) error {
+	opener, ok := Provisioner.(provision.ShellOpener)
+	if !ok {
+		return provision.ErrShellNotSupported
+	}
+	app.Log(fmt.Sprintf("opening a shell (unit=%q, isolated=%t)", opts.Unit, opts.Isolated), "tsuru")
+	return opener.OpenShell(app, opts)
+}
+
+// RunIsolated executes the command in a one-off unit, created from the
+// current image of the app with its environment variables, instead of in the
+// units serving the app. The unit is removed when the command exits, and the
+// exit status of the command is recorded in the log of the app.
+func (app *App) RunIsolated(cmd string, w io.Writer) error {
+	app.Log(fmt.Sprintf("running '%s' in an isolated unit", cmd), "tsuru")
+	return app.sourcedIsolated(cmd, w)
+}
+
 func (app *App) sourced(cmd string, w io.Writer, once bool) error {
+	return app.run(app.sourcedCmd(cmd), w, once)
+}
+
+func (app *App) sourcedIsolated(cmd string, w io.Writer) error {
+	runner, ok := Provisioner.(provision.IsolatedRunner)
+	if !ok {
+		return provision.ErrIsolatedRunNotSupported
+	}
+	code, err := runner.ExecuteCommandIsolated(w, w, app, app.sourcedCmd(cmd))
+	if err != nil {
+		return err
+	}
+	app.Log(fmt.Sprintf("'%s' exited with status %d", cmd, code), "tsuru")
+	if code != 0 {
+		return fmt.Errorf("Command %q exited with status %d.", cmd, code)
+	}
+	return nil
+}
+
+// sourcedCmd returns the command line that runs the given command after
+// sourcing apprc, in the directory of the app.
+func (app *App) sourcedCmd(cmd string) string {
 	var mapEnv = func(name string) string {
-		if e, ok := app.Env[name]; ok {
-			return e.Value
+		if _, ok := app.Env[name]; ok {
+			return app.envValue(name)
 		}
 		if e := os.Getenv(name); e != "" {
 			return e
@@ -524,8 +580,7 @@ func (app *App) sourced(cmd string, w io.Writer, once bool) error {
 	}
 	source := "[ -f /home/application/apprc ] && source /home/application/apprc"
 	cd := "[ -d /home/application/current ] && cd /home/application/current"
-	cmd = fmt.Sprintf("%s; %s; %s", source, cd, os.Expand(cmd, mapEnv))
-	return app.run(cmd, w, once)
+	return fmt.Sprintf("%s; %s; %s", source, cd, os.Expand(cmd, mapEnv))
 }
 
 func (app *App) run(cmd string, w io.Writer, once bool) error {
@@ -601,35 +656,6 @@ func (app *App) GetDeploys() uint {
 	return app.Deploys
 }
 
-type Deploy struct {
-	App       string
-	Timestamp time.Time
-}
-
-func (app *App) ListDeploys() ([]Deploy, error) {
-	var list []Deploy
-	conn, err := db.Conn()
-	if err != nil {
-		return nil, err
-	}
-	if err := conn.Deploys().Find(bson.M{"app": app.Name}).Sort("-timestamp").All(&list); err != nil {
-		return []Deploy{}, err
-	}
-	return list, nil
-}
-
-func ListDeploys() ([]Deploy, error) {
-	var list []Deploy
-	conn, err := db.Conn()
-	if err != nil {
-		return nil, err
-	}
-	if err := conn.Deploys().Find(nil).Sort("-timestamp").All(&list); err != nil {
-		return []Deploy{}, err
-	}
-	return list, err
-}
-
 // ProvisionedUnits returns the internal list of units converted to
 // provision.AppUnit.
 func (app *App) ProvisionedUnits() []provision.AppUnit {
@@ -642,9 +668,10 @@ func (app *App) ProvisionedUnits() []provision.AppUnit {
 	return units
 }
 
-// Env returns app.Env
+// Envs returns the environment variables of the app, with the values of
+// private variables decrypted, to be delivered to its units.
 func (app *App) Envs() map[string]bind.EnvVar {
-	return app.Env
+	return app.decryptedEnvs()
 }
 
 // SerializeEnvVars serializes the environment variables of the app. The
@@ -654,7 +681,7 @@ func (app *App) SerializeEnvVars() error {
 	var buf bytes.Buffer
 	cmd := "cat > /home/application/apprc <<END\n"
 	cmd += fmt.Sprintf("# generated by tsuru at %s\n", time.Now().Format(time.RFC822Z))
-	for k, v := range app.Env {
+	for k, v := range app.decryptedEnvs() {
 		cmd += fmt.Sprintf(`export %s="%s"`+"\n", k, v.Value)
 	}
 	cmd += "END\n"
@@ -687,6 +714,10 @@ func (app *App) SetEnvs(envs []bind.EnvVar, publicOnly bool) error {
 //
 // If useQueue is true, it will use a queue to write the environment variables
 // in the units of the app.
+//
+// The values of private variables are encrypted before they're saved, when
+// encryption:key is configured, and only decrypted when delivered to the
+// units of the app.
 func (app *App) setEnvsToApp(envs []bind.EnvVar, publicOnly, useQueue bool) error {
 	if len(envs) > 0 {
 		for _, env := range envs {
@@ -698,6 +729,9 @@ func (app *App) setEnvsToApp(envs []bind.EnvVar, publicOnly, useQueue bool) erro
 				}
 			}
 			if set {
+				if err := encryptEnv(&env); err != nil {
+					return err
+				}
 				app.setEnv(env)
 			}
 		}
@@ -751,47 +785,130 @@ func (app *App) UnsetEnvs(variableNames []string, publicOnly bool) error {
 	return nil
 }
 
-// SetCName defines the CName of the app. It updates the attribute,
-// calls the SetCName function on the provisioner and saves
-// the app in the database, returning an error when it cannot save the change
-// in the database or set the CName on the provisioner.
-func (app *App) SetCName(cname string) error {
-	if cname != "" && !cnameRegexp.MatchString(cname) {
-		return stderr.New("Invalid cname")
+// AddCName adds a CName to the app. It validates the CName, calls the
+// SetCName function on the provisioner and saves the new CName in the
+// database, returning an error when the CName is invalid, is already used by
+// an app, or when it cannot be set on the provisioner or saved in the
+// database.
+func (app *App) AddCName(cname string) error {
+	if !cnameRegexp.MatchString(cname) {
+		return ErrInvalidCName
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	n, err := conn.Apps().Find(bson.M{"cname": cname}).Count()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrCNameExists
+	}
+	if err = normalizeCNames(conn, app.Name); err != nil {
+		return err
 	}
 	if s, ok := Provisioner.(provision.CNameManager); ok {
 		if err := s.SetCName(app, cname); err != nil {
 			return err
 		}
 	}
-	conn, err := db.Conn()
+	err = conn.Apps().Update(
+		bson.M{"name": app.Name},
+		bson.M{"$push": bson.M{"cname": cname}},
+	)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
-	app.CName = cname
-	return conn.Apps().Update(
-		bson.M{"name": app.Name},
-		bson.M{"$set": bson.M{"cname": app.CName}},
-	)
+	app.CName = append(app.CName, cname)
+	return nil
 }
 
-func (app *App) UnsetCName() error {
-	if s, ok := Provisioner.(provision.CNameManager); ok {
-		if err := s.UnsetCName(app, app.CName); err != nil {
+// SetBSON decodes the app stored in the database. The cname of the app is
+// converted to a list when it's stored as a string, like older versions of
+// tsuru did, as mgo would silently drop it.
+func (app *App) SetBSON(raw bson.Raw) error {
+	type storedApp App
+	if err := raw.Unmarshal((*storedApp)(app)); err != nil {
+		return err
+	}
+	if app.CName == nil {
+		var legacy struct{ CName interface{} }
+		if err := raw.Unmarshal(&legacy); err != nil {
 			return err
 		}
+		if cname, ok := legacy.CName.(string); ok && cname != "" {
+			app.CName = []string{cname}
+		}
+	}
+	return nil
+}
+
+// normalizeCNames converts the cname of the app to a list, when it's stored
+// as a string, like older versions of tsuru did, so it can be updated with
+// $push and $pull.
+func normalizeCNames(conn *db.Storage, appName string) error {
+	var stored struct{ CName interface{} }
+	err := conn.Apps().Find(bson.M{"name": appName}).Select(bson.M{"cname": 1}).One(&stored)
+	if err != nil {
+		return err
+	}
+	cname, ok := stored.CName.(string)
+	if !ok {
+		return nil
+	}
+	cnames := []string{}
+	if cname != "" {
+		cnames = append(cnames, cname)
 	}
+	return conn.Apps().Update(
+		bson.M{"name": appName, "cname": cname},
+		bson.M{"$set": bson.M{"cname": cnames}},
+	)
+}
+
+// RemoveCName removes a CName from the app, both from the provisioner and
+// from the database, along with its certificate.
+func (app *App) RemoveCName(cname string) error {
 	conn, err := db.Conn()
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
-	app.CName = ""
-	return conn.Apps().Update(
+	n, err := conn.Apps().Find(bson.M{"name": app.Name, "cname": cname}).Count()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCNameNotFound
+	}
+	if err = normalizeCNames(conn, app.Name); err != nil {
+		return err
+	}
+	if s, ok := Provisioner.(provision.CNameManager); ok {
+		if err := s.UnsetCName(app, cname); err != nil {
+			return err
+		}
+	}
+	err = conn.Apps().Update(
 		bson.M{"name": app.Name},
-		bson.M{"$set": bson.M{"cname": app.CName}},
+		bson.M{"$pull": bson.M{"cname": cname}},
 	)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Certificates().RemoveAll(bson.M{"_id": cname, "app": app.Name})
+	if err != nil {
+		return err
+	}
+	for i, c := range app.CName {
+		if c == cname {
+			app.CName = append(app.CName[:i], app.CName[i+1:]...)
+			break
+		}
+	}
+	return nil
 }
 
 // Log adds a log message to the app. Specifying a good source is good so the
@@ -879,32 +996,3 @@ func Swap(app1, app2 *App) error {
 	return Provisioner.Swap(app1, app2)
 }
 
-// DeployApp calls the Provisioner.Deploy
-func DeployApp(app *App, version string, writer io.Writer) error {
-	pipeline := Provisioner.DeployPipeline()
-	if pipeline == nil {
-		actions := []*action.Action{&ProvisionerDeploy, &IncrementDeploy}
-		pipeline = action.NewPipeline(actions...)
-	}
-	logWriter := LogWriter{App: app, Writer: writer}
-	return pipeline.Execute(app, version, &logWriter)
-}
-
-func incrementDeploy(app *App) error {
-	conn, err := db.Conn()
-	if err != nil {
-		return err
-	}
-	deploy := Deploy{
-		App:       app.Name,
-		Timestamp: time.Now(),
-	}
-	if err := conn.Deploys().Insert(deploy); err != nil {
-		return err
-	}
-	defer conn.Close()
-	return conn.Apps().Update(
-		bson.M{"name": app.Name},
-		bson.M{"$inc": bson.M{"deploys": 1}},
-	)
-}
@@ -46,6 +46,27 @@ func (s *S) TestGet(c *gocheck.C) {
 	c.Assert(myApp.Name, gocheck.Equals, newApp.Name)
 }
 
+func (s *S) TestGetLegacyStringCName(c *gocheck.C) {
+	err := s.conn.Apps().Insert(bson.M{"name": "ktulu", "framework": "ruby", "cname": "ktulu.mycompany.com"})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": "ktulu"})
+	a := App{Name: "ktulu"}
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Platform, gocheck.Equals, "ruby")
+	c.Assert(a.CName, gocheck.DeepEquals, []string{"ktulu.mycompany.com"})
+}
+
+func (s *S) TestGetEmptyLegacyStringCName(c *gocheck.C) {
+	err := s.conn.Apps().Insert(bson.M{"name": "ktulu", "cname": ""})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": "ktulu"})
+	a := App{Name: "ktulu"}
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.CName, gocheck.HasLen, 0)
+}
+
 func (s *S) TestDelete(c *gocheck.C) {
 	err := quota.Create(s.user.Email, 1)
 	c.Assert(err, gocheck.IsNil)
@@ -1209,21 +1230,24 @@ func (s *S) TestInstanceEnvironmentDoesNotPanicIfTheEnvMapIsNil(c *gocheck.C) {
 	c.Assert(a.InstanceEnv("mysql"), gocheck.DeepEquals, map[string]bind.EnvVar{})
 }
 
-func (s *S) TestSetCName(c *gocheck.C) {
+func (s *S) TestAddCName(c *gocheck.C) {
 	a := App{Name: "ktulu"}
 	err := s.conn.Apps().Insert(a)
 	c.Assert(err, gocheck.IsNil)
 	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
 	s.provisioner.Provision(&a)
 	defer s.provisioner.Destroy(&a)
-	err = a.SetCName("ktulu.mycompany.com")
+	err = a.AddCName("ktulu.mycompany.com")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.CName, gocheck.DeepEquals, []string{"ktulu.mycompany.com"})
+	err = a.AddCName("www.ktulu.mycompany.com")
 	c.Assert(err, gocheck.IsNil)
 	err = a.Get()
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(a.CName, gocheck.Equals, "ktulu.mycompany.com")
+	c.Assert(a.CName, gocheck.DeepEquals, []string{"ktulu.mycompany.com", "www.ktulu.mycompany.com"})
 }
 
-func (s *S) TestSetCNamePartialUpdate(c *gocheck.C) {
+func (s *S) TestAddCNamePartialUpdate(c *gocheck.C) {
 	a := App{Name: "master", Platform: "puppet"}
 	err := s.conn.Apps().Insert(a)
 	c.Assert(err, gocheck.IsNil)
@@ -1231,21 +1255,35 @@ func (s *S) TestSetCNamePartialUpdate(c *gocheck.C) {
 	s.provisioner.Provision(&a)
 	defer s.provisioner.Destroy(&a)
 	other := App{Name: a.Name}
-	err = other.SetCName("ktulu.mycompany.com")
+	err = other.AddCName("ktulu.mycompany.com")
 	c.Assert(err, gocheck.IsNil)
 	err = other.Get()
 	c.Assert(other.Platform, gocheck.Equals, "puppet")
 	c.Assert(other.Name, gocheck.Equals, "master")
-	c.Assert(other.CName, gocheck.Equals, "ktulu.mycompany.com")
+	c.Assert(other.CName, gocheck.DeepEquals, []string{"ktulu.mycompany.com"})
 }
 
-func (s *S) TestSetCNameUnknownApp(c *gocheck.C) {
+func (s *S) TestAddCNameToLegacyStringCName(c *gocheck.C) {
+	err := s.conn.Apps().Insert(bson.M{"name": "ktulu", "cname": "ktulu.mycompany.com"})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": "ktulu"})
 	a := App{Name: "ktulu"}
-	err := a.SetCName("ktulu.mycompany.com")
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = a.AddCName("www.ktulu.mycompany.com")
+	c.Assert(err, gocheck.IsNil)
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.CName, gocheck.DeepEquals, []string{"ktulu.mycompany.com", "www.ktulu.mycompany.com"})
+}
+
+func (s *S) TestAddCNameUnknownApp(c *gocheck.C) {
+	a := App{Name: "ktulu"}
+	err := a.AddCName("ktulu.mycompany.com")
 	c.Assert(err, gocheck.NotNil)
 }
 
-func (s *S) TestSetCNameValidatesTheCName(c *gocheck.C) {
+func (s *S) TestAddCNameValidatesTheCName(c *gocheck.C) {
 	var data = []struct {
 		input string
 		valid bool
@@ -1262,7 +1300,7 @@ func (s *S) TestSetCNameValidatesTheCName(c *gocheck.C) {
 		{".ktulu.mycompany.com", false},
 		{"0800.com", true},
 		{"-0800.com", false},
-		{"", true},
+		{"", false},
 	}
 	a := App{Name: "live-to-die"}
 	err := s.conn.Apps().Insert(a)
@@ -1271,57 +1309,105 @@ func (s *S) TestSetCNameValidatesTheCName(c *gocheck.C) {
 	s.provisioner.Provision(&a)
 	defer s.provisioner.Destroy(&a)
 	for _, t := range data {
-		err := a.SetCName(t.input)
+		err := a.AddCName(t.input)
 		if !t.valid {
-			c.Check(err.Error(), gocheck.Equals, "Invalid cname")
+			c.Check(err, gocheck.Equals, ErrInvalidCName)
 		} else {
 			c.Check(err, gocheck.IsNil)
 		}
 	}
 }
 
-func (s *S) TestSetCNameCallsProvisionerSetCName(c *gocheck.C) {
+func (s *S) TestAddCNameAlreadyInUse(c *gocheck.C) {
+	a := App{Name: "ktulu", CName: []string{"ktulu.mycompany.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	other := App{Name: "fight-fire"}
+	err = s.conn.Apps().Insert(other)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": other.Name})
+	s.provisioner.Provision(&other)
+	defer s.provisioner.Destroy(&other)
+	err = a.AddCName("ktulu.mycompany.com")
+	c.Assert(err, gocheck.Equals, ErrCNameExists)
+	err = other.AddCName("ktulu.mycompany.com")
+	c.Assert(err, gocheck.Equals, ErrCNameExists)
+	c.Assert(s.provisioner.HasCName(&other, "ktulu.mycompany.com"), gocheck.Equals, false)
+}
+
+func (s *S) TestAddCNameCallsProvisionerSetCName(c *gocheck.C) {
 	a := App{Name: "ktulu"}
 	err := s.conn.Apps().Insert(a)
 	c.Assert(err, gocheck.IsNil)
 	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
 	s.provisioner.Provision(&a)
 	defer s.provisioner.Destroy(&a)
-	err = a.SetCName("ktulu.mycompany.com")
+	err = a.AddCName("ktulu.mycompany.com")
 	c.Assert(err, gocheck.IsNil)
-	hasCName := s.provisioner.HasCName(&a, "ktulu.mycompany.com")
-	c.Assert(hasCName, gocheck.Equals, true)
+	err = a.AddCName("www.ktulu.mycompany.com")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(s.provisioner.HasCName(&a, "ktulu.mycompany.com"), gocheck.Equals, true)
+	c.Assert(s.provisioner.HasCName(&a, "www.ktulu.mycompany.com"), gocheck.Equals, true)
 }
 
-func (s *S) TestUnsetCNameRemovesFromDatabase(c *gocheck.C) {
+func (s *S) TestRemoveCNameRemovesFromDatabase(c *gocheck.C) {
 	a := App{Name: "ktulu"}
 	err := s.conn.Apps().Insert(a)
 	c.Assert(err, gocheck.IsNil)
 	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
 	s.provisioner.Provision(&a)
 	defer s.provisioner.Destroy(&a)
-	err = a.SetCName("ktulu.mycompany.com")
+	err = a.AddCName("ktulu.mycompany.com")
+	c.Assert(err, gocheck.IsNil)
+	err = a.AddCName("www.ktulu.mycompany.com")
 	c.Assert(err, gocheck.IsNil)
-	err = a.UnsetCName()
+	err = a.RemoveCName("ktulu.mycompany.com")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.CName, gocheck.DeepEquals, []string{"www.ktulu.mycompany.com"})
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.CName, gocheck.DeepEquals, []string{"www.ktulu.mycompany.com"})
+}
+
+func (s *S) TestRemoveCNameFromLegacyStringCName(c *gocheck.C) {
+	err := s.conn.Apps().Insert(bson.M{"name": "ktulu", "cname": "ktulu.mycompany.com"})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": "ktulu"})
+	a := App{Name: "ktulu"}
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = a.RemoveCName("ktulu.mycompany.com")
 	c.Assert(err, gocheck.IsNil)
 	err = a.Get()
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(a.CName, gocheck.Equals, "")
+	c.Assert(a.CName, gocheck.HasLen, 0)
 }
 
-func (s *S) TestUnsetCNameRemovesFromRouter(c *gocheck.C) {
+func (s *S) TestRemoveCNameRemovesFromRouter(c *gocheck.C) {
 	a := App{Name: "ktulu"}
 	err := s.conn.Apps().Insert(a)
 	c.Assert(err, gocheck.IsNil)
 	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
 	s.provisioner.Provision(&a)
 	defer s.provisioner.Destroy(&a)
-	err = a.SetCName("ktulu.mycompany.com")
+	err = a.AddCName("ktulu.mycompany.com")
 	c.Assert(err, gocheck.IsNil)
-	err = a.UnsetCName()
+	err = a.AddCName("www.ktulu.mycompany.com")
 	c.Assert(err, gocheck.IsNil)
-	hasCName := s.provisioner.HasCName(&a, "ktulu.mycompany.com")
-	c.Assert(hasCName, gocheck.Equals, false)
+	err = a.RemoveCName("ktulu.mycompany.com")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(s.provisioner.HasCName(&a, "ktulu.mycompany.com"), gocheck.Equals, false)
+	c.Assert(s.provisioner.HasCName(&a, "www.ktulu.mycompany.com"), gocheck.Equals, true)
+}
+
+func (s *S) TestRemoveCNameNotFound(c *gocheck.C) {
+	a := App{Name: "ktulu"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	err = a.RemoveCName("ktulu.mycompany.com")
+	c.Assert(err, gocheck.Equals, ErrCNameNotFound)
 }
 
 func (s *S) TestIsValid(c *gocheck.C) {
@@ -1626,7 +1712,7 @@ func (s *S) TestAppMarshalJSON(c *gocheck.C) {
 		Platform: "Framework",
 		Teams:    []string{"team1"},
 		Ip:       "10.10.10.1",
-		CName:    "name.mycompany.com",
+		CName:    []string{"name.mycompany.com"},
 	}
 	expected := make(map[string]interface{})
 	expected["name"] = "name"
@@ -1635,7 +1721,7 @@ func (s *S) TestAppMarshalJSON(c *gocheck.C) {
 	expected["teams"] = []interface{}{"team1"}
 	expected["units"] = nil
 	expected["ip"] = "10.10.10.1"
-	expected["cname"] = "name.mycompany.com"
+	expected["cname"] = []interface{}{"name.mycompany.com"}
 	expected["ready"] = false
 	data, err := app.MarshalJSON()
 	c.Assert(err, gocheck.IsNil)
@@ -1651,7 +1737,7 @@ func (s *S) TestAppMarshalJSONReady(c *gocheck.C) {
 		Platform: "Framework",
 		Teams:    []string{"team1"},
 		Ip:       "10.10.10.1",
-		CName:    "name.mycompany.com",
+		CName:    []string{"name.mycompany.com"},
 		State:    "ready",
 	}
 	expected := make(map[string]interface{})
@@ -1661,7 +1747,7 @@ func (s *S) TestAppMarshalJSONReady(c *gocheck.C) {
 	expected["teams"] = []interface{}{"team1"}
 	expected["units"] = nil
 	expected["ip"] = "10.10.10.1"
-	expected["cname"] = "name.mycompany.com"
+	expected["cname"] = []interface{}{"name.mycompany.com"}
 	expected["ready"] = true
 	data, err := app.MarshalJSON()
 	c.Assert(err, gocheck.IsNil)
@@ -1688,6 +1774,18 @@ func (s *S) TestRun(c *gocheck.C) {
 	c.Assert(cmds, gocheck.HasLen, 1)
 }
 
+func (s *S) TestShell(c *gocheck.C) {
+	app := App{Name: "myapp"}
+	s.provisioner.Provision(&app)
+	defer s.provisioner.Destroy(&app)
+	var buf bytes.Buffer
+	opts := provision.ShellOptions{Conn: &buf, Unit: "myapp/0", Width: 80, Height: 24}
+	err := app.Shell(opts)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Equals, "shell for myapp\n")
+	c.Assert(s.provisioner.Shells(&app), gocheck.DeepEquals, []provision.ShellOptions{opts})
+}
+
 func (s *S) TestRunOnce(c *gocheck.C) {
 	s.provisioner.PrepareOutput([]byte("a lot of files"))
 	app := App{
@@ -1722,6 +1820,60 @@ func (s *S) TestRunWithoutEnv(c *gocheck.C) {
 	c.Assert(cmds, gocheck.HasLen, 1)
 }
 
+func (s *S) TestRunIsolated(c *gocheck.C) {
+	app := App{Name: "myapp"}
+	s.provisioner.Provision(&app)
+	defer s.provisioner.Destroy(&app)
+	defer s.conn.Logs().Remove(bson.M{"appname": app.Name})
+	s.provisioner.PrepareOutput([]byte("migrated"))
+	var buf bytes.Buffer
+	err := app.RunIsolated("python manage.py migrate", &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Equals, "migrated")
+	expected := "[ -f /home/application/apprc ] && source /home/application/apprc;"
+	expected += " [ -d /home/application/current ] && cd /home/application/current;"
+	expected += " python manage.py migrate"
+	cmds := s.provisioner.IsolatedCmds(&app)
+	c.Assert(cmds, gocheck.HasLen, 1)
+	c.Assert(cmds[0].Cmd, gocheck.Equals, expected)
+	c.Assert(s.provisioner.GetCmds("", &app), gocheck.HasLen, 0)
+	var logs []Applog
+	err = s.conn.Logs().Find(bson.M{"appname": app.Name}).Sort("$natural").All(&logs)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(logs, gocheck.HasLen, 2)
+	c.Assert(logs[0].Message, gocheck.Equals, "running 'python manage.py migrate' in an isolated unit")
+	c.Assert(logs[1].Message, gocheck.Equals, "'python manage.py migrate' exited with status 0")
+}
+
+func (s *S) TestRunIsolatedExitStatus(c *gocheck.C) {
+	app := App{Name: "myapp"}
+	s.provisioner.Provision(&app)
+	defer s.provisioner.Destroy(&app)
+	defer s.conn.Logs().Remove(bson.M{"appname": app.Name})
+	s.provisioner.PrepareExitCode(3)
+	var buf bytes.Buffer
+	err := app.RunIsolated("python manage.py migrate", &buf)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, `Command "python manage.py migrate" exited with status 3.`)
+	var logs []Applog
+	err = s.conn.Logs().Find(bson.M{"appname": app.Name}).Sort("$natural").All(&logs)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(logs, gocheck.HasLen, 2)
+	c.Assert(logs[1].Message, gocheck.Equals, "'python manage.py migrate' exited with status 3")
+}
+
+func (s *S) TestRunIsolatedNotSupported(c *gocheck.C) {
+	Provisioner = struct{ provision.Provisioner }{s.provisioner}
+	defer func() {
+		Provisioner = s.provisioner
+	}()
+	app := App{Name: "myapp"}
+	defer s.conn.Logs().Remove(bson.M{"appname": app.Name})
+	var buf bytes.Buffer
+	err := app.RunIsolated("ls", &buf)
+	c.Assert(err, gocheck.Equals, provision.ErrIsolatedRunNotSupported)
+}
+
 func (s *S) TestEnvs(c *gocheck.C) {
 	app := App{
 		Name: "time",
@@ -1869,8 +2021,8 @@ func (s *S) TestListAppDeploys(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
 	insert := []interface{}{
-		Deploy{App: "g1", Timestamp: time.Now().Add(-3600 * time.Second)},
-		Deploy{App: "g1", Timestamp: time.Now()},
+		Deploy{ID: bson.NewObjectId(), App: "g1", Timestamp: time.Now().Add(-3600 * time.Second)},
+		Deploy{ID: bson.NewObjectId(), App: "g1", Timestamp: time.Now()},
 	}
 	s.conn.Deploys().Insert(insert...)
 	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
@@ -1889,8 +2041,8 @@ func (s *S) TestListAppDeploys(c *gocheck.C) {
 func (s *S) TestListAllDeploys(c *gocheck.C) {
 	s.conn.Deploys().RemoveAll(nil)
 	insert := []interface{}{
-		Deploy{App: "g1", Timestamp: time.Now().Add(-3600 * time.Second)},
-		Deploy{App: "ge", Timestamp: time.Now()},
+		Deploy{ID: bson.NewObjectId(), App: "g1", Timestamp: time.Now().Add(-3600 * time.Second)},
+		Deploy{ID: bson.NewObjectId(), App: "ge", Timestamp: time.Now()},
 	}
 	s.conn.Deploys().Insert(insert...)
 	defer s.conn.Deploys().RemoveAll(nil)
@@ -1960,7 +2112,7 @@ func (s *S) TestDeployApp(c *gocheck.C) {
 	s.provisioner.Provision(&a)
 	defer s.provisioner.Destroy(&a)
 	writer := &bytes.Buffer{}
-	err = DeployApp(&a, "version", writer)
+	err = DeployApp(&a, "version", "someone@tsuru.io", writer)
 	c.Assert(err, gocheck.IsNil)
 	logs := writer.String()
 	c.Assert(logs, gocheck.Equals, "Deploy called")
@@ -1979,7 +2131,7 @@ func (s *S) TestDeployAppIncrementDeployNumber(c *gocheck.C) {
 	s.provisioner.Provision(&a)
 	defer s.provisioner.Destroy(&a)
 	writer := &bytes.Buffer{}
-	err = DeployApp(&a, "version", writer)
+	err = DeployApp(&a, "version", "someone@tsuru.io", writer)
 	c.Assert(err, gocheck.IsNil)
 	s.conn.Apps().Find(bson.M{"name": a.Name}).One(&a)
 	c.Assert(a.Deploys, gocheck.Equals, uint(1))
@@ -2004,11 +2156,11 @@ func (s *S) TestDeployCustomPipeline(c *gocheck.C) {
 	s.provisioner.Provision(&a)
 	defer s.provisioner.Destroy(&a)
 	writer := &bytes.Buffer{}
-	err = DeployApp(&a, "version", writer)
+	err = DeployApp(&a, "version", "someone@tsuru.io", writer)
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(s.provisioner.ExecutedPipeline(), gocheck.Equals, false)
 	s.provisioner.CustomPipeline = true
-	err = DeployApp(&a, "version", writer)
+	err = DeployApp(&a, "version", "someone@tsuru.io", writer)
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(s.provisioner.ExecutedPipeline(), gocheck.Equals, true)
 }
@@ -0,0 +1,98 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/db"
+	"io"
+	"labix.org/v2/mgo"
+	"strings"
+)
+
+// archive is the version deployed by DeployArchive. It's given to the
+// deploy pipeline in place of the git version of the app.
+type archive struct {
+	url string
+}
+
+// DeployArchive deploys the app from the tar.gz archive available in the
+// given URL, running the same pipeline used by DeployApp.
+func DeployArchive(app *App, archiveURL, user string, writer io.Writer) error {
+	return runDeploy(app, archive{url: archiveURL}, "", user, writer)
+}
+
+// StoreArchive stores the archive read from r, so it can be downloaded by
+// units during the deploy. It returns the id of the archive, that is hard to
+// guess and used in its URL.
+func StoreArchive(r io.Reader) (string, error) {
+	key := make([]byte, 16)
+	if _, err := io.ReadFull(rReader, key); err != nil {
+		return "", err
+	}
+	id := fmt.Sprintf("%x", key)
+	conn, err := db.Conn()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	file, err := conn.Archives().Create(id)
+	if err != nil {
+		return "", err
+	}
+	file.SetContentType("application/x-gzip")
+	if _, err = io.Copy(file, r); err != nil {
+		file.Abort()
+		file.Close()
+		return "", err
+	}
+	return id, file.Close()
+}
+
+type archiveFile struct {
+	*mgo.GridFile
+	conn *db.Storage
+}
+
+func (f *archiveFile) Close() error {
+	defer f.conn.Close()
+	return f.GridFile.Close()
+}
+
+// OpenArchive opens the archive identified by the given id for reading. The
+// caller must close the archive.
+func OpenArchive(id string) (io.ReadCloser, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	file, err := conn.Archives().Open(id)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &archiveFile{GridFile: file, conn: conn}, nil
+}
+
+// RemoveArchive removes the archive identified by the given id.
+func RemoveArchive(id string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Archives().Remove(id)
+}
+
+// ArchiveURL returns the URL where units download the archive identified by
+// the given id, based on the host of the tsuru API.
+func ArchiveURL(id string) (string, error) {
+	host, err := config.GetString("host")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(host, "/") + "/archives/" + id, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/action"
+	"github.com/globocom/tsuru/provision"
+	"io/ioutil"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"strings"
+)
+
+func (s *S) TestStoreArchive(c *gocheck.C) {
+	id, err := StoreArchive(strings.NewReader("compressed bytes"))
+	c.Assert(err, gocheck.IsNil)
+	defer RemoveArchive(id)
+	c.Assert(id, gocheck.Matches, "[0-9a-f]{32}")
+	archive, err := OpenArchive(id)
+	c.Assert(err, gocheck.IsNil)
+	defer archive.Close()
+	content, err := ioutil.ReadAll(archive)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(content), gocheck.Equals, "compressed bytes")
+}
+
+func (s *S) TestRemoveArchive(c *gocheck.C) {
+	id, err := StoreArchive(strings.NewReader("compressed bytes"))
+	c.Assert(err, gocheck.IsNil)
+	err = RemoveArchive(id)
+	c.Assert(err, gocheck.IsNil)
+	_, err = OpenArchive(id)
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestOpenArchiveNotFound(c *gocheck.C) {
+	_, err := OpenArchive("unknown")
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestArchiveURL(c *gocheck.C) {
+	config.Set("host", "http://tsuru.io:8080/")
+	defer config.Unset("host")
+	url, err := ArchiveURL("abc123")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(url, gocheck.Equals, "http://tsuru.io:8080/archives/abc123")
+}
+
+func (s *S) TestDeployArchive(c *gocheck.C) {
+	a := App{
+		Name:     "someApp",
+		Platform: "django",
+		Teams:    []string{s.team.Name},
+		Units:    []Unit{{Name: "i-0800", State: "started"}},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	writer := &bytes.Buffer{}
+	err = DeployArchive(&a, "http://example.com/someApp.tar.gz", "someone@tsuru.io", writer)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(writer.String(), gocheck.Equals, "Archive deploy called")
+	c.Assert(s.provisioner.ArchiveURL(&a), gocheck.Equals, "http://example.com/someApp.tar.gz")
+	c.Assert(s.provisioner.Version(&a), gocheck.Equals, "")
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Deploys, gocheck.Equals, uint(1))
+}
+
+func (s *S) TestProvisionerDeployForwardArchiveNotSupported(c *gocheck.C) {
+	Provisioner = struct{ provision.Provisioner }{s.provisioner}
+	defer func() {
+		Provisioner = s.provisioner
+	}()
+	a := App{Name: "someApp"}
+	ctx := action.FWContext{Params: []interface{}{&a, archive{url: "http://example.com/a.tar.gz"}, &bytes.Buffer{}}}
+	_, err := ProvisionerDeploy.Forward(ctx)
+	c.Assert(err, gocheck.Equals, provision.ErrArchiveDeployNotSupported)
+}
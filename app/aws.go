@@ -190,9 +190,8 @@ func createIAMUserPolicy(user *iam.User, appName, bucketName string) (*iam.UserP
 // related to the bucket (IAM user and IAM access key).
 func destroyBucket(app *App) error {
 	appName := strings.ToLower(app.Name)
-	env := app.InstanceEnv(s3InstanceName)
-	accessKeyID := env["TSURU_S3_ACCESS_KEY_ID"].Value
-	bucketName := env["TSURU_S3_BUCKET"].Value
+	accessKeyID := app.envValue("TSURU_S3_ACCESS_KEY_ID")
+	bucketName := app.envValue("TSURU_S3_BUCKET")
 	policyName := fmt.Sprintf("app-%s-bucket", appName)
 	s3Endpoint := getS3Endpoint()
 	iamEndpoint := getIAMEndpoint()
@@ -14,6 +14,10 @@ type EnvVar struct {
 	Value        string
 	Public       bool
 	InstanceName string
+
+	// Encrypted tells whether the value is encrypted. It's set by tsuru when
+	// the variable is stored.
+	Encrypted bool `json:"-"`
 }
 
 func (e *EnvVar) String() string {
@@ -0,0 +1,135 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	stderr "errors"
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+var ErrCertificateNotFound = stderr.New("certificate not found")
+
+// Certificate is a TLS certificate used for serving one of the cnames of an
+// app using HTTPS. The private key is stored along with the certificate,
+// always encrypted, and it's never serialized to JSON.
+type Certificate struct {
+	CName       string `bson:"_id"`
+	App         string
+	Certificate string
+	Key         string `json:"-"`
+	Expiration  time.Time
+}
+
+// parseCertificate parses the given PEM encoded certificate and key, checking
+// that they match, that the certificate is still valid and that it covers
+// the given cname.
+func parseCertificate(cname, certificate, key string) (*x509.Certificate, error) {
+	pair, err := tls.X509KeyPair([]byte(certificate), []byte(key))
+	if err != nil {
+		return nil, &errors.ValidationError{Message: "Invalid certificate or key: " + err.Error()}
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, &errors.ValidationError{Message: "Invalid certificate: " + err.Error()}
+	}
+	if err = leaf.VerifyHostname(cname); err != nil {
+		return nil, &errors.ValidationError{Message: err.Error()}
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return nil, &errors.ValidationError{Message: "The certificate has expired."}
+	}
+	return leaf, nil
+}
+
+// PrivateKey returns the private key of the certificate, decrypted.
+func (c *Certificate) PrivateKey() (string, error) {
+	return decryptSecret(c.Key)
+}
+
+// SetCertificate validates the certificate and the key of one of the cnames
+// of the app, sends them to the provisioner and stores them in the database,
+// replacing any previous certificate of the cname.
+//
+// The key is encrypted before it's stored, so SetCertificate returns
+// ErrNoEncryptionKey when encryption is not configured.
+func (app *App) SetCertificate(cname, certificate, key string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	n, err := conn.Apps().Find(bson.M{"name": app.Name, "cname": cname}).Count()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCNameNotFound
+	}
+	leaf, err := parseCertificate(cname, certificate, key)
+	if err != nil {
+		return err
+	}
+	s, ok := Provisioner.(provision.TLSManager)
+	if !ok {
+		return provision.ErrTLSNotSupported
+	}
+	encryptedKey, err := encryptSecret(key)
+	if err != nil {
+		return err
+	}
+	if err = s.SetCertificate(app, cname, certificate, key); err != nil {
+		return err
+	}
+	cert := Certificate{
+		CName:       cname,
+		App:         app.Name,
+		Certificate: certificate,
+		Key:         encryptedKey,
+		Expiration:  leaf.NotAfter,
+	}
+	_, err = conn.Certificates().UpsertId(cname, cert)
+	return err
+}
+
+// RemoveCertificate removes the certificate of one of the cnames of the app,
+// both from the provisioner and from the database.
+func (app *App) RemoveCertificate(cname string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	n, err := conn.Certificates().Find(bson.M{"_id": cname, "app": app.Name}).Count()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCertificateNotFound
+	}
+	if s, ok := Provisioner.(provision.TLSManager); ok {
+		if err = s.UnsetCertificate(app, cname); err != nil {
+			return err
+		}
+	}
+	return conn.Certificates().RemoveId(cname)
+}
+
+// Certificates returns the certificates of the app, sorted by cname.
+func (app *App) Certificates() ([]Certificate, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var certs []Certificate
+	err = conn.Certificates().Find(bson.M{"app": app.Name}).Sort("_id").All(&certs)
+	return certs, err
+}
@@ -0,0 +1,242 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"github.com/globocom/config"
+	tsuruErrors "github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"io/ioutil"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"strings"
+)
+
+func (s *S) readCertificate(c *gocheck.C) (string, string) {
+	cert, err := ioutil.ReadFile("testdata/myapp.crt")
+	c.Assert(err, gocheck.IsNil)
+	key, err := ioutil.ReadFile("testdata/myapp.key")
+	c.Assert(err, gocheck.IsNil)
+	return string(cert), string(key)
+}
+
+func (s *S) TestParseCertificate(c *gocheck.C) {
+	cert, key := s.readCertificate(c)
+	leaf, err := parseCertificate("myapp.com", cert, key)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(leaf.Subject.CommonName, gocheck.Equals, "myapp.com")
+	_, err = parseCertificate("www.myapp.com", cert, key)
+	c.Assert(err, gocheck.IsNil)
+}
+
+func (s *S) TestParseCertificateWrongHost(c *gocheck.C) {
+	cert, key := s.readCertificate(c)
+	_, err := parseCertificate("other.com", cert, key)
+	c.Assert(err, gocheck.NotNil)
+	_, ok := err.(*tsuruErrors.ValidationError)
+	c.Assert(ok, gocheck.Equals, true)
+}
+
+func (s *S) TestParseCertificateInvalidPair(c *gocheck.C) {
+	cert, _ := s.readCertificate(c)
+	_, err := parseCertificate("myapp.com", cert, "not a key")
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*tsuruErrors.ValidationError)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Message, gocheck.Matches, "^Invalid certificate or key: .*")
+}
+
+func (s *S) TestSetCertificate(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	a := App{Name: "ktulu", CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	defer s.conn.Certificates().RemoveId("myapp.com")
+	cert, key := s.readCertificate(c)
+	err = a.SetCertificate("myapp.com", cert, key)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(s.provisioner.HasCertificate(&a, "myapp.com", cert), gocheck.Equals, true)
+	var stored Certificate
+	err = s.conn.Certificates().FindId("myapp.com").One(&stored)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stored.App, gocheck.Equals, "ktulu")
+	c.Assert(stored.Certificate, gocheck.Equals, cert)
+	c.Assert(strings.HasPrefix(stored.Key, encryptedPrefix), gocheck.Equals, true)
+	c.Assert(stored.Expiration.IsZero(), gocheck.Equals, false)
+	plain, err := stored.PrivateKey()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(plain, gocheck.Equals, key)
+}
+
+func (s *S) TestSetCertificateWithoutEncryptionKey(c *gocheck.C) {
+	a := App{Name: "ktulu", CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	cert, key := s.readCertificate(c)
+	err = a.SetCertificate("myapp.com", cert, key)
+	c.Assert(err, gocheck.Equals, ErrNoEncryptionKey)
+	c.Assert(s.provisioner.HasCertificate(&a, "myapp.com", cert), gocheck.Equals, false)
+	n, err := s.conn.Certificates().FindId("myapp.com").Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestSetCertificateReplacesPreviousCertificate(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	a := App{Name: "ktulu", CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	defer s.conn.Certificates().RemoveId("myapp.com")
+	err = s.conn.Certificates().Insert(Certificate{CName: "myapp.com", App: a.Name, Certificate: "old"})
+	c.Assert(err, gocheck.IsNil)
+	cert, key := s.readCertificate(c)
+	err = a.SetCertificate("myapp.com", cert, key)
+	c.Assert(err, gocheck.IsNil)
+	n, err := s.conn.Certificates().Find(bson.M{"certificate": cert}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 1)
+	n, err = s.conn.Certificates().Find(bson.M{"certificate": "old"}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestSetCertificateUnknownCName(c *gocheck.C) {
+	a := App{Name: "ktulu", CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	cert, key := s.readCertificate(c)
+	err = a.SetCertificate("www.myapp.com", cert, key)
+	c.Assert(err, gocheck.Equals, ErrCNameNotFound)
+}
+
+func (s *S) TestSetCertificateInvalidCertificate(c *gocheck.C) {
+	a := App{Name: "ktulu", CName: []string{"other.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	cert, key := s.readCertificate(c)
+	err = a.SetCertificate("other.com", cert, key)
+	c.Assert(err, gocheck.NotNil)
+	_, ok := err.(*tsuruErrors.ValidationError)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(s.provisioner.HasCertificate(&a, "other.com", cert), gocheck.Equals, false)
+	n, err := s.conn.Certificates().FindId("other.com").Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestSetCertificateProvisionerFailure(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	a := App{Name: "ktulu", CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.PrepareFailure("SetCertificate", provision.ErrTLSNotSupported)
+	cert, key := s.readCertificate(c)
+	err = a.SetCertificate("myapp.com", cert, key)
+	c.Assert(err, gocheck.Equals, provision.ErrTLSNotSupported)
+	n, err := s.conn.Certificates().FindId("myapp.com").Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestRemoveCertificate(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	a := App{Name: "ktulu", CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	cert, key := s.readCertificate(c)
+	err = a.SetCertificate("myapp.com", cert, key)
+	c.Assert(err, gocheck.IsNil)
+	err = a.RemoveCertificate("myapp.com")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(s.provisioner.HasCertificate(&a, "myapp.com", cert), gocheck.Equals, false)
+	n, err := s.conn.Certificates().FindId("myapp.com").Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestRemoveCertificateNotFound(c *gocheck.C) {
+	a := App{Name: "ktulu"}
+	err := a.RemoveCertificate("myapp.com")
+	c.Assert(err, gocheck.Equals, ErrCertificateNotFound)
+}
+
+func (s *S) TestRemoveCertificateFromAnotherApp(c *gocheck.C) {
+	err := s.conn.Certificates().Insert(Certificate{CName: "myapp.com", App: "other"})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Certificates().RemoveId("myapp.com")
+	a := App{Name: "ktulu"}
+	err = a.RemoveCertificate("myapp.com")
+	c.Assert(err, gocheck.Equals, ErrCertificateNotFound)
+}
+
+func (s *S) TestRemoveCertificateProvisionerFailure(c *gocheck.C) {
+	err := s.conn.Certificates().Insert(Certificate{CName: "myapp.com", App: "ktulu"})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Certificates().RemoveId("myapp.com")
+	s.provisioner.PrepareFailure("UnsetCertificate", errors.New("router is down"))
+	a := App{Name: "ktulu"}
+	err = a.RemoveCertificate("myapp.com")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "router is down")
+	n, err := s.conn.Certificates().FindId("myapp.com").Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 1)
+}
+
+func (s *S) TestCertificates(c *gocheck.C) {
+	certs := []interface{}{
+		Certificate{CName: "www.myapp.com", App: "ktulu"},
+		Certificate{CName: "myapp.com", App: "ktulu"},
+		Certificate{CName: "other.com", App: "other"},
+	}
+	err := s.conn.Certificates().Insert(certs...)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Certificates().RemoveAll(nil)
+	a := App{Name: "ktulu"}
+	got, err := a.Certificates()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got, gocheck.HasLen, 2)
+	c.Assert(got[0].CName, gocheck.Equals, "myapp.com")
+	c.Assert(got[1].CName, gocheck.Equals, "www.myapp.com")
+}
+
+func (s *S) TestRemoveCNameRemovesCertificate(c *gocheck.C) {
+	a := App{Name: "ktulu", CName: []string{"myapp.com"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = s.conn.Certificates().Insert(Certificate{CName: "myapp.com", App: a.Name})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Certificates().RemoveId("myapp.com")
+	err = a.RemoveCName("myapp.com")
+	c.Assert(err, gocheck.IsNil)
+	n, err := s.conn.Certificates().FindId("myapp.com").Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
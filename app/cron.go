@@ -0,0 +1,150 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduleAliases are the shortcuts accepted in place of cron expressions.
+var scheduleAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// schedule is a parsed cron expression. Each field is a set of the values
+// in which the schedule fires, with bit n set for the value n.
+type schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// domAny and dowAny tell whether the days of the month and of the
+	// week are unrestricted. When both are restricted, the schedule fires
+	// in the days matching any of them, as in cron.
+	domAny, dowAny bool
+}
+
+// parseSchedule parses a cron expression with five fields: minute, hour,
+// day of the month, month and day of the week. Fields accept *, numbers,
+// ranges (1-5), lists (1,15) and steps (*/10, 8-18/2). Sunday is either 0 or
+// 7 in the day of the week.
+func parseSchedule(expr string) (*schedule, error) {
+	if alias, ok := scheduleAliases[strings.TrimSpace(expr)]; ok {
+		expr = alias
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Invalid schedule %q: it must have five fields (minute, hour, day of the month, month and day of the week).", expr)
+	}
+	var (
+		s   schedule
+		err error
+	)
+	bounds := []struct {
+		field    *uint64
+		min, max uint
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	}
+	for i, b := range bounds {
+		if *b.field, err = parseScheduleField(fields[i], b.min, b.max); err != nil {
+			return nil, fmt.Errorf("Invalid schedule %q: %s", expr, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	if s.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("Invalid schedule %q: it never fires.", expr)
+	}
+	return &s, nil
+}
+
+// parseScheduleField parses one field of a cron expression, returning the
+// set of its values.
+func parseScheduleField(field string, min, max uint) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		start, end, step := min, max, uint(1)
+		rangePart := part
+		if i := strings.Index(part, "/"); i != -1 {
+			n, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || n == 0 {
+				return 0, fmt.Errorf("invalid step in %q.", part)
+			}
+			step = uint(n)
+			rangePart = part[:i]
+		}
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			n, err := strconv.ParseUint(bounds[0], 10, 8)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %q.", part)
+			}
+			start, end = uint(n), uint(n)
+			if len(bounds) == 2 {
+				n, err = strconv.ParseUint(bounds[1], 10, 8)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value in %q.", part)
+				}
+				end = uint(n)
+			} else if step > 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("%q is out of the range %d-%d.", part, min, max)
+		}
+		for v := start; v <= end; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (s *schedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first minute after t in which the schedule fires, in the
+// location of t. It returns the zero time if the schedule doesn't fire in
+// the next five years.
+func (s *schedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,54 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"launchpad.net/gocheck"
+	"time"
+)
+
+func (s *S) TestScheduleNext(c *gocheck.C) {
+	base := time.Date(2013, time.December, 5, 10, 30, 15, 0, time.UTC) // a Thursday
+	var tests = []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2013, time.December, 5, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2013, time.December, 5, 10, 45, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2013, time.December, 6, 10, 30, 0, 0, time.UTC)},
+		{"0 8-18/2 * * *", time.Date(2013, time.December, 5, 12, 0, 0, 0, time.UTC)},
+		{"0 3 * * 1-5", time.Date(2013, time.December, 6, 3, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2013, time.December, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2013, time.December, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2013, time.December, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2016, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2013, time.December, 5, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2014, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, t := range tests {
+		s, err := parseSchedule(t.expr)
+		c.Assert(err, gocheck.IsNil)
+		c.Check(s.next(base), gocheck.DeepEquals, t.expected, gocheck.Commentf("%q", t.expr))
+	}
+}
+
+func (s *S) TestParseScheduleInvalid(c *gocheck.C) {
+	var tests = []struct {
+		expr string
+		msg  string
+	}{
+		{"* * * *", `Invalid schedule "\* \* \* \*": it must have five fields.*`},
+		{"60 * * * *", `Invalid schedule "60 \* \* \* \*": "60" is out of the range 0-59.`},
+		{"* * * 0 *", `Invalid schedule .*: "0" is out of the range 1-12.`},
+		{"5-1 * * * *", `Invalid schedule .*: "5-1" is out of the range 0-59.`},
+		{"*/0 * * * *", `Invalid schedule .*: invalid step in "\*/0".`},
+		{"a * * * *", `Invalid schedule .*: invalid value in "a".`},
+		{"0 0 30 2 *", `Invalid schedule "0 0 30 2 \*": it never fires.`},
+	}
+	for _, t := range tests {
+		_, err := parseSchedule(t.expr)
+		c.Check(err, gocheck.ErrorMatches, t.msg)
+	}
+}
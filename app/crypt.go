@@ -0,0 +1,124 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	stderr "errors"
+	"fmt"
+	"github.com/globocom/config"
+	"io"
+	"strings"
+)
+
+// encryptedPrefix marks the values encrypted by tsuru. It's followed by the
+// id of the key used in the encryption, a colon and the encrypted value, in
+// base64.
+const encryptedPrefix = "tsuru-encrypted:"
+
+var ErrNoEncryptionKey = stderr.New("The encryption key is not configured (encryption:key).")
+
+// secretKey is a key used to encrypt secrets stored in the database. The id
+// identifies the key in the encrypted values, so they can be decrypted after
+// the key is rotated.
+type secretKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func newSecretKey(secret string) (*secretKey, error) {
+	sum := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	id := sha256.Sum256(sum[:])
+	return &secretKey{id: hex.EncodeToString(id[:4]), aead: aead}, nil
+}
+
+// secretKeys returns the key that encrypts secrets, from encryption:key, and
+// all keys that decrypt them, including the previous keys, from
+// encryption:previous-keys. The current key is nil when encryption is not
+// configured.
+func secretKeys() (*secretKey, map[string]*secretKey, error) {
+	keys := make(map[string]*secretKey)
+	var current *secretKey
+	if secret, err := config.GetString("encryption:key"); err == nil && secret != "" {
+		if current, err = newSecretKey(secret); err != nil {
+			return nil, nil, err
+		}
+		keys[current.id] = current
+	}
+	previous, _ := config.GetList("encryption:previous-keys")
+	for _, secret := range previous {
+		key, err := newSecretKey(secret)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys[key.id] = key
+	}
+	return current, keys, nil
+}
+
+func (k *secretKey) encrypt(value string) (string, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := k.aead.Seal(nonce, nonce, []byte(value), []byte(k.id))
+	return encryptedPrefix + k.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// encryptSecret encrypts the value with the current key. It returns
+// ErrNoEncryptionKey when encryption is not configured.
+func encryptSecret(value string) (string, error) {
+	current, _, err := secretKeys()
+	if err != nil {
+		return "", err
+	}
+	if current == nil {
+		return "", ErrNoEncryptionKey
+	}
+	return current.encrypt(value)
+}
+
+// decryptSecret decrypts a value encrypted by encryptSecret, with the key
+// that encrypted it.
+func decryptSecret(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return "", stderr.New("Malformed encrypted value.")
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, encryptedPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", stderr.New("Malformed encrypted value.")
+	}
+	_, keys, err := secretKeys()
+	if err != nil {
+		return "", err
+	}
+	key, ok := keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("Unknown encryption key %q, add it to encryption:previous-keys.", parts[0])
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(sealed) < key.aead.NonceSize() {
+		return "", stderr.New("Malformed encrypted value.")
+	}
+	nonce := sealed[:key.aead.NonceSize()]
+	plain, err := key.aead.Open(nil, nonce, sealed[len(nonce):], []byte(key.id))
+	if err != nil {
+		return "", fmt.Errorf("Failed to decrypt value with the key %q: %s", key.id, err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,36 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/globocom/config"
+	"launchpad.net/gocheck"
+	"strings"
+)
+
+func (s *S) TestEncryptSecretWithoutKey(c *gocheck.C) {
+	_, err := encryptSecret("s3cr3t")
+	c.Assert(err, gocheck.Equals, ErrNoEncryptionKey)
+}
+
+func (s *S) TestEncryptAndDecryptSecret(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	value, err := encryptSecret("s3cr3t")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(strings.HasPrefix(value, encryptedPrefix), gocheck.Equals, true)
+	c.Assert(strings.Contains(value, "s3cr3t"), gocheck.Equals, false)
+	plain, err := decryptSecret(value)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(plain, gocheck.Equals, "s3cr3t")
+}
+
+func (s *S) TestDecryptSecretNotEncrypted(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	_, err := decryptSecret("s3cr3t")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Malformed encrypted value.")
+}
@@ -0,0 +1,144 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	stderr "errors"
+	"github.com/globocom/tsuru/app/bind"
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"labix.org/v2/mgo/bson"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrDependencyExists   = stderr.New("The app already depends on this app.")
+	ErrDependencyNotFound = stderr.New("The app does not depend on this app.")
+
+	envNameRegexp = regexp.MustCompile(`[^A-Z0-9]`)
+)
+
+// InternalURLEnvName returns the name of the environment variable that holds
+// the internal URL of the given app in the apps that depend on it. The
+// variable holding the list of all internal URLs has the same name, with the
+// suffix "S".
+//
+// For example, the internal URL of the app "my-api" is available in the
+// variable MY_API_INTERNAL_URL.
+func InternalURLEnvName(appName string) string {
+	return envNameRegexp.ReplaceAllString(strings.ToUpper(appName), "_") + "_INTERNAL_URL"
+}
+
+// internalEnvs returns the environment variables that publish the internal
+// addresses of the units of the given app.
+func internalEnvs(dep *App) ([]bind.EnvVar, error) {
+	addresser, ok := Provisioner.(provision.InternalAddresser)
+	if !ok {
+		return nil, stderr.New("The provisioner in use does not support internal addresses.")
+	}
+	addresses, err := addresser.InternalAddresses(dep)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(addresses)
+	var first string
+	if len(addresses) > 0 {
+		first = addresses[0]
+	}
+	name := InternalURLEnvName(dep.Name)
+	return []bind.EnvVar{
+		{Name: name, Value: first, Public: false},
+		{Name: name + "S", Value: strings.Join(addresses, ","), Public: false},
+	}, nil
+}
+
+// AddDependency declares that the app depends on the given app, injecting
+// the internal addresses of the dependency in the environment of the app.
+// The variables are kept up to date whenever units of the dependency are
+// added or removed.
+func (app *App) AddDependency(dep *App) error {
+	if app.Name == dep.Name {
+		return &errors.ValidationError{Message: "An app cannot depend on itself."}
+	}
+	for _, name := range app.Dependencies {
+		if name == dep.Name {
+			return ErrDependencyExists
+		}
+	}
+	envs, err := internalEnvs(dep)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$addToSet": bson.M{"dependencies": dep.Name}})
+	if err != nil {
+		return err
+	}
+	app.Dependencies = append(app.Dependencies, dep.Name)
+	return app.setEnvsToApp(envs, false, true)
+}
+
+// RemoveDependency removes the given app from the list of dependencies of
+// the app, unsetting the variables that hold its internal addresses.
+func (app *App) RemoveDependency(depName string) error {
+	index := -1
+	for i, name := range app.Dependencies {
+		if name == depName {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return ErrDependencyNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$pull": bson.M{"dependencies": depName}})
+	if err != nil {
+		return err
+	}
+	app.Dependencies = append(app.Dependencies[:index], app.Dependencies[index+1:]...)
+	name := InternalURLEnvName(depName)
+	return app.UnsetEnvs([]string{name, name + "S"}, false)
+}
+
+// updateDependents refreshes the internal addresses of the given app in the
+// environment of all apps that depend on it.
+func updateDependents(appName string) error {
+	dep := App{Name: appName}
+	if err := dep.Get(); err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var dependents []App
+	err = conn.Apps().Find(bson.M{"dependencies": appName}).All(&dependents)
+	if err != nil || len(dependents) == 0 {
+		return err
+	}
+	envs, err := internalEnvs(&dep)
+	if err != nil {
+		return err
+	}
+	for _, a := range dependents {
+		if err := a.setEnvsToApp(envs, false, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
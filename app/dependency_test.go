@@ -0,0 +1,165 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/globocom/tsuru/app/bind"
+	tsuruErrors "github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/log/testing"
+	"github.com/globocom/tsuru/queue"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+)
+
+func (s *S) TestInternalURLEnvName(c *gocheck.C) {
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"myapi", "MYAPI_INTERNAL_URL"},
+		{"my-api", "MY_API_INTERNAL_URL"},
+		{"my.api2", "MY_API2_INTERNAL_URL"},
+	}
+	for _, t := range tests {
+		c.Check(InternalURLEnvName(t.input), gocheck.Equals, t.expected)
+	}
+}
+
+func (s *S) TestAddDependency(c *gocheck.C) {
+	a := App{Name: "frontend"}
+	dep := App{Name: "backend-api"}
+	err := s.conn.Apps().Insert(a, dep)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().RemoveAll(bson.M{"name": bson.M{"$in": []string{a.Name, dep.Name}}})
+	s.provisioner.Provision(&dep)
+	defer s.provisioner.Destroy(&dep)
+	err = a.AddDependency(&dep)
+	c.Assert(err, gocheck.IsNil)
+	defer func() {
+		msg, err := aqueue().Get(1e6)
+		c.Assert(err, gocheck.IsNil)
+		c.Assert(msg.Action, gocheck.Equals, regenerateApprc)
+		c.Assert(msg.Args, gocheck.DeepEquals, []string{a.Name})
+		msg.Delete()
+	}()
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Dependencies, gocheck.DeepEquals, []string{"backend-api"})
+	expected := map[string]bind.EnvVar{
+		"BACKEND_API_INTERNAL_URL": {
+			Name:   "BACKEND_API_INTERNAL_URL",
+			Value:  "http://10.10.10.1:8888",
+			Public: false,
+		},
+		"BACKEND_API_INTERNAL_URLS": {
+			Name:   "BACKEND_API_INTERNAL_URLS",
+			Value:  "http://10.10.10.1:8888",
+			Public: false,
+		},
+	}
+	c.Assert(a.Env, gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestAddDependencyOnItself(c *gocheck.C) {
+	a := App{Name: "frontend"}
+	err := a.AddDependency(&a)
+	c.Assert(err, gocheck.NotNil)
+	_, ok := err.(*tsuruErrors.ValidationError)
+	c.Assert(ok, gocheck.Equals, true)
+}
+
+func (s *S) TestAddDependencyExists(c *gocheck.C) {
+	a := App{Name: "frontend", Dependencies: []string{"backend-api"}}
+	err := a.AddDependency(&App{Name: "backend-api"})
+	c.Assert(err, gocheck.Equals, ErrDependencyExists)
+}
+
+func (s *S) TestAddDependencyNotProvisioned(c *gocheck.C) {
+	a := App{Name: "frontend"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	err = a.AddDependency(&App{Name: "backend-api"})
+	c.Assert(err, gocheck.NotNil)
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Dependencies, gocheck.HasLen, 0)
+}
+
+func (s *S) TestRemoveDependency(c *gocheck.C) {
+	a := App{
+		Name:         "frontend",
+		Dependencies: []string{"backend-api", "auth"},
+		Env: map[string]bind.EnvVar{
+			"BACKEND_API_INTERNAL_URL":  {Name: "BACKEND_API_INTERNAL_URL", Value: "http://10.10.10.1:8888"},
+			"BACKEND_API_INTERNAL_URLS": {Name: "BACKEND_API_INTERNAL_URLS", Value: "http://10.10.10.1:8888"},
+			"DATABASE_HOST":             {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	err = a.RemoveDependency("backend-api")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Dependencies, gocheck.DeepEquals, []string{"auth"})
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Dependencies, gocheck.DeepEquals, []string{"auth"})
+	expected := map[string]bind.EnvVar{
+		"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+	}
+	c.Assert(a.Env, gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestRemoveDependencyNotFound(c *gocheck.C) {
+	a := App{Name: "frontend", Dependencies: []string{"auth"}}
+	err := a.RemoveDependency("backend-api")
+	c.Assert(err, gocheck.Equals, ErrDependencyNotFound)
+}
+
+func (s *S) TestDeleteRemovesAppFromDependencies(c *gocheck.C) {
+	a := App{Name: "backend-api"}
+	dependent := App{Name: "frontend", Dependencies: []string{"backend-api", "auth"}}
+	err := s.conn.Apps().Insert(a, dependent)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": dependent.Name})
+	err = Delete(&a)
+	c.Assert(err, gocheck.IsNil)
+	err = dependent.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(dependent.Dependencies, gocheck.DeepEquals, []string{"auth"})
+}
+
+func (s *S) TestHandleUpdateDependentsMessage(c *gocheck.C) {
+	dep := App{Name: "backend-api"}
+	a := App{Name: "frontend", Dependencies: []string{"backend-api"}}
+	other := App{Name: "otherapp"}
+	err := s.conn.Apps().Insert(dep, a, other)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().RemoveAll(bson.M{"name": bson.M{"$in": []string{dep.Name, a.Name, other.Name}}})
+	s.provisioner.Provision(&dep)
+	defer s.provisioner.Destroy(&dep)
+	message := queue.Message{Action: UpdateDependents, Args: []string{dep.Name}}
+	handle(&message)
+	msg, err := aqueue().Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(msg.Action, gocheck.Equals, regenerateApprc)
+	c.Assert(msg.Args, gocheck.DeepEquals, []string{a.Name})
+	msg.Delete()
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Env["BACKEND_API_INTERNAL_URL"].Value, gocheck.Equals, "http://10.10.10.1:8888")
+	err = other.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(other.Env, gocheck.HasLen, 0)
+}
+
+func (s *S) TestHandleUpdateDependentsMessageUnknownApp(c *gocheck.C) {
+	message := queue.Message{Action: UpdateDependents, Args: []string{"unknown"}}
+	logger := testing.NewFakeLogger().(*testing.FakeLogger)
+	handle(&message)
+	expected := `Error handling "update-dependents" for the app "unknown": not found` + "\n"
+	c.Assert(logger.Buf.String(), gocheck.Equals, expected)
+}
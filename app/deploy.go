@@ -0,0 +1,358 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/action"
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/log"
+	"github.com/globocom/tsuru/provision"
+	"io"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDeployNotFound   = errors.New("Deploy not found.")
+	ErrDeployInProgress = errors.New("There is already a deploy of this app in progress.")
+	ErrNoDeployRunning  = errors.New("There is no deploy of this app in progress.")
+)
+
+// Possible values of the status of a deploy.
+const (
+	DeployRunning   = "running"
+	DeploySucceeded = "succeeded"
+	DeployFailed    = "failed"
+	DeployCanceled  = "canceled"
+)
+
+// Deploy is the record of a deploy of an app, holding its full output.
+type Deploy struct {
+	ID         bson.ObjectId `bson:"_id,omitempty" json:"Id"`
+	App        string
+	Timestamp  time.Time
+	End        time.Time `bson:",omitempty"`
+	Commit     string
+	User       string
+	Status     string
+	Error      string               `bson:",omitempty" json:",omitempty"`
+	Log        string               `bson:",omitempty" json:",omitempty"`
+	Lock       string               `bson:",omitempty" json:"-"`
+	Canceled   bool                 `bson:",omitempty" json:"-"`
+	CanceledBy string               `bson:",omitempty" json:",omitempty"`
+	AppConfig  *provision.AppConfig `bson:",omitempty" json:",omitempty"`
+
+	// PromotedFrom is the name of the app whose build was deployed, in
+	// deploys made with Promote.
+	PromotedFrom string `bson:",omitempty" json:",omitempty"`
+
+	// Build is the identifier of the build released by the deploy, given
+	// by provisioners that are a provision.Builder. It's the build deployed
+	// when the app is promoted.
+	Build string `bson:",omitempty" json:",omitempty"`
+}
+
+// Duration returns how long the deploy took, or has been running for.
+func (d *Deploy) Duration() time.Duration {
+	if d.End.IsZero() {
+		return time.Since(d.Timestamp)
+	}
+	return d.End.Sub(d.Timestamp)
+}
+
+// deployListFields selects the fields of deploys returned in listings,
+// leaving the logs and the app.yaml out.
+var deployListFields = bson.M{"log": 0, "appconfig": 0}
+
+func listDeploys(query bson.M) ([]Deploy, error) {
+	var list []Deploy
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.Deploys().Find(query).Select(deployListFields).Sort("-timestamp").All(&list); err != nil {
+		return []Deploy{}, err
+	}
+	return list, nil
+}
+
+// ListDeploys returns the deploys of the app, newest first, without their
+// logs.
+func (app *App) ListDeploys() ([]Deploy, error) {
+	return listDeploys(bson.M{"app": app.Name})
+}
+
+// ListDeploys returns the deploys of all apps, newest first, without their
+// logs.
+func ListDeploys() ([]Deploy, error) {
+	return listDeploys(nil)
+}
+
+// GetDeploy returns the deploy identified by the given id, including its log.
+func GetDeploy(id string) (*Deploy, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrDeployNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var d Deploy
+	if err := conn.Deploys().FindId(bson.ObjectIdHex(id)).One(&d); err != nil {
+		return nil, ErrDeployNotFound
+	}
+	return &d, nil
+}
+
+// deployLog collects the output of a deploy. Units may still be writing to
+// it after the deploy finishes, so writes are synchronized.
+type deployLog struct {
+	buf bytes.Buffer
+	mut sync.Mutex
+}
+
+func (l *deployLog) Write(data []byte) (int, error) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return l.buf.Write(data)
+}
+
+func (l *deployLog) String() string {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return l.buf.String()
+}
+
+// lockDeploy records the deploy as running, holding the deploy lock of the
+// app. The lock is the "lock" field of the record, which has a unique index,
+// so only one deploy per app runs at a time, even across API servers.
+//
+// When the lock is taken, lockDeploy waits up to deploy:queue-timeout
+// seconds for it to be released, returning ErrDeployInProgress after that.
+// By default, concurrent deploys are rejected immediately.
+func lockDeploy(d *Deploy, writer io.Writer) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	timeout, _ := config.GetInt("deploy:queue-timeout")
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+	d.Lock = d.App
+	for waiting := false; ; waiting = true {
+		d.Timestamp = time.Now()
+		err = conn.Deploys().Insert(d)
+		if !mgo.IsDup(err) {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			return ErrDeployInProgress
+		}
+		if !waiting {
+			fmt.Fprint(writer, "\n ---> Waiting for the deploy in progress to finish\n")
+		}
+		time.Sleep(deployLockInterval)
+	}
+}
+
+// deployLockInterval is the interval between attempts to take the deploy
+// lock of an app.
+var deployLockInterval = time.Second
+
+// runDeploy runs the deploy pipeline with the given version, recording the
+// deploy with its status and full output.
+func runDeploy(app *App, version interface{}, commit, user string, writer io.Writer) error {
+	d := Deploy{
+		ID:     bson.NewObjectId(),
+		App:    app.Name,
+		Commit: commit,
+		User:   user,
+		Status: DeployRunning,
+	}
+	if p, ok := version.(promotion); ok {
+		d.PromotedFrom = p.source.Name
+	}
+	if err := lockDeploy(&d, writer); err != nil {
+		return err
+	}
+	pipeline := Provisioner.DeployPipeline()
+	if pipeline == nil {
+		actions := []*action.Action{&ProvisionerDeploy, &IncrementDeploy}
+		pipeline = action.NewPipeline(actions...)
+	}
+	var output deployLog
+	logWriter := LogWriter{App: app, Writer: io.MultiWriter(writer, &output)}
+	deployErr := pipeline.Execute(app, version, &logWriter)
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	update := bson.M{"end": time.Now(), "status": DeploySucceeded, "log": output.String()}
+	if deployErr != nil {
+		update["status"] = DeployFailed
+		update["error"] = deployErr.Error()
+		var current Deploy
+		if conn.Deploys().FindId(d.ID).One(&current) == nil && current.Canceled {
+			update["status"] = DeployCanceled
+		}
+	}
+	err = conn.Deploys().UpdateId(d.ID, bson.M{"$set": update, "$unset": bson.M{"lock": ""}})
+	if err != nil {
+		return err
+	}
+	return deployErr
+}
+
+// CancelDeploy cancels the deploy of the app in progress. The provisioner
+// interrupts the build, and the deploy fails, rolling back the steps of its
+// pipeline.
+//
+// A deploy that has already been canceled, but is still holding the lock,
+// is considered stuck (e.g. the API server running it died), and canceling
+// it again releases the lock.
+func CancelDeploy(app *App, user string) error {
+	canceler, ok := Provisioner.(provision.DeployCanceler)
+	if !ok {
+		return provision.ErrDeployCancelNotSupported
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var d Deploy
+	if err := conn.Deploys().Find(bson.M{"lock": app.Name}).One(&d); err != nil {
+		return ErrNoDeployRunning
+	}
+	if d.Canceled {
+		return conn.Deploys().UpdateId(d.ID, bson.M{
+			"$set":   bson.M{"end": time.Now(), "status": DeployCanceled},
+			"$unset": bson.M{"lock": ""},
+		})
+	}
+	err = conn.Deploys().UpdateId(d.ID, bson.M{"$set": bson.M{"canceled": true, "canceledby": user}})
+	if err != nil {
+		return err
+	}
+	return canceler.CancelDeploy(app)
+}
+
+// saveAppConfig stores the configuration of the app, read from its app.yaml
+// in the build, with the deploy of the app in progress.
+func saveAppConfig(app *App, config *provision.AppConfig) error {
+	return updateCurrentDeploy(app, bson.M{"appconfig": config})
+}
+
+// saveBuild stores the identifier of the build released by the deploy of the
+// app in progress.
+func saveBuild(app *App, build string) error {
+	return updateCurrentDeploy(app, bson.M{"build": build})
+}
+
+func updateCurrentDeploy(app *App, fields bson.M) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Deploys().Update(bson.M{"lock": app.Name}, bson.M{"$set": fields})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// lastDeploy returns the last successful deploy of the app, without its
+// log.
+func lastDeploy(appName string) (*Deploy, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var d Deploy
+	query := bson.M{"app": appName, "status": DeploySucceeded}
+	err = conn.Deploys().Find(query).Select(bson.M{"log": 0}).Sort("-timestamp").One(&d)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// lastAppConfig returns the configuration of the app stored with its last
+// successful deploy, or nil if the deploy has none.
+func lastAppConfig(appName string) (*provision.AppConfig, error) {
+	d, err := lastDeploy(appName)
+	if err != nil {
+		return nil, err
+	}
+	return d.AppConfig, nil
+}
+
+// buildAndRelease deploys the app with a provision.Builder. The app is built,
+// and then released with releaseBuild.
+func buildAndRelease(app *App, builder provision.Builder, opts provision.BuildOptions, w io.Writer) error {
+	config, err := builder.Build(app, opts, w)
+	if err != nil {
+		return err
+	}
+	return releaseBuild(app, builder, config, w)
+}
+
+// releaseBuild releases the last build of the app. The configuration read
+// from the app.yaml of the build is stored with the deploy. Then the
+// pre-deploy hooks run in the build, and a failure aborts the deploy, before
+// the units of the app are touched, removing the build. Finally, the build is
+// released, its identifier is stored with the deploy, and the post-deploy
+// hooks run.
+func releaseBuild(app *App, builder provision.Builder, config *provision.AppConfig, w io.Writer) error {
+	if err := saveAppConfig(app, config); err != nil {
+		return err
+	}
+	err := runDeployHooks(app, builder, "pre-deploy", config.Hooks.PreDeploy, w)
+	if err != nil {
+		fmt.Fprint(w, " ---> Deploy aborted, the units of the app were not changed\n")
+		if rmErr := builder.RemoveBuild(app); rmErr != nil {
+			log.Errorf("Failed to remove the build of the app %q: %s", app.Name, rmErr)
+		}
+		return err
+	}
+	build, err := builder.Release(app, w)
+	if err != nil {
+		return err
+	}
+	if err = saveBuild(app, build); err != nil {
+		return err
+	}
+	return runDeployHooks(app, builder, "post-deploy", config.Hooks.PostDeploy, w)
+}
+
+// DeployApp deploys the given version of the app, from its git repository,
+// on behalf of the given user.
+func DeployApp(app *App, version, user string, writer io.Writer) error {
+	return runDeploy(app, version, version, user, writer)
+}
+
+// incrementDeploy increments the number of deploys of the app.
+func incrementDeploy(app *App) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Apps().Update(
+		bson.M{"name": app.Name},
+		bson.M{"$inc": bson.M{"deploys": 1}},
+	)
+}
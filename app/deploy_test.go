@@ -0,0 +1,302 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"errors"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/testing"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"time"
+)
+
+func (s *S) TestDeployAppRecordsDeploy(c *gocheck.C) {
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = DeployApp(&a, "f1a2b3", "someone@tsuru.io", &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+	var d Deploy
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name}).One(&d)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(d.Commit, gocheck.Equals, "f1a2b3")
+	c.Assert(d.User, gocheck.Equals, "someone@tsuru.io")
+	c.Assert(d.Status, gocheck.Equals, DeploySucceeded)
+	c.Assert(d.Log, gocheck.Equals, "Deploy called")
+	c.Assert(d.Error, gocheck.Equals, "")
+	c.Assert(d.End.After(d.Timestamp) || d.End.Equal(d.Timestamp), gocheck.Equals, true)
+}
+
+func (s *S) TestDeployAppRecordsFailedDeploy(c *gocheck.C) {
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	s.provisioner.PrepareFailure("Deploy", errors.New("build failed"))
+	err = DeployApp(&a, "f1a2b3", "someone@tsuru.io", &bytes.Buffer{})
+	c.Assert(err, gocheck.NotNil)
+	var d Deploy
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name}).One(&d)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(d.Status, gocheck.Equals, DeployFailed)
+	c.Assert(d.Error, gocheck.Equals, "build failed")
+}
+
+func (s *S) TestDeployAppReleasesTheLock(c *gocheck.C) {
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = DeployApp(&a, "f1a2b3", "someone@tsuru.io", &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+	n, err := s.conn.Deploys().Find(bson.M{"lock": a.Name}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	err = DeployApp(&a, "f1a2b4", "someone@tsuru.io", &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+}
+
+func (s *S) TestDeployAppRejectsConcurrentDeploys(c *gocheck.C) {
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	running := Deploy{ID: bson.NewObjectId(), App: a.Name, Timestamp: time.Now(), Status: DeployRunning, Lock: a.Name}
+	err = s.conn.Deploys().Insert(running)
+	c.Assert(err, gocheck.IsNil)
+	err = DeployApp(&a, "f1a2b3", "someone@tsuru.io", &bytes.Buffer{})
+	c.Assert(err, gocheck.Equals, ErrDeployInProgress)
+	c.Assert(s.provisioner.Version(&a), gocheck.Equals, "")
+	n, err := s.conn.Deploys().Find(bson.M{"app": a.Name}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 1)
+}
+
+func (s *S) TestDeployAppWaitsForTheDeployInProgress(c *gocheck.C) {
+	config.Set("deploy:queue-timeout", 10)
+	defer config.Unset("deploy:queue-timeout")
+	old := deployLockInterval
+	deployLockInterval = 10 * time.Millisecond
+	defer func() { deployLockInterval = old }()
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	running := Deploy{ID: bson.NewObjectId(), App: a.Name, Timestamp: time.Now(), Status: DeployRunning, Lock: a.Name}
+	err = s.conn.Deploys().Insert(running)
+	c.Assert(err, gocheck.IsNil)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		s.conn.Deploys().UpdateId(running.ID, bson.M{"$unset": bson.M{"lock": ""}})
+	}()
+	var buf bytes.Buffer
+	err = DeployApp(&a, "f1a2b3", "someone@tsuru.io", &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Matches, "(?s).*Waiting for the deploy in progress to finish.*")
+	c.Assert(s.provisioner.Version(&a), gocheck.Equals, "f1a2b3")
+}
+
+func (s *S) TestCancelDeploy(c *gocheck.C) {
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	running := Deploy{ID: bson.NewObjectId(), App: a.Name, Timestamp: time.Now(), Status: DeployRunning, Lock: a.Name}
+	err := s.conn.Deploys().Insert(running)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Deploys().RemoveId(running.ID)
+	err = CancelDeploy(&a, "someone@tsuru.io")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(s.provisioner.Cancels(&a), gocheck.Equals, 1)
+	var d Deploy
+	err = s.conn.Deploys().FindId(running.ID).One(&d)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(d.Canceled, gocheck.Equals, true)
+	c.Assert(d.CanceledBy, gocheck.Equals, "someone@tsuru.io")
+	c.Assert(d.Lock, gocheck.Equals, a.Name)
+}
+
+func (s *S) TestCancelDeployTwiceReleasesTheLock(c *gocheck.C) {
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	running := Deploy{ID: bson.NewObjectId(), App: a.Name, Timestamp: time.Now(), Status: DeployRunning, Lock: a.Name}
+	err := s.conn.Deploys().Insert(running)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Deploys().RemoveId(running.ID)
+	err = CancelDeploy(&a, "someone@tsuru.io")
+	c.Assert(err, gocheck.IsNil)
+	err = CancelDeploy(&a, "someone@tsuru.io")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(s.provisioner.Cancels(&a), gocheck.Equals, 1)
+	var d Deploy
+	err = s.conn.Deploys().FindId(running.ID).One(&d)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(d.Lock, gocheck.Equals, "")
+	c.Assert(d.Status, gocheck.Equals, DeployCanceled)
+	err = CancelDeploy(&a, "someone@tsuru.io")
+	c.Assert(err, gocheck.Equals, ErrNoDeployRunning)
+}
+
+func (s *S) TestCancelDeployWithoutDeployInProgress(c *gocheck.C) {
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	err := CancelDeploy(&a, "someone@tsuru.io")
+	c.Assert(err, gocheck.Equals, ErrNoDeployRunning)
+}
+
+func (s *S) TestCancelDeployNotSupported(c *gocheck.C) {
+	old := Provisioner
+	Provisioner = struct{ provision.Provisioner }{s.provisioner}
+	defer func() { Provisioner = old }()
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	err := CancelDeploy(&a, "someone@tsuru.io")
+	c.Assert(err, gocheck.Equals, provision.ErrDeployCancelNotSupported)
+}
+
+func (s *S) TestDeployAppWithBuilder(c *gocheck.C) {
+	config := provision.AppConfig{
+		Hooks: provision.Hooks{
+			PreDeploy:  []string{"python manage.py migrate"},
+			PostDeploy: []string{"python manage.py clear-cache"},
+		},
+	}
+	p := testing.BuilderProvisioner{FakeProvisioner: s.provisioner, AppConfig: &config}
+	Provisioner = &p
+	defer func() { Provisioner = s.provisioner }()
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	var buf bytes.Buffer
+	err = DeployApp(&a, "f1a2b3", "someone@tsuru.io", &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(p.Builds(), gocheck.DeepEquals, []provision.BuildOptions{{Version: "f1a2b3"}})
+	steps := p.Steps()
+	c.Assert(steps, gocheck.HasLen, 4)
+	c.Assert(steps[0], gocheck.Equals, "build")
+	c.Assert(steps[1], gocheck.Matches, `^run .*source /home/application/apprc.*python manage.py migrate$`)
+	c.Assert(steps[2], gocheck.Equals, "release")
+	c.Assert(steps[3], gocheck.Matches, `^run .*source /home/application/apprc.*python manage.py clear-cache$`)
+	c.Assert(buf.String(), gocheck.Matches, `(?s).*Running pre-deploy hooks.*Running post-deploy hooks.*`)
+	var d Deploy
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name}).One(&d)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(d.Status, gocheck.Equals, DeploySucceeded)
+	c.Assert(d.AppConfig, gocheck.DeepEquals, &config)
+	c.Assert(d.Build, gocheck.Equals, "someApp-1")
+	stored, err := lastAppConfig(a.Name)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stored, gocheck.DeepEquals, &config)
+}
+
+func (s *S) TestDeployAppWithBuilderPreDeployFailure(c *gocheck.C) {
+	config := provision.AppConfig{
+		Hooks: provision.Hooks{
+			PreDeploy:  []string{"python manage.py migrate", "ls"},
+			PostDeploy: []string{"python manage.py clear-cache"},
+		},
+	}
+	p := testing.BuilderProvisioner{FakeProvisioner: s.provisioner, AppConfig: &config}
+	Provisioner = &p
+	defer func() { Provisioner = s.provisioner }()
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	s.provisioner.PrepareExitCode(1)
+	var buf bytes.Buffer
+	err = DeployApp(&a, "f1a2b3", "someone@tsuru.io", &buf)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, `The pre-deploy hook "python manage.py migrate" failed: exited with status 1.`)
+	steps := p.Steps()
+	c.Assert(steps, gocheck.HasLen, 3)
+	c.Assert(steps[2], gocheck.Equals, "remove build")
+	c.Assert(buf.String(), gocheck.Matches, `(?s).*The pre-deploy hook "python manage.py migrate" failed: exited with status 1.*Deploy aborted.*`)
+	var d Deploy
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name}).One(&d)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(d.Status, gocheck.Equals, DeployFailed)
+	c.Assert(d.AppConfig, gocheck.DeepEquals, &config)
+}
+
+func (s *S) TestDeployArchiveWithBuilder(c *gocheck.C) {
+	p := testing.BuilderProvisioner{FakeProvisioner: s.provisioner}
+	Provisioner = &p
+	defer func() { Provisioner = s.provisioner }()
+	a := App{Name: "someApp", Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = DeployArchive(&a, "https://s3.amazonaws.com/wat/someApp.tar.gz", "someone@tsuru.io", &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(p.Builds(), gocheck.DeepEquals, []provision.BuildOptions{{ArchiveURL: "https://s3.amazonaws.com/wat/someApp.tar.gz"}})
+	c.Assert(p.Steps(), gocheck.DeepEquals, []string{"build", "release"})
+}
+
+func (s *S) TestListDeploysOmitsLogs(c *gocheck.C) {
+	d := Deploy{ID: bson.NewObjectId(), App: "g1", Timestamp: time.Now(), Log: "a long output"}
+	err := s.conn.Deploys().Insert(d)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Deploys().RemoveId(d.ID)
+	deploys, err := (&App{Name: "g1"}).ListDeploys()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(deploys, gocheck.HasLen, 1)
+	c.Assert(deploys[0].ID, gocheck.Equals, d.ID)
+	c.Assert(deploys[0].Log, gocheck.Equals, "")
+}
+
+func (s *S) TestGetDeploy(c *gocheck.C) {
+	d := Deploy{ID: bson.NewObjectId(), App: "g1", Timestamp: time.Now(), Log: "a long output"}
+	err := s.conn.Deploys().Insert(d)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Deploys().RemoveId(d.ID)
+	got, err := GetDeploy(d.ID.Hex())
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got.App, gocheck.Equals, "g1")
+	c.Assert(got.Log, gocheck.Equals, "a long output")
+}
+
+func (s *S) TestGetDeployNotFound(c *gocheck.C) {
+	_, err := GetDeploy(bson.NewObjectId().Hex())
+	c.Assert(err, gocheck.Equals, ErrDeployNotFound)
+	_, err = GetDeploy("invalid")
+	c.Assert(err, gocheck.Equals, ErrDeployNotFound)
+}
+
+func (s *S) TestDeployDuration(c *gocheck.C) {
+	start := time.Now().Add(-time.Minute)
+	d := Deploy{Timestamp: start, End: start.Add(30 * time.Second)}
+	c.Assert(d.Duration(), gocheck.Equals, 30*time.Second)
+	d.End = time.Time{}
+	c.Assert(d.Duration() >= time.Minute, gocheck.Equals, true)
+}
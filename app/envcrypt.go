@@ -0,0 +1,164 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"github.com/globocom/tsuru/app/bind"
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/log"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"strings"
+)
+
+// encryptEnv encrypts the value of a private environment variable with the
+// current key, marking it as encrypted. Values are stored in plain text when
+// encryption is not configured. Whether the given value is already encrypted
+// is ignored, so it's always encrypted, even when it looks like an
+// encrypted value.
+func encryptEnv(env *bind.EnvVar) error {
+	env.Encrypted = false
+	if env.Public {
+		return nil
+	}
+	current, _, err := secretKeys()
+	if err != nil || current == nil {
+		return err
+	}
+	value, err := current.encrypt(env.Value)
+	if err != nil {
+		return err
+	}
+	env.Value = value
+	env.Encrypted = true
+	return nil
+}
+
+// decryptEnv returns the value of the environment variable, decrypted with
+// the key that encrypted it when the variable is marked as encrypted.
+func decryptEnv(env bind.EnvVar) (string, error) {
+	if !env.Encrypted {
+		return env.Value, nil
+	}
+	return decryptSecret(env.Value)
+}
+
+// envValue returns the value of the environment variable of the app,
+// decrypted. It's empty when the variable is not set or cannot be
+// decrypted.
+func (app *App) envValue(name string) string {
+	value, err := decryptEnv(app.Env[name])
+	if err != nil {
+		log.Errorf("Failed to decrypt the env %s of the app %q: %s", name, app.Name, err)
+	}
+	return value
+}
+
+// decryptedEnvs returns the environment variables of the app with their
+// values decrypted, to be delivered to its units. Variables that cannot be
+// decrypted are left out.
+func (app *App) decryptedEnvs() map[string]bind.EnvVar {
+	envs := make(map[string]bind.EnvVar, len(app.Env))
+	for name, env := range app.Env {
+		value, err := decryptEnv(env)
+		if err != nil {
+			log.Errorf("Failed to decrypt the env %s of the app %q: %s", name, app.Name, err)
+			continue
+		}
+		env.Value = value
+		env.Encrypted = false
+		envs[name] = env
+	}
+	return envs
+}
+
+// ReencryptEnvs encrypts the values of the private environment variables of
+// all apps and the keys of their certificates with the current key,
+// including the ones stored in plain text and the ones encrypted with
+// previous keys. It returns the number of values encrypted again, so the
+// previous keys can be removed from the configuration once all values use
+// the current key.
+func ReencryptEnvs() (int, error) {
+	current, _, err := secretKeys()
+	if err != nil {
+		return 0, err
+	}
+	if current == nil {
+		return 0, ErrNoEncryptionKey
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	var apps []App
+	err = conn.Apps().Find(nil).Select(bson.M{"name": 1, "env": 1}).All(&apps)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, app := range apps {
+		for name, env := range app.Env {
+			if env.Public || (env.Encrypted && strings.HasPrefix(env.Value, encryptedPrefix+current.id+":")) {
+				continue
+			}
+			value, err := decryptEnv(env)
+			if err != nil {
+				return n, fmt.Errorf("Failed to decrypt the env %s of the app %q: %s", name, app.Name, err)
+			}
+			if value, err = current.encrypt(value); err != nil {
+				return n, err
+			}
+			field := "env." + name
+			err = conn.Apps().Update(
+				bson.M{"name": app.Name, field + ".value": env.Value},
+				bson.M{"$set": bson.M{field + ".value": value, field + ".encrypted": true}},
+			)
+			if err == mgo.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	m, err := reencryptCertificateKeys(conn, current)
+	return n + m, err
+}
+
+func reencryptCertificateKeys(conn *db.Storage, current *secretKey) (int, error) {
+	var certs []Certificate
+	err := conn.Certificates().Find(nil).Select(bson.M{"_id": 1, "app": 1, "key": 1}).All(&certs)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, cert := range certs {
+		if strings.HasPrefix(cert.Key, encryptedPrefix+current.id+":") {
+			continue
+		}
+		key, err := cert.PrivateKey()
+		if err != nil {
+			return n, fmt.Errorf("Failed to decrypt the key of the certificate of %q: %s", cert.CName, err)
+		}
+		if key, err = current.encrypt(key); err != nil {
+			return n, err
+		}
+		err = conn.Certificates().Update(
+			bson.M{"_id": cert.CName, "key": cert.Key},
+			bson.M{"$set": bson.M{"key": key}},
+		)
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
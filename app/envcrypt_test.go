@@ -0,0 +1,207 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/app/bind"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"strings"
+)
+
+func (s *S) TestEncryptEnvWithoutKey(c *gocheck.C) {
+	env := bind.EnvVar{Name: "DATABASE_PASSWORD", Value: "s3cr3t"}
+	err := encryptEnv(&env)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(env.Value, gocheck.Equals, "s3cr3t")
+	c.Assert(env.Encrypted, gocheck.Equals, false)
+}
+
+func (s *S) TestEncryptAndDecryptEnv(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	env := bind.EnvVar{Name: "DATABASE_PASSWORD", Value: "s3cr3t"}
+	err := encryptEnv(&env)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(env.Encrypted, gocheck.Equals, true)
+	c.Assert(strings.HasPrefix(env.Value, encryptedPrefix), gocheck.Equals, true)
+	c.Assert(strings.Contains(env.Value, "s3cr3t"), gocheck.Equals, false)
+	other := bind.EnvVar{Name: "DATABASE_PASSWORD", Value: "s3cr3t"}
+	err = encryptEnv(&other)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(other.Value, gocheck.Not(gocheck.Equals), env.Value)
+	plain, err := decryptEnv(env)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(plain, gocheck.Equals, "s3cr3t")
+	plain, err = decryptEnv(bind.EnvVar{Name: "DATABASE_PASSWORD", Value: "stored before encryption"})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(plain, gocheck.Equals, "stored before encryption")
+}
+
+func (s *S) TestEncryptEnvPublic(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	env := bind.EnvVar{Name: "DEBUG", Value: "0", Public: true, Encrypted: true}
+	err := encryptEnv(&env)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(env.Value, gocheck.Equals, "0")
+	c.Assert(env.Encrypted, gocheck.Equals, false)
+}
+
+func (s *S) TestEncryptEnvValueThatLooksEncrypted(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	value := encryptedPrefix + "abcd1234:c2VjcmV0"
+	env := bind.EnvVar{Name: "TOKEN", Value: value, Encrypted: true}
+	err := encryptEnv(&env)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(env.Value, gocheck.Not(gocheck.Equals), value)
+	plain, err := decryptEnv(env)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(plain, gocheck.Equals, value)
+	plain, err = decryptEnv(bind.EnvVar{Name: "TOKEN", Value: value})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(plain, gocheck.Equals, value)
+}
+
+func (s *S) TestDecryptEnvAfterKeyRotation(c *gocheck.C) {
+	config.Set("encryption:key", "old-key")
+	defer config.Unset("encryption:key")
+	env := bind.EnvVar{Name: "DATABASE_PASSWORD", Value: "s3cr3t"}
+	err := encryptEnv(&env)
+	c.Assert(err, gocheck.IsNil)
+	config.Set("encryption:key", "new-key")
+	_, err = decryptEnv(env)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Matches, `Unknown encryption key ".*", add it to encryption:previous-keys.`)
+	config.Set("encryption:previous-keys", []string{"old-key"})
+	defer config.Unset("encryption:previous-keys")
+	plain, err := decryptEnv(env)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(plain, gocheck.Equals, "s3cr3t")
+}
+
+func (s *S) TestDecryptEnvTampered(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	env := bind.EnvVar{Name: "DATABASE_PASSWORD", Value: "s3cr3t"}
+	err := encryptEnv(&env)
+	c.Assert(err, gocheck.IsNil)
+	env.Value = env.Value[:len(env.Value)-4] + "AAA="
+	_, err = decryptEnv(env)
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestSetEnvsEncryptsPrivateValues(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	a := App{Name: "secretive"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	envs := []bind.EnvVar{
+		{Name: "DATABASE_PASSWORD", Value: "s3cr3t", InstanceName: "mysql"},
+		{Name: "DEBUG", Value: "0", Public: true},
+	}
+	err = a.SetEnvs(envs, false)
+	c.Assert(err, gocheck.IsNil)
+	var stored App
+	err = s.conn.Apps().Find(bson.M{"name": a.Name}).One(&stored)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stored.Env["DATABASE_PASSWORD"].Encrypted, gocheck.Equals, true)
+	c.Assert(strings.HasPrefix(stored.Env["DATABASE_PASSWORD"].Value, encryptedPrefix), gocheck.Equals, true)
+	c.Assert(stored.Env["DEBUG"].Value, gocheck.Equals, "0")
+	c.Assert(stored.Env["DEBUG"].Encrypted, gocheck.Equals, false)
+	delivered := stored.Envs()
+	c.Assert(delivered["DATABASE_PASSWORD"].Value, gocheck.Equals, "s3cr3t")
+	c.Assert(delivered["DEBUG"].Value, gocheck.Equals, "0")
+}
+
+func (s *S) TestSerializeEnvVarsDecryptsValues(c *gocheck.C) {
+	config.Set("encryption:key", "current-key")
+	defer config.Unset("encryption:key")
+	env := bind.EnvVar{Name: "DATABASE_PASSWORD", Value: "s3cr3t"}
+	err := encryptEnv(&env)
+	c.Assert(err, gocheck.IsNil)
+	a := App{
+		Name: "secretive",
+		Env: map[string]bind.EnvVar{
+			"DATABASE_PASSWORD": env,
+		},
+		Units: []Unit{{Name: "i-0800", State: "started"}},
+	}
+	err = a.SerializeEnvVars()
+	c.Assert(err, gocheck.IsNil)
+	cmds := s.provisioner.GetCmds("", &a)
+	c.Assert(cmds, gocheck.HasLen, 1)
+	c.Assert(cmds[0].Cmd, gocheck.Matches, `(?s).*export DATABASE_PASSWORD="s3cr3t".*`)
+}
+
+func (s *S) TestReencryptEnvs(c *gocheck.C) {
+	config.Set("encryption:key", "old-key")
+	defer config.Unset("encryption:key")
+	old := bind.EnvVar{Name: "OLD", Value: "old-secret"}
+	err := encryptEnv(&old)
+	c.Assert(err, gocheck.IsNil)
+	a := App{
+		Name: "secretive",
+		Env: map[string]bind.EnvVar{
+			"OLD":    old,
+			"PLAIN":  {Name: "PLAIN", Value: "plain-secret"},
+			"PUBLIC": {Name: "PUBLIC", Value: "0", Public: true},
+		},
+	}
+	err = s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	config.Set("encryption:key", "new-key")
+	config.Set("encryption:previous-keys", []string{"old-key"})
+	defer config.Unset("encryption:previous-keys")
+	n, err := ReencryptEnvs()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n >= 2, gocheck.Equals, true)
+	config.Unset("encryption:previous-keys")
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Env["PUBLIC"].Value, gocheck.Equals, "0")
+	c.Assert(a.envValue("OLD"), gocheck.Equals, "old-secret")
+	c.Assert(a.envValue("PLAIN"), gocheck.Equals, "plain-secret")
+	c.Assert(a.Env["PLAIN"].Value, gocheck.Not(gocheck.Equals), "plain-secret")
+	c.Assert(a.Env["PLAIN"].Encrypted, gocheck.Equals, true)
+	n, err = ReencryptEnvs()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestReencryptEnvsCertificateKeys(c *gocheck.C) {
+	config.Set("encryption:key", "old-key")
+	defer config.Unset("encryption:key")
+	old, err := encryptSecret("private-key")
+	c.Assert(err, gocheck.IsNil)
+	cert := Certificate{CName: "secretive.com", App: "secretive", Key: old}
+	err = s.conn.Certificates().Insert(cert)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Certificates().RemoveId(cert.CName)
+	config.Set("encryption:key", "new-key")
+	config.Set("encryption:previous-keys", []string{"old-key"})
+	defer config.Unset("encryption:previous-keys")
+	n, err := ReencryptEnvs()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n >= 1, gocheck.Equals, true)
+	config.Unset("encryption:previous-keys")
+	var stored Certificate
+	err = s.conn.Certificates().FindId(cert.CName).One(&stored)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stored.Key, gocheck.Not(gocheck.Equals), old)
+	key, err := stored.PrivateKey()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(key, gocheck.Equals, "private-key")
+}
+
+func (s *S) TestReencryptEnvsWithoutKey(c *gocheck.C) {
+	_, err := ReencryptEnvs()
+	c.Assert(err, gocheck.Equals, ErrNoEncryptionKey)
+}
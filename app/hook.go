@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"github.com/globocom/tsuru/provision"
 	"github.com/globocom/tsuru/repository"
 	"io"
 	"launchpad.net/goyaml"
@@ -21,16 +22,7 @@ type hookRunner interface {
 }
 
 type yamlHookRunner struct {
-	config *appConfig
-}
-
-type appConfig struct {
-	Restart hook
-}
-
-type hook struct {
-	Before []string
-	After  []string
+	config *provision.Hooks
 }
 
 func (r *yamlHookRunner) Restart(app *App, w io.Writer, kind string) error {
@@ -48,7 +40,12 @@ func (r *yamlHookRunner) Restart(app *App, w io.Writer, kind string) error {
 	if len(cmds) > 0 {
 		fmt.Fprintf(w, " ---> Running restart:%s\n\n", kind)
 		for _, cmd := range cmds {
-			err := app.sourced(cmd, w, true)
+			var err error
+			if r.config.Restart.Isolated {
+				err = app.sourcedIsolated(cmd, w)
+			} else {
+				err = app.sourced(cmd, w, true)
+			}
 			if err != nil {
 				return err
 			}
@@ -57,10 +54,17 @@ func (r *yamlHookRunner) Restart(app *App, w io.Writer, kind string) error {
 	return nil
 }
 
+// loadConfig loads the hooks of the app from the app.yaml stored with its
+// last successful deploy. Apps deployed before app.yaml files were stored
+// with deploys have it read from one of their units.
 func (r *yamlHookRunner) loadConfig(app *App) error {
 	if r.config != nil {
 		return nil
 	}
+	if config, err := lastAppConfig(app.Name); err == nil && config != nil {
+		r.config = &config.Hooks
+		return nil
+	}
 	repoPath, err := repository.GetPath()
 	if err != nil {
 		return err
@@ -75,13 +79,34 @@ func (r *yamlHookRunner) loadConfig(app *App) error {
 func (r *yamlHookRunner) loadConfigFromFile(app *App, filename string) error {
 	var buf bytes.Buffer
 	app.run("cat "+filename, &buf, true)
-	var m map[string]appConfig
+	var m map[string]provision.Hooks
 	goyaml.Unmarshal(buf.Bytes(), &m)
 	if _, ok := m["hooks"]; !ok {
-		r.config = &appConfig{}
+		r.config = &provision.Hooks{}
 		return errCannotLoadAppYAML
 	}
 	config := m["hooks"]
 	r.config = &config
 	return nil
 }
+
+// runDeployHooks runs the given deploy hooks, of the given kind, in one-off
+// units created from the build of the app, stopping in the first failure.
+func runDeployHooks(app *App, builder provision.Builder, kind string, hooks []string, w io.Writer) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "\n ---> Running %s hooks\n", kind)
+	for _, hook := range hooks {
+		fmt.Fprintf(w, " ---> Running %q\n", hook)
+		code, err := builder.ExecuteCommandInBuild(w, w, app, app.sourcedCmd(hook))
+		if err == nil && code != 0 {
+			err = fmt.Errorf("exited with status %d", code)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "\n ---> The %s hook %q failed: %s\n", kind, hook, err)
+			return fmt.Errorf("The %s hook %q failed: %s.", kind, hook, err)
+		}
+	}
+	return nil
+}
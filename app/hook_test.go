@@ -5,8 +5,11 @@ package app
 import (
 	"bytes"
 	"github.com/globocom/config"
+	"github.com/globocom/tsuru/provision"
 	"io"
+	"labix.org/v2/mgo/bson"
 	"launchpad.net/gocheck"
+	"time"
 )
 
 func (s *S) TestYAMLHookLoadConfig(c *gocheck.C) {
@@ -25,8 +28,8 @@ func (s *S) TestYAMLHookLoadConfig(c *gocheck.C) {
 	app := App{Name: "beside"}
 	err := runner.loadConfig(&app)
 	c.Assert(err, gocheck.IsNil)
-	expected := appConfig{
-		Restart: hook{
+	expected := provision.Hooks{
+		Restart: provision.RestartHooks{
 			Before: []string{
 				"python manage.py collectstatic",
 				"python manage.py migrate",
@@ -40,8 +43,8 @@ func (s *S) TestYAMLHookLoadConfig(c *gocheck.C) {
 }
 
 func (s *S) TestYAMLLoadConfigCaches(c *gocheck.C) {
-	config := appConfig{
-		Restart: hook{
+	config := provision.Hooks{
+		Restart: provision.RestartHooks{
 			Before: []string{
 				"python manage.py collectstatic",
 				"python manage.py migrate",
@@ -58,6 +61,26 @@ func (s *S) TestYAMLLoadConfigCaches(c *gocheck.C) {
 	c.Assert(s.provisioner.GetCmds("", &app), gocheck.HasLen, 0)
 }
 
+func (s *S) TestYAMLLoadConfigFromLastDeploy(c *gocheck.C) {
+	config := provision.AppConfig{
+		Hooks: provision.Hooks{
+			Restart: provision.RestartHooks{Before: []string{"python manage.py collectstatic"}},
+		},
+	}
+	old := Deploy{ID: bson.NewObjectId(), App: "beside", Timestamp: time.Now().Add(-time.Hour), Status: DeploySucceeded}
+	last := Deploy{ID: bson.NewObjectId(), App: "beside", Timestamp: time.Now(), Status: DeploySucceeded, AppConfig: &config}
+	failed := Deploy{ID: bson.NewObjectId(), App: "beside", Timestamp: time.Now().Add(time.Minute), Status: DeployFailed}
+	err := s.conn.Deploys().Insert(old, last, failed)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": "beside"})
+	var runner yamlHookRunner
+	app := App{Name: "beside"}
+	err = runner.loadConfig(&app)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(*runner.config, gocheck.DeepEquals, config.Hooks)
+	c.Assert(s.provisioner.GetCmds("", &app), gocheck.HasLen, 0)
+}
+
 func (s *S) TestYAMLLoadConfigAppYML(c *gocheck.C) {
 	s.provisioner.PrepareOutput([]byte("Not yam ::: l!"))
 	output := `hooks:
@@ -75,8 +98,8 @@ func (s *S) TestYAMLLoadConfigAppYML(c *gocheck.C) {
 	app := App{Name: "beside"}
 	err := runner.loadConfig(&app)
 	c.Assert(err, gocheck.IsNil)
-	expected := appConfig{
-		Restart: hook{
+	expected := provision.Hooks{
+		Restart: provision.RestartHooks{
 			Before: []string{
 				"python manage.py collectstatic",
 				"python manage.py migrate",
@@ -110,8 +133,8 @@ func (s *S) TestYAMLRunnerRestartBefore(c *gocheck.C) {
 	s.provisioner.PrepareOutput([]byte(". .."))
 	s.provisioner.PrepareOutput([]byte("nothing"))
 	runner := yamlHookRunner{
-		config: &appConfig{
-			Restart: hook{Before: []string{"ls -a", "cat /home/application/apprc"}},
+		config: &provision.Hooks{
+			Restart: provision.RestartHooks{Before: []string{"ls -a", "cat /home/application/apprc"}},
 		},
 	}
 	var buf bytes.Buffer
@@ -133,8 +156,8 @@ func (s *S) TestYAMLRunnerRestartAfter(c *gocheck.C) {
 	}
 	s.provisioner.PrepareOutput([]byte(". .."))
 	runner := yamlHookRunner{
-		config: &appConfig{
-			Restart: hook{After: []string{"ls -la"}},
+		config: &provision.Hooks{
+			Restart: provision.RestartHooks{After: []string{"ls -la"}},
 		},
 	}
 	var buf bytes.Buffer
@@ -146,6 +169,60 @@ func (s *S) TestYAMLRunnerRestartAfter(c *gocheck.C) {
 	c.Check(cmds[0].Cmd, gocheck.Matches, `.*source /home/application/apprc.*ls -la$`)
 }
 
+func (s *S) TestYAMLRunnerRestartBeforeIsolated(c *gocheck.C) {
+	app := App{Name: "kn"}
+	s.provisioner.Provision(&app)
+	defer s.provisioner.Destroy(&app)
+	defer s.conn.Logs().Remove(bson.M{"appname": app.Name})
+	s.provisioner.PrepareOutput([]byte("migrated"))
+	runner := yamlHookRunner{
+		config: &provision.Hooks{
+			Restart: provision.RestartHooks{Before: []string{"python manage.py migrate"}, Isolated: true},
+		},
+	}
+	var buf bytes.Buffer
+	err := runner.Restart(&app, &buf, "before")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Equals, " ---> Running restart:before\n\nmigrated")
+	c.Assert(s.provisioner.GetCmds("", &app), gocheck.HasLen, 0)
+	cmds := s.provisioner.IsolatedCmds(&app)
+	c.Assert(cmds, gocheck.HasLen, 1)
+	c.Check(cmds[0].Cmd, gocheck.Matches, `.*source /home/application/apprc.*python manage.py migrate$`)
+}
+
+func (s *S) TestYAMLRunnerRestartBeforeIsolatedFailure(c *gocheck.C) {
+	app := App{Name: "kn"}
+	s.provisioner.Provision(&app)
+	defer s.provisioner.Destroy(&app)
+	defer s.conn.Logs().Remove(bson.M{"appname": app.Name})
+	s.provisioner.PrepareExitCode(1)
+	runner := yamlHookRunner{
+		config: &provision.Hooks{
+			Restart: provision.RestartHooks{Before: []string{"python manage.py migrate", "ls"}, Isolated: true},
+		},
+	}
+	var buf bytes.Buffer
+	err := runner.Restart(&app, &buf, "before")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(s.provisioner.IsolatedCmds(&app), gocheck.HasLen, 1)
+}
+
+func (s *S) TestYAMLRunnerLoadsIsolatedConfig(c *gocheck.C) {
+	app := App{Name: "kn"}
+	yaml := `hooks:
+  restart:
+    before:
+      - python manage.py migrate
+    isolated: true
+`
+	s.provisioner.PrepareOutput([]byte(yaml))
+	var runner yamlHookRunner
+	err := runner.loadConfig(&app)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(runner.config.Restart.Isolated, gocheck.Equals, true)
+	c.Assert(runner.config.Restart.Before, gocheck.DeepEquals, []string{"python manage.py migrate"})
+}
+
 func (s *S) TestYAMLRunnerLoadsConfig(c *gocheck.C) {
 	app := App{Name: "kn"}
 	yaml := `hooks:
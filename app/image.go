@@ -0,0 +1,19 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import "io"
+
+// image is the version deployed by DeployImage. It's given to the deploy
+// pipeline in place of the git version of the app.
+type image struct {
+	name string
+}
+
+// DeployImage deploys the app from the given prebuilt image, running the
+// same pipeline used by DeployApp, without building the app.
+func DeployImage(app *App, imageName, user string, writer io.Writer) error {
+	return runDeploy(app, image{name: imageName}, "", user, writer)
+}
@@ -0,0 +1,48 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"github.com/globocom/tsuru/action"
+	"github.com/globocom/tsuru/provision"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+)
+
+func (s *S) TestDeployImage(c *gocheck.C) {
+	a := App{
+		Name:     "someApp",
+		Platform: "django",
+		Teams:    []string{s.team.Name},
+		Units:    []Unit{{Name: "i-0800", State: "started"}},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	writer := &bytes.Buffer{}
+	err = DeployImage(&a, "registry.example.com/someapp:1.0", "someone@tsuru.io", writer)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(writer.String(), gocheck.Equals, "Image deploy called")
+	c.Assert(s.provisioner.DeployedImage(&a), gocheck.Equals, "registry.example.com/someapp:1.0")
+	c.Assert(s.provisioner.Version(&a), gocheck.Equals, "")
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Deploys, gocheck.Equals, uint(1))
+}
+
+func (s *S) TestProvisionerDeployForwardImageNotSupported(c *gocheck.C) {
+	Provisioner = struct{ provision.Provisioner }{s.provisioner}
+	defer func() {
+		Provisioner = s.provisioner
+	}()
+	a := App{Name: "someApp"}
+	ctx := action.FWContext{Params: []interface{}{&a, image{name: "someapp:1.0"}, &bytes.Buffer{}}}
+	_, err := ProvisionerDeploy.Forward(ctx)
+	c.Assert(err, gocheck.Equals, provision.ErrImageDeployNotSupported)
+}
@@ -0,0 +1,274 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	stderr "errors"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/log"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"strings"
+	"time"
+)
+
+var ErrJobNotFound = stderr.New("Job not found.")
+
+// Possible values of the status of a job run.
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job is a command that runs periodically in one unit of an app, following
+// a cron schedule.
+type Job struct {
+	ID       bson.ObjectId `bson:"_id,omitempty" json:"Id"`
+	App      string
+	Schedule string
+	Command  string
+	User     string
+	NextRun  time.Time
+}
+
+// JobRun is the record of a run of a job, holding its output.
+type JobRun struct {
+	ID        bson.ObjectId `bson:"_id,omitempty" json:"Id"`
+	Job       bson.ObjectId
+	App       string
+	Command   string
+	Timestamp time.Time
+	End       time.Time `bson:",omitempty"`
+	Status    string
+	Output    string
+	Error     string `bson:",omitempty" json:",omitempty"`
+}
+
+// AddJob schedules the command to run in one unit of the app, following the
+// given cron expression, on behalf of the given user.
+func AddJob(app *App, expr, command, user string) (*Job, error) {
+	if strings.TrimSpace(command) == "" {
+		return nil, &errors.ValidationError{Message: "You must provide the command of the job."}
+	}
+	s, err := parseSchedule(expr)
+	if err != nil {
+		return nil, &errors.ValidationError{Message: err.Error()}
+	}
+	job := Job{
+		ID:       bson.NewObjectId(),
+		App:      app.Name,
+		Schedule: expr,
+		Command:  command,
+		User:     user,
+		NextRun:  s.next(time.Now()),
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err = conn.Jobs().Insert(job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs returns the jobs of the app.
+func (app *App) ListJobs() ([]Job, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	jobs := []Job{}
+	err = conn.Jobs().Find(bson.M{"app": app.Name}).Sort("_id").All(&jobs)
+	return jobs, err
+}
+
+// GetJob returns the job of the app identified by the given id.
+func (app *App) GetJob(id string) (*Job, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrJobNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var job Job
+	err = conn.Jobs().Find(bson.M{"_id": bson.ObjectIdHex(id), "app": app.Name}).One(&job)
+	if err != nil {
+		return nil, ErrJobNotFound
+	}
+	return &job, nil
+}
+
+// RemoveJob removes the job of the app identified by the given id, along
+// with the history of its runs.
+func (app *App) RemoveJob(id string) error {
+	job, err := app.GetJob(id)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err = conn.Jobs().RemoveId(job.ID); err != nil {
+		return err
+	}
+	_, err = conn.JobRuns().RemoveAll(bson.M{"job": job.ID})
+	return err
+}
+
+// Runs returns the runs of the job kept in its history, newest first.
+func (j *Job) Runs() ([]JobRun, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	runs := []JobRun{}
+	err = conn.JobRuns().Find(bson.M{"job": j.ID}).Sort("-timestamp").All(&runs)
+	return runs, err
+}
+
+// claim moves the next run of the job to the next time its schedule fires
+// after now, returning whether the current run was claimed. The update only
+// matches while the next run is unchanged, so when many API servers find the
+// job due, only one of them claims it.
+func (j *Job) claim(now time.Time) (bool, error) {
+	s, err := parseSchedule(j.Schedule)
+	if err != nil {
+		return false, err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	next := s.next(now)
+	err = conn.Jobs().Update(
+		bson.M{"_id": j.ID, "nextrun": j.NextRun},
+		bson.M{"$set": bson.M{"nextrun": next}},
+	)
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	j.NextRun = next
+	return true, nil
+}
+
+// run runs the command of the job in one unit of its app, recording the run
+// in the history of the job.
+func (j *Job) run() (*JobRun, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	r := JobRun{
+		ID:        bson.NewObjectId(),
+		Job:       j.ID,
+		App:       j.App,
+		Command:   j.Command,
+		Timestamp: time.Now(),
+		Status:    JobRunning,
+	}
+	if err = conn.JobRuns().Insert(r); err != nil {
+		return nil, err
+	}
+	var output bytes.Buffer
+	app := App{Name: j.App}
+	if err = app.Get(); err == nil {
+		err = app.Run(j.Command, &output, true)
+	}
+	r.End = time.Now()
+	r.Status = JobSucceeded
+	r.Output = output.String()
+	if err != nil {
+		r.Status = JobFailed
+		r.Error = err.Error()
+	}
+	if err = conn.JobRuns().UpdateId(r.ID, r); err != nil {
+		return nil, err
+	}
+	return &r, j.pruneRuns()
+}
+
+// pruneRuns removes the oldest runs of the job from its history, keeping
+// the last jobs:history-size runs (20 by default).
+func (j *Job) pruneRuns() error {
+	size, err := config.GetInt("jobs:history-size")
+	if err != nil || size < 1 {
+		size = 20
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var oldest JobRun
+	query := bson.M{"job": j.ID}
+	err = conn.JobRuns().Find(query).Sort("-timestamp").Skip(size - 1).Select(bson.M{"timestamp": 1}).One(&oldest)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	query["timestamp"] = bson.M{"$lt": oldest.Timestamp}
+	_, err = conn.JobRuns().RemoveAll(query)
+	return err
+}
+
+// runDueJobs claims and runs, in background, the jobs whose next run is not
+// after now.
+func runDueJobs(now time.Time) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var jobs []Job
+	err = conn.Jobs().Find(bson.M{"nextrun": bson.M{"$lte": now}}).All(&jobs)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		claimed, err := job.claim(now)
+		if err != nil {
+			log.Errorf("Failed to claim the job %s of the app %q: %s", job.ID.Hex(), job.App, err)
+			continue
+		}
+		if claimed {
+			go func(job Job) {
+				if _, err := job.run(); err != nil {
+					log.Errorf("Failed to run the job %s of the app %q: %s", job.ID.Hex(), job.App, err)
+				}
+			}(job)
+		}
+	}
+	return nil
+}
+
+// RunJobScheduler runs the jobs that are due in each tick of the given
+// ticker. Every API server runs the scheduler, and each run of a job is
+// claimed by only one of them.
+func RunJobScheduler(ticker <-chan time.Time) {
+	for now := range ticker {
+		if err := runDueJobs(now); err != nil {
+			log.Errorf("Failed to run jobs: %s", err)
+		}
+	}
+}
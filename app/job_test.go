@@ -0,0 +1,197 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/errors"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"time"
+)
+
+func (s *S) TestAddJob(c *gocheck.C) {
+	a := App{Name: "myapp"}
+	job, err := AddJob(&a, "*/10 * * * *", "python manage.py report", "someone@tsuru.io")
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Jobs().RemoveId(job.ID)
+	c.Assert(job.App, gocheck.Equals, "myapp")
+	c.Assert(job.NextRun.After(time.Now()), gocheck.Equals, true)
+	c.Assert(job.NextRun.Minute()%10, gocheck.Equals, 0)
+	var stored Job
+	err = s.conn.Jobs().FindId(job.ID).One(&stored)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stored.Schedule, gocheck.Equals, "*/10 * * * *")
+	c.Assert(stored.Command, gocheck.Equals, "python manage.py report")
+	c.Assert(stored.User, gocheck.Equals, "someone@tsuru.io")
+}
+
+func (s *S) TestAddJobInvalidSchedule(c *gocheck.C) {
+	a := App{Name: "myapp"}
+	_, err := AddJob(&a, "* * *", "python manage.py report", "someone@tsuru.io")
+	c.Assert(err, gocheck.NotNil)
+	_, ok := err.(*errors.ValidationError)
+	c.Assert(ok, gocheck.Equals, true)
+	n, err := s.conn.Jobs().Find(bson.M{"app": "myapp"}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestAddJobWithoutCommand(c *gocheck.C) {
+	a := App{Name: "myapp"}
+	_, err := AddJob(&a, "@daily", " ", "someone@tsuru.io")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "You must provide the command of the job.")
+}
+
+func (s *S) TestListAndRemoveJobs(c *gocheck.C) {
+	a := App{Name: "myapp"}
+	job1, err := AddJob(&a, "@daily", "python manage.py report", "someone@tsuru.io")
+	c.Assert(err, gocheck.IsNil)
+	job2, err := AddJob(&a, "@hourly", "python manage.py warm", "someone@tsuru.io")
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Jobs().RemoveAll(bson.M{"app": "myapp"})
+	err = s.conn.JobRuns().Insert(JobRun{Job: job1.ID, App: "myapp", Timestamp: time.Now()})
+	c.Assert(err, gocheck.IsNil)
+	jobs, err := a.ListJobs()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(jobs, gocheck.HasLen, 2)
+	c.Assert(jobs[0].ID, gocheck.Equals, job1.ID)
+	c.Assert(jobs[1].ID, gocheck.Equals, job2.ID)
+	err = a.RemoveJob(job1.ID.Hex())
+	c.Assert(err, gocheck.IsNil)
+	jobs, err = a.ListJobs()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(jobs, gocheck.HasLen, 1)
+	n, err := s.conn.JobRuns().Find(bson.M{"job": job1.ID}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestGetJobFromOtherApp(c *gocheck.C) {
+	a := App{Name: "myapp"}
+	job, err := AddJob(&a, "@daily", "python manage.py report", "someone@tsuru.io")
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Jobs().RemoveId(job.ID)
+	other := App{Name: "otherapp"}
+	_, err = other.GetJob(job.ID.Hex())
+	c.Assert(err, gocheck.Equals, ErrJobNotFound)
+	err = other.RemoveJob(job.ID.Hex())
+	c.Assert(err, gocheck.Equals, ErrJobNotFound)
+	_, err = a.GetJob("not-an-id")
+	c.Assert(err, gocheck.Equals, ErrJobNotFound)
+}
+
+func (s *S) TestJobClaim(c *gocheck.C) {
+	now := time.Now()
+	job := Job{ID: bson.NewObjectId(), App: "myapp", Schedule: "@hourly", NextRun: now.Truncate(time.Minute)}
+	err := s.conn.Jobs().Insert(job)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Jobs().RemoveId(job.ID)
+	other := job
+	claimed, err := job.claim(now)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(claimed, gocheck.Equals, true)
+	c.Assert(job.NextRun.After(now), gocheck.Equals, true)
+	claimed, err = other.claim(now)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(claimed, gocheck.Equals, false)
+}
+
+func (s *S) TestJobRun(c *gocheck.C) {
+	a := App{
+		Name:  "myapp",
+		Units: []Unit{{Name: "i-0800", State: "started"}},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	job := Job{ID: bson.NewObjectId(), App: a.Name, Command: "python manage.py report"}
+	defer s.conn.JobRuns().RemoveAll(bson.M{"job": job.ID})
+	s.provisioner.PrepareOutput([]byte("report sent"))
+	r, err := job.run()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.Status, gocheck.Equals, JobSucceeded)
+	c.Assert(r.Output, gocheck.Equals, "report sent")
+	runs, err := job.Runs()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(runs, gocheck.HasLen, 1)
+	c.Assert(runs[0].Status, gocheck.Equals, JobSucceeded)
+	c.Assert(runs[0].Output, gocheck.Equals, "report sent")
+	c.Assert(runs[0].End.IsZero(), gocheck.Equals, false)
+	cmds := s.provisioner.GetCmds("", &a)
+	c.Assert(cmds, gocheck.HasLen, 1)
+	c.Assert(cmds[0].Cmd, gocheck.Matches, ".*python manage.py report$")
+}
+
+func (s *S) TestJobRunFailure(c *gocheck.C) {
+	job := Job{ID: bson.NewObjectId(), App: "unknown", Command: "python manage.py report"}
+	defer s.conn.JobRuns().RemoveAll(bson.M{"job": job.ID})
+	r, err := job.run()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.Status, gocheck.Equals, JobFailed)
+	c.Assert(r.Error, gocheck.Not(gocheck.Equals), "")
+}
+
+func (s *S) TestJobRunPrunesHistory(c *gocheck.C) {
+	config.Set("jobs:history-size", 2)
+	defer config.Unset("jobs:history-size")
+	job := Job{ID: bson.NewObjectId(), App: "unknown", Command: "ls"}
+	defer s.conn.JobRuns().RemoveAll(bson.M{"job": job.ID})
+	for i := 3; i > 0; i-- {
+		old := JobRun{Job: job.ID, Timestamp: time.Now().Add(-time.Duration(i) * time.Hour)}
+		err := s.conn.JobRuns().Insert(old)
+		c.Assert(err, gocheck.IsNil)
+	}
+	r, err := job.run()
+	c.Assert(err, gocheck.IsNil)
+	runs, err := job.Runs()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(runs, gocheck.HasLen, 2)
+	c.Assert(runs[0].ID, gocheck.Equals, r.ID)
+}
+
+func (s *S) TestRunDueJobs(c *gocheck.C) {
+	now := time.Now()
+	due := Job{ID: bson.NewObjectId(), App: "unknown", Schedule: "* * * * *", Command: "ls", NextRun: now.Add(-time.Minute)}
+	notDue := Job{ID: bson.NewObjectId(), App: "unknown", Schedule: "* * * * *", Command: "ls", NextRun: now.Add(time.Minute)}
+	err := s.conn.Jobs().Insert(due, notDue)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Jobs().RemoveAll(bson.M{"app": "unknown"})
+	defer s.conn.JobRuns().RemoveAll(bson.M{"app": "unknown"})
+	err = runDueJobs(now)
+	c.Assert(err, gocheck.IsNil)
+	var runs []JobRun
+	for i := 0; i < 100 && len(runs) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		s.conn.JobRuns().Find(bson.M{"job": due.ID}).All(&runs)
+	}
+	c.Assert(runs, gocheck.HasLen, 1)
+	n, err := s.conn.JobRuns().Find(bson.M{"job": notDue.ID}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	var stored Job
+	err = s.conn.Jobs().FindId(due.ID).One(&stored)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stored.NextRun.After(now), gocheck.Equals, true)
+}
+
+func (s *S) TestDeleteRemovesJobs(c *gocheck.C) {
+	a := App{Name: "myapp"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	job, err := AddJob(&a, "@daily", "ls", "someone@tsuru.io")
+	c.Assert(err, gocheck.IsNil)
+	err = s.conn.JobRuns().Insert(JobRun{Job: job.ID, App: a.Name, Timestamp: time.Now()})
+	c.Assert(err, gocheck.IsNil)
+	err = Delete(&a)
+	c.Assert(err, gocheck.IsNil)
+	n, err := s.conn.Jobs().Find(bson.M{"app": a.Name}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	n, err = s.conn.JobRuns().Find(bson.M{"app": a.Name}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
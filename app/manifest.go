@@ -0,0 +1,165 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"github.com/globocom/tsuru/action"
+	"github.com/globocom/tsuru/app/bind"
+	"github.com/globocom/tsuru/auth"
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/service"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/goyaml"
+	"strings"
+)
+
+// Manifest declares an app along with its units, environment variables,
+// cnames and service instances, so the app can be created in one step. The
+// manifest of an existing app is exported by the Manifest method.
+type Manifest struct {
+	Name     string            `yaml:",omitempty"`
+	Platform string            `yaml:",omitempty"`
+	Units    uint              `yaml:",omitempty"`
+	Env      map[string]string `yaml:",omitempty"`
+	CNames   []string          `yaml:"cnames,omitempty"`
+	Services []ManifestService `yaml:",omitempty"`
+}
+
+// ManifestService is a service instance declared in a manifest. When the
+// service is set and the instance does not exist, the instance is created
+// before it is bound to the app.
+type ManifestService struct {
+	Instance string
+	Service  string `yaml:",omitempty"`
+}
+
+// manifestInstance is a service instance declared in a manifest, resolved
+// before the app is created.
+type manifestInstance struct {
+	instance *service.ServiceInstance
+	service  *service.Service
+}
+
+// ParseManifest parses the content of a manifest file.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := goyaml.Unmarshal(data, &m); err != nil {
+		return nil, &errors.ValidationError{Message: fmt.Sprintf("Invalid manifest: %s", err)}
+	}
+	return &m, nil
+}
+
+// CreateAppFromManifest creates the app declared in the manifest, on behalf
+// of the given user.
+//
+// Besides creating the app, it sets its environment variables, adds its
+// cnames, adds its units, creates the service instances that do not exist
+// yet and binds the service instances to the app. All steps run in one
+// pipeline: if any of them fails, the previous ones are rolled back, and the
+// app is removed.
+func CreateAppFromManifest(m *Manifest, user *auth.User) (*App, error) {
+	instances, err := m.resolveServices(user)
+	if err != nil {
+		return nil, err
+	}
+	app := App{Name: m.Name, Platform: m.Platform}
+	pipeline := action.NewPipeline(
+		&createManifestApp,
+		&setManifestEnvs,
+		&addManifestCNames,
+		&addManifestUnits,
+		&createManifestServiceInstances,
+		&bindManifestServiceInstances,
+	)
+	if err = pipeline.Execute(&app, m, user, instances); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// resolveServices finds the service instances declared in the manifest,
+// checking that the user has access to them, and the services of the
+// instances that must be created.
+func (m *Manifest) resolveServices(user *auth.User) ([]manifestInstance, error) {
+	instances := make([]manifestInstance, len(m.Services))
+	for i, s := range m.Services {
+		if s.Instance == "" {
+			return nil, &errors.ValidationError{Message: "You must provide the name of each service instance in the manifest."}
+		}
+		si, err := service.GetServiceInstance(s.Instance, user)
+		if err == nil {
+			if s.Service != "" && si.ServiceName != s.Service {
+				msg := fmt.Sprintf("The service instance %q is an instance of %q, not %q.", s.Instance, si.ServiceName, s.Service)
+				return nil, &errors.ValidationError{Message: msg}
+			}
+			instances[i].instance = si
+			continue
+		}
+		if err != service.ErrServiceInstanceNotFound {
+			return nil, err
+		}
+		if s.Service == "" {
+			msg := fmt.Sprintf("The service instance %q does not exist. Declare its service to create it.", s.Instance)
+			return nil, &errors.ValidationError{Message: msg}
+		}
+		srv := service.Service{Name: s.Service}
+		if err = srv.Get(); err != nil {
+			return nil, &errors.ValidationError{Message: fmt.Sprintf("The service %q does not exist.", s.Service)}
+		}
+		if srv.IsRestricted && !auth.CheckUserAccess(srv.Teams, user) {
+			return nil, &errors.ValidationError{Message: fmt.Sprintf("You do not have access to the service %q.", s.Service)}
+		}
+		instances[i].instance = &service.ServiceInstance{Name: s.Instance, ServiceName: s.Service}
+		instances[i].service = &srv
+	}
+	return instances, nil
+}
+
+// envs returns the environment variables declared in the manifest, which
+// are public.
+func (m *Manifest) envs() []bind.EnvVar {
+	envs := make([]bind.EnvVar, 0, len(m.Env))
+	for name, value := range m.Env {
+		envs = append(envs, bind.EnvVar{Name: name, Value: value, Public: true})
+	}
+	return envs
+}
+
+// Manifest exports the manifest of the app, that creates a similar app
+// when given to CreateAppFromManifest. Private environment variables, like
+// the ones set by tsuru and by service instances, are not exported.
+func (app *App) Manifest() (*Manifest, error) {
+	m := Manifest{
+		Name:     app.Name,
+		Platform: app.Platform,
+		Units:    uint(len(app.Units)),
+		CNames:   app.CName,
+	}
+	for name, env := range app.Env {
+		if !env.Public || env.InstanceName != "" || strings.HasPrefix(name, "TSURU_") {
+			continue
+		}
+		if m.Env == nil {
+			m.Env = make(map[string]string)
+		}
+		m.Env[name] = env.Value
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var instances []service.ServiceInstance
+	err = conn.ServiceInstances().Find(bson.M{"apps": app.Name}).Sort("name").All(&instances)
+	if err != nil {
+		return nil, err
+	}
+	for _, si := range instances {
+		m.Services = append(m.Services, ManifestService{Instance: si.Name, Service: si.ServiceName})
+	}
+	return &m, nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/app/bind"
+	tsuruErrors "github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/service"
+	"github.com/globocom/tsuru/testing"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (s *S) createManifestServices(c *gocheck.C) func() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/resources/") {
+			name := strings.TrimPrefix(r.URL.Path, "/resources/")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"DATABASE_NAME":%q}`, name)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := srvc.Create()
+	c.Assert(err, gocheck.IsNil)
+	instance := service.ServiceInstance{Name: "my-redis", ServiceName: "mysql", Teams: []string{s.team.Name}}
+	err = instance.Create()
+	c.Assert(err, gocheck.IsNil)
+	return func() {
+		ts.Close()
+		s.conn.Services().RemoveId(srvc.Name)
+		s.conn.ServiceInstances().RemoveAll(bson.M{"service_name": srvc.Name})
+	}
+}
+
+func (s *S) TestParseManifest(c *gocheck.C) {
+	data := []byte(`name: myapp
+platform: python
+units: 3
+env:
+  DEBUG: "0"
+cnames:
+  - myapp.example.com
+services:
+  - instance: my-mysql
+    service: mysql
+  - instance: my-redis
+`)
+	m, err := ParseManifest(data)
+	c.Assert(err, gocheck.IsNil)
+	expected := Manifest{
+		Name:     "myapp",
+		Platform: "python",
+		Units:    3,
+		Env:      map[string]string{"DEBUG": "0"},
+		CNames:   []string{"myapp.example.com"},
+		Services: []ManifestService{
+			{Instance: "my-mysql", Service: "mysql"},
+			{Instance: "my-redis"},
+		},
+	}
+	c.Assert(*m, gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestParseManifestInvalid(c *gocheck.C) {
+	_, err := ParseManifest([]byte("units: [1, 2"))
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*tsuruErrors.ValidationError)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Message, gocheck.Matches, "Invalid manifest: .*")
+}
+
+func (s *S) TestCreateAppFromManifest(c *gocheck.C) {
+	config.Unset("bucket-support")
+	defer config.Set("bucket-support", true)
+	ts := s.t.StartGandalfTestServer(&testHandler{})
+	defer ts.Close()
+	defer s.createManifestServices(c)()
+	defer testing.CleanQ(queueName)
+	m := Manifest{
+		Name:     "manifested",
+		Platform: "python",
+		Units:    2,
+		Env:      map[string]string{"DEBUG": "0"},
+		CNames:   []string{"manifested.example.com"},
+		Services: []ManifestService{
+			{Instance: "my-mysql", Service: "mysql"},
+			{Instance: "my-redis"},
+		},
+	}
+	a, err := CreateAppFromManifest(&m, s.user)
+	c.Assert(err, gocheck.IsNil)
+	defer Delete(a)
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.Platform, gocheck.Equals, "python")
+	c.Assert(a.Units, gocheck.HasLen, 2)
+	c.Assert(a.CName, gocheck.DeepEquals, []string{"manifested.example.com"})
+	c.Assert(a.Env["DEBUG"], gocheck.DeepEquals, bind.EnvVar{Name: "DEBUG", Value: "0", Public: true})
+	for _, name := range []string{"my-mysql", "my-redis"} {
+		var si service.ServiceInstance
+		err = s.conn.ServiceInstances().Find(bson.M{"name": name}).One(&si)
+		c.Assert(err, gocheck.IsNil)
+		c.Assert(si.Apps, gocheck.DeepEquals, []string{a.Name})
+	}
+}
+
+func (s *S) TestCreateAppFromManifestSetsTheEnvsOfTheInstances(c *gocheck.C) {
+	config.Unset("bucket-support")
+	defer config.Set("bucket-support", true)
+	ts := s.t.StartGandalfTestServer(&testHandler{})
+	defer ts.Close()
+	defer s.createManifestServices(c)()
+	defer testing.CleanQ(queueName)
+	m := Manifest{
+		Name:     "manifested",
+		Platform: "python",
+		Units:    1,
+		Services: []ManifestService{{Instance: "my-redis"}},
+	}
+	a, err := CreateAppFromManifest(&m, s.user)
+	c.Assert(err, gocheck.IsNil)
+	defer Delete(a)
+	err = a.Get()
+	c.Assert(err, gocheck.IsNil)
+	expected := bind.EnvVar{Name: "DATABASE_NAME", Value: "my-redis", InstanceName: "my-redis"}
+	c.Assert(a.Env["DATABASE_NAME"], gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestCreateAppFromManifestWithoutUnits(c *gocheck.C) {
+	config.Unset("bucket-support")
+	defer config.Set("bucket-support", true)
+	ts := s.t.StartGandalfTestServer(&testHandler{})
+	defer ts.Close()
+	defer s.createManifestServices(c)()
+	m := Manifest{
+		Name:     "manifested",
+		Platform: "python",
+		Services: []ManifestService{{Instance: "my-redis"}},
+	}
+	a, err := CreateAppFromManifest(&m, s.user)
+	c.Assert(err, gocheck.IsNil)
+	defer Delete(a)
+	var si service.ServiceInstance
+	err = s.conn.ServiceInstances().Find(bson.M{"name": "my-redis"}).One(&si)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(si.Apps, gocheck.DeepEquals, []string{a.Name})
+}
+
+func (s *S) TestCreateAppFromManifestRollback(c *gocheck.C) {
+	config.Unset("bucket-support")
+	defer config.Set("bucket-support", true)
+	ts := s.t.StartGandalfTestServer(&testHandler{})
+	defer ts.Close()
+	defer s.createManifestServices(c)()
+	defer testing.CleanQ(queueName)
+	s.provisioner.PrepareFailure("AddUnits", errors.New("no capacity"))
+	m := Manifest{
+		Name:     "manifested",
+		Platform: "python",
+		Units:    3,
+		CNames:   []string{"manifested.example.com"},
+		Services: []ManifestService{
+			{Instance: "my-mysql", Service: "mysql"},
+			{Instance: "my-redis"},
+		},
+	}
+	_, err := CreateAppFromManifest(&m, s.user)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "no capacity")
+	n, err := s.conn.Apps().Find(bson.M{"name": m.Name}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	n, err = s.conn.ServiceInstances().Find(bson.M{"name": "my-mysql"}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	var si service.ServiceInstance
+	err = s.conn.ServiceInstances().Find(bson.M{"name": "my-redis"}).One(&si)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(si.Apps, gocheck.HasLen, 0)
+	c.Assert(s.provisioner.Provisioned(&App{Name: m.Name}), gocheck.Equals, false)
+}
+
+func (s *S) TestCreateAppFromManifestUnknownInstance(c *gocheck.C) {
+	m := Manifest{
+		Name:     "manifested",
+		Platform: "python",
+		Services: []ManifestService{{Instance: "my-mongo"}},
+	}
+	_, err := CreateAppFromManifest(&m, s.user)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*tsuruErrors.ValidationError)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Message, gocheck.Equals, `The service instance "my-mongo" does not exist. Declare its service to create it.`)
+	n, err := s.conn.Apps().Find(bson.M{"name": m.Name}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestCreateAppFromManifestInstanceOfOtherService(c *gocheck.C) {
+	defer s.createManifestServices(c)()
+	m := Manifest{
+		Name:     "manifested",
+		Platform: "python",
+		Services: []ManifestService{{Instance: "my-redis", Service: "redis"}},
+	}
+	_, err := CreateAppFromManifest(&m, s.user)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, `The service instance "my-redis" is an instance of "mysql", not "redis".`)
+}
+
+func (s *S) TestAppManifest(c *gocheck.C) {
+	defer s.createManifestServices(c)()
+	a := App{
+		Name:     "exported",
+		Platform: "python",
+		CName:    []string{"exported.example.com"},
+		Units:    []Unit{{Name: "exported/0"}, {Name: "exported/1"}},
+		Env: map[string]bind.EnvVar{
+			"DEBUG":           {Name: "DEBUG", Value: "0", Public: true},
+			"SECRET":          {Name: "SECRET", Value: "s3cr3t"},
+			"TSURU_APPNAME":   {Name: "TSURU_APPNAME", Value: "exported", Public: true},
+			"DATABASE_HOST":   {Name: "DATABASE_HOST", Value: "10.0.0.1", Public: true, InstanceName: "my-redis"},
+			"TSURU_APP_TOKEN": {Name: "TSURU_APP_TOKEN", Value: "abc"},
+		},
+	}
+	err := s.conn.ServiceInstances().Update(bson.M{"name": "my-redis"}, bson.M{"$push": bson.M{"apps": a.Name}})
+	c.Assert(err, gocheck.IsNil)
+	m, err := a.Manifest()
+	c.Assert(err, gocheck.IsNil)
+	expected := Manifest{
+		Name:     "exported",
+		Platform: "python",
+		Units:    2,
+		Env:      map[string]string{"DEBUG": "0"},
+		CNames:   []string{"exported.example.com"},
+		Services: []ManifestService{{Instance: "my-redis", Service: "mysql"}},
+	}
+	c.Assert(*m, gocheck.DeepEquals, expected)
+}
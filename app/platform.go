@@ -5,12 +5,35 @@
 package app
 
 import (
+	stderr "errors"
+	"fmt"
 	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/queue"
+	"io"
+	"labix.org/v2/mgo"
 	"labix.org/v2/mgo/bson"
+	"net/url"
+	"regexp"
 )
 
+var (
+	ErrPlatformNotFound  = stderr.New("Platform not found.")
+	ErrDuplicatePlatform = stderr.New("Duplicate platform.")
+	ErrPlatformInUse     = stderr.New("Platform has apps. You must remove them before removing the platform.")
+
+	platformNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+)
+
+// defaultBranch is the git branch deployed when rebuilding apps deployed
+// before deploys recorded their commit.
+const defaultBranch = "master"
+
 type Platform struct {
-	Name string `bson:"_id"`
+	Name       string `bson:"_id"`
+	Dockerfile string `bson:",omitempty" json:",omitempty"`
+	Image      string `bson:",omitempty" json:",omitempty"`
 }
 
 // Platforms returns the list of available platforms.
@@ -42,3 +65,160 @@ type InvalidPlatformError struct{}
 func (InvalidPlatformError) Error() string {
 	return "Invalid platform"
 }
+
+// validate checks the name of the platform and its base image, that must be
+// either the URL of a Dockerfile or an image reference.
+func (p *Platform) validate() error {
+	if !platformNameRegexp.MatchString(p.Name) {
+		msg := "Invalid platform name, it must start with a letter and contain only lower case letters, numbers, underscores and dashes."
+		return &errors.ValidationError{Message: msg}
+	}
+	if (p.Dockerfile == "") == (p.Image == "") {
+		return &errors.ValidationError{Message: "You must provide either the Dockerfile or the image of the platform."}
+	}
+	if p.Dockerfile != "" {
+		u, err := url.Parse(p.Dockerfile)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return &errors.ValidationError{Message: "The Dockerfile must be an HTTP or HTTPS URL."}
+		}
+	}
+	return nil
+}
+
+func (p *Platform) options() provision.PlatformOptions {
+	return provision.PlatformOptions{Name: p.Name, Dockerfile: p.Dockerfile, Image: p.Image}
+}
+
+func platformManager() (provision.PlatformManager, error) {
+	manager, ok := Provisioner.(provision.PlatformManager)
+	if !ok {
+		return nil, provision.ErrPlatformManagementNotSupported
+	}
+	return manager, nil
+}
+
+// PlatformAdd registers the given platform, building its image in the
+// provisioner. The platform is not registered if the build fails.
+func PlatformAdd(p Platform, w io.Writer) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	manager, err := platformManager()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Platforms().Insert(p)
+	if mgo.IsDup(err) {
+		return ErrDuplicatePlatform
+	}
+	if err != nil {
+		return err
+	}
+	if err = manager.PlatformAdd(p.options(), w); err != nil {
+		conn.Platforms().RemoveId(p.Name)
+		return err
+	}
+	return nil
+}
+
+// PlatformUpdate changes the base image of the given platform, rebuilding
+// its image in the provisioner. The apps that use the platform and have
+// been deployed are rebuilt in background, on behalf of the given user. The
+// apps that cannot be rebuilt are reported in w.
+func PlatformUpdate(p Platform, user string, w io.Writer) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	manager, err := platformManager()
+	if err != nil {
+		return err
+	}
+	if _, err = getPlatform(p.Name); err != nil {
+		return ErrPlatformNotFound
+	}
+	if err = manager.PlatformUpdate(p.options(), w); err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err = conn.Platforms().UpdateId(p.Name, p); err != nil {
+		return err
+	}
+	var apps []App
+	err = conn.Apps().Find(bson.M{"framework": p.Name, "deploys": bson.M{"$gt": 0}}).Select(bson.M{"name": 1}).All(&apps)
+	if err != nil {
+		return err
+	}
+	var messages []queue.Message
+	for _, a := range apps {
+		version, reason, err := rebuildVersion(a.Name)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			fmt.Fprintf(w, "The app %q will not be rebuilt: %s. It must be deployed again.\n", a.Name, reason)
+			continue
+		}
+		messages = append(messages, queue.Message{Action: rebuildApp, Args: []string{a.Name, user, version}})
+	}
+	if len(messages) > 0 {
+		Enqueue(messages...)
+	}
+	return nil
+}
+
+// rebuildVersion returns the version deployed when the app is rebuilt: the
+// commit of its last deploy, or its default branch when it was deployed
+// before deploys recorded their commit. Apps last deployed from an archive,
+// an image or a promotion are not rebuilt, as their source is not kept, and
+// the returned reason tells why.
+func rebuildVersion(appName string) (version, reason string, err error) {
+	d, err := lastDeploy(appName)
+	if err == mgo.ErrNotFound {
+		return defaultBranch, "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if d.PromotedFrom != "" {
+		return "", fmt.Sprintf("it was last deployed by promoting the app %q", d.PromotedFrom), nil
+	}
+	if d.Commit == "" {
+		return "", "it was last deployed from an archive or an image", nil
+	}
+	return d.Commit, "", nil
+}
+
+// PlatformRemove removes the given platform and its image. Platforms used by
+// apps cannot be removed.
+func PlatformRemove(name string) error {
+	manager, err := platformManager()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if n, err := conn.Platforms().FindId(name).Count(); err != nil || n == 0 {
+		return ErrPlatformNotFound
+	}
+	if n, err := conn.Apps().Find(bson.M{"framework": name}).Count(); err != nil {
+		return err
+	} else if n > 0 {
+		return ErrPlatformInUse
+	}
+	if err = manager.PlatformRemove(name); err != nil {
+		return err
+	}
+	return conn.Platforms().RemoveId(name)
+}
@@ -5,9 +5,15 @@
 package app
 
 import (
+	"bytes"
+	stderr "errors"
 	"github.com/globocom/config"
 	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+	"labix.org/v2/mgo/bson"
 	"launchpad.net/gocheck"
+	"time"
 )
 
 type PlatformSuite struct{}
@@ -67,3 +73,162 @@ func (s *PlatformSuite) TestGetPlatform(c *gocheck.C) {
 	_, ok := err.(InvalidPlatformError)
 	c.Assert(ok, gocheck.Equals, true)
 }
+
+func (s *S) TestPlatformAdd(c *gocheck.C) {
+	var buf bytes.Buffer
+	p := Platform{Name: "ruby", Image: "tsuru/ruby-base"}
+	err := PlatformAdd(p, &buf)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Platforms().RemoveId(p.Name)
+	c.Assert(buf.String(), gocheck.Equals, "Platform add called")
+	opts, ok := s.provisioner.GetPlatform("ruby")
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(opts, gocheck.DeepEquals, provision.PlatformOptions{Name: "ruby", Image: "tsuru/ruby-base"})
+	got, err := getPlatform("ruby")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(*got, gocheck.DeepEquals, p)
+}
+
+func (s *S) TestPlatformAddValidation(c *gocheck.C) {
+	var tests = []Platform{
+		{Name: "Ruby", Image: "tsuru/ruby"},
+		{Name: "ruby"},
+		{Name: "ruby", Image: "tsuru/ruby", Dockerfile: "http://example.com/Dockerfile"},
+		{Name: "ruby", Dockerfile: "/etc/Dockerfile"},
+	}
+	for _, p := range tests {
+		err := PlatformAdd(p, &bytes.Buffer{})
+		c.Check(err, gocheck.FitsTypeOf, &errors.ValidationError{})
+	}
+}
+
+func (s *S) TestPlatformAddDuplicate(c *gocheck.C) {
+	err := PlatformAdd(Platform{Name: "python", Image: "tsuru/python"}, &bytes.Buffer{})
+	c.Assert(err, gocheck.Equals, ErrDuplicatePlatform)
+}
+
+func (s *S) TestPlatformAddBuildFailure(c *gocheck.C) {
+	s.provisioner.PrepareFailure("PlatformAdd", stderr.New("build failed"))
+	err := PlatformAdd(Platform{Name: "ruby", Image: "tsuru/ruby"}, &bytes.Buffer{})
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "build failed")
+	_, err = getPlatform("ruby")
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestPlatformAddNotSupported(c *gocheck.C) {
+	Provisioner = struct{ provision.Provisioner }{s.provisioner}
+	defer func() {
+		Provisioner = s.provisioner
+	}()
+	err := PlatformAdd(Platform{Name: "ruby", Image: "tsuru/ruby"}, &bytes.Buffer{})
+	c.Assert(err, gocheck.Equals, provision.ErrPlatformManagementNotSupported)
+}
+
+func (s *S) TestPlatformUpdate(c *gocheck.C) {
+	err := PlatformAdd(Platform{Name: "ruby", Image: "tsuru/ruby"}, &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Platforms().RemoveId("ruby")
+	deployed := App{Name: "deployed", Platform: "ruby", Deploys: 3}
+	fresh := App{Name: "fresh", Platform: "ruby"}
+	other := App{Name: "other", Platform: "python", Deploys: 1}
+	err = s.conn.Apps().Insert(deployed, fresh, other)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().RemoveAll(bson.M{"name": bson.M{"$in": []string{deployed.Name, fresh.Name, other.Name}}})
+	var buf bytes.Buffer
+	p := Platform{Name: "ruby", Dockerfile: "http://example.com/ruby/Dockerfile"}
+	err = PlatformUpdate(p, "admin@tsuru.io", &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Equals, "Platform update called")
+	got, err := getPlatform("ruby")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(*got, gocheck.DeepEquals, p)
+	msg, err := aqueue().Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	defer msg.Delete()
+	c.Assert(msg.Action, gocheck.Equals, rebuildApp)
+	c.Assert(msg.Args, gocheck.DeepEquals, []string{"deployed", "admin@tsuru.io", "master"})
+	_, err = aqueue().Get(1e6)
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestPlatformUpdateRebuildsTheCommitOfTheLastDeploy(c *gocheck.C) {
+	err := PlatformAdd(Platform{Name: "ruby", Image: "tsuru/ruby"}, &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Platforms().RemoveId("ruby")
+	a := App{Name: "deployed", Platform: "ruby", Deploys: 2}
+	err = s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	deploys := []interface{}{
+		Deploy{App: a.Name, Commit: "abc123", Status: DeploySucceeded, Timestamp: time.Now().Add(-time.Hour)},
+		Deploy{App: a.Name, Commit: "def456", Status: DeployFailed, Timestamp: time.Now()},
+	}
+	err = s.conn.Deploys().Insert(deploys...)
+	c.Assert(err, gocheck.IsNil)
+	err = PlatformUpdate(Platform{Name: "ruby", Image: "tsuru/ruby-2"}, "admin@tsuru.io", &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+	msg, err := aqueue().Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	defer msg.Delete()
+	c.Assert(msg.Args, gocheck.DeepEquals, []string{"deployed", "admin@tsuru.io", "abc123"})
+}
+
+func (s *S) TestPlatformUpdateReportsAppsNotDeployedFromGit(c *gocheck.C) {
+	err := PlatformAdd(Platform{Name: "ruby", Image: "tsuru/ruby"}, &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Platforms().RemoveId("ruby")
+	archived := App{Name: "archived", Platform: "ruby", Deploys: 1}
+	promoted := App{Name: "promoted", Platform: "ruby", Deploys: 1}
+	err = s.conn.Apps().Insert(archived, promoted)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().RemoveAll(bson.M{"name": bson.M{"$in": []string{archived.Name, promoted.Name}}})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": bson.M{"$in": []string{archived.Name, promoted.Name}}})
+	deploys := []interface{}{
+		Deploy{App: archived.Name, Status: DeploySucceeded, Timestamp: time.Now()},
+		Deploy{App: promoted.Name, Commit: "abc123", PromotedFrom: "staging", Status: DeploySucceeded, Timestamp: time.Now()},
+	}
+	err = s.conn.Deploys().Insert(deploys...)
+	c.Assert(err, gocheck.IsNil)
+	var buf bytes.Buffer
+	err = PlatformUpdate(Platform{Name: "ruby", Image: "tsuru/ruby-2"}, "admin@tsuru.io", &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Matches, `(?s).*The app "archived" will not be rebuilt: it was last deployed from an archive or an image\. It must be deployed again\..*`)
+	c.Assert(buf.String(), gocheck.Matches, `(?s).*The app "promoted" will not be rebuilt: it was last deployed by promoting the app "staging"\. It must be deployed again\..*`)
+	_, err = aqueue().Get(1e6)
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestPlatformUpdateNotFound(c *gocheck.C) {
+	err := PlatformUpdate(Platform{Name: "ruby", Image: "tsuru/ruby"}, "admin@tsuru.io", &bytes.Buffer{})
+	c.Assert(err, gocheck.Equals, ErrPlatformNotFound)
+}
+
+func (s *S) TestPlatformRemove(c *gocheck.C) {
+	err := PlatformAdd(Platform{Name: "ruby", Image: "tsuru/ruby"}, &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+	err = PlatformRemove("ruby")
+	c.Assert(err, gocheck.IsNil)
+	_, err = getPlatform("ruby")
+	c.Assert(err, gocheck.NotNil)
+	_, ok := s.provisioner.GetPlatform("ruby")
+	c.Assert(ok, gocheck.Equals, false)
+}
+
+func (s *S) TestPlatformRemoveNotFound(c *gocheck.C) {
+	err := PlatformRemove("ruby")
+	c.Assert(err, gocheck.Equals, ErrPlatformNotFound)
+}
+
+func (s *S) TestPlatformRemoveInUse(c *gocheck.C) {
+	err := PlatformAdd(Platform{Name: "ruby", Image: "tsuru/ruby"}, &bytes.Buffer{})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Platforms().RemoveId("ruby")
+	a := App{Name: "myapp", Platform: "ruby"}
+	err = s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	err = PlatformRemove("ruby")
+	c.Assert(err, gocheck.Equals, ErrPlatformInUse)
+}
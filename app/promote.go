@@ -0,0 +1,56 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/globocom/tsuru/provision"
+	"io"
+)
+
+var (
+	ErrPromoteToSelf    = errors.New("An app can't be promoted to itself.")
+	ErrNothingToPromote = errors.New("The app has no successful deploy with a build to promote.")
+)
+
+// promotion is the version deployed by Promote. It's given to the deploy
+// pipeline in place of the git version of the app.
+type promotion struct {
+	source *App
+	deploy *Deploy
+}
+
+// Promote deploys the target app with the build released in the last
+// successful deploy of the source app, without building it again. The build
+// is the one recorded with the deploy, not the one running in the units of
+// the source app, that may have changed since then. The target app keeps its
+// own environment variables and service binds, and the promotion is recorded
+// as a deploy of the target app, with the commit of the promoted deploy.
+func Promote(source, target *App, user string, writer io.Writer) error {
+	if source.Name == target.Name {
+		return ErrPromoteToSelf
+	}
+	d, err := lastDeploy(source.Name)
+	if err != nil || d.Build == "" {
+		return ErrNothingToPromote
+	}
+	return runDeploy(target, promotion{source: source, deploy: d}, d.Commit, user, writer)
+}
+
+// promoteAndRelease copies the build of the promoted deploy as the last build
+// of the app, and releases it. The configuration stored with the promoted
+// deploy is used, as the build isn't read again.
+func promoteAndRelease(app *App, promoter provision.Promoter, p promotion, w io.Writer) error {
+	fmt.Fprintf(w, "\n ---> Promoting the app %q to %q\n", p.source.Name, app.Name)
+	if err := promoter.Promote(p.deploy.Build, app, w); err != nil {
+		return err
+	}
+	config := p.deploy.AppConfig
+	if config == nil {
+		config = &provision.AppConfig{}
+	}
+	return releaseBuild(app, promoter, config, w)
+}
@@ -0,0 +1,95 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"github.com/globocom/tsuru/action"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/testing"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"time"
+)
+
+func (s *S) TestPromote(c *gocheck.C) {
+	config := provision.AppConfig{
+		Hooks: provision.Hooks{PreDeploy: []string{"python manage.py migrate"}},
+	}
+	p := testing.BuilderProvisioner{FakeProvisioner: s.provisioner}
+	Provisioner = &p
+	defer func() { Provisioner = s.provisioner }()
+	source := App{Name: "staging", Platform: "django", Teams: []string{s.team.Name}}
+	target := App{Name: "production", Platform: "django", Teams: []string{s.team.Name}}
+	for _, a := range []App{source, target} {
+		err := s.conn.Apps().Insert(a)
+		c.Assert(err, gocheck.IsNil)
+		defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+		defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+		defer s.conn.Logs().Remove(bson.M{"appname": a.Name})
+	}
+	now := time.Now()
+	err := s.conn.Deploys().Insert(
+		Deploy{App: "staging", Commit: "f1a2b3", Status: DeploySucceeded, Timestamp: now.Add(-time.Hour), AppConfig: &config, Build: "staging-1"},
+		Deploy{App: "staging", Commit: "a4b5c6", Status: DeployFailed, Timestamp: now, Build: "staging-2"},
+	)
+	c.Assert(err, gocheck.IsNil)
+	var buf bytes.Buffer
+	err = Promote(&source, &target, "someone@tsuru.io", &buf)
+	c.Assert(err, gocheck.IsNil)
+	steps := p.Steps()
+	c.Assert(steps, gocheck.HasLen, 3)
+	c.Assert(steps[0], gocheck.Equals, "promote staging-1")
+	c.Assert(steps[1], gocheck.Matches, `^run .*python manage.py migrate$`)
+	c.Assert(steps[2], gocheck.Equals, "release")
+	c.Assert(p.Builds(), gocheck.HasLen, 0)
+	c.Assert(buf.String(), gocheck.Matches, `(?s).*Promoting the app "staging" to "production".*`)
+	var d Deploy
+	err = s.conn.Deploys().Find(bson.M{"app": "production"}).One(&d)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(d.Status, gocheck.Equals, DeploySucceeded)
+	c.Assert(d.Commit, gocheck.Equals, "f1a2b3")
+	c.Assert(d.User, gocheck.Equals, "someone@tsuru.io")
+	c.Assert(d.PromotedFrom, gocheck.Equals, "staging")
+	c.Assert(d.AppConfig, gocheck.DeepEquals, &config)
+	c.Assert(d.Build, gocheck.Equals, "production-1")
+}
+
+func (s *S) TestPromoteWithoutSuccessfulDeploy(c *gocheck.C) {
+	source := App{Name: "staging"}
+	target := App{Name: "production"}
+	err := s.conn.Deploys().Insert(Deploy{App: "staging", Status: DeployFailed, Timestamp: time.Now()})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": "staging"})
+	err = Promote(&source, &target, "someone@tsuru.io", &bytes.Buffer{})
+	c.Assert(err, gocheck.Equals, ErrNothingToPromote)
+	n, err := s.conn.Deploys().Find(bson.M{"app": "production"}).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *S) TestPromoteDeployWithoutBuild(c *gocheck.C) {
+	source := App{Name: "staging"}
+	target := App{Name: "production"}
+	err := s.conn.Deploys().Insert(Deploy{App: "staging", Status: DeploySucceeded, Timestamp: time.Now()})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": "staging"})
+	err = Promote(&source, &target, "someone@tsuru.io", &bytes.Buffer{})
+	c.Assert(err, gocheck.Equals, ErrNothingToPromote)
+}
+
+func (s *S) TestPromoteToSelf(c *gocheck.C) {
+	a := App{Name: "staging"}
+	err := Promote(&a, &a, "someone@tsuru.io", &bytes.Buffer{})
+	c.Assert(err, gocheck.Equals, ErrPromoteToSelf)
+}
+
+func (s *S) TestProvisionerDeployForwardPromoteNotSupported(c *gocheck.C) {
+	a := App{Name: "production"}
+	version := promotion{source: &App{Name: "staging"}, deploy: &Deploy{}}
+	ctx := action.FWContext{Params: []interface{}{&a, version, &bytes.Buffer{}}}
+	_, err := ProvisionerDeploy.Forward(ctx)
+	c.Assert(err, gocheck.Equals, provision.ErrPromoteNotSupported)
+}
@@ -22,6 +22,8 @@ const (
 	startApp                = "start-app"
 	RegenerateApprcAndStart = "regenerate-apprc-start-app"
 	BindService             = "bind-service"
+	UpdateDependents        = "update-dependents"
+	rebuildApp              = "rebuild-app"
 
 	queueName = "tsuru-app"
 )
@@ -134,12 +136,44 @@ func handle(msg *queue.Message) {
 			return
 		}
 		msg.Delete()
+	case UpdateDependents:
+		if len(msg.Args) < 1 {
+			log.Errorf("Error handling %q: this action requires at least 1 argument.", msg.Action)
+			msg.Delete()
+			return
+		}
+		err := updateDependents(msg.Args[0])
+		if err != nil {
+			log.Errorf("Error handling %q for the app %q: %s", msg.Action, msg.Args[0], err)
+		}
+		msg.Delete()
+	case rebuildApp:
+		if len(msg.Args) < 3 {
+			log.Errorf("Error handling %q: this action requires 3 arguments.", msg.Action)
+			msg.Delete()
+			return
+		}
+		err := rebuild(msg.Args[0], msg.Args[1], msg.Args[2])
+		if err != nil {
+			log.Errorf("Error handling %q for the app %q: %s", msg.Action, msg.Args[0], err)
+		}
+		msg.Delete()
 	default:
 		log.Errorf("Error handling %q: invalid action.", msg.Action)
 		msg.Delete()
 	}
 }
 
+// rebuild deploys again the given version of the app, on behalf of the given
+// user.
+func rebuild(appName, user, version string) error {
+	a := App{Name: appName}
+	if err := a.Get(); err != nil {
+		return err
+	}
+	return DeployApp(&a, version, user, ioutil.Discard)
+}
+
 // unitList is a simple slice of units, with special methods to handle state.
 type unitList []Unit
 
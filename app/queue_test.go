@@ -211,6 +211,35 @@ func (s *S) TestHandleRestartAppMessage(c *gocheck.C) {
 	c.Assert(restarts, gocheck.Equals, 1)
 }
 
+func (s *S) TestHandleRebuildAppMessage(c *gocheck.C) {
+	a := App{Name: "nemesis", Platform: "python", Deploys: 2}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.conn.Deploys().RemoveAll(bson.M{"app": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	message := queue.Message{Action: rebuildApp, Args: []string{a.Name, "admin@tsuru.io", "abc123"}}
+	handle(&message)
+	c.Assert(s.provisioner.Version(&a), gocheck.Equals, "abc123")
+	d, err := lastDeploy(a.Name)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(d.Commit, gocheck.Equals, "abc123")
+	c.Assert(d.User, gocheck.Equals, "admin@tsuru.io")
+}
+
+func (s *S) TestHandleRebuildAppMessageWithoutVersion(c *gocheck.C) {
+	a := App{Name: "nemesis", Platform: "python", Deploys: 2}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	message := queue.Message{Action: rebuildApp, Args: []string{a.Name, "admin@tsuru.io"}}
+	handle(&message)
+	c.Assert(s.provisioner.Version(&a), gocheck.Equals, "")
+}
+
 func (s *S) TestHandleRegenerateAndRestart(c *gocheck.C) {
 	s.provisioner.PrepareOutput([]byte("exported"))
 	s.provisioner.PrepareOutput([]byte("started"))
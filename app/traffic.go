@@ -0,0 +1,40 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/provision"
+)
+
+// SplitTraffic sends weight percent of the traffic of the app to the units
+// of the target app, for canary releases.
+//
+// A weight of 0 removes the split, sending all the traffic back to the
+// units of the app, and target may be nil in this case. A weight of 100
+// finalizes the split: it's removed and the apps are swapped, so the target
+// app receives all the traffic.
+func (app *App) SplitTraffic(target *App, weight int) error {
+	if weight < 0 || weight > 100 {
+		return &errors.ValidationError{Message: "The weight must be a percentage between 0 and 100."}
+	}
+	if weight > 0 && target == nil {
+		return &errors.ValidationError{Message: "You must provide the target app."}
+	}
+	s, ok := Provisioner.(provision.TrafficSplitter)
+	if !ok {
+		return provision.ErrTrafficSplitNotSupported
+	}
+	if weight == 0 {
+		return s.SplitTraffic(app, nil, 0)
+	}
+	if weight == 100 {
+		if err := s.SplitTraffic(app, nil, 0); err != nil {
+			return err
+		}
+		return Swap(app, target)
+	}
+	return s.SplitTraffic(app, target, weight)
+}
@@ -0,0 +1,80 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	tsuruErrors "github.com/globocom/tsuru/errors"
+	"launchpad.net/gocheck"
+)
+
+func (s *S) TestSplitTraffic(c *gocheck.C) {
+	a := App{Name: "ktulu"}
+	target := App{Name: "ktulu-canary"}
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err := a.SplitTraffic(&target, 10)
+	c.Assert(err, gocheck.IsNil)
+	name, weight := s.provisioner.Split(&a)
+	c.Assert(name, gocheck.Equals, "ktulu-canary")
+	c.Assert(weight, gocheck.Equals, 10)
+}
+
+func (s *S) TestSplitTrafficRemove(c *gocheck.C) {
+	a := App{Name: "ktulu"}
+	target := App{Name: "ktulu-canary"}
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err := a.SplitTraffic(&target, 10)
+	c.Assert(err, gocheck.IsNil)
+	err = a.SplitTraffic(nil, 0)
+	c.Assert(err, gocheck.IsNil)
+	name, weight := s.provisioner.Split(&a)
+	c.Assert(name, gocheck.Equals, "")
+	c.Assert(weight, gocheck.Equals, 0)
+}
+
+func (s *S) TestSplitTrafficFinalize(c *gocheck.C) {
+	a := App{Name: "ktulu"}
+	target := App{Name: "ktulu-canary"}
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err := a.SplitTraffic(&target, 10)
+	c.Assert(err, gocheck.IsNil)
+	err = a.SplitTraffic(&target, 100)
+	c.Assert(err, gocheck.IsNil)
+	name, weight := s.provisioner.Split(&a)
+	c.Assert(name, gocheck.Equals, "")
+	c.Assert(weight, gocheck.Equals, 0)
+}
+
+func (s *S) TestSplitTrafficInvalidWeight(c *gocheck.C) {
+	a := App{Name: "ktulu"}
+	target := App{Name: "ktulu-canary"}
+	for _, weight := range []int{-1, 101} {
+		err := a.SplitTraffic(&target, weight)
+		c.Assert(err, gocheck.NotNil)
+		_, ok := err.(*tsuruErrors.ValidationError)
+		c.Check(ok, gocheck.Equals, true)
+	}
+}
+
+func (s *S) TestSplitTrafficWithoutTarget(c *gocheck.C) {
+	a := App{Name: "ktulu"}
+	err := a.SplitTraffic(nil, 10)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*tsuruErrors.ValidationError)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Message, gocheck.Equals, "You must provide the target app.")
+}
+
+func (s *S) TestSplitTrafficFailure(c *gocheck.C) {
+	a := App{Name: "ktulu"}
+	target := App{Name: "ktulu-canary"}
+	s.provisioner.PrepareFailure("SplitTraffic", errors.New("split failed"))
+	err := a.SplitTraffic(&target, 10)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "split failed")
+}
@@ -5,10 +5,14 @@
 package cmd
 
 import (
+	"bufio"
+	"crypto/tls"
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 )
@@ -79,3 +83,62 @@ and download the last version.
 	}
 	return response, nil
 }
+
+// hijackedConn is a connection upgraded by the tsuru server. Reads go through
+// the buffered reader used to parse the response, that may hold data sent
+// right after the headers.
+type hijackedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *hijackedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// Upgrade sends the request asking the server to upgrade the connection to a
+// raw TCP stream, and returns the stream. It's used by commands that need
+// bidirectional communication with the server, like app-shell.
+func (c *Client) Upgrade(request *http.Request) (io.ReadWriteCloser, error) {
+	if token, err := readToken(); err == nil {
+		request.Header.Set("Authorization", "bearer "+token)
+	}
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "tcp")
+	host := request.URL.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		if request.URL.Scheme == "https" {
+			host += ":443"
+		} else {
+			host += ":80"
+		}
+	}
+	var conn net.Conn
+	var err error
+	if request.URL.Scheme == "https" {
+		conn, err = tls.Dial("tcp", host, nil)
+	} else {
+		conn, err = net.Dial("tcp", host)
+	}
+	if err != nil {
+		target, _ := readTarget()
+		return nil, fmt.Errorf("Failed to connect to tsuru server (%s), it's probably down.", target)
+	}
+	if err = request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		defer conn.Close()
+		defer response.Body.Close()
+		result, _ := ioutil.ReadAll(response.Body)
+		return nil, errors.New(string(result))
+	}
+	return &hijackedConn{Conn: conn, reader: reader}, nil
+}
@@ -8,8 +8,11 @@ import (
 	"bytes"
 	ttesting "github.com/globocom/tsuru/cmd/testing"
 	"github.com/globocom/tsuru/fs/testing"
+	"io"
+	"io/ioutil"
 	"launchpad.net/gocheck"
 	"net/http"
+	"net/http/httptest"
 )
 
 func (s *S) TestShouldSetCloseToTrue(c *gocheck.C) {
@@ -149,3 +152,46 @@ func (s *S) TestShouldSkupValidationIfServerDoesNotReturnSupportedHeader(c *goch
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(buf.String(), gocheck.Equals, "")
 }
+
+func (s *S) TestUpgrade(c *gocheck.C) {
+	var upgrade, connection string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrade = r.Header.Get("Upgrade")
+		connection = r.Header.Get("Connection")
+		conn, _, err := w.(http.Hijacker).Hijack()
+		c.Assert(err, gocheck.IsNil)
+		defer conn.Close()
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\nhello\n"))
+		buf := make([]byte, 4)
+		io.ReadFull(conn, buf)
+		conn.Write(buf)
+	}))
+	defer server.Close()
+	request, err := http.NewRequest("GET", server.URL+"/apps/myapp/shell", nil)
+	c.Assert(err, gocheck.IsNil)
+	client := NewClient(&http.Client{}, nil, manager)
+	conn, err := client.Upgrade(request)
+	c.Assert(err, gocheck.IsNil)
+	defer conn.Close()
+	_, err = conn.Write([]byte("ping"))
+	c.Assert(err, gocheck.IsNil)
+	output, err := ioutil.ReadAll(conn)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(output), gocheck.Equals, "hello\nping")
+	c.Assert(upgrade, gocheck.Equals, "tcp")
+	c.Assert(connection, gocheck.Equals, "Upgrade")
+}
+
+func (s *S) TestUpgradeReturnsBodyMessageOnError(c *gocheck.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "App not found.", http.StatusNotFound)
+	}))
+	defer server.Close()
+	request, err := http.NewRequest("GET", server.URL+"/apps/myapp/shell", nil)
+	c.Assert(err, gocheck.IsNil)
+	client := NewClient(&http.Client{}, nil, manager)
+	conn, err := client.Upgrade(request)
+	c.Assert(conn, gocheck.IsNil)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "App not found.\n")
+}
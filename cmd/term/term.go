@@ -53,3 +53,55 @@ func ReadPassword(fd uintptr) (string, error) {
 	}
 	return string(pass), nil
 }
+
+// State holds the settings of a terminal, to be restored with Restore.
+type State struct {
+	termios syscall.Termios
+}
+
+// IsTerminal reports whether the given file descriptor is a terminal.
+func IsTerminal(fd uintptr) bool {
+	var termios syscall.Termios
+	_, _, e := syscall.Syscall6(syscall.SYS_IOCTL, fd, TCGETS, uintptr(unsafe.Pointer(&termios)), 0, 0, 0)
+	return e == 0
+}
+
+// MakeRaw puts the terminal in raw mode, so every key stroke is sent as-is,
+// and returns its previous state.
+func MakeRaw(fd uintptr) (*State, error) {
+	var oldState State
+	if _, _, e := syscall.Syscall6(syscall.SYS_IOCTL, fd, TCGETS, uintptr(unsafe.Pointer(&oldState.termios)), 0, 0, 0); e != 0 {
+		return nil, e
+	}
+	termios := oldState.termios
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	termios.Oflag &^= syscall.OPOST
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+	termios.Cflag |= syscall.CS8
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+	if _, _, e := syscall.Syscall6(syscall.SYS_IOCTL, fd, TCSETS, uintptr(unsafe.Pointer(&termios)), 0, 0, 0); e != 0 {
+		return nil, e
+	}
+	return &oldState, nil
+}
+
+// Restore restores the terminal to the given state.
+func Restore(fd uintptr, state *State) error {
+	if _, _, e := syscall.Syscall6(syscall.SYS_IOCTL, fd, TCSETS, uintptr(unsafe.Pointer(&state.termios)), 0, 0, 0); e != 0 {
+		return e
+	}
+	return nil
+}
+
+// GetSize returns the width and the height of the terminal.
+func GetSize(fd uintptr) (width, height int, err error) {
+	var size struct {
+		rows, cols, xpixel, ypixel uint16
+	}
+	if _, _, e := syscall.Syscall6(syscall.SYS_IOCTL, fd, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&size)), 0, 0, 0); e != 0 {
+		return 0, 0, e
+	}
+	return int(size.cols), int(size.rows), nil
+}
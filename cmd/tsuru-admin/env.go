@@ -0,0 +1,54 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/globocom/tsuru/cmd"
+	"net/http"
+)
+
+type envReencrypt struct{}
+
+func (envReencrypt) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "env-reencrypt",
+		Usage: "env-reencrypt",
+		Desc: `encrypts the private environment variables and the certificate keys of all
+apps with the current key.
+
+Run it after rotating the key in encryption:key, keeping the old key in
+encryption:previous-keys until the command finishes. Variables stored in
+plain text, before encryption was configured, are encrypted too.`,
+		MinArgs: 0,
+	}
+}
+
+func (envReencrypt) Run(context *cmd.Context, client *cmd.Client) error {
+	url, err := cmd.GetURL("/env/reencrypt")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var result map[string]int
+	if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result["reencrypted"] == 0 {
+		fmt.Fprintln(context.Stdout, "All private environment variables are encrypted with the current key.")
+		return nil
+	}
+	fmt.Fprintf(context.Stdout, "%d private environment variables encrypted with the current key.\n", result["reencrypted"])
+	return nil
+}
@@ -24,6 +24,14 @@ func buildManager(name string) *cmd.Manager {
 	m.Register(&tokenGen{})
 	m.Register(&logRemove{})
 	m.Register(&changeQuota{})
+	m.Register(deadLetterList{})
+	m.Register(deadLetterInfo{})
+	m.Register(deadLetterRequeue{})
+	m.Register(&routerCheck{})
+	m.Register(envReencrypt{})
+	m.Register(&platformAdd{})
+	m.Register(&platformUpdate{})
+	m.Register(platformRemove{})
 	return m
 }
 
@@ -52,6 +52,54 @@ func (s *S) TestChangeQuotaIsRegistered(c *gocheck.C) {
 	c.Assert(token, gocheck.FitsTypeOf, &changeQuota{})
 }
 
+func (s *S) TestDeadLetterCommandsAreRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru-admin")
+	list, ok := manager.Commands["dead-letter-list"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(list, gocheck.FitsTypeOf, deadLetterList{})
+	info, ok := manager.Commands["dead-letter-info"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(info, gocheck.FitsTypeOf, deadLetterInfo{})
+	requeue, ok := manager.Commands["dead-letter-requeue"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(requeue, gocheck.FitsTypeOf, deadLetterRequeue{})
+}
+
+func (s *S) TestRouterCheckIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru-admin")
+	check, ok := manager.Commands["router-check"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(check, gocheck.FitsTypeOf, &routerCheck{})
+}
+
+func (s *S) TestEnvReencryptIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru-admin")
+	reencrypt, ok := manager.Commands["env-reencrypt"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(reencrypt, gocheck.FitsTypeOf, envReencrypt{})
+}
+
+func (s *S) TestPlatformAddIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru-admin")
+	add, ok := manager.Commands["platform-add"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(add, gocheck.FitsTypeOf, &platformAdd{})
+}
+
+func (s *S) TestPlatformUpdateIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru-admin")
+	update, ok := manager.Commands["platform-update"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(update, gocheck.FitsTypeOf, &platformUpdate{})
+}
+
+func (s *S) TestPlatformRemoveIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru-admin")
+	remove, ok := manager.Commands["platform-remove"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(remove, gocheck.FitsTypeOf, platformRemove{})
+}
+
 func (s *S) TestCommandsFromBaseManagerAreRegistered(c *gocheck.C) {
 	baseManager := cmd.BuildBaseManager("tsuru", version, header)
 	manager := buildManager("tsuru")
@@ -0,0 +1,137 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/globocom/tsuru/cmd"
+	"io"
+	"launchpad.net/gnuflag"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// platformImage holds the flags that define the base image of a platform,
+// shared by platform-add and platform-update.
+type platformImage struct {
+	fs         *gnuflag.FlagSet
+	dockerfile string
+	image      string
+}
+
+func (p *platformImage) flags(name string) *gnuflag.FlagSet {
+	if p.fs == nil {
+		p.fs = gnuflag.NewFlagSet(name, gnuflag.ExitOnError)
+		p.fs.StringVar(&p.dockerfile, "dockerfile", "", "URL of the Dockerfile that builds the image of the platform")
+		p.fs.StringVar(&p.dockerfile, "d", "", "URL of the Dockerfile that builds the image of the platform")
+		p.fs.StringVar(&p.image, "image", "", "Base image of the platform")
+		p.fs.StringVar(&p.image, "i", "", "Base image of the platform")
+	}
+	return p.fs
+}
+
+// send sends the base image of the platform to tsuru, streaming the output
+// of the build.
+func (p *platformImage) send(context *cmd.Context, client *cmd.Client, method, path string, values url.Values) error {
+	if p.dockerfile == "" && p.image == "" {
+		return errors.New("You must provide the Dockerfile or the image of the platform.")
+	}
+	values.Set("dockerfile", p.dockerfile)
+	values.Set("image", p.image)
+	address, err := cmd.GetURL(path)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(method, address, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, err = io.Copy(context.Stdout, response.Body)
+	return err
+}
+
+type platformAdd struct {
+	platformImage
+}
+
+func (c *platformAdd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "platform-add",
+		Usage: "platform-add <name> [--dockerfile/-d url] [--image/-i image]",
+		Desc: `adds a new platform, built from the Dockerfile available in the given URL or
+based on the given image.`,
+		MinArgs: 1,
+	}
+}
+
+func (c *platformAdd) Flags() *gnuflag.FlagSet {
+	return c.flags("platform-add")
+}
+
+func (c *platformAdd) Run(context *cmd.Context, client *cmd.Client) error {
+	values := url.Values{"name": {context.Args[0]}}
+	return c.send(context, client, "POST", "/platforms", values)
+}
+
+type platformUpdate struct {
+	platformImage
+}
+
+func (c *platformUpdate) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "platform-update",
+		Usage: "platform-update <name> [--dockerfile/-d url] [--image/-i image]",
+		Desc: `changes the Dockerfile or the base image of a platform, rebuilding it.
+
+Apps that use the platform are rebuilt in background. The apps that cannot be
+rebuilt, because they were last deployed from an archive, an image or a
+promotion, are listed in the output.`,
+		MinArgs: 1,
+	}
+}
+
+func (c *platformUpdate) Flags() *gnuflag.FlagSet {
+	return c.flags("platform-update")
+}
+
+func (c *platformUpdate) Run(context *cmd.Context, client *cmd.Client) error {
+	return c.send(context, client, "PUT", "/platforms/"+context.Args[0], url.Values{})
+}
+
+type platformRemove struct{}
+
+func (platformRemove) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "platform-remove",
+		Usage:   "platform-remove <name>",
+		Desc:    "removes a platform. Platforms used by apps cannot be removed.",
+		MinArgs: 1,
+	}
+}
+
+func (platformRemove) Run(context *cmd.Context, client *cmd.Client) error {
+	address, err := cmd.GetURL("/platforms/" + context.Args[0])
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", address, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Platform %q successfully removed!\n", context.Args[0])
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/testing"
+	"launchpad.net/gocheck"
+	"net/http"
+)
+
+func (s *S) TestPlatformAddInfo(c *gocheck.C) {
+	info := (&platformAdd{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "platform-add")
+	c.Assert(info.Usage, gocheck.Equals, "platform-add <name> [--dockerfile/-d url] [--image/-i image]")
+	c.Assert(info.MinArgs, gocheck.Equals, 1)
+}
+
+func (s *S) TestPlatformAddFlags(c *gocheck.C) {
+	command := platformAdd{}
+	flagset := command.Flags()
+	flagset.Parse(true, []string{"-d", "http://example.com/Dockerfile", "--image", "tsuru/ruby"})
+	c.Assert(command.dockerfile, gocheck.Equals, "http://example.com/Dockerfile")
+	c.Assert(command.image, gocheck.Equals, "tsuru/ruby")
+	dockerfile := flagset.Lookup("dockerfile")
+	c.Assert(dockerfile.Usage, gocheck.Equals, "URL of the Dockerfile that builds the image of the platform")
+	image := flagset.Lookup("i")
+	c.Assert(image.Usage, gocheck.Equals, "Base image of the platform")
+}
+
+func (s *S) TestPlatformAddRun(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Args: []string{"ruby"}, Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "Successfully built", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/platforms" && req.Method == "POST" &&
+				req.FormValue("name") == "ruby" && req.FormValue("image") == "tsuru/ruby"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := platformAdd{}
+	command.Flags().Parse(true, []string{"--image", "tsuru/ruby"})
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "Successfully built")
+}
+
+func (s *S) TestPlatformAddRunWithoutImage(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Args: []string{"ruby"}, Stdout: &stdout, Stderr: &stderr}
+	command := platformAdd{}
+	command.Flags().Parse(true, []string{})
+	err := command.Run(&context, nil)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "You must provide the Dockerfile or the image of the platform.")
+}
+
+func (s *S) TestPlatformUpdateInfo(c *gocheck.C) {
+	info := (&platformUpdate{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "platform-update")
+	c.Assert(info.MinArgs, gocheck.Equals, 1)
+}
+
+func (s *S) TestPlatformUpdateRun(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Args: []string{"ruby"}, Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "Successfully built", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/platforms/ruby" && req.Method == "PUT" &&
+				req.FormValue("dockerfile") == "http://example.com/Dockerfile"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := platformUpdate{}
+	command.Flags().Parse(true, []string{"--dockerfile", "http://example.com/Dockerfile"})
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "Successfully built")
+}
+
+func (s *S) TestPlatformRemoveInfo(c *gocheck.C) {
+	info := platformRemove{}.Info()
+	c.Assert(info.Name, gocheck.Equals, "platform-remove")
+	c.Assert(info.Usage, gocheck.Equals, "platform-remove <name>")
+	c.Assert(info.MinArgs, gocheck.Equals, 1)
+}
+
+func (s *S) TestPlatformRemoveRun(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Args: []string{"ruby"}, Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/platforms/ruby" && req.Method == "DELETE"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	err := platformRemove{}.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "Platform \"ruby\" successfully removed!\n")
+}
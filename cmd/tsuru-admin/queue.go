@@ -0,0 +1,136 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/globocom/tsuru/cmd"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type deadLetter struct {
+	Id        string
+	Queue     string
+	Action    string
+	Args      []string
+	Attempts  uint
+	FirstSeen time.Time
+	Died      time.Time
+}
+
+type deadLetterList struct{}
+
+func (deadLetterList) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "dead-letter-list",
+		Usage:   "dead-letter-list",
+		Desc:    "list queue messages that reached the maximum number of attempts.",
+		MinArgs: 0,
+	}
+}
+
+func (deadLetterList) Run(context *cmd.Context, client *cmd.Client) error {
+	url, err := cmd.GetURL("/queue/dead-letters")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusNoContent {
+		fmt.Fprintln(context.Stdout, "No dead letters.")
+		return nil
+	}
+	defer response.Body.Close()
+	var letters []deadLetter
+	err = json.NewDecoder(response.Body).Decode(&letters)
+	if err != nil {
+		return err
+	}
+	table := cmd.NewTable()
+	table.Headers = cmd.Row([]string{"Id", "Queue", "Action", "Attempts", "Died"})
+	for _, l := range letters {
+		died := l.Died.Format(time.RFC822)
+		table.AddRow(cmd.Row([]string{l.Id, l.Queue, l.Action, fmt.Sprint(l.Attempts), died}))
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
+
+type deadLetterInfo struct{}
+
+func (deadLetterInfo) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "dead-letter-info",
+		Usage:   "dead-letter-info <id>",
+		Desc:    "show the details of a message in the dead-letter queue.",
+		MinArgs: 1,
+	}
+}
+
+func (deadLetterInfo) Run(context *cmd.Context, client *cmd.Client) error {
+	url, err := cmd.GetURL("/queue/dead-letters/" + context.Args[0])
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var l deadLetter
+	err = json.NewDecoder(response.Body).Decode(&l)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Id: %s\n", l.Id)
+	fmt.Fprintf(context.Stdout, "Queue: %s\n", l.Queue)
+	fmt.Fprintf(context.Stdout, "Action: %s\n", l.Action)
+	fmt.Fprintf(context.Stdout, "Args: %s\n", strings.Join(l.Args, " "))
+	fmt.Fprintf(context.Stdout, "Attempts: %d\n", l.Attempts)
+	fmt.Fprintf(context.Stdout, "First seen: %s\n", l.FirstSeen.Format(time.RFC822))
+	fmt.Fprintf(context.Stdout, "Died: %s\n", l.Died.Format(time.RFC822))
+	return nil
+}
+
+type deadLetterRequeue struct{}
+
+func (deadLetterRequeue) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "dead-letter-requeue",
+		Usage:   "dead-letter-requeue <id>",
+		Desc:    "put a message from the dead-letter queue back in its original queue.",
+		MinArgs: 1,
+	}
+}
+
+func (deadLetterRequeue) Run(context *cmd.Context, client *cmd.Client) error {
+	url, err := cmd.GetURL("/queue/dead-letters/" + context.Args[0] + "/requeue")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Message %s successfully put back in the queue!\n", context.Args[0])
+	return nil
+}
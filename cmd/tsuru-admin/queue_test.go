@@ -0,0 +1,107 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/testing"
+	"launchpad.net/gocheck"
+	"net/http"
+)
+
+func (s *S) TestDeadLetterListInfo(c *gocheck.C) {
+	c.Assert(deadLetterList{}.Info().Name, gocheck.Equals, "dead-letter-list")
+	c.Assert(deadLetterList{}.Info().MinArgs, gocheck.Equals, 0)
+}
+
+func (s *S) TestDeadLetterListRun(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	result := `[{"Id":"5200f3f8c4e1f6a3b0000001","Queue":"tsuru-app","Action":"start-app","Args":["myapp"],"Attempts":10,"FirstSeen":"2013-08-01T09:00:00Z","Died":"2013-08-01T10:00:00Z"}]`
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/queue/dead-letters" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	err := deadLetterList{}.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	expected := `+--------------------------+-----------+-----------+----------+---------------------+
+| Id                       | Queue     | Action    | Attempts | Died                |
++--------------------------+-----------+-----------+----------+---------------------+
+| 5200f3f8c4e1f6a3b0000001 | tsuru-app | start-app | 10       | 01 Aug 13 10:00 UTC |
++--------------------------+-----------+-----------+----------+---------------------+
+`
+	c.Assert(stdout.String(), gocheck.Equals, expected)
+}
+
+func (s *S) TestDeadLetterListRunEmpty(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.Transport{Message: "", Status: http.StatusNoContent}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	err := deadLetterList{}.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "No dead letters.\n")
+}
+
+func (s *S) TestDeadLetterInfoInfo(c *gocheck.C) {
+	c.Assert(deadLetterInfo{}.Info().Name, gocheck.Equals, "dead-letter-info")
+	c.Assert(deadLetterInfo{}.Info().MinArgs, gocheck.Equals, 1)
+}
+
+func (s *S) TestDeadLetterInfoRun(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Args:   []string{"5200f3f8c4e1f6a3b0000001"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	result := `{"Id":"5200f3f8c4e1f6a3b0000001","Queue":"tsuru-app","Action":"start-app","Args":["myapp","other"],"Attempts":10,"FirstSeen":"2013-08-01T09:00:00Z","Died":"2013-08-01T10:00:00Z"}`
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/queue/dead-letters/5200f3f8c4e1f6a3b0000001" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	err := deadLetterInfo{}.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	expected := `Id: 5200f3f8c4e1f6a3b0000001
+Queue: tsuru-app
+Action: start-app
+Args: myapp other
+Attempts: 10
+First seen: 01 Aug 13 09:00 UTC
+Died: 01 Aug 13 10:00 UTC
+`
+	c.Assert(stdout.String(), gocheck.Equals, expected)
+}
+
+func (s *S) TestDeadLetterRequeueInfo(c *gocheck.C) {
+	c.Assert(deadLetterRequeue{}.Info().Name, gocheck.Equals, "dead-letter-requeue")
+	c.Assert(deadLetterRequeue{}.Info().MinArgs, gocheck.Equals, 1)
+}
+
+func (s *S) TestDeadLetterRequeueRun(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Args:   []string{"5200f3f8c4e1f6a3b0000001"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/queue/dead-letters/5200f3f8c4e1f6a3b0000001/requeue" && req.Method == "POST"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	err := deadLetterRequeue{}.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "Message 5200f3f8c4e1f6a3b0000001 successfully put back in the queue!\n")
+}
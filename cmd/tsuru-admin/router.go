@@ -0,0 +1,90 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/globocom/tsuru/cmd"
+	"launchpad.net/gnuflag"
+	"net/http"
+)
+
+type routeProblem struct {
+	App   string
+	Kind  string
+	Entry string
+	Fixed bool
+}
+
+type routerCheck struct {
+	fs  *gnuflag.FlagSet
+	fix bool
+}
+
+func (c *routerCheck) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "router-check",
+		Usage:   "router-check [--fix]",
+		Desc:    "compare the routes of each app with the router, reporting (and optionally fixing) missing, stale and orphaned entries.",
+		MinArgs: 0,
+	}
+}
+
+func (c *routerCheck) Run(context *cmd.Context, client *cmd.Client) error {
+	url, err := cmd.GetURL("/router/check")
+	if err != nil {
+		return err
+	}
+	method := "GET"
+	if c.fix {
+		method = "POST"
+	}
+	request, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusNoContent {
+		fmt.Fprintln(context.Stdout, "The router is consistent.")
+		return nil
+	}
+	defer response.Body.Close()
+	var problems []routeProblem
+	err = json.NewDecoder(response.Body).Decode(&problems)
+	if err != nil {
+		return err
+	}
+	table := cmd.NewTable()
+	headers := []string{"App", "Problem", "Entry"}
+	if c.fix {
+		headers = append(headers, "Fixed?")
+	}
+	table.Headers = cmd.Row(headers)
+	for _, p := range problems {
+		row := []string{p.App, p.Kind, p.Entry}
+		if c.fix {
+			fixed := "No"
+			if p.Fixed {
+				fixed = "Yes"
+			}
+			row = append(row, fixed)
+		}
+		table.AddRow(cmd.Row(row))
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
+
+func (c *routerCheck) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("router-check", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.fix, "fix", false, "Fix the problems found in the router")
+	}
+	return c.fs
+}
@@ -0,0 +1,84 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/testing"
+	"launchpad.net/gocheck"
+	"net/http"
+)
+
+func (s *S) TestRouterCheckInfo(c *gocheck.C) {
+	c.Assert((&routerCheck{}).Info().Name, gocheck.Equals, "router-check")
+	c.Assert((&routerCheck{}).Info().MinArgs, gocheck.Equals, 0)
+}
+
+func (s *S) TestRouterCheckRun(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	result := `[{"App":"myapp","Kind":"missing","Entry":"backend","Fixed":false},{"App":"myapp","Kind":"stale","Entry":"route http://a:1","Fixed":false}]`
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/router/check" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	err := (&routerCheck{}).Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	expected := `+-------+---------+------------------+
+| App   | Problem | Entry            |
++-------+---------+------------------+
+| myapp | missing | backend          |
+| myapp | stale   | route http://a:1 |
++-------+---------+------------------+
+`
+	c.Assert(stdout.String(), gocheck.Equals, expected)
+}
+
+func (s *S) TestRouterCheckRunWithFix(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	result := `[{"App":"myapp","Kind":"missing","Entry":"backend","Fixed":true},{"App":"myapp","Kind":"stale","Entry":"route http://a:1","Fixed":false}]`
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/router/check" && req.Method == "POST"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := routerCheck{}
+	command.Flags().Parse(true, []string{"--fix"})
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	expected := `+-------+---------+------------------+--------+
+| App   | Problem | Entry            | Fixed? |
++-------+---------+------------------+--------+
+| myapp | missing | backend          | Yes    |
+| myapp | stale   | route http://a:1 | No     |
++-------+---------+------------------+--------+
+`
+	c.Assert(stdout.String(), gocheck.Equals, expected)
+}
+
+func (s *S) TestRouterCheckRunConsistent(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.Transport{Message: "", Status: http.StatusNoContent}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	err := (&routerCheck{}).Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "The router is consistent.\n")
+}
+
+func (s *S) TestRouterCheckFlags(c *gocheck.C) {
+	command := routerCheck{}
+	flagset := command.Flags()
+	c.Assert(flagset, gocheck.NotNil)
+	flagset.Parse(true, []string{"--fix"})
+	c.Assert(command.fix, gocheck.Equals, true)
+}
@@ -65,7 +65,7 @@ type unit struct {
 
 type app struct {
 	Ip         string
-	CName      string
+	CName      []string
 	Name       string
 	Platform   string
 	Repository string
@@ -75,8 +75,8 @@ type app struct {
 }
 
 func (a *app) Addr() string {
-	if a.CName != "" {
-		return a.CName
+	if len(a.CName) > 0 {
+		return a.CName[0]
 	}
 	return a.Ip
 }
@@ -313,7 +313,7 @@ func (c *SetCName) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:    "set-cname",
 		Usage:   "set-cname <cname> [--app appname]",
-		Desc:    `defines a cname for your app.`,
+		Desc:    `adds a cname to your app.`,
 		MinArgs: 1,
 	}
 }
@@ -323,7 +323,7 @@ type UnsetCName struct {
 }
 
 func (c *UnsetCName) Run(context *cmd.Context, client *cmd.Client) error {
-	err := unsetCName(c.GuessingCommand, client)
+	err := unsetCName(context.Args[0], c.GuessingCommand, client)
 	if err != nil {
 		return err
 	}
@@ -334,13 +334,13 @@ func (c *UnsetCName) Run(context *cmd.Context, client *cmd.Client) error {
 func (c *UnsetCName) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:    "unset-cname",
-		Usage:   "unset-cname [--app appname]",
-		Desc:    `unsets the current cname of your app.`,
-		MinArgs: 0,
+		Usage:   "unset-cname <cname> [--app appname]",
+		Desc:    `removes a cname from your app.`,
+		MinArgs: 1,
 	}
 }
 
-func unsetCName(g GuessingCommand, client *cmd.Client) error {
+func unsetCName(v string, g GuessingCommand, client *cmd.Client) error {
 	appName, err := g.Guess()
 	if err != nil {
 		return err
@@ -349,7 +349,8 @@ func unsetCName(g GuessingCommand, client *cmd.Client) error {
 	if err != nil {
 		return err
 	}
-	request, err := http.NewRequest("DELETE", url, nil)
+	body := strings.NewReader(fmt.Sprintf(`{"cname": "%s"}`, v))
+	request, err := http.NewRequest("DELETE", url, body)
 	if err != nil {
 		return err
 	}
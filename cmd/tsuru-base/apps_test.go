@@ -30,7 +30,7 @@ var appshortflag = &gnuflag.Flag{
 
 func (s *S) TestAppInfo(c *gocheck.C) {
 	var stdout, stderr bytes.Buffer
-	result := `{"name":"app1","cname":"","ip":"myapp.tsuru.io","platform":"php","repository":"git@git.com:php.git","state":"dead", "units":[{"Ip":"10.10.10.10","Name":"app1/0","State":"started"}, {"Ip":"9.9.9.9","Name":"app1/1","State":"started"}, {"Ip":"","Name":"app1/2","State":"pending"}],"teams":["tsuruteam","crane"]}`
+	result := `{"name":"app1","cname":[],"ip":"myapp.tsuru.io","platform":"php","repository":"git@git.com:php.git","state":"dead", "units":[{"Ip":"10.10.10.10","Name":"app1/0","State":"started"}, {"Ip":"9.9.9.9","Name":"app1/1","State":"started"}, {"Ip":"","Name":"app1/2","State":"pending"}],"teams":["tsuruteam","crane"]}`
 	expected := `Application: app1
 Repository: git@git.com:php.git
 Platform: php
@@ -82,7 +82,7 @@ Address: app1.tsuru.io
 
 func (s *S) TestAppInfoEmptyUnit(c *gocheck.C) {
 	var stdout, stderr bytes.Buffer
-	result := `{"name":"app1","cname":"","ip":"myapp.tsuru.io","platform":"php","repository":"git@git.com:php.git","state":"dead", "units":[{"Name":"","State":""}],"teams":["tsuruteam","crane"]}`
+	result := `{"name":"app1","cname":[],"ip":"myapp.tsuru.io","platform":"php","repository":"git@git.com:php.git","state":"dead", "units":[{"Name":"","State":""}],"teams":["tsuruteam","crane"]}`
 	expected := `Application: app1
 Repository: git@git.com:php.git
 Platform: php
@@ -141,7 +141,7 @@ Units:
 
 func (s *S) TestAppInfoCName(c *gocheck.C) {
 	var stdout, stderr bytes.Buffer
-	result := `{"name":"app1","ip":"myapp.tsuru.io","cname":"yourapp.tsuru.io","platform":"php","repository":"git@git.com:php.git","state":"dead","units":[{"Ip":"10.10.10.10","Name":"app1/0","State":"started"}, {"Ip":"9.9.9.9","Name":"app1/1","State":"started"}, {"Ip":"","Name":"app1/2","State":"pending"}],"Teams":["tsuruteam","crane"]}`
+	result := `{"name":"app1","ip":"myapp.tsuru.io","cname":["yourapp.tsuru.io","www.yourapp.com"],"platform":"php","repository":"git@git.com:php.git","state":"dead","units":[{"Ip":"10.10.10.10","Name":"app1/0","State":"started"}, {"Ip":"9.9.9.9","Name":"app1/1","State":"started"}, {"Ip":"","Name":"app1/2","State":"pending"}],"Teams":["tsuruteam","crane"]}`
 	expected := `Application: app1
 Repository: git@git.com:php.git
 Platform: php
@@ -387,7 +387,7 @@ func (s *S) TestAppListNotReady(c *gocheck.C) {
 
 func (s *S) TestAppListCName(c *gocheck.C) {
 	var stdout, stderr bytes.Buffer
-	result := `[{"ip":"10.10.10.10","cname":"app1.tsuru.io","name":"app1","ready":true,"units":[{"Name":"app1/0","State":"started"}]}]`
+	result := `[{"ip":"10.10.10.10","cname":["app1.tsuru.io"],"name":"app1","ready":true,"units":[{"Name":"app1/0","State":"started"}]}]`
 	expected := `+-------------+-------------------------+---------------+--------+
 | Application | Units State Summary     | Address       | Ready? |
 +-------------+-------------------------+---------------+--------+
@@ -568,7 +568,7 @@ func (s *S) TestSetCNameInfo(c *gocheck.C) {
 	expected := &cmd.Info{
 		Name:    "set-cname",
 		Usage:   "set-cname <cname> [--app appname]",
-		Desc:    `defines a cname for your app.`,
+		Desc:    `adds a cname to your app.`,
 		MinArgs: 1,
 	}
 	c.Assert((&SetCName{}).Info(), gocheck.DeepEquals, expected)
@@ -586,12 +586,18 @@ func (s *S) TestUnsetCName(c *gocheck.C) {
 	context := cmd.Context{
 		Stdout: &stdout,
 		Stderr: &stderr,
+		Args:   []string{"death.evergrey.mycompany.com"},
 	}
 	trans := &testing.ConditionalTransport{
 		Transport: testing.Transport{Message: "Restarted", Status: http.StatusOK},
 		CondFunc: func(req *http.Request) bool {
 			called = true
-			return req.URL.Path == "/apps/death/cname" && req.Method == "DELETE"
+			var m map[string]string
+			err := json.NewDecoder(req.Body).Decode(&m)
+			c.Assert(err, gocheck.IsNil)
+			return req.URL.Path == "/apps/death/cname" &&
+				req.Method == "DELETE" &&
+				m["cname"] == "death.evergrey.mycompany.com"
 		},
 	}
 	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
@@ -611,13 +617,19 @@ func (s *S) TestUnsetCNameWithoutTheFlag(c *gocheck.C) {
 	context := cmd.Context{
 		Stdout: &stdout,
 		Stderr: &stderr,
+		Args:   []string{"corey.evergrey.mycompany.com"},
 	}
 	fake := &FakeGuesser{name: "corey"}
 	trans := &testing.ConditionalTransport{
 		Transport: testing.Transport{Message: "Restarted", Status: http.StatusOK},
 		CondFunc: func(req *http.Request) bool {
 			called = true
-			return req.URL.Path == "/apps/corey/cname" && req.Method == "DELETE"
+			var m map[string]string
+			err := json.NewDecoder(req.Body).Decode(&m)
+			c.Assert(err, gocheck.IsNil)
+			return req.URL.Path == "/apps/corey/cname" &&
+				req.Method == "DELETE" &&
+				m["cname"] == "corey.evergrey.mycompany.com"
 		},
 	}
 	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
@@ -630,9 +642,9 @@ func (s *S) TestUnsetCNameWithoutTheFlag(c *gocheck.C) {
 func (s *S) TestUnsetCNameInfo(c *gocheck.C) {
 	expected := &cmd.Info{
 		Name:    "unset-cname",
-		Usage:   "unset-cname [--app appname]",
-		Desc:    `unsets the current cname of your app.`,
-		MinArgs: 0,
+		Usage:   "unset-cname <cname> [--app appname]",
+		Desc:    `removes a cname from your app.`,
+		MinArgs: 1,
 	}
 	c.Assert((&UnsetCName{}).Info(), gocheck.DeepEquals, expected)
 }
@@ -15,7 +15,8 @@ import (
 
 type AppRun struct {
 	GuessingCommand
-	once bool
+	once     bool
+	isolated bool
 }
 
 func (c *AppRun) Info() *cmd.Info {
@@ -23,11 +24,17 @@ func (c *AppRun) Info() *cmd.Info {
 
 If you use the '--once' flag tsuru will run the command only in one unit.
 
+If you use the '--isolated' flag (or its alias, '--detached'), tsuru will run
+the command in a new container, created from the current image of the app and
+with its environment variables, instead of in the units serving the app. The
+container is removed when the command exits. Use it for heavy tasks, like
+database migrations.
+
 If you don't provide the app name, tsuru will try to guess it.
 `
 	return &cmd.Info{
 		Name:    "run",
-		Usage:   `run <command> [commandarg1] [commandarg2] ... [commandargn] [--app appname] [--once]`,
+		Usage:   `run <command> [commandarg1] [commandarg2] ... [commandargn] [--app appname] [--once] [--isolated]`,
 		Desc:    desc,
 		MinArgs: 1,
 	}
@@ -38,7 +45,7 @@ func (c *AppRun) Run(context *cmd.Context, client *cmd.Client) error {
 	if err != nil {
 		return err
 	}
-	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/run?once=%t", appName, c.once))
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/run?once=%t&isolated=%t", appName, c.once, c.isolated))
 	if err != nil {
 		return err
 	}
@@ -61,6 +68,8 @@ func (c *AppRun) Flags() *gnuflag.FlagSet {
 		c.fs = c.GuessingCommand.Flags()
 		c.fs.BoolVar(&c.once, "once", false, "Running only one unit")
 		c.fs.BoolVar(&c.once, "o", false, "Running only one unit")
+		c.fs.BoolVar(&c.isolated, "isolated", false, "Running in a new container, instead of in the units of the app")
+		c.fs.BoolVar(&c.isolated, "detached", false, "Running in a new container, instead of in the units of the app")
 	}
 	return c.fs
 }
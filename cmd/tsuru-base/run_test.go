@@ -99,14 +99,54 @@ func (s *S) TestAppRunInfo(c *gocheck.C) {
 
 If you use the '--once' flag tsuru will run the command only in one unit.
 
+If you use the '--isolated' flag (or its alias, '--detached'), tsuru will run
+the command in a new container, created from the current image of the app and
+with its environment variables, instead of in the units serving the app. The
+container is removed when the command exits. Use it for heavy tasks, like
+database migrations.
+
 If you don't provide the app name, tsuru will try to guess it.
 `
 	expected := &cmd.Info{
 		Name:    "run",
-		Usage:   `run <command> [commandarg1] [commandarg2] ... [commandargn] [--app appname] [--once]`,
+		Usage:   `run <command> [commandarg1] [commandarg2] ... [commandargn] [--app appname] [--once] [--isolated]`,
 		Desc:    desc,
 		MinArgs: 1,
 	}
 	command := AppRun{}
 	c.Assert(command.Info(), gocheck.DeepEquals, expected)
 }
+
+func (s *S) TestAppRunIsolated(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Args:   []string{"python", "manage.py", "migrate"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{
+			Message: "migrated",
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/apps/ble/run" && req.URL.Query().Get("isolated") == "true"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := AppRun{}
+	command.Flags().Parse(true, []string{"--app", "ble", "--isolated"})
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "migrated")
+}
+
+func (s *S) TestAppRunFlags(c *gocheck.C) {
+	command := AppRun{}
+	flagset := command.Flags()
+	flagset.Parse(true, []string{"--detached"})
+	c.Assert(command.isolated, gocheck.Equals, true)
+	isolated := flagset.Lookup("isolated")
+	c.Assert(isolated, gocheck.NotNil)
+	c.Assert(isolated.DefValue, gocheck.Equals, "false")
+}
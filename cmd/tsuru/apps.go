@@ -7,29 +7,70 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/globocom/tsuru/cmd"
 	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"io"
 	"io/ioutil"
 	"launchpad.net/gnuflag"
+	"launchpad.net/goyaml"
 	"net/http"
 )
 
-type AppCreate struct{}
+type AppCreate struct {
+	from string
+	fs   *gnuflag.FlagSet
+}
 
-func (AppCreate) Run(context *cmd.Context, client *cmd.Client) error {
-	appName := context.Args[0]
-	platform := context.Args[1]
-	b := bytes.NewBufferString(fmt.Sprintf(`{"name":"%s","platform":"%s"}`, appName, platform))
-	url, err := cmd.GetURL("/apps")
-	if err != nil {
-		return err
+func (c *AppCreate) Run(context *cmd.Context, client *cmd.Client) error {
+	var (
+		appName string
+		url     string
+		body    io.Reader
+		err     error
+	)
+	if c.from != "" {
+		manifest, err := ioutil.ReadFile(c.from)
+		if err != nil {
+			return err
+		}
+		var m struct{ Name string }
+		if err = goyaml.Unmarshal(manifest, &m); err != nil {
+			return fmt.Errorf("Invalid manifest: %s", err)
+		}
+		appName = m.Name
+		path := "/apps/manifest"
+		if len(context.Args) > 0 {
+			appName = context.Args[0]
+			path += "?name=" + appName
+		}
+		url, err = cmd.GetURL(path)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(manifest)
+	} else {
+		if len(context.Args) < 2 {
+			return errors.New("You must provide the name and the platform of the app, or a manifest with --from.")
+		}
+		appName = context.Args[0]
+		platform := context.Args[1]
+		body = bytes.NewBufferString(fmt.Sprintf(`{"name":"%s","platform":"%s"}`, appName, platform))
+		url, err = cmd.GetURL("/apps")
+		if err != nil {
+			return err
+		}
 	}
-	request, err := http.NewRequest("POST", url, b)
+	request, err := http.NewRequest("POST", url, body)
 	if err != nil {
 		return err
 	}
-	request.Header.Set("Content-Type", "application/json")
+	if c.from != "" {
+		request.Header.Set("Content-Type", "application/x-yaml")
+	} else {
+		request.Header.Set("Content-Type", "application/json")
+	}
 	response, err := client.Do(request)
 	if err != nil {
 		return err
@@ -50,15 +91,68 @@ func (AppCreate) Run(context *cmd.Context, client *cmd.Client) error {
 	return nil
 }
 
-func (AppCreate) Info() *cmd.Info {
+func (c *AppCreate) Info() *cmd.Info {
 	return &cmd.Info{
-		Name:    "app-create",
-		Usage:   "app-create <appname> <platform>",
-		Desc:    "create a new app.",
-		MinArgs: 2,
+		Name:  "app-create",
+		Usage: "app-create <appname> <platform> | app-create --from <manifest> [appname]",
+		Desc: `create a new app.
+
+With the --from flag, the app is created from a manifest file, that declares
+its platform, units, environment variables, cnames and service instances (see
+app-export). The app name given as argument replaces the one in the manifest.`,
+		MinArgs: 0,
 	}
 }
 
+func (c *AppCreate) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("app-create", gnuflag.ExitOnError)
+		c.fs.StringVar(&c.from, "from", "", "Manifest file declaring the app")
+		c.fs.StringVar(&c.from, "f", "", "Manifest file declaring the app")
+	}
+	return c.fs
+}
+
+type appExport struct {
+	tsuru.GuessingCommand
+}
+
+func (c *appExport) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "app-export",
+		Usage: "app-export [--app appname]",
+		Desc: `prints the manifest of an app, that creates a similar app with app-create --from.
+
+The manifest declares the platform, the number of units, the public environment
+variables, the cnames and the service instances bound to the app.
+
+If you don't provide the app name, tsuru will try to guess it.`,
+		MinArgs: 0,
+	}
+}
+
+func (c *appExport) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/manifest", appName))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, err = io.Copy(context.Stdout, response.Body)
+	return err
+}
+
 type AppRemove struct {
 	tsuru.GuessingCommand
 	yes bool
@@ -12,17 +12,15 @@ import (
 	"io/ioutil"
 	"launchpad.net/gocheck"
 	"net/http"
+	"os"
 	"strings"
 )
 
 func (s *S) TestAppCreateInfo(c *gocheck.C) {
-	expected := &cmd.Info{
-		Name:    "app-create",
-		Usage:   "app-create <appname> <platform>",
-		Desc:    "create a new app.",
-		MinArgs: 2,
-	}
-	c.Assert((&AppCreate{}).Info(), gocheck.DeepEquals, expected)
+	info := (&AppCreate{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "app-create")
+	c.Assert(info.Usage, gocheck.Equals, "app-create <appname> <platform> | app-create --from <manifest> [appname]")
+	c.Assert(info.MinArgs, gocheck.Equals, 0)
 }
 
 func (s *S) TestAppCreate(c *gocheck.C) {
@@ -67,6 +65,101 @@ func (s *S) TestAppCreateWithInvalidFramework(c *gocheck.C) {
 	c.Assert(stdout.String(), gocheck.Equals, "")
 }
 
+func (s *S) TestAppCreateWithoutPlatform(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Args:   []string{"ble"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	command := AppCreate{}
+	err := command.Run(&context, nil)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "You must provide the name and the platform of the app, or a manifest with --from.")
+}
+
+func (s *S) TestAppCreateFromManifest(c *gocheck.C) {
+	manifest := "name: ble\nplatform: django\nunits: 2\nenv:\n  DEBUG: \"0\"\n"
+	f, err := ioutil.TempFile("", "tsuru-manifest")
+	c.Assert(err, gocheck.IsNil)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(manifest)
+	c.Assert(err, gocheck.IsNil)
+	f.Close()
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	result := `{"status":"success", "repository_url":"git@tsuru.plataformas.glb.com:ble.git"}`
+	trans := testing.ConditionalTransport{
+		Transport: testing.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			defer req.Body.Close()
+			body, err := ioutil.ReadAll(req.Body)
+			c.Assert(err, gocheck.IsNil)
+			c.Assert(string(body), gocheck.Equals, manifest)
+			return req.Method == "POST" && req.URL.Path == "/apps/manifest" && req.URL.RawQuery == ""
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: &trans}, nil, manager)
+	command := AppCreate{}
+	err = command.Flags().Parse(true, []string{"--from", f.Name()})
+	c.Assert(err, gocheck.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Matches, `App "ble" is being created!\n(?s).*`)
+}
+
+func (s *S) TestAppCreateFromManifestWithName(c *gocheck.C) {
+	f, err := ioutil.TempFile("", "tsuru-manifest")
+	c.Assert(err, gocheck.IsNil)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("name: ble\nplatform: django\n")
+	c.Assert(err, gocheck.IsNil)
+	f.Close()
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Args:   []string{"other"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	result := `{"status":"success", "repository_url":"git@tsuru.plataformas.glb.com:other.git"}`
+	trans := testing.ConditionalTransport{
+		Transport: testing.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/apps/manifest" && req.URL.Query().Get("name") == "other"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: &trans}, nil, manager)
+	command := AppCreate{}
+	err = command.Flags().Parse(true, []string{"-f", f.Name()})
+	c.Assert(err, gocheck.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Matches, `App "other" is being created!\n(?s).*`)
+}
+
+func (s *S) TestAppExportInfo(c *gocheck.C) {
+	info := (&appExport{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "app-export")
+	c.Assert(info.Usage, gocheck.Equals, "app-export [--app appname]")
+}
+
+func (s *S) TestAppExport(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	manifest := "name: myapp\nplatform: python\nunits: 2\n"
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: manifest, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/apps/myapp/manifest" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := appExport{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, manifest)
+}
+
 func (s *S) TestAppRemove(c *gocheck.C) {
 	var stdout, stderr bytes.Buffer
 	expected := `Are you sure you want to remove app "ble"? (y/n) App "ble" successfully removed!` + "\n"
@@ -0,0 +1,170 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"github.com/globocom/tsuru/fs"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+type certificateSet struct {
+	tsuru.GuessingCommand
+	fsystem fs.Fs
+}
+
+func (c *certificateSet) fs() fs.Fs {
+	if c.fsystem == nil {
+		c.fsystem = fs.OsFs{}
+	}
+	return c.fsystem
+}
+
+func (c *certificateSet) readFile(path string) (string, error) {
+	f, err := c.fs().Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	return string(content), err
+}
+
+func (c *certificateSet) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "certificate-set",
+		Usage:   "certificate-set <cname> <path/to/certificate.crt> <path/to/key.key> [--app appname]",
+		Desc:    "defines the TLS certificate used for serving one of the cnames of your app.",
+		MinArgs: 3,
+	}
+}
+
+func (c *certificateSet) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	cname := context.Args[0]
+	cert, err := c.readFile(context.Args[1])
+	if err != nil {
+		return err
+	}
+	key, err := c.readFile(context.Args[2])
+	if err != nil {
+		return err
+	}
+	params := map[string]string{"cname": cname, "certificate": cert, "key": key}
+	var body bytes.Buffer
+	if err = json.NewEncoder(&body).Encode(params); err != nil {
+		return err
+	}
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/certificates", appName))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", url, &body)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Certificate of %q successfully defined.\n", cname)
+	return nil
+}
+
+type certificateUnset struct {
+	tsuru.GuessingCommand
+}
+
+func (c *certificateUnset) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "certificate-unset",
+		Usage:   "certificate-unset <cname> [--app appname]",
+		Desc:    "removes the TLS certificate of one of the cnames of your app.",
+		MinArgs: 1,
+	}
+}
+
+func (c *certificateUnset) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/certificates/%s", appName, context.Args[0]))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Certificate of %q successfully removed.\n", context.Args[0])
+	return nil
+}
+
+type certificate struct {
+	CName      string
+	Expiration time.Time
+}
+
+type certificateList struct {
+	tsuru.GuessingCommand
+}
+
+func (c *certificateList) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "certificate-list",
+		Usage:   "certificate-list [--app appname]",
+		Desc:    "lists the TLS certificates of the cnames of your app.",
+		MinArgs: 0,
+	}
+}
+
+func (c *certificateList) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/certificates", appName))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		fmt.Fprintln(context.Stdout, "No certificates defined.")
+		return nil
+	}
+	var certs []certificate
+	if err = json.NewDecoder(resp.Body).Decode(&certs); err != nil {
+		return err
+	}
+	table := cmd.NewTable()
+	table.Headers = cmd.Row([]string{"CName", "Expiration"})
+	for _, cert := range certs {
+		table.AddRow(cmd.Row([]string{cert.CName, cert.Expiration.UTC().Format("2006-01-02 15:04:05")}))
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
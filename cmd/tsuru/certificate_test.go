@@ -0,0 +1,163 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/testing"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	fs_test "github.com/globocom/tsuru/fs/testing"
+	"launchpad.net/gocheck"
+	"net/http"
+)
+
+func (s *S) TestCertificateSetInfo(c *gocheck.C) {
+	expected := &cmd.Info{
+		Name:    "certificate-set",
+		Usage:   "certificate-set <cname> <path/to/certificate.crt> <path/to/key.key> [--app appname]",
+		Desc:    "defines the TLS certificate used for serving one of the cnames of your app.",
+		MinArgs: 3,
+	}
+	c.Assert((&certificateSet{}).Info(), gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestCertificateSet(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	var called bool
+	context := cmd.Context{
+		Args:   []string{"myapp.com", "myapp.crt", "myapp.key"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			called = true
+			var params map[string]string
+			err := json.NewDecoder(req.Body).Decode(&params)
+			c.Assert(err, gocheck.IsNil)
+			c.Assert(params["cname"], gocheck.Equals, "myapp.com")
+			c.Assert(params["certificate"], gocheck.Equals, "file content")
+			c.Assert(params["key"], gocheck.Equals, "file content")
+			return req.URL.Path == "/apps/myapp/certificates" && req.Method == "POST"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	fs := fs_test.RecordingFs{FileContent: "file content"}
+	command := certificateSet{
+		GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}},
+		fsystem:         &fs,
+	}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(called, gocheck.Equals, true)
+	c.Assert(stdout.String(), gocheck.Equals, `Certificate of "myapp.com" successfully defined.`+"\n")
+	c.Assert(fs.HasAction("open myapp.crt"), gocheck.Equals, true)
+	c.Assert(fs.HasAction("open myapp.key"), gocheck.Equals, true)
+}
+
+func (s *S) TestCertificateSetFileNotFound(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Args:   []string{"myapp.com", "myapp.crt", "myapp.key"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	fs := fs_test.FileNotFoundFs{RecordingFs: fs_test.RecordingFs{}}
+	command := certificateSet{
+		GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}},
+		fsystem:         &fs,
+	}
+	err := command.Run(&context, nil)
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestCertificateUnsetInfo(c *gocheck.C) {
+	expected := &cmd.Info{
+		Name:    "certificate-unset",
+		Usage:   "certificate-unset <cname> [--app appname]",
+		Desc:    "removes the TLS certificate of one of the cnames of your app.",
+		MinArgs: 1,
+	}
+	c.Assert((&certificateUnset{}).Info(), gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestCertificateUnset(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	var called bool
+	context := cmd.Context{
+		Args:   []string{"myapp.com"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			called = true
+			return req.URL.Path == "/apps/myapp/certificates/myapp.com" && req.Method == "DELETE"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := certificateUnset{}
+	command.Flags().Parse(true, []string{"--app", "myapp"})
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(called, gocheck.Equals, true)
+	c.Assert(stdout.String(), gocheck.Equals, `Certificate of "myapp.com" successfully removed.`+"\n")
+}
+
+func (s *S) TestCertificateListInfo(c *gocheck.C) {
+	expected := &cmd.Info{
+		Name:    "certificate-list",
+		Usage:   "certificate-list [--app appname]",
+		Desc:    "lists the TLS certificates of the cnames of your app.",
+		MinArgs: 0,
+	}
+	c.Assert((&certificateList{}).Info(), gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestCertificateList(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	result := `[{"CName":"myapp.com","Certificate":"cert","Expiration":"2126-09-24T20:44:20Z"}]`
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/apps/myapp/certificates" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := certificateList{}
+	command.Flags().Parse(true, []string{"--app", "myapp"})
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	expected := `+-----------+---------------------+
+| CName     | Expiration          |
++-----------+---------------------+
+| myapp.com | 2126-09-24 20:44:20 |
++-----------+---------------------+
+`
+	c.Assert(stdout.String(), gocheck.Equals, expected)
+}
+
+func (s *S) TestCertificateListEmpty(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	trans := &testing.Transport{Message: "", Status: http.StatusNoContent}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := certificateList{}
+	command.Flags().Parse(true, []string{"--app", "myapp"})
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "No certificates defined.\n")
+}
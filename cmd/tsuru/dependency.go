@@ -0,0 +1,89 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"net/http"
+)
+
+type dependencyAdd struct {
+	tsuru.GuessingCommand
+}
+
+func (c *dependencyAdd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "dependency-add",
+		Usage: "dependency-add <dependency-app> [--app appname]",
+		Desc: `declares that your app depends on another app.
+
+The internal addresses of the units of the dependency are injected in the
+environment of your app, in the variables <APP>_INTERNAL_URL and
+<APP>_INTERNAL_URLS, and kept up to date when units are added or removed.`,
+		MinArgs: 1,
+	}
+}
+
+func (c *dependencyAdd) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	dependency := context.Args[0]
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/dependencies/%s", appName, dependency))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "App %q now depends on app %q.\n", appName, dependency)
+	return nil
+}
+
+type dependencyRemove struct {
+	tsuru.GuessingCommand
+}
+
+func (c *dependencyRemove) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "dependency-remove",
+		Usage: "dependency-remove <dependency-app> [--app appname]",
+		Desc: `removes a dependency of your app.
+
+The variables holding the internal addresses of the dependency are removed
+from the environment of your app.`,
+		MinArgs: 1,
+	}
+}
+
+func (c *dependencyRemove) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	dependency := context.Args[0]
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/dependencies/%s", appName, dependency))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "App %q no longer depends on app %q.\n", appName, dependency)
+	return nil
+}
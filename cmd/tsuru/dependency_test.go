@@ -0,0 +1,66 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/testing"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"launchpad.net/gocheck"
+	"net/http"
+)
+
+func (s *S) TestDependencyAddInfo(c *gocheck.C) {
+	info := (&dependencyAdd{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "dependency-add")
+	c.Assert(info.Usage, gocheck.Equals, "dependency-add <dependency-app> [--app appname]")
+	c.Assert(info.MinArgs, gocheck.Equals, 1)
+}
+
+func (s *S) TestDependencyAdd(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	var called bool
+	context := cmd.Context{Args: []string{"backend"}, Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			called = true
+			return req.URL.Path == "/apps/myapp/dependencies/backend" && req.Method == "POST"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := dependencyAdd{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(called, gocheck.Equals, true)
+	c.Assert(stdout.String(), gocheck.Equals, `App "myapp" now depends on app "backend".`+"\n")
+}
+
+func (s *S) TestDependencyRemoveInfo(c *gocheck.C) {
+	info := (&dependencyRemove{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "dependency-remove")
+	c.Assert(info.Usage, gocheck.Equals, "dependency-remove <dependency-app> [--app appname]")
+	c.Assert(info.MinArgs, gocheck.Equals, 1)
+}
+
+func (s *S) TestDependencyRemove(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	var called bool
+	context := cmd.Context{Args: []string{"backend"}, Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			called = true
+			return req.URL.Path == "/apps/myapp/dependencies/backend" && req.Method == "DELETE"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := dependencyRemove{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(called, gocheck.Equals, true)
+	c.Assert(stdout.String(), gocheck.Equals, `App "myapp" no longer depends on app "backend".`+"\n")
+}
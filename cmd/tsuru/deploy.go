@@ -0,0 +1,376 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"io"
+	"launchpad.net/gnuflag"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ignoreFile is the name of the file, in the root of the deployed directory,
+// that lists the patterns of files that are not sent to tsuru.
+const ignoreFile = ".tsuruignore"
+
+type appDeploy struct {
+	tsuru.GuessingCommand
+	image string
+	fs    *gnuflag.FlagSet
+}
+
+func (c *appDeploy) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "app-deploy",
+		Usage: "app-deploy <directory> [--app appname] | app-deploy --image <image> [--app appname]",
+		Desc: `deploys the code in the given directory, without git.
+
+The directory is compressed and sent to tsuru, that builds it the same way as a
+git push. Files matching the patterns listed in the .tsuruignore file, in the
+root of the directory, are not sent.
+
+With the --image flag, the app is deployed from the given prebuilt docker
+image, instead of being built by tsuru.
+
+If you don't provide the app name, tsuru will try to guess it.`,
+	}
+}
+
+func (c *appDeploy) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.StringVar(&c.image, "image", "", "Prebuilt docker image to deploy, instead of a directory")
+		c.fs.StringVar(&c.image, "i", "", "Prebuilt docker image to deploy, instead of a directory")
+	}
+	return c.fs
+}
+
+func (c *appDeploy) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	deployURL, err := cmd.GetURL(fmt.Sprintf("/apps/%s/deploy", appName))
+	if err != nil {
+		return err
+	}
+	var request *http.Request
+	if c.image != "" {
+		request, err = imageRequest(deployURL, c.image)
+	} else if len(context.Args) > 0 {
+		request, err = archiveRequest(deployURL, context.Args[0])
+	} else {
+		return errors.New("You must provide the directory or the image to deploy.")
+	}
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, err = io.Copy(context.Stdout, response.Body)
+	return err
+}
+
+// imageRequest returns the request that deploys the given image.
+func imageRequest(deployURL, image string) (*http.Request, error) {
+	body := strings.NewReader(url.Values{"image": {image}}.Encode())
+	request, err := http.NewRequest("POST", deployURL, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return request, nil
+}
+
+// archiveRequest returns the request that deploys the given directory,
+// sending it in a tar.gz archive.
+func archiveRequest(deployURL, dir string) (*http.Request, error) {
+	ignored, err := readIgnoreFile(filepath.Join(dir, ignoreFile))
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	file, err := writer.CreateFormFile("file", "archive.tar.gz")
+	if err != nil {
+		return nil, err
+	}
+	if err = buildArchive(file, dir, ignored); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest("POST", deployURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return request, nil
+}
+
+// readIgnoreFile returns the patterns listed in the given ignore file, one
+// per line. Empty lines and lines starting with # are skipped. A missing
+// file means that no file is ignored.
+func readIgnoreFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, strings.TrimRight(line, "/"))
+	}
+	return patterns, scanner.Err()
+}
+
+// isIgnored checks whether the given path, relative to the root of the
+// deployed directory, matches any of the patterns. Patterns are matched
+// against the whole path and against its last element.
+func isIgnored(path string, patterns []string) bool {
+	base := filepath.Base(path)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// buildArchive writes a tar.gz archive with the contents of the given
+// directory, skipping the files that match the ignored patterns.
+func buildArchive(w io.Writer, dir string, ignored []string) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if isIgnored(rel, ignored) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err = tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err = tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+type deploy struct {
+	Id        string
+	Timestamp time.Time
+	End       time.Time
+	Commit    string
+	User      string
+	Status    string
+}
+
+type appDeployList struct {
+	tsuru.GuessingCommand
+}
+
+func (c *appDeployList) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "app-deploy-list",
+		Usage:   "app-deploy-list [--app appname]",
+		Desc:    "lists the deploys of your app, newest first.",
+		MinArgs: 0,
+	}
+}
+
+func (c *appDeployList) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	deploysURL, err := cmd.GetURL(fmt.Sprintf("/apps/%s/deploys", appName))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", deploysURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var deploys []deploy
+	if err = json.NewDecoder(resp.Body).Decode(&deploys); err != nil {
+		return err
+	}
+	if len(deploys) == 0 {
+		fmt.Fprintln(context.Stdout, "No deploys.")
+		return nil
+	}
+	table := cmd.NewTable()
+	table.Headers = cmd.Row([]string{"Id", "Start", "Duration", "Commit", "User", "Status"})
+	for _, d := range deploys {
+		duration := "-"
+		if !d.End.IsZero() {
+			duration = d.End.Sub(d.Timestamp).String()
+		}
+		start := d.Timestamp.UTC().Format("2006-01-02 15:04:05")
+		table.AddRow(cmd.Row([]string{d.Id, start, duration, d.Commit, d.User, d.Status}))
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
+
+type appDeployCancel struct {
+	tsuru.GuessingCommand
+}
+
+func (c *appDeployCancel) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "app-deploy-cancel",
+		Usage:   "app-deploy-cancel [--app appname]",
+		Desc:    "cancels the deploy of your app in progress.",
+		MinArgs: 0,
+	}
+}
+
+func (c *appDeployCancel) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	cancelURL, err := cmd.GetURL(fmt.Sprintf("/apps/%s/deploys/current", appName))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", cancelURL, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "The deploy of the app %q was canceled.\n", appName)
+	return nil
+}
+
+type appPromote struct {
+	from string
+	to   string
+	fs   *gnuflag.FlagSet
+}
+
+func (c *appPromote) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "app-promote",
+		Usage: "app-promote --from <appname> --to <appname>",
+		Desc: `deploys an app with the build of another app.
+
+The build released in the last successful deploy of the app given in --from is
+deployed in the app given in --to, without being built again. The target app
+keeps its own environment variables and service binds. You must be able to
+deploy both apps.`,
+		MinArgs: 0,
+	}
+}
+
+func (c *appPromote) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("app-promote", gnuflag.ExitOnError)
+		c.fs.StringVar(&c.from, "from", "", "The app to promote")
+		c.fs.StringVar(&c.to, "to", "", "The app that receives the build")
+	}
+	return c.fs
+}
+
+func (c *appPromote) Run(context *cmd.Context, client *cmd.Client) error {
+	if c.from == "" || c.to == "" {
+		return errors.New("You must provide both the --from and the --to apps.")
+	}
+	promoteURL, err := cmd.GetURL(fmt.Sprintf("/apps/%s/promote", c.to))
+	if err != nil {
+		return err
+	}
+	body := strings.NewReader(url.Values{"from": {c.from}}.Encode())
+	request, err := http.NewRequest("POST", promoteURL, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, err = io.Copy(context.Stdout, response.Body)
+	return err
+}
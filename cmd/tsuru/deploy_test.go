@@ -0,0 +1,297 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/testing"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"io"
+	"io/ioutil"
+	"launchpad.net/gocheck"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+func createDeployDir(c *gocheck.C) string {
+	dir, err := ioutil.TempDir("", "tsuru-deploy")
+	c.Assert(err, gocheck.IsNil)
+	files := map[string]string{
+		"app.py":             "print 'hello'",
+		"requirements.txt":   "flask",
+		"static/style.css":   "body {}",
+		"app.pyc":            "compiled",
+		".git/HEAD":          "ref: refs/heads/master",
+		"logs/production.og": "GET /",
+		ignoreFile:           "# compiled files\n*.pyc\n\n.git\nlogs/\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		c.Assert(err, gocheck.IsNil)
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		c.Assert(err, gocheck.IsNil)
+	}
+	return dir
+}
+
+func readArchive(c *gocheck.C, r io.Reader) map[string]string {
+	gzipReader, err := gzip.NewReader(r)
+	c.Assert(err, gocheck.IsNil)
+	tarReader := tar.NewReader(gzipReader)
+	files := make(map[string]string)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, gocheck.IsNil)
+		content, err := ioutil.ReadAll(tarReader)
+		c.Assert(err, gocheck.IsNil)
+		files[header.Name] = string(content)
+	}
+	return files
+}
+
+func (s *S) TestAppDeployInfo(c *gocheck.C) {
+	info := (&appDeploy{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "app-deploy")
+	c.Assert(info.Usage, gocheck.Equals, "app-deploy <directory> [--app appname] | app-deploy --image <image> [--app appname]")
+	c.Assert(info.MinArgs, gocheck.Equals, 0)
+}
+
+func (s *S) TestAppDeployFlags(c *gocheck.C) {
+	command := appDeploy{}
+	flagset := command.Flags()
+	flagset.Parse(true, []string{"--image", "registry.tsuru.io/myapp:1.0"})
+	c.Assert(command.image, gocheck.Equals, "registry.tsuru.io/myapp:1.0")
+	image := flagset.Lookup("image")
+	c.Assert(image.Usage, gocheck.Equals, "Prebuilt docker image to deploy, instead of a directory")
+	simage := flagset.Lookup("i")
+	c.Assert(simage.Usage, gocheck.Equals, "Prebuilt docker image to deploy, instead of a directory")
+}
+
+func (s *S) TestReadIgnoreFile(c *gocheck.C) {
+	dir := createDeployDir(c)
+	defer os.RemoveAll(dir)
+	patterns, err := readIgnoreFile(filepath.Join(dir, ignoreFile))
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(patterns, gocheck.DeepEquals, []string{"*.pyc", ".git", "logs"})
+}
+
+func (s *S) TestReadIgnoreFileNotFound(c *gocheck.C) {
+	patterns, err := readIgnoreFile("/tmp/not-found/.tsuruignore")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(patterns, gocheck.HasLen, 0)
+}
+
+func (s *S) TestIsIgnored(c *gocheck.C) {
+	patterns := []string{"*.pyc", ".git", "static/*.css"}
+	var tests = []struct {
+		path    string
+		ignored bool
+	}{
+		{"app.py", false},
+		{"app.pyc", true},
+		{"lib/util.pyc", true},
+		{".git", true},
+		{"static/style.css", true},
+		{"static/logo.png", false},
+	}
+	for _, t := range tests {
+		c.Check(isIgnored(t.path, patterns), gocheck.Equals, t.ignored)
+	}
+}
+
+func (s *S) TestBuildArchive(c *gocheck.C) {
+	dir := createDeployDir(c)
+	defer os.RemoveAll(dir)
+	var buf bytes.Buffer
+	err := buildArchive(&buf, dir, []string{"*.pyc", ".git", "logs"})
+	c.Assert(err, gocheck.IsNil)
+	files := readArchive(c, &buf)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	expected := []string{ignoreFile, "app.py", "requirements.txt", "static/", "static/style.css"}
+	c.Assert(names, gocheck.DeepEquals, expected)
+	c.Assert(files["app.py"], gocheck.Equals, "print 'hello'")
+	c.Assert(files["static/style.css"], gocheck.Equals, "body {}")
+}
+
+func (s *S) TestAppDeploy(c *gocheck.C) {
+	dir := createDeployDir(c)
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	var files map[string]string
+	context := cmd.Context{Args: []string{dir}, Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "deploy done", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			file, _, err := req.FormFile("file")
+			if err != nil {
+				return false
+			}
+			defer file.Close()
+			files = readArchive(c, file)
+			return req.URL.Path == "/apps/myapp/deploy" && req.Method == "POST"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := appDeploy{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "deploy done")
+	c.Assert(files["app.py"], gocheck.Equals, "print 'hello'")
+	_, ok := files["app.pyc"]
+	c.Assert(ok, gocheck.Equals, false)
+}
+
+func (s *S) TestAppDeployImage(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "deploy done", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/apps/myapp/deploy" && req.Method == "POST" &&
+				req.FormValue("image") == "registry.tsuru.io/myapp:1.0"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := appDeploy{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	command.Flags().Parse(true, []string{"--image", "registry.tsuru.io/myapp:1.0"})
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "deploy done")
+}
+
+func (s *S) TestAppDeployWithoutDirectoryOrImage(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	command := appDeploy{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, nil)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "You must provide the directory or the image to deploy.")
+}
+
+func (s *S) TestAppDeployListInfo(c *gocheck.C) {
+	expected := &cmd.Info{
+		Name:    "app-deploy-list",
+		Usage:   "app-deploy-list [--app appname]",
+		Desc:    "lists the deploys of your app, newest first.",
+		MinArgs: 0,
+	}
+	c.Assert((&appDeployList{}).Info(), gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestAppDeployList(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	result := `[{"Id":"5283e7e2a6b3e8a5c8000001","Timestamp":"2013-11-13T20:44:20Z","End":"2013-11-13T20:45:50Z","Commit":"e82nn93","User":"someone@tsuru.io","Status":"succeeded"},
+{"Id":"5283e7e2a6b3e8a5c8000002","Timestamp":"2013-11-14T10:00:00Z","End":"0001-01-01T00:00:00Z","Commit":"","User":"someone@tsuru.io","Status":"running"}]`
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/apps/myapp/deploys" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := appDeployList{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	expected := `+--------------------------+---------------------+----------+---------+------------------+-----------+
+| Id                       | Start               | Duration | Commit  | User             | Status    |
++--------------------------+---------------------+----------+---------+------------------+-----------+
+| 5283e7e2a6b3e8a5c8000001 | 2013-11-13 20:44:20 | 1m30s    | e82nn93 | someone@tsuru.io | succeeded |
+| 5283e7e2a6b3e8a5c8000002 | 2013-11-14 10:00:00 | -        |         | someone@tsuru.io | running   |
++--------------------------+---------------------+----------+---------+------------------+-----------+
+`
+	c.Assert(stdout.String(), gocheck.Equals, expected)
+}
+
+func (s *S) TestAppDeployListEmpty(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "null", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/apps/myapp/deploys" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := appDeployList{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "No deploys.\n")
+}
+
+func (s *S) TestAppDeployCancelInfo(c *gocheck.C) {
+	expected := &cmd.Info{
+		Name:    "app-deploy-cancel",
+		Usage:   "app-deploy-cancel [--app appname]",
+		Desc:    "cancels the deploy of your app in progress.",
+		MinArgs: 0,
+	}
+	c.Assert((&appDeployCancel{}).Info(), gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestAppDeployCancel(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/apps/myapp/deploys/current" && req.Method == "DELETE"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := appDeployCancel{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, `The deploy of the app "myapp" was canceled.`+"\n")
+}
+
+func (s *S) TestAppPromoteInfo(c *gocheck.C) {
+	info := (&appPromote{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "app-promote")
+	c.Assert(info.Usage, gocheck.Equals, "app-promote --from <appname> --to <appname>")
+}
+
+func (s *S) TestAppPromote(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "Promote called", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/apps/production/promote" && req.Method == "POST" &&
+				req.FormValue("from") == "staging"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := appPromote{}
+	err := command.Flags().Parse(true, []string{"--from", "staging", "--to", "production"})
+	c.Assert(err, gocheck.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "Promote called")
+}
+
+func (s *S) TestAppPromoteWithoutApps(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	command := appPromote{}
+	err := command.Flags().Parse(true, []string{"--from", "staging"})
+	c.Assert(err, gocheck.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "You must provide both the --from and the --to apps.")
+}
@@ -47,9 +47,25 @@ The currently available commands are (grouped by subject):
 	log               shows log for an app
 	run               runs a command in all units of an app
 	restart           restarts the app's application server
-	set-cname         defines a cname for an app
-	unset-cname       unsets the cname from an app
+	set-cname         adds a cname to an app
+	unset-cname       removes a cname from an app
+	certificate-set   defines the TLS certificate of a cname
+	certificate-unset removes the TLS certificate of a cname
+	certificate-list  lists the TLS certificates of an app
 	swap              swaps the router between two apps
+	traffic-split     sends a percentage of the traffic of an app to another app
+	dependency-add    declares that an app depends on another app
+	dependency-remove removes a dependency of an app
+	job-add           schedules a command to run periodically in an app
+	job-list          lists the jobs of an app
+	job-remove        removes a job of an app
+	job-runs          shows the last runs of a job, with their output
+	app-shell         opens an interactive shell in a unit of an app
+	app-deploy        deploys the code in a directory or a docker image, without git
+	app-deploy-list   lists the deploys of an app
+	app-deploy-cancel cancels the deploy of an app in progress
+	app-promote       deploys an app with the build of another app
+	app-export        prints the manifest of an app, used to create similar apps
 
 	env-get           display environment variables for an app
 	env-set           set environment variable(s) to an app
@@ -298,6 +314,29 @@ In order to create an app, you need to be member of at least one team. All
 teams that you are member (see "tsuru team-list") will be able to access the
 app.
 
+	% tsuru app-create --from <manifest> [app-name]
+
+With the --from flag, the app is created from a YAML manifest, that declares
+its platform, number of units, environment variables, cnames and service
+instances:
+
+	name: myapp
+	platform: python
+	units: 2
+	env:
+	  DEBUG: "0"
+	cnames:
+	  - myapp.example.com
+	services:
+	  - instance: myapp-mysql
+	    service: mysql
+	  - instance: shared-redis
+
+Service instances declared with their service are created when they do not
+exist, and all of them are bound to the app. The app name given as argument
+replaces the name in the manifest, so one manifest creates many similar apps.
+If any step fails, the app is removed, along with everything created for it.
+
 
 Remove an app
 
@@ -405,7 +444,7 @@ Run an arbitrary command in the app machine
 
 Usage:
 
-	% tsuru run <command> [commandarg1] [commandarg2] ... [commandargn] [--app appname]
+	% tsuru run <command> [commandarg1] [commandarg2] ... [commandargn] [--app appname] [--once] [--isolated]
 
 Run will run an arbitrary command in the app machine. Base directory for all
 commands is the root of the app. For example, in a Django app, "tsuru run" may
@@ -426,31 +465,77 @@ show the following output:
 	urls.py
 	urls.pyc
 
+With the --isolated flag (or its alias, --detached), the command runs in a new
+container, created from the current image of the app and with its environment
+variables, instead of in the units serving the app. The output of the command
+is streamed while it runs, its exit status is recorded in the log of the app,
+and the container is removed when the command exits. Use it for heavy tasks,
+like database migrations, that should not compete with production traffic.
+
 The --app flag is optional, see "Guessing app names" section for more details.
 
 
-Define a CNAME for the app
+Add a CNAME to the app
 
 Usage:
 
 	% tsuru set-cname <cname> [--app appname]
 
-set-cname will define a CNAME for the app. It will not manage any DNS register,
-it's up to the user to create the DNS register. Once the app contains a custom
-CNAME, it will be displayed by "app-list" and "app-info".
+set-cname will add a CNAME to the app. An app may have many CNAMEs, and each
+CNAME may be used by only one app. It will not manage any DNS register, it's up
+to the user to create the DNS register. Once the app contains a custom CNAME,
+the first one will be displayed by "app-list" and "app-info".
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+
+Remove a CNAME from the app
+
+Usage:
+
+	% tsuru unset-cname <cname> [--app appname]
+
+unset-cname undoes the change that set-cname does, removing the given CNAME
+from the app. After removing all CNAMEs from the app, "app-list" and "app-info"
+will display the internal, unfriendly address that tsuru uses.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+
+Define the TLS certificate of a CNAME
+
+Usage:
+
+	% tsuru certificate-set <cname> <path/to/certificate.crt> <path/to/key.key> [--app appname]
+
+certificate-set uploads the certificate and the private key used for serving
+one of the CNAMEs of the app using HTTPS. Both files must be PEM encoded, and
+the certificate must be valid for the CNAME. Defining a certificate for a CNAME
+that already has one replaces it. The router in use must support TLS.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+
+Remove the TLS certificate of a CNAME
+
+Usage:
+
+	% tsuru certificate-unset <cname> [--app appname]
+
+certificate-unset removes the certificate of one of the CNAMEs of the app.
+Removing a CNAME with "unset-cname" also removes its certificate.
 
 The --app flag is optional, see "Guessing app names" section for more details.
 
 
-Undefine the CNAME from the app
+List the TLS certificates of an app
 
 Usage:
 
-	% tsuru unset-cname [--app appname]
+	% tsuru certificate-list [--app appname]
 
-unset-cname undoes the change that set-cname does. After unsetting the CNAME
-from the app, "app-list" and "app-info" will display the internal, unfriendly
-address that tsuru uses.
+certificate-list displays the CNAMEs of the app that have a certificate, along
+with the expiration date of each certificate.
 
 The --app flag is optional, see "Guessing app names" section for more details.
 
@@ -587,6 +672,205 @@ Usage:
 
 swap will swap the routing between two apps enabling blue/green deploy, zero downtime and make the rollbacks easier.
 
+Split the traffic between two apps
+
+Usage:
+
+	% tsuru traffic-split <weight> [target-app] [--app appname]
+
+traffic-split sends a percentage (the weight) of the traffic of the app to the
+units of the target app, allowing canary releases: deploy the new version of
+your code to the target app, send a small share of the traffic to it and
+increase the weight as you gain confidence.
+
+A weight of 0 sends all the traffic back to the units of the app (the target
+app is not needed in this case), and a weight of 100 finalizes the split,
+swapping the apps. Apps involved in a split can't be swapped with the swap
+command.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+Declare a dependency between apps
+
+Usage:
+
+	% tsuru dependency-add <dependency-app> [--app appname]
+
+dependency-add declares that the app depends on another app, injecting the
+internal addresses of the units of the dependency in the environment of the
+app. The variable <APP>_INTERNAL_URL holds the address of one of the units,
+and <APP>_INTERNAL_URLS holds a comma-separated list with the addresses of all
+units, where <APP> is the name of the dependency in uppercase, with dashes and
+dots replaced by underscores. The variables are updated whenever units of the
+dependency are added or removed.
+
+You need access to both apps. The --app flag is optional, see "Guessing app
+names" section for more details.
+
+Remove a dependency between apps
+
+Usage:
+
+	% tsuru dependency-remove <dependency-app> [--app appname]
+
+dependency-remove removes the dependency, unsetting the variables that hold
+the internal addresses of the dependency.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+Schedule a job
+
+Usage:
+
+	% tsuru job-add <schedule> <command> [--app appname]
+
+job-add schedules the command to run periodically in one unit of the app,
+with the environment variables of the app, like "tsuru run --once". The
+schedule is a cron expression with five fields: minute, hour, day of the
+month, month and day of the week, in the time zone of the tsuru servers.
+The shortcuts @hourly, @daily, @weekly, @monthly and @yearly are also accepted.
+For instance, to send a report every day at 3 AM:
+
+	% tsuru job-add "0 3 * * *" python manage.py report
+
+Each run happens only once, even when there are many tsuru servers.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+List the jobs of an app
+
+Usage:
+
+	% tsuru job-list [--app appname]
+
+job-list lists the jobs of the app, with their ids, schedules, commands and
+the time of their next runs.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+Remove a job
+
+Usage:
+
+	% tsuru job-remove <id> [--app appname]
+
+job-remove removes the job with the given id, along with the history of its
+runs.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+Show the runs of a job
+
+Usage:
+
+	% tsuru job-runs <id> [--app appname]
+
+job-runs shows the last runs of the job with the given id, newest first, with
+their status, duration and output.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+Open a shell in a unit of an app
+
+Usage:
+
+	% tsuru app-shell [unit-id] [--isolated] [--app appname]
+
+app-shell opens an interactive shell in a unit of the app, identified by its
+id or a prefix of it. Without the unit id, the shell is opened in the first
+unit of the app.
+
+With the --isolated flag, the shell is opened in a new container, created from
+the image of the app and with its environment variables, that is removed when
+the shell exits. Use it to inspect the app without touching the units that
+serve it.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+Deploy an app without git
+
+Usage:
+
+	% tsuru app-deploy <directory> [--app appname]
+	% tsuru app-deploy --image <image> [--app appname]
+
+app-deploy compresses the given directory and sends it to tsuru, that builds
+it the same way as a git push. Use it to deploy artifacts built by your CI, or
+code kept in other version control systems.
+
+Files matching the patterns listed in the .tsuruignore file, in the root of the
+directory, are not sent. Patterns are matched against the path of the file,
+relative to the directory, and against its name. Empty lines and lines starting
+with # are skipped. Example:
+
+	# compiled files
+	*.pyc
+	.git
+	logs
+
+With the --image flag, the app is deployed from a prebuilt docker image,
+instead of being built by tsuru. The image is pulled and tagged as the image of
+the app, so it must include the command that starts the app. Example:
+
+	% tsuru app-deploy --image registry.example.com/myapp:1.0
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+List the deploys of an app
+
+Usage:
+
+	% tsuru app-deploy-list [--app appname]
+
+app-deploy-list lists the deploys of the app, newest first, with their commit,
+the user that triggered them, their status, start time and duration. The full
+output of each deploy is kept by tsuru, and available in the API.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+Cancel a deploy in progress
+
+Usage:
+
+	% tsuru app-deploy-cancel [--app appname]
+
+app-deploy-cancel interrupts the deploy of the app in progress. The build of
+the app is stopped and rolled back, and the units keep running the previous
+version of the app. Only one deploy of each app runs at a time, so canceling
+a stuck deploy allows new deploys to start. Canceling a deploy that has
+already been canceled releases it, even if it is not responding.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+Export the manifest of an app
+
+Usage:
+
+	% tsuru app-export [--app appname]
+
+app-export prints the manifest of an app, with its platform, number of units,
+public environment variables, cnames and the service instances bound to it.
+Save it in a file and give it to "tsuru app-create --from" to create a similar
+app. Private environment variables are not exported.
+
+The --app flag is optional, see "Guessing app names" section for more details.
+
+
+Promote the build of an app to another app
+
+Usage:
+
+	% tsuru app-promote --from <appname> --to <appname>
+
+app-promote deploys the app given in --to with the build released in the last
+successful deploy of the app given in --from, without building it again. It's
+useful for promoting the exact build tested in a staging app to the
+production app. The target app keeps its own environment variables and
+service binds, and runs its pre-deploy and post-deploy hooks. The promotion is
+recorded as a deploy of the target app, and you must be able to deploy both
+apps. Promotions are supported by the docker provisioner only; the juju
+provisioner does not promote charm revisions.
+
 Create a new service instance
 
 Usage:
@@ -0,0 +1,227 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type job struct {
+	Id       string
+	Schedule string
+	Command  string
+	User     string
+	NextRun  time.Time
+}
+
+type jobRun struct {
+	Timestamp time.Time
+	End       time.Time
+	Status    string
+	Output    string
+	Error     string
+}
+
+type jobAdd struct {
+	tsuru.GuessingCommand
+}
+
+func (c *jobAdd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "job-add",
+		Usage: "job-add <schedule> <command> [--app appname]",
+		Desc: `schedules a command to run periodically in one unit of your app.
+
+The schedule is a cron expression, with five fields: minute, hour, day of the
+month, month and day of the week. Quote it, as it contains spaces. The
+shortcuts @hourly, @daily, @weekly, @monthly and @yearly are also accepted.`,
+		MinArgs: 2,
+	}
+}
+
+func (c *jobAdd) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/jobs", appName))
+	if err != nil {
+		return err
+	}
+	params := map[string]string{
+		"schedule": context.Args[0],
+		"command":  strings.Join(context.Args[1:], " "),
+	}
+	var body bytes.Buffer
+	if err = json.NewEncoder(&body).Encode(params); err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", url, &body)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var j job
+	if err = json.NewDecoder(response.Body).Decode(&j); err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Job %s successfully added, its first run is at %s.\n", j.Id, formatTime(j.NextRun))
+	return nil
+}
+
+type jobList struct {
+	tsuru.GuessingCommand
+}
+
+func (c *jobList) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "job-list",
+		Usage:   "job-list [--app appname]",
+		Desc:    "lists the jobs of your app.",
+		MinArgs: 0,
+	}
+}
+
+func (c *jobList) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/jobs", appName))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNoContent {
+		fmt.Fprintln(context.Stdout, "No jobs.")
+		return nil
+	}
+	var jobs []job
+	if err = json.NewDecoder(response.Body).Decode(&jobs); err != nil {
+		return err
+	}
+	table := cmd.NewTable()
+	table.Headers = cmd.Row([]string{"Id", "Schedule", "Command", "Next run"})
+	for _, j := range jobs {
+		table.AddRow(cmd.Row([]string{j.Id, j.Schedule, j.Command, formatTime(j.NextRun)}))
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
+
+type jobRemove struct {
+	tsuru.GuessingCommand
+}
+
+func (c *jobRemove) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "job-remove",
+		Usage:   "job-remove <id> [--app appname]",
+		Desc:    "removes a job from your app, along with the history of its runs.",
+		MinArgs: 1,
+	}
+}
+
+func (c *jobRemove) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/jobs/%s", appName, context.Args[0]))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Job %s successfully removed.\n", context.Args[0])
+	return nil
+}
+
+type jobRuns struct {
+	tsuru.GuessingCommand
+}
+
+func (c *jobRuns) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "job-runs",
+		Usage:   "job-runs <id> [--app appname]",
+		Desc:    "shows the last runs of a job of your app, newest first, with their output.",
+		MinArgs: 1,
+	}
+}
+
+func (c *jobRuns) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/jobs/%s/runs", appName, context.Args[0]))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var runs []jobRun
+	if err = json.NewDecoder(response.Body).Decode(&runs); err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Fprintln(context.Stdout, "The job has not run yet.")
+		return nil
+	}
+	for i, r := range runs {
+		if i > 0 {
+			fmt.Fprintln(context.Stdout)
+		}
+		duration := "-"
+		if !r.End.IsZero() {
+			duration = r.End.Sub(r.Timestamp).String()
+		}
+		fmt.Fprintf(context.Stdout, "Run at %s, %s (%s):\n", formatTime(r.Timestamp), r.Status, duration)
+		if r.Output != "" {
+			fmt.Fprintln(context.Stdout, strings.TrimRight(r.Output, "\n"))
+		}
+		if r.Error != "" {
+			fmt.Fprintf(context.Stdout, "Error: %s\n", r.Error)
+		}
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,144 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/testing"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"launchpad.net/gocheck"
+	"net/http"
+)
+
+func (s *S) TestJobAddInfo(c *gocheck.C) {
+	info := (&jobAdd{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "job-add")
+	c.Assert(info.Usage, gocheck.Equals, "job-add <schedule> <command> [--app appname]")
+	c.Assert(info.MinArgs, gocheck.Equals, 2)
+}
+
+func (s *S) TestJobAdd(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Args:   []string{"0 3 * * *", "python", "manage.py", "report"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	result := `{"Id":"52a0b4d5c1a2b3c4d5e6f708","Schedule":"0 3 * * *","Command":"python manage.py report","NextRun":"2013-12-06T03:00:00Z"}`
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: result, Status: http.StatusCreated},
+		CondFunc: func(req *http.Request) bool {
+			var params map[string]string
+			if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+				return false
+			}
+			return req.URL.Path == "/apps/myapp/jobs" && req.Method == "POST" &&
+				params["schedule"] == "0 3 * * *" && params["command"] == "python manage.py report"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := jobAdd{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	expected := "Job 52a0b4d5c1a2b3c4d5e6f708 successfully added, its first run is at 2013-12-06 03:00:00.\n"
+	c.Assert(stdout.String(), gocheck.Equals, expected)
+}
+
+func (s *S) TestJobListInfo(c *gocheck.C) {
+	info := (&jobList{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "job-list")
+	c.Assert(info.Usage, gocheck.Equals, "job-list [--app appname]")
+}
+
+func (s *S) TestJobList(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	result := `[{"Id":"52a0b4d5c1a2b3c4d5e6f708","Schedule":"@daily","Command":"ls","NextRun":"2013-12-06T00:00:00Z"}]`
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/apps/myapp/jobs" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := jobList{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	expected := `+--------------------------+----------+---------+---------------------+
+| Id                       | Schedule | Command | Next run            |
++--------------------------+----------+---------+---------------------+
+| 52a0b4d5c1a2b3c4d5e6f708 | @daily   | ls      | 2013-12-06 00:00:00 |
++--------------------------+----------+---------+---------------------+
+`
+	c.Assert(stdout.String(), gocheck.Equals, expected)
+}
+
+func (s *S) TestJobListWithoutJobs(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.Transport{Message: "", Status: http.StatusNoContent}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := jobList{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "No jobs.\n")
+}
+
+func (s *S) TestJobRemoveInfo(c *gocheck.C) {
+	info := (&jobRemove{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "job-remove")
+	c.Assert(info.Usage, gocheck.Equals, "job-remove <id> [--app appname]")
+	c.Assert(info.MinArgs, gocheck.Equals, 1)
+}
+
+func (s *S) TestJobRemove(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Args: []string{"52a0b4d5c1a2b3c4d5e6f708"}, Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/apps/myapp/jobs/52a0b4d5c1a2b3c4d5e6f708" && req.Method == "DELETE"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := jobRemove{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "Job 52a0b4d5c1a2b3c4d5e6f708 successfully removed.\n")
+}
+
+func (s *S) TestJobRunsInfo(c *gocheck.C) {
+	info := (&jobRuns{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "job-runs")
+	c.Assert(info.Usage, gocheck.Equals, "job-runs <id> [--app appname]")
+	c.Assert(info.MinArgs, gocheck.Equals, 1)
+}
+
+func (s *S) TestJobRuns(c *gocheck.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Args: []string{"52a0b4d5c1a2b3c4d5e6f708"}, Stdout: &stdout, Stderr: &stderr}
+	result := `[{"Timestamp":"2013-12-06T03:00:00Z","End":"2013-12-06T03:00:02Z","Status":"failed","Output":"Traceback\n","Error":"exit status 1"},
+{"Timestamp":"2013-12-05T03:00:00Z","End":"2013-12-05T03:00:01Z","Status":"succeeded","Output":"report sent\n"}]`
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/apps/myapp/jobs/52a0b4d5c1a2b3c4d5e6f708/runs" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := jobRuns{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	expected := `Run at 2013-12-06 03:00:00, failed (2s):
+Traceback
+Error: exit status 1
+
+Run at 2013-12-05 03:00:00, succeeded (1s):
+report sent
+`
+	c.Assert(stdout.String(), gocheck.Equals, expected)
+}
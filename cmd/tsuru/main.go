@@ -19,7 +19,7 @@ func buildManager(name string) *cmd.Manager {
 	m := cmd.BuildBaseManager(name, version, header)
 	m.Register(&tsuru.AppRun{})
 	m.Register(&tsuru.AppInfo{})
-	m.Register(AppCreate{})
+	m.Register(&AppCreate{})
 	m.Register(&AppRemove{})
 	m.Register(&UnitAdd{})
 	m.Register(&UnitRemove{})
@@ -30,6 +30,9 @@ func buildManager(name string) *cmd.Manager {
 	m.Register(&tsuru.AppRestart{})
 	m.Register(&tsuru.SetCName{})
 	m.Register(&tsuru.UnsetCName{})
+	m.Register(&certificateSet{})
+	m.Register(&certificateUnset{})
+	m.Register(&certificateList{})
 	m.Register(&tsuru.EnvGet{})
 	m.Register(&tsuru.EnvSet{})
 	m.Register(&tsuru.EnvUnset{})
@@ -45,6 +48,19 @@ func buildManager(name string) *cmd.Manager {
 	m.Register(&tsuru.ServiceUnbind{})
 	m.Register(platformList{})
 	m.Register(swap{})
+	m.Register(&trafficSplit{})
+	m.Register(&dependencyAdd{})
+	m.Register(&dependencyRemove{})
+	m.Register(&appShell{})
+	m.Register(&appDeploy{})
+	m.Register(&appDeployList{})
+	m.Register(&appDeployCancel{})
+	m.Register(&appPromote{})
+	m.Register(&appExport{})
+	m.Register(&jobAdd{})
+	m.Register(&jobList{})
+	m.Register(&jobRemove{})
+	m.Register(&jobRuns{})
 	return m
 }
 
@@ -24,7 +24,7 @@ func (s *S) TestAppCreateIsRegistered(c *gocheck.C) {
 	manager := buildManager("tsuru")
 	create, ok := manager.Commands["app-create"]
 	c.Assert(ok, gocheck.Equals, true)
-	c.Assert(create, gocheck.FitsTypeOf, AppCreate{})
+	c.Assert(create, gocheck.FitsTypeOf, &AppCreate{})
 }
 
 func (s *S) TestAppRemoveIsRegistered(c *gocheck.C) {
@@ -195,6 +195,105 @@ func (s *S) TestSetCNameIsRegistered(c *gocheck.C) {
 	c.Assert(cname, gocheck.FitsTypeOf, &tsuru.SetCName{})
 }
 
+func (s *S) TestCertificateSetIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	cert, ok := manager.Commands["certificate-set"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(cert, gocheck.FitsTypeOf, &certificateSet{})
+}
+
+func (s *S) TestCertificateUnsetIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	cert, ok := manager.Commands["certificate-unset"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(cert, gocheck.FitsTypeOf, &certificateUnset{})
+}
+
+func (s *S) TestCertificateListIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	cert, ok := manager.Commands["certificate-list"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(cert, gocheck.FitsTypeOf, &certificateList{})
+}
+
+func (s *S) TestTrafficSplitIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	split, ok := manager.Commands["traffic-split"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(split, gocheck.FitsTypeOf, &trafficSplit{})
+}
+
+func (s *S) TestDependencyAddIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	add, ok := manager.Commands["dependency-add"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(add, gocheck.FitsTypeOf, &dependencyAdd{})
+}
+
+func (s *S) TestDependencyRemoveIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	remove, ok := manager.Commands["dependency-remove"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(remove, gocheck.FitsTypeOf, &dependencyRemove{})
+}
+
+func (s *S) TestAppShellIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	shell, ok := manager.Commands["app-shell"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(shell, gocheck.FitsTypeOf, &appShell{})
+}
+
+func (s *S) TestAppDeployIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	deploy, ok := manager.Commands["app-deploy"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(deploy, gocheck.FitsTypeOf, &appDeploy{})
+}
+
+func (s *S) TestAppDeployListIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	list, ok := manager.Commands["app-deploy-list"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(list, gocheck.FitsTypeOf, &appDeployList{})
+}
+
+func (s *S) TestAppDeployCancelIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	cancel, ok := manager.Commands["app-deploy-cancel"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(cancel, gocheck.FitsTypeOf, &appDeployCancel{})
+}
+
+func (s *S) TestAppPromoteIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	promote, ok := manager.Commands["app-promote"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(promote, gocheck.FitsTypeOf, &appPromote{})
+}
+
+func (s *S) TestAppExportIsRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	export, ok := manager.Commands["app-export"]
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(export, gocheck.FitsTypeOf, &appExport{})
+}
+
+func (s *S) TestJobCommandsAreRegistered(c *gocheck.C) {
+	manager := buildManager("tsuru")
+	commands := map[string]interface{}{
+		"job-add":    &jobAdd{},
+		"job-list":   &jobList{},
+		"job-remove": &jobRemove{},
+		"job-runs":   &jobRuns{},
+	}
+	for name, expected := range commands {
+		command, ok := manager.Commands[name]
+		c.Assert(ok, gocheck.Equals, true)
+		c.Assert(command, gocheck.FitsTypeOf, expected)
+	}
+}
+
 func (s *S) TestUnsetCNameIsRegistered(c *gocheck.C) {
 	manager := buildManager("tsuru")
 	cname, ok := manager.Commands["unset-cname"]
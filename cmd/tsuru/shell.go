@@ -0,0 +1,90 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/term"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"io"
+	"launchpad.net/gnuflag"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+type appShell struct {
+	tsuru.GuessingCommand
+	isolated bool
+	fs       *gnuflag.FlagSet
+}
+
+func (c *appShell) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "app-shell",
+		Usage: "app-shell [unit-id] [--isolated] [--app appname]",
+		Desc: `opens an interactive shell in a unit of your app.
+
+If you don't provide the unit id, tsuru will open the shell in the first unit
+of the app. The unit id may be a prefix of the id of the unit.
+
+With the '--isolated' flag, tsuru opens the shell in a new container, created
+from the image of your app and with its environment variables, that is
+removed when the shell exits. It doesn't affect the units serving your app.
+
+If you don't provide the app name, tsuru will try to guess it.`,
+		MinArgs: 0,
+	}
+}
+
+func (c *appShell) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	params := url.Values{}
+	if len(context.Args) > 0 {
+		params.Set("unit", context.Args[0])
+	}
+	params.Set("isolated", fmt.Sprintf("%t", c.isolated))
+	if stdin, ok := context.Stdin.(*os.File); ok && term.IsTerminal(stdin.Fd()) {
+		if width, height, err := term.GetSize(stdin.Fd()); err == nil {
+			params.Set("width", fmt.Sprintf("%d", width))
+			params.Set("height", fmt.Sprintf("%d", height))
+		}
+		params.Set("term", os.Getenv("TERM"))
+		state, err := term.MakeRaw(stdin.Fd())
+		if err != nil {
+			return err
+		}
+		defer term.Restore(stdin.Fd(), state)
+	}
+	u, err := cmd.GetURL(fmt.Sprintf("/apps/%s/shell?%s", appName, params.Encode()))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	conn, err := client.Upgrade(request)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go io.Copy(conn, context.Stdin)
+	_, err = io.Copy(context.Stdout, conn)
+	return err
+}
+
+func (c *appShell) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.BoolVar(&c.isolated, "isolated", false, "Open the shell in a new container, instead of in a unit of the app")
+		c.fs.BoolVar(&c.isolated, "i", false, "Open the shell in a new container, instead of in a unit of the app")
+	}
+	return c.fs
+}
@@ -0,0 +1,92 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"io/ioutil"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+)
+
+func (s *S) TestAppShellInfo(c *gocheck.C) {
+	info := (&appShell{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "app-shell")
+	c.Assert(info.Usage, gocheck.Equals, "app-shell [unit-id] [--isolated] [--app appname]")
+	c.Assert(info.MinArgs, gocheck.Equals, 0)
+}
+
+func (s *S) TestAppShellFlags(c *gocheck.C) {
+	command := appShell{}
+	flagset := command.Flags()
+	c.Assert(flagset, gocheck.NotNil)
+	flagset.Parse(true, []string{"--isolated"})
+	c.Assert(command.isolated, gocheck.Equals, true)
+	isolated := flagset.Lookup("isolated")
+	c.Assert(isolated, gocheck.NotNil)
+	c.Assert(isolated.DefValue, gocheck.Equals, "false")
+	short := flagset.Lookup("i")
+	c.Assert(short, gocheck.NotNil)
+}
+
+func (s *S) TestAppShell(c *gocheck.C) {
+	var path, unit, isolated, upgrade string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		unit = r.URL.Query().Get("unit")
+		isolated = r.URL.Query().Get("isolated")
+		upgrade = r.Header.Get("Upgrade")
+		conn, _, err := w.(http.Hijacker).Hijack()
+		c.Assert(err, gocheck.IsNil)
+		defer conn.Close()
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n"))
+		conn.Write([]byte("root@myapp:~# "))
+	}))
+	defer server.Close()
+	targetFile := os.Getenv("HOME") + "/.tsuru_target"
+	err := ioutil.WriteFile(targetFile, []byte(server.URL), 0600)
+	c.Assert(err, gocheck.IsNil)
+	defer ioutil.WriteFile(targetFile, []byte("http://localhost"), 0600)
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Args:   []string{"a1b2c3"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Stdin:  strings.NewReader(""),
+	}
+	client := cmd.NewClient(&http.Client{}, nil, manager)
+	command := appShell{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	command.Flags().Parse(true, []string{"--isolated"})
+	err = command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "root@myapp:~# ")
+	c.Assert(path, gocheck.Equals, "/apps/myapp/shell")
+	c.Assert(unit, gocheck.Equals, "a1b2c3")
+	c.Assert(isolated, gocheck.Equals, "true")
+	c.Assert(upgrade, gocheck.Equals, "tcp")
+}
+
+func (s *S) TestAppShellError(c *gocheck.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "The provisioner in use does not support interactive shells.", http.StatusPreconditionFailed)
+	}))
+	defer server.Close()
+	targetFile := os.Getenv("HOME") + "/.tsuru_target"
+	err := ioutil.WriteFile(targetFile, []byte(server.URL), 0600)
+	c.Assert(err, gocheck.IsNil)
+	defer ioutil.WriteFile(targetFile, []byte("http://localhost"), 0600)
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("")}
+	client := cmd.NewClient(&http.Client{}, nil, manager)
+	command := appShell{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err = command.Run(&context, client)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "The provisioner in use does not support interactive shells.\n")
+}
@@ -0,0 +1,78 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"net/http"
+	"strconv"
+)
+
+type trafficSplit struct {
+	tsuru.GuessingCommand
+}
+
+func (c *trafficSplit) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "traffic-split",
+		Usage: "traffic-split <weight> [target-app] [--app appname]",
+		Desc: `sends a percentage of the traffic of your app to the units of another app.
+
+Use it for canary releases: deploy the new version to the target app and send
+a small share of the traffic to it, increasing the weight as you gain
+confidence. A weight of 0 sends all the traffic back to your app, and a weight
+of 100 finalizes the split, swapping the apps.`,
+		MinArgs: 1,
+	}
+}
+
+func (c *trafficSplit) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	weight, err := strconv.Atoi(context.Args[0])
+	if err != nil || weight < 0 || weight > 100 {
+		return errors.New("The weight must be a percentage between 0 and 100.")
+	}
+	var target string
+	if len(context.Args) > 1 {
+		target = context.Args[1]
+	}
+	if weight > 0 && target == "" {
+		return errors.New("You must provide the target app.")
+	}
+	params := map[string]interface{}{"target": target, "weight": weight}
+	var body bytes.Buffer
+	if err = json.NewEncoder(&body).Encode(params); err != nil {
+		return err
+	}
+	url, err := cmd.GetURL(fmt.Sprintf("/apps/%s/traffic", appName))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", url, &body)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	switch weight {
+	case 0:
+		fmt.Fprintf(context.Stdout, "All the traffic of app %q is now sent to its own units.\n", appName)
+	case 100:
+		fmt.Fprintf(context.Stdout, "Apps %q and %q successfully swapped.\n", appName, target)
+	default:
+		fmt.Fprintf(context.Stdout, "%d%% of the traffic of app %q is now sent to app %q.\n", weight, appName, target)
+	}
+	return nil
+}
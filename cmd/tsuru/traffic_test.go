@@ -0,0 +1,79 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/globocom/tsuru/cmd"
+	"github.com/globocom/tsuru/cmd/testing"
+	"github.com/globocom/tsuru/cmd/tsuru-base"
+	"launchpad.net/gocheck"
+	"net/http"
+)
+
+func (s *S) TestTrafficSplitInfo(c *gocheck.C) {
+	info := (&trafficSplit{}).Info()
+	c.Assert(info.Name, gocheck.Equals, "traffic-split")
+	c.Assert(info.Usage, gocheck.Equals, "traffic-split <weight> [target-app] [--app appname]")
+	c.Assert(info.MinArgs, gocheck.Equals, 1)
+}
+
+func (s *S) runTrafficSplit(c *gocheck.C, args []string, target string, weight int) string {
+	var stdout, stderr bytes.Buffer
+	var called bool
+	context := cmd.Context{Args: args, Stdout: &stdout, Stderr: &stderr}
+	trans := &testing.ConditionalTransport{
+		Transport: testing.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			called = true
+			var params map[string]interface{}
+			err := json.NewDecoder(req.Body).Decode(&params)
+			c.Assert(err, gocheck.IsNil)
+			c.Assert(params["target"], gocheck.Equals, target)
+			c.Assert(params["weight"], gocheck.Equals, float64(weight))
+			return req.URL.Path == "/apps/myapp/traffic" && req.Method == "POST"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	command := trafficSplit{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(called, gocheck.Equals, true)
+	return stdout.String()
+}
+
+func (s *S) TestTrafficSplit(c *gocheck.C) {
+	out := s.runTrafficSplit(c, []string{"10", "myapp-canary"}, "myapp-canary", 10)
+	c.Assert(out, gocheck.Equals, `10% of the traffic of app "myapp" is now sent to app "myapp-canary".`+"\n")
+}
+
+func (s *S) TestTrafficSplitRemove(c *gocheck.C) {
+	out := s.runTrafficSplit(c, []string{"0"}, "", 0)
+	c.Assert(out, gocheck.Equals, `All the traffic of app "myapp" is now sent to its own units.`+"\n")
+}
+
+func (s *S) TestTrafficSplitFinalize(c *gocheck.C) {
+	out := s.runTrafficSplit(c, []string{"100", "myapp-canary"}, "myapp-canary", 100)
+	c.Assert(out, gocheck.Equals, `Apps "myapp" and "myapp-canary" successfully swapped.`+"\n")
+}
+
+func (s *S) TestTrafficSplitInvalidWeight(c *gocheck.C) {
+	command := trafficSplit{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	for _, weight := range []string{"abc", "-1", "101"} {
+		context := cmd.Context{Args: []string{weight, "myapp-canary"}}
+		err := command.Run(&context, nil)
+		c.Assert(err, gocheck.NotNil)
+		c.Check(err.Error(), gocheck.Equals, "The weight must be a percentage between 0 and 100.")
+	}
+}
+
+func (s *S) TestTrafficSplitWithoutTarget(c *gocheck.C) {
+	command := trafficSplit{GuessingCommand: tsuru.GuessingCommand{G: &FakeGuesser{name: "myapp"}}}
+	context := cmd.Context{Args: []string{"10"}}
+	err := command.Run(&context, nil)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "You must provide the target app.")
+}
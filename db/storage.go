@@ -132,8 +132,39 @@ func (s *Storage) Apps() *Collection {
 	return c
 }
 
+// Deploys returns the deploys collection from MongoDB.
+//
+// The unique index on the "lock" field holds the deploy lock of apps: only
+// running deploys have it set, so there's at most one per app.
 func (s *Storage) Deploys() *Collection {
-	return s.Collection("deploys")
+	lockIndex := mgo.Index{Key: []string{"lock"}, Unique: true, Sparse: true}
+	c := s.Collection("deploys")
+	c.EnsureIndex(lockIndex)
+	return c
+}
+
+// Jobs returns the jobs collection from MongoDB.
+func (s *Storage) Jobs() *Collection {
+	appIndex := mgo.Index{Key: []string{"app"}}
+	nextRunIndex := mgo.Index{Key: []string{"nextrun"}}
+	c := s.Collection("jobs")
+	c.EnsureIndex(appIndex)
+	c.EnsureIndex(nextRunIndex)
+	return c
+}
+
+// JobRuns returns the job_runs collection from MongoDB, that holds the
+// history of the runs of jobs.
+func (s *Storage) JobRuns() *Collection {
+	jobIndex := mgo.Index{Key: []string{"job", "-timestamp"}}
+	c := s.Collection("job_runs")
+	c.EnsureIndex(jobIndex)
+	return c
+}
+
+// Archives returns the GridFS that stores the archives uploaded for deploys.
+func (s *Storage) Archives() *mgo.GridFS {
+	return s.session.DB(s.dbname).GridFS("archives")
 }
 
 // Platforms returns the platforms collection from MongoDB.
@@ -191,6 +222,30 @@ func (s *Storage) Teams() *Collection {
 	return s.Collection("teams")
 }
 
+// Queue returns the queue collection from MongoDB. It's used by the mongodb
+// queue implementation for storing messages.
+func (s *Storage) Queue() *Collection {
+	queueIndex := mgo.Index{Key: []string{"queue", "visible"}}
+	c := s.Collection("queue")
+	c.EnsureIndex(queueIndex)
+	return c
+}
+
+// DeadLetters returns the collection of messages that could not be handled
+// after the maximum number of attempts.
+func (s *Storage) DeadLetters() *Collection {
+	return s.Collection("dead_letters")
+}
+
+// Certificates returns the collection of TLS certificates used to serve the
+// cnames of apps.
+func (s *Storage) Certificates() *Collection {
+	appIndex := mgo.Index{Key: []string{"app"}}
+	c := s.Collection("certificates")
+	c.EnsureIndex(appIndex)
+	return c
+}
+
 // Quota returns the quota collection from MongoDB.
 func (s *Storage) Quota() *Collection {
 	userIndex := mgo.Index{Key: []string{"owner"}, Unique: true}
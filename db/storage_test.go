@@ -213,6 +213,34 @@ func (s *S) TestDeploys(c *gocheck.C) {
 	deploys := storage.Deploys()
 	deploysc := storage.Collection("deploys")
 	c.Assert(deploys, gocheck.DeepEquals, deploysc)
+	c.Assert(deploys, HasUniqueIndex, []string{"lock"})
+}
+
+func (s *S) TestJobs(c *gocheck.C) {
+	storage, _ := Open("127.0.0.1:27017", "tsuru_storage_test")
+	defer storage.session.Close()
+	jobs := storage.Jobs()
+	jobsc := storage.Collection("jobs")
+	c.Assert(jobs, gocheck.DeepEquals, jobsc)
+	c.Assert(jobs, HasIndex, []string{"app"})
+	c.Assert(jobs, HasIndex, []string{"nextrun"})
+}
+
+func (s *S) TestJobRuns(c *gocheck.C) {
+	storage, _ := Open("127.0.0.1:27017", "tsuru_storage_test")
+	defer storage.session.Close()
+	runs := storage.JobRuns()
+	runsc := storage.Collection("job_runs")
+	c.Assert(runs, gocheck.DeepEquals, runsc)
+	c.Assert(runs, HasIndex, []string{"job", "-timestamp"})
+}
+
+func (s *S) TestArchives(c *gocheck.C) {
+	storage, _ := Open("127.0.0.1:27017", "tsuru_storage_test")
+	defer storage.session.Close()
+	archives := storage.Archives()
+	c.Assert(archives.Files.Name, gocheck.Equals, "archives.files")
+	c.Assert(archives.Chunks.Name, gocheck.Equals, "archives.chunks")
 }
 
 func (s *S) TestPlatforms(c *gocheck.C) {
@@ -298,6 +326,14 @@ func (s *S) TestQuotaOwnerIsUnique(c *gocheck.C) {
 	c.Assert(quota, HasUniqueIndex, []string{"owner"})
 }
 
+func (s *S) TestCertificates(c *gocheck.C) {
+	storage, _ := Open("127.0.0.1:27017", "tsuru_storage_test")
+	defer storage.session.Close()
+	certificates := storage.Certificates()
+	certificatesc := storage.Collection("certificates")
+	c.Assert(certificates, gocheck.DeepEquals, certificatesc)
+}
+
 func (s *S) TestRetire(c *gocheck.C) {
 	defer func() {
 		if r := recover(); !c.Failed() && r == nil {
@@ -5,6 +5,9 @@
 package io
 
 import (
+	"bufio"
+	"errors"
+	"net"
 	"net/http"
 )
 
@@ -34,3 +37,14 @@ func (w *FlushingWriter) Write(data []byte) (int, error) {
 func (w *FlushingWriter) Wrote() bool {
 	return w.wrote
 }
+
+// Hijack lets the caller take over the connection, if the underlying
+// ResponseWriter is also an http.Hijacker. After hijacking, the writer is
+// marked as written.
+func (w *FlushingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.wrote = true
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("The underlying ResponseWriter is not a Hijacker.")
+}
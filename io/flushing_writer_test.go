@@ -5,7 +5,9 @@
 package io
 
 import (
+	"bufio"
 	"launchpad.net/gocheck"
+	"net"
 	"net/http/httptest"
 	"testing"
 )
@@ -59,3 +61,31 @@ func (s *FlushingSuite) TestFlushingWriterWrote(c *gocheck.C) {
 	writer.wrote = true
 	c.Assert(writer.Wrote(), gocheck.Equals, true)
 }
+
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (r *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return r.conn, nil, nil
+}
+
+func (s *FlushingSuite) TestFlushingWriterHijack(c *gocheck.C) {
+	conn, other := net.Pipe()
+	defer conn.Close()
+	defer other.Close()
+	recorder := hijackableRecorder{httptest.NewRecorder(), conn}
+	writer := FlushingWriter{&recorder, false}
+	hijacked, _, err := writer.Hijack()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(hijacked, gocheck.Equals, conn)
+	c.Assert(writer.wrote, gocheck.Equals, true)
+}
+
+func (s *FlushingSuite) TestFlushingWriterHijackNotHijacker(c *gocheck.C) {
+	writer := FlushingWriter{httptest.NewRecorder(), false}
+	_, _, err := writer.Hijack()
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(writer.wrote, gocheck.Equals, false)
+}
@@ -0,0 +1,54 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"fmt"
+	"launchpad.net/goyaml"
+)
+
+// AppConfig is the configuration of an app, declared in the app.yaml (or
+// app.yml) file in the root of its code. It's read once, when the app is
+// built, and stored with the deploy.
+type AppConfig struct {
+	Hooks Hooks
+}
+
+// Hooks are the commands that run during the deploy and the restart of an
+// app:
+//
+//   - build hooks run in the build of the app, after the deploy command, and
+//     their changes are part of the image of the app;
+//   - pre-deploy hooks run once, with the new code, before the units of the
+//     app are replaced, and a failure aborts the deploy;
+//   - post-deploy hooks run once, after the units are replaced;
+//   - restart hooks run before and after the app is restarted.
+type Hooks struct {
+	Build      []string
+	PreDeploy  []string `yaml:"pre-deploy" bson:"predeploy" json:"PreDeploy"`
+	PostDeploy []string `yaml:"post-deploy" bson:"postdeploy" json:"PostDeploy"`
+	Restart    RestartHooks
+}
+
+// RestartHooks are the commands that run before and after an app is
+// restarted.
+type RestartHooks struct {
+	Before []string
+	After  []string
+
+	// Isolated indicates that the commands run in one-off units, instead
+	// of in one of the units serving the app.
+	Isolated bool
+}
+
+// ParseAppConfig parses the content of an app.yaml file. An empty content,
+// from an app without app.yaml, is a valid configuration with no hooks.
+func ParseAppConfig(data []byte) (*AppConfig, error) {
+	var config AppConfig
+	if err := goyaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("Invalid app.yaml: %s", err)
+	}
+	return &config, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"launchpad.net/gocheck"
+)
+
+func (ProvisionSuite) TestParseAppConfig(c *gocheck.C) {
+	data := `hooks:
+  build:
+    - python manage.py collectstatic --noinput
+  pre-deploy:
+    - python manage.py migrate
+  post-deploy:
+    - python manage.py clear-cache
+  restart:
+    before:
+      - python manage.py download-manifest
+    isolated: true
+`
+	config, err := ParseAppConfig([]byte(data))
+	c.Assert(err, gocheck.IsNil)
+	expected := AppConfig{
+		Hooks: Hooks{
+			Build:      []string{"python manage.py collectstatic --noinput"},
+			PreDeploy:  []string{"python manage.py migrate"},
+			PostDeploy: []string{"python manage.py clear-cache"},
+			Restart: RestartHooks{
+				Before:   []string{"python manage.py download-manifest"},
+				Isolated: true,
+			},
+		},
+	}
+	c.Assert(*config, gocheck.DeepEquals, expected)
+}
+
+func (ProvisionSuite) TestParseAppConfigEmpty(c *gocheck.C) {
+	config, err := ParseAppConfig(nil)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(*config, gocheck.DeepEquals, AppConfig{})
+}
+
+func (ProvisionSuite) TestParseAppConfigInvalid(c *gocheck.C) {
+	_, err := ParseAppConfig([]byte("hooks:\n  build: [\n"))
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Matches, "^Invalid app.yaml: .*")
+}
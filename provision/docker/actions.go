@@ -6,12 +6,14 @@ package docker
 
 import (
 	"errors"
+	"fmt"
 	"github.com/globocom/tsuru/action"
 	"github.com/globocom/tsuru/app"
 	"github.com/globocom/tsuru/db"
 	"github.com/globocom/tsuru/log"
 	"github.com/globocom/tsuru/provision"
 	"github.com/globocom/tsuru/queue"
+	"io"
 	"labix.org/v2/mgo/bson"
 	"net"
 	"strings"
@@ -108,6 +110,57 @@ var startContainer = action.Action{
 	},
 }
 
+// startBuildContainer starts the build container, mounting the build cache
+// of the app in it.
+var startBuildContainer = action.Action{
+	Name: "start-build-container",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		c := ctx.Previous.(container)
+		app := ctx.Params[0].(provision.App)
+		log.Debugf("starting build container %s", c.ID)
+		err := dockerCluster().StartContainer(c.ID, buildHostConfig(app))
+		if err != nil {
+			log.Errorf("error on start build container %s - %s", c.ID, err)
+			return nil, err
+		}
+		return c, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		startContainer.Backward(ctx)
+	},
+}
+
+// followBuild waits for the build container to finish, writing its output
+// to the writer in the params. A build that fails or is canceled fails the
+// action, so the container is stopped and removed by the previous actions.
+var followBuild = action.Action{
+	Name: "follow-build",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		c := ctx.Previous.(container)
+		w := ctx.Params[3].(io.Writer)
+		if err := c.setStatus("building"); err != nil {
+			return nil, err
+		}
+		status, err := dockerCluster().WaitContainer(c.ID)
+		if err != nil {
+			log.Errorf("Process failed for container %q: %s", c.ID, err)
+			return nil, err
+		}
+		if err := c.logs(w); err != nil {
+			log.Errorf("error on get logs for container %s - %s", c.ID, err)
+			return nil, err
+		}
+		if current, err := getContainer(c.ID); err == nil && current.Status == "canceled" {
+			return nil, provision.ErrDeployCanceled
+		}
+		if status != 0 {
+			return nil, fmt.Errorf("The build of the app failed with status %d.", status)
+		}
+		return c, nil
+	},
+	MinParams: 4,
+}
+
 var injectEnvirons = action.Action{
 	Name: "inject-environs",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
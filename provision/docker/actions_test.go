@@ -5,10 +5,13 @@
 package docker
 
 import (
+	"bytes"
 	dockerClient "github.com/fsouza/go-dockerclient"
+	"github.com/globocom/config"
 	"github.com/globocom/tsuru/action"
 	"github.com/globocom/tsuru/app"
 	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/provision"
 	rtesting "github.com/globocom/tsuru/router/testing"
 	"github.com/globocom/tsuru/testing"
 	"labix.org/v2/mgo/bson"
@@ -167,6 +170,76 @@ func (s *S) TestStartContainerBackward(c *gocheck.C) {
 	c.Assert(cc.State.Running, gocheck.Equals, false)
 }
 
+func (s *S) TestStartBuildContainerName(c *gocheck.C) {
+	c.Assert(startBuildContainer.Name, gocheck.Equals, "start-build-container")
+}
+
+func (s *S) TestStartBuildContainerForward(c *gocheck.C) {
+	config.Set("docker:build-cache:host-dir", "/var/lib/tsuru/cache")
+	defer config.Unset("docker:build-cache:host-dir")
+	err := newImage("tsuru/python", s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	cont, err := s.newContainer(nil)
+	c.Assert(err, gocheck.IsNil)
+	defer s.removeTestContainer(cont)
+	app := testing.NewFakeApp(cont.AppName, "python", 1)
+	context := action.FWContext{Previous: *cont, Params: []interface{}{app}}
+	r, err := startBuildContainer.Forward(context)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.(container).ID, gocheck.Equals, cont.ID)
+	dcli, err := dockerClient.NewClient(s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	cc, err := dcli.InspectContainer(cont.ID)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(cc.State.Running, gocheck.Equals, true)
+}
+
+func (s *S) TestFollowBuildName(c *gocheck.C) {
+	c.Assert(followBuild.Name, gocheck.Equals, "follow-build")
+}
+
+func (s *S) TestFollowBuildForward(c *gocheck.C) {
+	err := newImage("tsuru/python", s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	cont, err := s.newContainer(nil)
+	c.Assert(err, gocheck.IsNil)
+	defer s.removeTestContainer(cont)
+	err = dockerCluster().StartContainer(cont.ID, nil)
+	c.Assert(err, gocheck.IsNil)
+	go s.stopContainers(1)
+	var buf bytes.Buffer
+	app := testing.NewFakeApp(cont.AppName, "python", 1)
+	context := action.FWContext{Previous: *cont, Params: []interface{}{app, "tsuru/python", []string{"ps"}, &buf}}
+	r, err := followBuild.Forward(context)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.(container).ID, gocheck.Equals, cont.ID)
+	retrieved, err := getContainer(cont.ID)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(retrieved.Status, gocheck.Equals, "building")
+}
+
+func (s *S) TestFollowBuildForwardCanceled(c *gocheck.C) {
+	err := newImage("tsuru/python", s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	cont, err := s.newContainer(nil)
+	c.Assert(err, gocheck.IsNil)
+	defer s.removeTestContainer(cont)
+	err = dockerCluster().StartContainer(cont.ID, nil)
+	c.Assert(err, gocheck.IsNil)
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		cancelBuild(cont.AppName)
+	}()
+	var buf bytes.Buffer
+	app := testing.NewFakeApp(cont.AppName, "python", 1)
+	context := action.FWContext{Previous: *cont, Params: []interface{}{app, "tsuru/python", []string{"ps"}, &buf}}
+	_, err = followBuild.Forward(context)
+	c.Assert(err, gocheck.Equals, provision.ErrDeployCanceled)
+	retrieved, err := getContainer(cont.ID)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(retrieved.Status, gocheck.Equals, "canceled")
+}
+
 func (s *S) TestInjectEnvironsName(c *gocheck.C) {
 	c.Assert(injectEnvirons.Name, gocheck.Equals, "inject-environs")
 }
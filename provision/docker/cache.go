@@ -0,0 +1,40 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"github.com/dotcloud/docker"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/provision"
+	"path"
+)
+
+const defaultBuildCacheDir = "/var/cache/tsuru"
+
+// buildCache returns the directory of the build cache of the app in the
+// nodes, and the directory where it's mounted in build containers. The cache
+// is enabled by setting docker:build-cache:host-dir, and is mounted in
+// docker:build-cache:dir, /var/cache/tsuru by default.
+func buildCache(app provision.App) (hostDir, dir string, ok bool) {
+	hostDir, err := config.GetString("docker:build-cache:host-dir")
+	if err != nil || hostDir == "" {
+		return "", "", false
+	}
+	dir, err = config.GetString("docker:build-cache:dir")
+	if err != nil || dir == "" {
+		dir = defaultBuildCacheDir
+	}
+	return path.Join(hostDir, app.GetName()), dir, true
+}
+
+// buildHostConfig returns the host configuration of build containers,
+// binding the build cache of the app, if enabled.
+func buildHostConfig(app provision.App) *docker.HostConfig {
+	hostDir, dir, ok := buildCache(app)
+	if !ok {
+		return nil
+	}
+	return &docker.HostConfig{Binds: []string{hostDir + ":" + dir}}
+}
@@ -0,0 +1,42 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/testing"
+	"launchpad.net/gocheck"
+)
+
+func (s *S) TestBuildCacheDisabled(c *gocheck.C) {
+	app := testing.NewFakeApp("myapp", "python", 1)
+	_, _, ok := buildCache(app)
+	c.Assert(ok, gocheck.Equals, false)
+	c.Assert(buildHostConfig(app), gocheck.IsNil)
+}
+
+func (s *S) TestBuildCache(c *gocheck.C) {
+	config.Set("docker:build-cache:host-dir", "/var/lib/tsuru/cache")
+	defer config.Unset("docker:build-cache:host-dir")
+	app := testing.NewFakeApp("myapp", "python", 1)
+	hostDir, dir, ok := buildCache(app)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(hostDir, gocheck.Equals, "/var/lib/tsuru/cache/myapp")
+	c.Assert(dir, gocheck.Equals, "/var/cache/tsuru")
+	hostConfig := buildHostConfig(app)
+	c.Assert(hostConfig, gocheck.NotNil)
+	c.Assert(hostConfig.Binds, gocheck.DeepEquals, []string{"/var/lib/tsuru/cache/myapp:/var/cache/tsuru"})
+}
+
+func (s *S) TestBuildCacheCustomDir(c *gocheck.C) {
+	config.Set("docker:build-cache:host-dir", "/var/lib/tsuru/cache")
+	defer config.Unset("docker:build-cache:host-dir")
+	config.Set("docker:build-cache:dir", "/home/application/.cache")
+	defer config.Unset("docker:build-cache:dir")
+	app := testing.NewFakeApp("myapp", "python", 1)
+	hostConfig := buildHostConfig(app)
+	c.Assert(hostConfig, gocheck.NotNil)
+	c.Assert(hostConfig.Binds, gocheck.DeepEquals, []string{"/var/lib/tsuru/cache/myapp:/home/application/.cache"})
+}
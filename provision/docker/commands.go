@@ -5,16 +5,10 @@
 package docker
 
 import (
-	"bytes"
 	"fmt"
 	"github.com/globocom/config"
 	"github.com/globocom/tsuru/provision"
 	"github.com/globocom/tsuru/repository"
-	"io/ioutil"
-	"os"
-	"os/user"
-	"path"
-	"strings"
 )
 
 // deployCmds returns the commands that is used when provisioner
@@ -25,60 +19,43 @@ func deployCmds(app provision.App, version string) ([]string, error) {
 		return nil, err
 	}
 	appRepo := repository.ReadOnlyURL(app.GetName())
-	var envs string
-	for _, env := range app.Envs() {
-		envs += fmt.Sprintf("%s='%s' ", env.Name, env.Value)
-	}
-	cmds := []string{deployCmd, appRepo, version, envs}
+	cmds := []string{deployCmd, appRepo, version, deployEnvs(app)}
 	return cmds, nil
 }
 
-// runCmds returns the commands that should be passed when the
-// provisioner will run an unit.
-func runCmds() ([]string, error) {
-	runCmd, err := config.GetString("docker:run-cmd:bin")
-	if err != nil {
-		return nil, err
-	}
-	ssh, err := sshCmds()
+// archiveDeployCmds returns the commands that build the app from the tar.gz
+// archive available in the given URL. The deploy command receives "archive"
+// as its first argument, followed by the URL of the archive.
+func archiveDeployCmds(app provision.App, archiveURL string) ([]string, error) {
+	deployCmd, err := config.GetString("docker:deploy-cmd")
 	if err != nil {
 		return nil, err
 	}
-	sshCmd := strings.Join(ssh, " && ")
-	cmd := fmt.Sprintf("%s && %s", runCmd, sshCmd)
-	cmds := []string{"/bin/bash", "-c", cmd}
+	cmds := []string{deployCmd, "archive", archiveURL, deployEnvs(app)}
 	return cmds, nil
 }
 
-// sshCmds returns the commands needed to start a ssh daemon.
-func sshCmds() ([]string, error) {
-	addKeyCommand, err := config.GetString("docker:ssh:add-key-cmd")
-	if err != nil {
-		return nil, err
-	}
-	keyFile, err := config.GetString("docker:ssh:public-key")
-	if err != nil {
-		if u, err := user.Current(); err == nil {
-			keyFile = path.Join(u.HomeDir, ".ssh", "id_rsa.pub")
-		} else {
-			keyFile = os.ExpandEnv("${HOME}/.ssh/id_rsa.pub")
-		}
+// deployEnvs returns the environment variables of the app, passed to the
+// deploy command. When the build cache is enabled, its location is given in
+// TSURU_BUILD_CACHE, so platforms keep downloaded dependencies there.
+func deployEnvs(app provision.App) string {
+	var envs string
+	for _, env := range app.Envs() {
+		envs += fmt.Sprintf("%s='%s' ", env.Name, env.Value)
 	}
-	f, err := filesystem().Open(keyFile)
-	if err != nil {
-		return nil, err
+	if _, dir, ok := buildCache(app); ok {
+		envs += fmt.Sprintf("TSURU_BUILD_CACHE='%s' ", dir)
 	}
-	defer f.Close()
-	keyContent, err := ioutil.ReadAll(f)
+	return envs
+}
+
+// runCmds returns the commands that should be passed when the
+// provisioner will run an unit. The run command must keep running in the
+// foreground, as the container stops when it exits.
+func runCmds() ([]string, error) {
+	runCmd, err := config.GetString("docker:run-cmd:bin")
 	if err != nil {
 		return nil, err
 	}
-	sshdCommand, err := config.GetString("docker:ssh:sshd-path")
-	if err != nil {
-		sshdCommand = "sudo /usr/sbin/sshd"
-	}
-	return []string{
-		fmt.Sprintf("%s %s", addKeyCommand, bytes.TrimSpace(keyContent)),
-		sshdCommand + " -D",
-	}, nil
+	return []string{"/bin/bash", "-c", runCmd}, nil
 }
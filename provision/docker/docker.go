@@ -6,7 +6,6 @@ package docker
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/dotcloud/docker"
@@ -15,7 +14,6 @@ import (
 	"github.com/globocom/docker-cluster/cluster"
 	"github.com/globocom/docker-cluster/storage"
 	"github.com/globocom/tsuru/action"
-	"github.com/globocom/tsuru/fs"
 	"github.com/globocom/tsuru/log"
 	"github.com/globocom/tsuru/provision"
 	"io"
@@ -30,7 +28,6 @@ import (
 var (
 	dCluster *cluster.Cluster
 	cmutext  sync.Mutex
-	fsystem  fs.Fs
 )
 
 const maxTry = 5
@@ -74,13 +71,6 @@ func dockerCluster() *cluster.Cluster {
 	return dCluster
 }
 
-func filesystem() fs.Fs {
-	if fsystem == nil {
-		fsystem = fs.OsFs{}
-	}
-	return fsystem
-}
-
 // runCmd executes commands and log the given stdout and stderror.
 func runCmd(cmd string, args ...string) (string, error) {
 	out := bytes.Buffer{}
@@ -96,6 +86,17 @@ func getPort() (string, error) {
 	return config.GetString("docker:run-cmd:port")
 }
 
+// dockerUser returns the user that runs the commands in the containers. It
+// was defined in docker:ssh:user before commands were run through the docker
+// API, and the old setting is still read when docker:user is not set.
+func dockerUser() string {
+	if user, err := config.GetString("docker:user"); err == nil {
+		return user
+	}
+	user, _ := config.GetString("docker:ssh:user")
+	return user
+}
+
 func getHostAddr(hostID string) string {
 	fullAddress := clusterNodes[hostID]
 	url, _ := url.Parse(fullAddress)
@@ -131,7 +132,7 @@ func newContainer(app provision.App, imageId string, cmds []string) (container,
 		log.Errorf("error on getting port for container %s - %s", cont.AppName, port)
 		return container{}, err
 	}
-	user, _ := config.GetString("docker:ssh:user")
+	user := dockerUser()
 	config := docker.Config{
 		Image:        imageId,
 		Cmd:          cmds,
@@ -185,40 +186,22 @@ func (c *container) setImage(imageId string) error {
 	return coll.UpdateId(c.ID, c)
 }
 
-func build(a provision.App, version string, w io.Writer) (string, error) {
-	imageID, err := deploy(a, version, w)
-	if err != nil {
-		return "", err
-	}
-	go Flatten(a)
-	return imageID, nil
-}
-
-func deploy(app provision.App, version string, w io.Writer) (string, error) {
-	commands, err := deployCmds(app, version)
-	if err != nil {
-		return "", err
-	}
-	imageId := getImage(app)
-	actions := []*action.Action{&createContainer, &startContainer, &insertContainer}
+// deploy runs the deploy commands in a container created from the image of
+// the platform of the app, so updates to the platform reach the app in its
+// next deploy, and commits the resulting image. Every build starts from the
+// clean platform image, so the image of the app doesn't grow across deploys;
+// dependencies are kept in the build cache of the app instead.
+func deploy(app provision.App, commands []string, w io.Writer) (string, error) {
+	imageId := assembleImageName(app.GetPlatform())
+	actions := []*action.Action{&createContainer, &startBuildContainer, &insertContainer, &followBuild}
 	pipeline := action.NewPipeline(actions...)
-	err = pipeline.Execute(app, imageId, commands)
+	err := pipeline.Execute(app, imageId, commands, w)
 	if err != nil {
 		log.Errorf("error on execute deploy pipeline for app %s - %s", app.GetName(), err)
 		return "", err
 	}
 	c := pipeline.Result().(container)
-	_, err = dockerCluster().WaitContainer(c.ID)
-	if err != nil {
-		log.Errorf("Process failed for container %q: %s", c.ID, err)
-		return "", err
-	}
-	err = c.logs(w)
-	if err != nil {
-		log.Errorf("error on get logs for container %s - %s", c.ID, err)
-		return "", err
-	}
-	imageId, err = c.commit()
+	imageId, err = c.commit(buildImageName(app.GetName()))
 	if err != nil {
 		log.Errorf("error on commit container %s - %s", c.ID, err)
 		return "", err
@@ -227,6 +210,27 @@ func deploy(app provision.App, version string, w io.Writer) (string, error) {
 	return imageId, nil
 }
 
+// cancelBuild stops the build containers of the app, marking them as
+// canceled so the deploy fails instead of committing a partial build.
+func cancelBuild(appName string) error {
+	var containers []container
+	coll := collection()
+	defer coll.Close()
+	err := coll.Find(bson.M{"appname": appName, "status": "building"}).All(&containers)
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if err := c.setStatus("canceled"); err != nil {
+			return err
+		}
+		if err := c.stop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func start(app provision.App, imageId string, w io.Writer) (*container, error) {
 	commands, err := runCmds()
 	if err != nil {
@@ -247,6 +251,7 @@ func start(app provision.App, imageId string, w io.Writer) (*container, error) {
 	if err != nil {
 		return nil, err
 	}
+	notifyDependents(c.AppName)
 	return &c, nil
 }
 
@@ -258,7 +263,6 @@ func (c *container) remove() error {
 	if err != nil {
 		log.Errorf("Failed to remove container from docker: %s", err)
 	}
-	c.removeHost()
 	log.Debugf("Removing container %s from database", c.ID)
 	coll := collection()
 	defer coll.Close()
@@ -275,45 +279,43 @@ func (c *container) remove() error {
 	return nil
 }
 
-func (c *container) removeHost() error {
-	url := fmt.Sprintf("http://%s:%d/container/%s", c.HostAddr, sshAgentPort(), c.IP)
-	request, _ := http.NewRequest("DELETE", url, nil)
-	resp, err := http.DefaultClient.Do(request)
+// exec runs the given command in the container through the docker API,
+// streaming its stdout and stderr to the given writers. It returns an error
+// if the command exits with a non-zero status.
+func (c *container) exec(stdout, stderr io.Writer, cmd string, args ...string) error {
+	address, err := c.nodeAddress()
 	if err != nil {
 		return err
 	}
-	resp.Body.Close()
-	return nil
-}
-
-func (c *container) ssh(stdout, stderr io.Writer, cmd string, args ...string) error {
-	ip, _, err := c.networkInfo()
+	cfg := execConfig{
+		Cmd:          append([]string{cmd}, args...),
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	id, err := c.createExec(address, cfg)
 	if err != nil {
 		return err
 	}
-	stdout = &filter{w: stdout, content: []byte("unable to resolve host")}
-	url := fmt.Sprintf("http://%s:%d/container/%s/cmd", c.HostAddr, sshAgentPort(), ip)
-	input := cmdInput{Cmd: cmd, Args: args}
-	var buf bytes.Buffer
-	err = json.NewEncoder(&buf).Encode(input)
-	if err != nil {
+	if err = startExec(address, id, false, nil, stdout, stderr); err != nil {
 		return err
 	}
-	resp, err := http.Post(url, "application/json", &buf)
+	code, err := inspectExec(address, id)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	_, err = io.Copy(stdout, resp.Body)
-	return err
+	if code != 0 {
+		return &execError{cmd: cmd, args: args, container: c.ID, code: code}
+	}
+	return nil
 }
 
-// commit commits an image in docker based in the container
-// and returns the image repository.
-func (c *container) commit() (string, error) {
+// commit commits an image in docker based in the container, with the given
+// name, and returns the ID of the image, that keeps identifying it after the
+// name is given to other images.
+func (c *container) commit(name string) (string, error) {
 	log.Debugf("commiting container %s", c.ID)
-	repository := assembleImageName(c.AppName)
-	opts := dclient.CommitContainerOptions{Container: c.ID, Repository: repository}
+	repository, tag := splitImageName(name)
+	opts := dclient.CommitContainerOptions{Container: c.ID, Repository: repository, Tag: tag}
 	image, err := dockerCluster().CommitContainer(opts)
 	if err != nil {
 		log.Errorf("Could not commit docker image: %s", err)
@@ -321,7 +323,7 @@ func (c *container) commit() (string, error) {
 	}
 	log.Debugf("image %s generated from container %s", image.ID, c.ID)
 	replicateImage(repository)
-	return repository, nil
+	return image.ID, nil
 }
 
 // stop stops the container.
@@ -394,11 +396,15 @@ func removeImage(imageId string) error {
 }
 
 func removeFromRegistry(imageId string) {
-	parts := strings.SplitN(imageId, "/", 3)
+	repository, tag := splitImageName(imageId)
+	parts := strings.SplitN(repository, "/", 3)
 	if len(parts) > 2 {
 		registryServer := parts[0]
 		url := fmt.Sprintf("http://%s/v1/repositories/%s/tags", registryServer,
 			strings.Join(parts[1:], "/"))
+		if tag != "" {
+			url += "/" + tag
+		}
 		request, err := http.NewRequest("DELETE", url, nil)
 		if err == nil {
 			http.DefaultClient.Do(request)
@@ -418,6 +424,18 @@ func (e *cmdError) Error() string {
 	return fmt.Sprintf("Failed to run command %q (%s): %s.", command, e.err, e.out)
 }
 
+type execError struct {
+	cmd       string
+	args      []string
+	container string
+	code      int
+}
+
+func (e *execError) Error() string {
+	command := strings.TrimSpace(e.cmd + " " + strings.Join(e.args, " "))
+	return fmt.Sprintf("Command %q exited with status %d in container %s.", command, e.code, e.container)
+}
+
 // replicateImage replicates the given image through all nodes in the cluster.
 func replicateImage(name string) error {
 	var buf bytes.Buffer
@@ -454,6 +472,23 @@ func replicateImage(name string) error {
 	return nil
 }
 
+// buildImageName returns the name of the image with the last build of the
+// app. The build is tagged as the image of the app only when it's released,
+// after its pre-deploy hooks.
+func buildImageName(appName string) string {
+	return assembleImageName(appName) + ":build"
+}
+
+// splitImageName splits the name of an image in its repository and its tag,
+// that's empty when the name has no tag.
+func splitImageName(name string) (string, string) {
+	i := strings.LastIndex(name, ":")
+	if i < 0 || strings.Contains(name[i:], "/") {
+		return name, ""
+	}
+	return name[:i], name[i+1:]
+}
+
 func assembleImageName(appName string) string {
 	parts := make([]string, 0, 3)
 	registry, _ := config.GetString("docker:registry")
@@ -14,7 +14,6 @@ import (
 	"github.com/globocom/docker-cluster/cluster"
 	"github.com/globocom/tsuru/db"
 	etesting "github.com/globocom/tsuru/exec/testing"
-	ftesting "github.com/globocom/tsuru/fs/testing"
 	rtesting "github.com/globocom/tsuru/router/testing"
 	"github.com/globocom/tsuru/testing"
 	"labix.org/v2/mgo/bson"
@@ -23,8 +22,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"os"
-	"strconv"
 	"strings"
 	"sync/atomic"
 )
@@ -58,7 +55,7 @@ func (s *S) TestNewContainer(c *gocheck.C) {
 	port, err := getPort()
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(cont.Port, gocheck.Equals, port)
-	user, err := config.GetString("docker:ssh:user")
+	user, err := config.GetString("docker:user")
 	c.Assert(err, gocheck.IsNil)
 	dcli, _ := dockerClient.NewClient(s.server.URL())
 	container, err := dcli.InspectContainer(cont.ID)
@@ -67,9 +64,9 @@ func (s *S) TestNewContainer(c *gocheck.C) {
 }
 
 func (s *S) TestNewContainerUndefinedUser(c *gocheck.C) {
-	oldUser, _ := config.Get("docker:ssh:user")
-	defer config.Set("docker:ssh:user", oldUser)
-	config.Unset("docker:ssh:user")
+	oldUser, _ := config.Get("docker:user")
+	defer config.Set("docker:user", oldUser)
+	config.Unset("docker:user")
 	oldClusterNodes := clusterNodes
 	clusterNodes = map[string]string{"server": s.server.URL()}
 	defer func() { clusterNodes = oldClusterNodes }()
@@ -87,61 +84,6 @@ func (s *S) TestNewContainerUndefinedUser(c *gocheck.C) {
 	c.Assert(container.Config.User, gocheck.Equals, "")
 }
 
-func (s *S) TestGetSSHCommandsDefaultSSHDPath(c *gocheck.C) {
-	rfs := ftesting.RecordingFs{}
-	f, err := rfs.Create("/opt/me/id_dsa.pub")
-	c.Assert(err, gocheck.IsNil)
-	f.Write([]byte("ssh-rsa ohwait! me@machine"))
-	f.Close()
-	old := fsystem
-	fsystem = &rfs
-	defer func() {
-		fsystem = old
-	}()
-	config.Set("docker:ssh:public-key", "/opt/me/id_dsa.pub")
-	defer config.Unset("docker:ssh:public-key")
-	commands, err := sshCmds()
-	c.Assert(err, gocheck.IsNil)
-	c.Assert(commands[1], gocheck.Equals, "sudo /usr/sbin/sshd -D")
-}
-
-func (s *S) TestGetSSHCommandsDefaultKeyFile(c *gocheck.C) {
-	rfs := ftesting.RecordingFs{}
-	f, err := rfs.Create(os.ExpandEnv("${HOME}/.ssh/id_rsa.pub"))
-	c.Assert(err, gocheck.IsNil)
-	f.Write([]byte("ssh-rsa ohwait! me@machine"))
-	f.Close()
-	old := fsystem
-	fsystem = &rfs
-	defer func() {
-		fsystem = old
-	}()
-	commands, err := sshCmds()
-	c.Assert(err, gocheck.IsNil)
-	c.Assert(commands[0], gocheck.Equals, "/var/lib/tsuru/add-key ssh-rsa ohwait! me@machine")
-}
-
-func (s *S) TestGetSSHCommandsMissingAddKeyCommand(c *gocheck.C) {
-	old, _ := config.Get("docker:ssh:add-key-cmd")
-	defer config.Set("docker:ssh:add-key-cmd", old)
-	config.Unset("docker:ssh:add-key-cmd")
-	commands, err := sshCmds()
-	c.Assert(commands, gocheck.IsNil)
-	c.Assert(err, gocheck.NotNil)
-}
-
-func (s *S) TestGetSSHCommandsKeyFileNotFound(c *gocheck.C) {
-	old := fsystem
-	fsystem = &ftesting.RecordingFs{}
-	defer func() {
-		fsystem = old
-	}()
-	commands, err := sshCmds()
-	c.Assert(commands, gocheck.IsNil)
-	c.Assert(err, gocheck.NotNil)
-	c.Assert(os.IsNotExist(err), gocheck.Equals, true)
-}
-
 func (s *S) TestGetPort(c *gocheck.C) {
 	port, err := getPort()
 	c.Assert(err, gocheck.IsNil)
@@ -243,8 +185,6 @@ func (s *S) removeTestContainer(c *container) error {
 }
 
 func (s *S) TestContainerRemove(c *gocheck.C) {
-	handler, cleanup := startSSHAgentServer("")
-	defer cleanup()
 	fexec := &etesting.FakeExecutor{}
 	setExecut(fexec)
 	defer setExecut(nil)
@@ -255,8 +195,6 @@ func (s *S) TestContainerRemove(c *gocheck.C) {
 	defer s.removeTestContainer(container)
 	err = container.remove()
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(handler.requests[0].Method, gocheck.Equals, "DELETE")
-	c.Assert(handler.requests[0].URL.Path, gocheck.Equals, "/container/"+container.IP)
 	coll := collection()
 	defer coll.Close()
 	err = coll.FindId(container.ID).One(&container)
@@ -271,8 +209,6 @@ func (s *S) TestContainerRemove(c *gocheck.C) {
 }
 
 func (s *S) TestRemoveContainerIgnoreErrors(c *gocheck.C) {
-	handler, cleanup := startSSHAgentServer("")
-	defer cleanup()
 	fexec := &etesting.FakeExecutor{}
 	setExecut(fexec)
 	defer setExecut(nil)
@@ -286,8 +222,6 @@ func (s *S) TestRemoveContainerIgnoreErrors(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 	err = container.remove()
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(handler.requests[0].Method, gocheck.Equals, "DELETE")
-	c.Assert(handler.requests[0].URL.Path, gocheck.Equals, "/container/"+container.IP)
 	coll := collection()
 	defer coll.Close()
 	err = coll.FindId(container.ID).One(&container)
@@ -296,26 +230,7 @@ func (s *S) TestRemoveContainerIgnoreErrors(c *gocheck.C) {
 	c.Assert(rtesting.FakeRouter.HasRoute(container.AppName, container.getAddress()), gocheck.Equals, false)
 }
 
-func (s *S) TestContainerRemoveHost(c *gocheck.C) {
-	var handler FakeSSHServer
-	handler.output = ". .."
-	server := httptest.NewServer(&handler)
-	defer server.Close()
-	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
-	portNumber, _ := strconv.Atoi(port)
-	config.Set("docker:ssh-agent-port", portNumber)
-	defer config.Unset("docker:ssh-agent-port")
-	container := container{ID: "c-036", AppName: "starbreaker", Type: "python", IP: "10.10.10.1", HostAddr: host}
-	err := container.removeHost()
-	c.Assert(err, gocheck.IsNil)
-	request := handler.requests[0]
-	c.Assert(request.Method, gocheck.Equals, "DELETE")
-	c.Assert(request.URL.Path, gocheck.Equals, "/container/10.10.10.1")
-}
-
 func (s *S) TestContainerNetworkInfo(c *gocheck.C) {
-	_, cleanup := startSSHAgentServer("")
-	defer cleanup()
 	err := newImage("tsuru/python", s.server.URL())
 	c.Assert(err, gocheck.IsNil)
 	cont, err := s.newContainer(nil)
@@ -370,45 +285,104 @@ func (s *S) TestContainerNetworkInfoNotFound(c *gocheck.C) {
 	c.Assert(err.Error(), gocheck.Equals, "Container port 8888 is not mapped to any host port")
 }
 
-func (s *S) TestContainerSSH(c *gocheck.C) {
-	var handler FakeSSHServer
-	handler.output = ". .."
-	server := httptest.NewServer(&handler)
-	defer server.Close()
-	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
-	portNumber, _ := strconv.Atoi(port)
-	config.Set("docker:ssh-agent-port", portNumber)
-	defer config.Unset("docker:ssh-agent-port")
+func (s *S) TestContainerExec(c *gocheck.C) {
+	handler, cleanup := startExecServer(". ..", "", 0)
+	defer cleanup()
+	container := container{ID: "c-036", AppName: "starbreaker", HostAddr: "127.0.0.1"}
 	var stdout, stderr bytes.Buffer
-	container, err := s.newContainer(nil)
+	err := container.exec(&stdout, &stderr, "ls", "-a")
 	c.Assert(err, gocheck.IsNil)
-	defer s.removeTestContainer(container)
-	container.HostAddr = host
-	err = container.ssh(&stdout, &stderr, "ls", "-a")
+	c.Assert(stdout.String(), gocheck.Equals, ". ..")
+	c.Assert(stderr.String(), gocheck.Equals, "")
+	expected := []execCall{{Container: "c-036", Cmd: []string{"ls", "-a"}}}
+	c.Assert(handler.calls, gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestContainerExecStderr(c *gocheck.C) {
+	_, cleanup := startExecServer("", "ls: cannot access wat", 0)
+	defer cleanup()
+	container := container{ID: "c-036", AppName: "starbreaker", HostAddr: "127.0.0.1"}
+	var stdout, stderr bytes.Buffer
+	err := container.exec(&stdout, &stderr, "ls", "wat")
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(stdout.String(), gocheck.Equals, handler.output)
-	body := handler.bodies[0]
-	input := cmdInput{Cmd: "ls", Args: []string{"-a"}}
-	c.Assert(body, gocheck.DeepEquals, input)
+	c.Assert(stdout.String(), gocheck.Equals, "")
+	c.Assert(stderr.String(), gocheck.Equals, "ls: cannot access wat")
 }
 
-func (s *S) TestContainerSSHFiltersStdout(c *gocheck.C) {
-	var handler FakeSSHServer
-	handler.output = "failed\nunable to resolve host abcdef"
-	server := httptest.NewServer(&handler)
-	defer server.Close()
-	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
-	portNumber, _ := strconv.Atoi(port)
-	config.Set("docker:ssh-agent-port", portNumber)
-	defer config.Unset("docker:ssh-agent-port")
+func (s *S) TestContainerExecNonZeroStatus(c *gocheck.C) {
+	_, cleanup := startExecServer("", "", 2)
+	defer cleanup()
+	container := container{ID: "c-036", AppName: "starbreaker", HostAddr: "127.0.0.1"}
 	var stdout, stderr bytes.Buffer
-	container, err := s.newContainer(nil)
+	err := container.exec(&stdout, &stderr, "ls", "wat")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, `Command "ls wat" exited with status 2 in container c-036.`)
+}
+
+func (s *S) TestContainerExecUnknownNode(c *gocheck.C) {
+	_, cleanup := startExecServer("", "", 0)
+	defer cleanup()
+	container := container{ID: "c-036", AppName: "starbreaker", HostAddr: "10.10.10.10"}
+	var stdout, stderr bytes.Buffer
+	err := container.exec(&stdout, &stderr, "ls")
+	c.Assert(err, gocheck.Equals, errNodeNotFoundForContainer)
+}
+
+func (s *S) TestContainerExecWithoutUpgrade(c *gocheck.C) {
+	handler, cleanup := startExecServer(". ..", "", 0)
+	defer cleanup()
+	handler.noUpgrade = true
+	container := container{ID: "c-036", AppName: "starbreaker", HostAddr: "127.0.0.1"}
+	var stdout, stderr bytes.Buffer
+	err := container.exec(&stdout, &stderr, "ls", "-a")
 	c.Assert(err, gocheck.IsNil)
-	defer s.removeTestContainer(container)
-	container.HostAddr = host
-	err = container.ssh(&stdout, &stderr, "ls", "-a")
+	c.Assert(stdout.String(), gocheck.Equals, ". ..")
+}
+
+func (s *S) TestStartExecSendsTheInput(c *gocheck.C) {
+	_, cleanup := startExecServer("", "", 0)
+	defer cleanup()
+	container := container{ID: "c-036", AppName: "starbreaker", HostAddr: "127.0.0.1"}
+	address, err := container.nodeAddress()
+	c.Assert(err, gocheck.IsNil)
+	cfg := execConfig{Cmd: []string{"cat"}, AttachStdin: true, AttachStdout: true, AttachStderr: true}
+	id, err := container.createExec(address, cfg)
+	c.Assert(err, gocheck.IsNil)
+	var stdout, stderr bytes.Buffer
+	err = startExec(address, id, false, strings.NewReader("some input"), &stdout, &stderr)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stdout.String(), gocheck.Equals, "some input")
+}
+
+func (s *S) TestStartExecUnsupportedEndpoint(c *gocheck.C) {
+	for _, address := range []string{"unix:///var/run/docker.sock", "https://10.10.10.10:4243"} {
+		var stdout, stderr bytes.Buffer
+		err := startExec(address, "exec-1", false, nil, &stdout, &stderr)
+		c.Assert(err, gocheck.NotNil)
+		c.Assert(err.Error(), gocheck.Equals, "Cannot run commands through the docker node "+address+": only http endpoints are supported.")
+	}
+}
+
+func (s *S) TestDemuxStream(c *gocheck.C) {
+	var stream, stdout, stderr bytes.Buffer
+	writeFrame(&stream, 1, "out")
+	writeFrame(&stream, 2, "err")
+	writeFrame(&stream, 1, "put")
+	err := demuxStream(&stream, &stdout, &stderr)
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(stdout.String(), gocheck.Equals, "failed\n")
+	c.Assert(stdout.String(), gocheck.Equals, "output")
+	c.Assert(stderr.String(), gocheck.Equals, "err")
+}
+
+func (s *S) TestDockerUser(c *gocheck.C) {
+	oldUser, _ := config.Get("docker:user")
+	defer config.Set("docker:user", oldUser)
+	config.Set("docker:user", "ubuntu")
+	c.Assert(dockerUser(), gocheck.Equals, "ubuntu")
+	config.Unset("docker:user")
+	config.Set("docker:ssh:user", "root")
+	defer config.Unset("docker:ssh:user")
+	c.Assert(dockerUser(), gocheck.Equals, "root")
 }
 
 func (s *S) TestGetContainer(c *gocheck.C) {
@@ -483,18 +457,53 @@ func (s *S) TestGetImageWithRegistry(c *gocheck.C) {
 }
 
 func (s *S) TestContainerCommit(c *gocheck.C) {
-	_, cleanup := startSSHAgentServer("")
-	defer cleanup()
 	err := newImage("tsuru/python", s.server.URL())
 	c.Assert(err, gocheck.IsNil)
 	cont, err := s.newContainer(nil)
 	c.Assert(err, gocheck.IsNil)
 	defer s.removeTestContainer(cont)
-	imageId, err := cont.commit()
+	imageId, err := cont.commit(assembleImageName(cont.AppName))
 	c.Assert(err, gocheck.IsNil)
 	repoNamespace, _ := config.GetString("docker:repository-namespace")
 	repository := repoNamespace + "/" + cont.AppName
-	c.Assert(imageId, gocheck.Equals, repository)
+	client, err := dockerClient.NewClient(s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	image, err := client.InspectImage(repository)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(imageId, gocheck.Equals, image.ID)
+}
+
+func (s *S) TestContainerCommitWithTag(c *gocheck.C) {
+	err := newImage("tsuru/python", s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	cont, err := s.newContainer(nil)
+	c.Assert(err, gocheck.IsNil)
+	defer s.removeTestContainer(cont)
+	imageId, err := cont.commit(buildImageName(cont.AppName))
+	c.Assert(err, gocheck.IsNil)
+	client, err := dockerClient.NewClient(s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	image, err := client.InspectImage(buildImageName(cont.AppName))
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(imageId, gocheck.Equals, image.ID)
+}
+
+func (s *S) TestSplitImageName(c *gocheck.C) {
+	var tests = []struct {
+		name       string
+		repository string
+		tag        string
+	}{
+		{"tsuru/myapp", "tsuru/myapp", ""},
+		{"tsuru/myapp:build", "tsuru/myapp", "build"},
+		{"localhost:3030/tsuru/myapp", "localhost:3030/tsuru/myapp", ""},
+		{"localhost:3030/tsuru/myapp:build", "localhost:3030/tsuru/myapp", "build"},
+	}
+	for _, t := range tests {
+		repository, tag := splitImageName(t.name)
+		c.Check(repository, gocheck.Equals, t.repository)
+		c.Check(tag, gocheck.Equals, t.tag)
+	}
 }
 
 func (s *S) TestRemoveImage(c *gocheck.C) {
@@ -533,20 +542,10 @@ func (s *S) TestContainerDeploy(c *gocheck.C) {
 	app := testing.NewFakeApp("myapp", "python", 1)
 	rtesting.FakeRouter.AddBackend(app.GetName())
 	defer rtesting.FakeRouter.RemoveBackend(app.GetName())
-	var buf bytes.Buffer
-	_, err = deploy(app, "ff13e", &buf)
+	commands, err := deployCmds(app, "ff13e")
 	c.Assert(err, gocheck.IsNil)
-}
-
-func (s *S) TestBuild(c *gocheck.C) {
-	go s.stopContainers(1)
-	err := newImage("tsuru/python", s.server.URL())
-	c.Assert(err, gocheck.IsNil)
-	app := testing.NewFakeApp("myapp", "python", 1)
-	rtesting.FakeRouter.AddBackend(app.GetName())
-	defer rtesting.FakeRouter.RemoveBackend(app.GetName())
-	buf := &bytes.Buffer{}
-	_, err = build(app, "versionff13e", buf)
+	var buf bytes.Buffer
+	_, err = deploy(app, commands, &buf)
 	c.Assert(err, gocheck.IsNil)
 }
 
@@ -603,8 +602,6 @@ func (s *S) TestContainerStop(c *gocheck.C) {
 }
 
 func (s *S) TestContainerLogs(c *gocheck.C) {
-	_, cleanup := startSSHAgentServer("")
-	defer cleanup()
 	err := newImage("tsuru/python", s.server.URL())
 	c.Assert(err, gocheck.IsNil)
 	cont, err := s.newContainer(nil)
@@ -731,6 +728,10 @@ func (s *S) TestBuildImageName(c *gocheck.C) {
 	c.Assert(repository, gocheck.Equals, s.repoNamespace+"/raising")
 }
 
+func (s *S) TestBuildImageNameOfTheLastBuild(c *gocheck.C) {
+	c.Assert(buildImageName("raising"), gocheck.Equals, s.repoNamespace+"/raising:build")
+}
+
 func (s *S) TestBuildImageNameWithRegistry(c *gocheck.C) {
 	config.Set("docker:registry", "localhost:3030")
 	defer config.Unset("docker:registry")
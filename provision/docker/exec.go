@@ -0,0 +1,203 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// The go-dockerclient and docker-cluster revisions used by tsuru predate the
+// exec endpoints of the docker API, so commands are started in running
+// containers by sending the requests directly to the docker node that runs
+// the container. The docker daemon in the nodes must support the API 1.15 or
+// newer.
+
+var errNodeNotFoundForContainer = errors.New("Could not find the docker node of the container.")
+
+type execConfig struct {
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+	Tty          bool
+	Cmd          []string
+}
+
+type execStartConfig struct {
+	Detach bool
+	Tty    bool
+}
+
+type execInspect struct {
+	ID       string `json:"Id"`
+	ExitCode int
+}
+
+// nodeAddress returns the address of the docker API of the node that runs
+// the container, looking for it in the docker:servers setting and in the
+// nodes registered in the segregated scheduler.
+func (c *container) nodeAddress() (string, error) {
+	dockerCluster()
+	addresses := make([]string, 0, len(clusterNodes))
+	for _, address := range clusterNodes {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	if nodes, err := (segregatedScheduler{}).Nodes(); err == nil {
+		for _, n := range nodes {
+			addresses = append(addresses, n.Address)
+		}
+	}
+	for _, address := range addresses {
+		u, err := url.Parse(address)
+		if err != nil {
+			continue
+		}
+		host, _, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			host = u.Host
+		}
+		if host == c.HostAddr {
+			return address, nil
+		}
+	}
+	return "", errNodeNotFoundForContainer
+}
+
+// createExec creates, without starting it, an exec instance that runs the
+// given command in the container.
+func (c *container) createExec(address string, cfg execConfig) (string, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(cfg); err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("%s/containers/%s/exec", address, c.ID)
+	resp, err := http.Post(url, "application/json", &body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("Failed to run the command in the container %s: %s", c.ID, bytes.TrimSpace(msg))
+	}
+	var e execInspect
+	if err = json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		return "", err
+	}
+	return e.ID, nil
+}
+
+// startExec starts the exec instance, hijacking the connection with the
+// docker node to send the input of the command and read its output until it
+// exits. The output follows the headers of the response in the connection,
+// and without a TTY docker multiplexes stdout and stderr in it. The write
+// side of the connection is closed once the input ends, so the command gets
+// EOF in its stdin.
+func startExec(address, id string, tty bool, stdin io.Reader, stdout, stderr io.Writer) error {
+	u, err := url.Parse(address)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" {
+		return fmt.Errorf("Cannot run commands through the docker node %s: only http endpoints are supported.", address)
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	body, err := json.Marshal(execStartConfig{Tty: tty})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/exec/%s/start", address, id), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	if err = req.Write(conn); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Failed to start the command: %s", bytes.TrimSpace(msg))
+	}
+	if stdin != nil {
+		go func() {
+			io.Copy(conn, stdin)
+			if c, ok := conn.(interface {
+				CloseWrite() error
+			}); ok {
+				c.CloseWrite()
+			}
+		}()
+	}
+	if tty {
+		_, err = io.Copy(stdout, reader)
+		return err
+	}
+	return demuxStream(reader, stdout, stderr)
+}
+
+// inspectExec returns the exit code of the command run by the exec
+// instance.
+func inspectExec(address, id string) (int, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/exec/%s/json", address, id))
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return -1, fmt.Errorf("Failed to get the status of the command: %s", bytes.TrimSpace(msg))
+	}
+	var e execInspect
+	if err = json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		return -1, err
+	}
+	return e.ExitCode, nil
+}
+
+// demuxStream copies the multiplexed output of a command to stdout and
+// stderr. Each frame of the stream has an eight bytes header: the first one
+// identifies the stream and the last four the size of the frame.
+func demuxStream(r io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/globocom/tsuru/log"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/repository"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// readAppConfig reads the app.yaml, or app.yml, of the app from the given
+// image, and parses it. An app without app.yaml has an empty configuration.
+func readAppConfig(app provision.App, imageId string) (*provision.AppConfig, error) {
+	repoPath, err := repository.GetPath()
+	if err != nil {
+		return nil, err
+	}
+	yamlPath := path.Join(repoPath, "app.yaml")
+	ymlPath := path.Join(repoPath, "app.yml")
+	cmd := fmt.Sprintf("cat %s 2>/dev/null || cat %s 2>/dev/null || true", yamlPath, ymlPath)
+	cfg := oneOffConfig(app, []string{"/bin/bash", "-c", cmd})
+	cfg.Image = imageId
+	var buf bytes.Buffer
+	if _, err := runOneOff(cfg, &buf, ioutil.Discard); err != nil {
+		return nil, err
+	}
+	return provision.ParseAppConfig(buf.Bytes())
+}
+
+// buildHooksCmd returns the command line that runs the given build hooks, in
+// the directory of the app, echoing each hook before running it and stopping
+// in the first failure.
+func buildHooksCmd(repoPath string, hooks []string) string {
+	cmds := []string{"set -e", "cd " + repoPath}
+	for _, hook := range hooks {
+		quoted := "'" + strings.Replace(hook, "'", `'\''`, -1) + "'"
+		cmds = append(cmds, "echo ' ---> Running '"+quoted, hook)
+	}
+	return strings.Join(cmds, "\n")
+}
+
+// runBuildHooks runs the build hooks of the app in a container created from
+// the given image, with the build cache of the app, and commits the result
+// as the new build of the app.
+func runBuildHooks(app provision.App, imageId string, hooks []string, w io.Writer) (string, error) {
+	repoPath, err := repository.GetPath()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(w, "\n ---> Running build hooks\n")
+	cfg := oneOffConfig(app, []string{"/bin/bash", "-c", buildHooksCmd(repoPath, hooks)})
+	cfg.Image = imageId
+	if _, dir, ok := buildCache(app); ok {
+		cfg.Env = append(cfg.Env, "TSURU_BUILD_CACHE="+dir)
+	}
+	_, cont, err := dockerCluster().CreateContainer(&cfg)
+	if err != nil {
+		return "", err
+	}
+	c := container{ID: cont.ID, AppName: app.GetName()}
+	defer func() {
+		if err := dockerCluster().RemoveContainer(c.ID); err != nil {
+			log.Errorf("Failed to remove the container %s: %s", c.ID, err)
+		}
+	}()
+	if err = dockerCluster().StartContainer(c.ID, buildHostConfig(app)); err != nil {
+		return "", err
+	}
+	status, err := dockerCluster().WaitContainer(c.ID)
+	if err != nil {
+		return "", err
+	}
+	if err = c.logs(w); err != nil {
+		return "", err
+	}
+	if status != 0 {
+		fmt.Fprintf(w, "\n ---> Build hooks failed with status %d\n", status)
+		return "", fmt.Errorf("The build hooks of the app failed with status %d.", status)
+	}
+	return c.commit(buildImageName(app.GetName()))
+}
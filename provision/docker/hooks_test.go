@@ -0,0 +1,27 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"github.com/globocom/tsuru/provision"
+	"launchpad.net/gocheck"
+)
+
+func (s *S) TestProvisionerIsBuilder(c *gocheck.C) {
+	var _ provision.Promoter = &dockerProvisioner{}
+	var _ provision.Builder = &dockerProvisioner{}
+	var _ provision.DeployCanceler = &dockerProvisioner{}
+}
+
+func (s *S) TestBuildHooksCmd(c *gocheck.C) {
+	hooks := []string{"python manage.py collectstatic --noinput", "echo 'done'"}
+	expected := `set -e
+cd /home/application/current
+echo ' ---> Running ''python manage.py collectstatic --noinput'
+python manage.py collectstatic --noinput
+echo ' ---> Running ''echo '\''done'\'''
+echo 'done'`
+	c.Assert(buildHooksCmd("/home/application/current", hooks), gocheck.Equals, expected)
+}
@@ -0,0 +1,133 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dotcloud/docker"
+	dclient "github.com/fsouza/go-dockerclient"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/log"
+	"github.com/globocom/tsuru/provision"
+	"io"
+	"strings"
+)
+
+// ErrImageDeployNotAllowed is returned when the platform of the app is not
+// allowed to be deployed from prebuilt images.
+var ErrImageDeployNotAllowed = errors.New("Deploys from prebuilt images are not allowed for the platform of this app.")
+
+// imageRegistry returns the registry part of the given image name, or an
+// empty string for images in the public index.
+func imageRegistry(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	if strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost" {
+		return parts[0]
+	}
+	return ""
+}
+
+// validateImageDeploy checks whether the app may be deployed from the given
+// image. Admins restrict which platforms may be deployed from prebuilt images
+// with docker:image-deploy:platforms, and where images may come from with
+// docker:image-deploy:registries. Unset lists allow everything.
+func validateImageDeploy(app provision.App, image string) error {
+	if image == "" || strings.ContainsAny(image, " \t\n") {
+		return fmt.Errorf("Invalid image name: %q.", image)
+	}
+	if platforms, err := config.GetList("docker:image-deploy:platforms"); err == nil {
+		if !contains(platforms, app.GetPlatform()) {
+			return ErrImageDeployNotAllowed
+		}
+	}
+	if registries, err := config.GetList("docker:image-deploy:registries"); err == nil {
+		if !contains(registries, imageRegistry(image)) {
+			return fmt.Errorf("Images from the registry %q are not allowed.", imageRegistry(image))
+		}
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// importImage copies the given image to the image of the app with the given
+// name. It runs a container from the image, checking that the run command is
+// available in it, and commits the container with the name, replicating the
+// new image through the nodes.
+func importImage(app provision.App, image, name string) (string, error) {
+	runCmd, err := config.GetString("docker:run-cmd:bin")
+	if err != nil {
+		return "", err
+	}
+	user := dockerUser()
+	cfg := docker.Config{
+		Image: image,
+		Cmd:   []string{"/bin/bash", "-c", "test -x " + runCmd},
+		User:  user,
+	}
+	_, cont, err := dockerCluster().CreateContainer(&cfg)
+	if err != nil {
+		return "", err
+	}
+	c := container{ID: cont.ID, AppName: app.GetName()}
+	defer func() {
+		if err := dockerCluster().RemoveContainer(c.ID); err != nil {
+			log.Errorf("Failed to remove the container %s: %s", c.ID, err)
+		}
+	}()
+	err = dockerCluster().StartContainer(c.ID, nil)
+	if err != nil {
+		return "", err
+	}
+	status, err := dockerCluster().WaitContainer(c.ID)
+	if err != nil {
+		return "", err
+	}
+	if status != 0 {
+		return "", fmt.Errorf("Invalid image %q: the run command %q was not found in it.", image, runCmd)
+	}
+	return c.commit(name)
+}
+
+// DeployImage deploys the app from a prebuilt image, instead of building it.
+// The image is pulled, validated and tagged in the repository of the app,
+// and units are restarted using it.
+func (p *dockerProvisioner) DeployImage(app provision.App, image string, w io.Writer) error {
+	if err := validateImageDeploy(app, image); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n ---> Pulling image %s\n", image)
+	err := dockerCluster().PullImage(dclient.PullImageOptions{Repository: image}, w)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n ---> Importing image %s\n", image)
+	imageId, err := importImage(app, image, assembleImageName(app.GetName()))
+	if err != nil {
+		return err
+	}
+	startUnits(app, imageId, w)
+	return nil
+}
+
+// Promote imports the image with the given ID, released in a deploy of
+// another app, as the last build of the target app, so it's released in the
+// target app without being built again.
+func (p *dockerProvisioner) Promote(build string, target provision.App, w io.Writer) error {
+	fmt.Fprintf(w, "\n ---> Importing image %s\n", build)
+	_, err := importImage(target, build, buildImageName(target.GetName()))
+	return err
+}
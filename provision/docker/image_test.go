@@ -0,0 +1,126 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bytes"
+	dockerClient "github.com/fsouza/go-dockerclient"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/app"
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/testing"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+)
+
+func (s *S) TestImageRegistry(c *gocheck.C) {
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"python", ""},
+		{"tsuru/python", ""},
+		{"tsuru/python:latest", ""},
+		{"registry.tsuru.io/tsuru/python", "registry.tsuru.io"},
+		{"localhost:5000/python", "localhost:5000"},
+		{"localhost/python", "localhost"},
+	}
+	for _, t := range tests {
+		c.Check(imageRegistry(t.input), gocheck.Equals, t.expected)
+	}
+}
+
+func (s *S) TestValidateImageDeploy(c *gocheck.C) {
+	app := testing.NewFakeApp("almah", "python", 1)
+	err := validateImageDeploy(app, "registry.tsuru.io/almah:1.0")
+	c.Assert(err, gocheck.IsNil)
+}
+
+func (s *S) TestValidateImageDeployInvalidName(c *gocheck.C) {
+	app := testing.NewFakeApp("almah", "python", 1)
+	err := validateImageDeploy(app, "")
+	c.Assert(err, gocheck.NotNil)
+	err = validateImageDeploy(app, "almah 1.0")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, `Invalid image name: "almah 1.0".`)
+}
+
+func (s *S) TestValidateImageDeployPlatforms(c *gocheck.C) {
+	config.Set("docker:image-deploy:platforms", []interface{}{"static", "go"})
+	defer config.Unset("docker:image-deploy:platforms")
+	err := validateImageDeploy(testing.NewFakeApp("almah", "go", 1), "almah:1.0")
+	c.Assert(err, gocheck.IsNil)
+	err = validateImageDeploy(testing.NewFakeApp("almah", "python", 1), "almah:1.0")
+	c.Assert(err, gocheck.Equals, ErrImageDeployNotAllowed)
+}
+
+func (s *S) TestValidateImageDeployRegistries(c *gocheck.C) {
+	config.Set("docker:image-deploy:registries", []interface{}{"registry.tsuru.io"})
+	defer config.Unset("docker:image-deploy:registries")
+	app := testing.NewFakeApp("almah", "python", 1)
+	err := validateImageDeploy(app, "registry.tsuru.io/almah:1.0")
+	c.Assert(err, gocheck.IsNil)
+	err = validateImageDeploy(app, "almah:1.0")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, `Images from the registry "" are not allowed.`)
+	err = validateImageDeploy(app, "evil.com/almah:1.0")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, `Images from the registry "evil.com" are not allowed.`)
+}
+
+func (s *S) TestDeployImage(c *gocheck.C) {
+	go s.stopContainers(1)
+	err := newImage("tsuru/otherapp-prebuilt", s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	p := dockerProvisioner{}
+	a := app.App{Name: "otherapp", Platform: "python"}
+	conn, err := db.Conn()
+	c.Assert(err, gocheck.IsNil)
+	defer conn.Close()
+	err = conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer conn.Apps().Remove(bson.M{"name": a.Name})
+	p.Provision(&a)
+	defer p.Destroy(&a)
+	var buf bytes.Buffer
+	err = p.DeployImage(&a, "tsuru/otherapp-prebuilt", &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Matches, "(?s).*Pulling image tsuru/otherapp-prebuilt.*")
+}
+
+func (s *S) TestDeployImageNotAllowed(c *gocheck.C) {
+	config.Set("docker:image-deploy:platforms", []interface{}{"static"})
+	defer config.Unset("docker:image-deploy:platforms")
+	p := dockerProvisioner{}
+	a := app.App{Name: "otherapp", Platform: "python"}
+	var buf bytes.Buffer
+	err := p.DeployImage(&a, "tsuru/otherapp-prebuilt", &buf)
+	c.Assert(err, gocheck.Equals, ErrImageDeployNotAllowed)
+	c.Assert(buf.String(), gocheck.Equals, "")
+}
+
+func (s *S) TestProvisionerIsImageDeployer(c *gocheck.C) {
+	var _ provision.ImageDeployer = &dockerProvisioner{}
+}
+
+func (s *S) TestPromote(c *gocheck.C) {
+	err := newImage("tsuru/python", s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	client, err := dockerClient.NewClient(s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	image, err := client.InspectImage("tsuru/python")
+	c.Assert(err, gocheck.IsNil)
+	go s.stopContainers(1)
+	p := dockerProvisioner{}
+	target := testing.NewFakeApp("production", "python", 1)
+	var buf bytes.Buffer
+	err = p.Promote(image.ID, target, &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Matches, "(?s).*Importing image "+image.ID+".*")
+	promoted, err := client.InspectImage(buildImageName(target.GetName()))
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(promoted.ID, gocheck.Not(gocheck.Equals), "")
+}
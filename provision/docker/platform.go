@@ -0,0 +1,68 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	dclient "github.com/fsouza/go-dockerclient"
+	"github.com/globocom/tsuru/provision"
+	"io"
+)
+
+// dockerfileContext returns a build context, in a tar archive, that holds
+// the given Dockerfile.
+func dockerfileContext(dockerfile string) (io.Reader, error) {
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	header := tar.Header{Name: "Dockerfile", Mode: 0644, Size: int64(len(dockerfile))}
+	if err := writer.WriteHeader(&header); err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write([]byte(dockerfile)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// buildPlatform builds the image of the platform, from the Dockerfile
+// available in the given URL or from the given base image, and replicates it
+// through the nodes.
+func buildPlatform(opts provision.PlatformOptions, w io.Writer) error {
+	imageName := assembleImageName(opts.Name)
+	buildOpts := dclient.BuildImageOptions{
+		Name:         imageName,
+		NoCache:      true,
+		OutputStream: w,
+	}
+	if opts.Dockerfile != "" {
+		buildOpts.Remote = opts.Dockerfile
+	} else {
+		context, err := dockerfileContext("FROM " + opts.Image + "\n")
+		if err != nil {
+			return err
+		}
+		buildOpts.InputStream = context
+	}
+	if err := dockerCluster().BuildImage(buildOpts); err != nil {
+		return err
+	}
+	return replicateImage(imageName)
+}
+
+func (p *dockerProvisioner) PlatformAdd(opts provision.PlatformOptions, w io.Writer) error {
+	return buildPlatform(opts, w)
+}
+
+func (p *dockerProvisioner) PlatformUpdate(opts provision.PlatformOptions, w io.Writer) error {
+	return buildPlatform(opts, w)
+}
+
+func (p *dockerProvisioner) PlatformRemove(name string) error {
+	return removeImage(assembleImageName(name))
+}
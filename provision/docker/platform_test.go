@@ -0,0 +1,86 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"github.com/globocom/docker-cluster/cluster"
+	"github.com/globocom/tsuru/provision"
+	"io/ioutil"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (s *S) TestDockerfileContext(c *gocheck.C) {
+	context, err := dockerfileContext("FROM tsuru/base\n")
+	c.Assert(err, gocheck.IsNil)
+	reader := tar.NewReader(context)
+	header, err := reader.Next()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(header.Name, gocheck.Equals, "Dockerfile")
+	content, err := ioutil.ReadAll(reader)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(content), gocheck.Equals, "FROM tsuru/base\n")
+}
+
+func (s *S) startBuildServer(c *gocheck.C, requests chan<- *http.Request) func() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		w.Write([]byte("Successfully built"))
+	}))
+	cmutext.Lock()
+	oldDockerCluster := dCluster
+	dCluster, _ = cluster.New(nil, cluster.Node{ID: "server0", Address: server.URL})
+	cmutext.Unlock()
+	return func() {
+		cmutext.Lock()
+		defer cmutext.Unlock()
+		dCluster = oldDockerCluster
+		server.Close()
+	}
+}
+
+func (s *S) TestPlatformAddFromDockerfile(c *gocheck.C) {
+	requests := make(chan *http.Request, 1)
+	defer s.startBuildServer(c, requests)()
+	var p dockerProvisioner
+	var buf bytes.Buffer
+	opts := provision.PlatformOptions{Name: "ruby", Dockerfile: "http://example.com/ruby/Dockerfile"}
+	err := p.PlatformAdd(opts, &buf)
+	c.Assert(err, gocheck.IsNil)
+	r := <-requests
+	c.Assert(r.URL.Path, gocheck.Equals, "/build")
+	c.Assert(r.URL.Query().Get("t"), gocheck.Equals, assembleImageName("ruby"))
+	c.Assert(r.URL.Query().Get("remote"), gocheck.Equals, "http://example.com/ruby/Dockerfile")
+	c.Assert(buf.String(), gocheck.Equals, "Successfully built")
+}
+
+func (s *S) TestPlatformUpdateFromImage(c *gocheck.C) {
+	requests := make(chan *http.Request, 1)
+	defer s.startBuildServer(c, requests)()
+	var p dockerProvisioner
+	var buf bytes.Buffer
+	opts := provision.PlatformOptions{Name: "ruby", Image: "tsuru/ruby-base"}
+	err := p.PlatformUpdate(opts, &buf)
+	c.Assert(err, gocheck.IsNil)
+	r := <-requests
+	c.Assert(r.URL.Path, gocheck.Equals, "/build")
+	c.Assert(r.URL.Query().Get("t"), gocheck.Equals, assembleImageName("ruby"))
+	c.Assert(r.URL.Query().Get("remote"), gocheck.Equals, "")
+}
+
+func (s *S) TestPlatformRemove(c *gocheck.C) {
+	err := newImage(assembleImageName("ruby"), s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	var p dockerProvisioner
+	err = p.PlatformRemove("ruby")
+	c.Assert(err, gocheck.IsNil)
+}
+
+func (s *S) TestProvisionerIsPlatformManager(c *gocheck.C) {
+	var _ provision.PlatformManager = &dockerProvisioner{}
+}
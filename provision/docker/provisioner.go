@@ -13,15 +13,18 @@ import (
 	"github.com/globocom/tsuru/app"
 	"github.com/globocom/tsuru/cmd"
 	"github.com/globocom/tsuru/db"
+	tsuruErrors "github.com/globocom/tsuru/errors"
 	"github.com/globocom/tsuru/exec"
 	"github.com/globocom/tsuru/log"
 	"github.com/globocom/tsuru/provision"
 	"github.com/globocom/tsuru/queue"
 	"github.com/globocom/tsuru/router"
+	_ "github.com/globocom/tsuru/router/file"
 	_ "github.com/globocom/tsuru/router/hipache"
 	_ "github.com/globocom/tsuru/router/testing"
 	"io"
 	"io/ioutil"
+	"sort"
 	"sync"
 	"time"
 )
@@ -76,7 +79,7 @@ func (p *dockerProvisioner) Restart(app provision.App) error {
 	}
 	var buf bytes.Buffer
 	for _, c := range containers {
-		err = c.ssh(&buf, &buf, "/var/lib/tsuru/restart")
+		err = c.exec(&buf, &buf, "/var/lib/tsuru/restart")
 		if err != nil {
 			log.Errorf("Failed to restart %q: %s.", app.GetName(), err)
 			log.Debug("Command outputs:")
@@ -127,10 +130,86 @@ func (dockerProvisioner) Swap(app1, app2 provision.App) error {
 }
 
 func (p *dockerProvisioner) Deploy(a provision.App, version string, w io.Writer) error {
-	imageId, err := build(a, version, w)
-	if err != nil {
+	return p.buildAndRelease(a, provision.BuildOptions{Version: version}, w)
+}
+
+// DeployArchive builds the app from the tar.gz archive available in the
+// given URL, the same way it's built from the git repository, and restarts
+// its units.
+func (p *dockerProvisioner) DeployArchive(a provision.App, archiveURL string, w io.Writer) error {
+	return p.buildAndRelease(a, provision.BuildOptions{ArchiveURL: archiveURL}, w)
+}
+
+func (p *dockerProvisioner) buildAndRelease(a provision.App, opts provision.BuildOptions, w io.Writer) error {
+	if _, err := p.Build(a, opts, w); err != nil {
 		return err
 	}
+	_, err := p.Release(a, w)
+	return err
+}
+
+// Build builds the image of the app, running the deploy command in a
+// container created from the image of its platform, followed by the build
+// hooks declared in its app.yaml.
+func (p *dockerProvisioner) Build(a provision.App, opts provision.BuildOptions, w io.Writer) (*provision.AppConfig, error) {
+	var (
+		commands []string
+		err      error
+	)
+	if opts.ArchiveURL != "" {
+		commands, err = archiveDeployCmds(a, opts.ArchiveURL)
+	} else {
+		commands, err = deployCmds(a, opts.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	imageId, err := deploy(a, commands, w)
+	if err != nil {
+		return nil, err
+	}
+	appConfig, err := readAppConfig(a, imageId)
+	if err != nil {
+		fmt.Fprintf(w, "\n ---> %s\n", err)
+		p.RemoveBuild(a)
+		return nil, err
+	}
+	if hooks := appConfig.Hooks.Build; len(hooks) > 0 {
+		if _, err = runBuildHooks(a, imageId, hooks, w); err != nil {
+			p.RemoveBuild(a)
+			return nil, err
+		}
+	}
+	return appConfig, nil
+}
+
+// Release tags the last build of the app as the image of the app, and
+// replaces the units of the app with units running it. It returns the ID of
+// the image.
+func (p *dockerProvisioner) Release(a provision.App, w io.Writer) (string, error) {
+	imageId, err := importImage(a, buildImageName(a.GetName()), assembleImageName(a.GetName()))
+	if err != nil {
+		return "", err
+	}
+	p.RemoveBuild(a)
+	startUnits(a, imageId, w)
+	return imageId, nil
+}
+
+// RemoveBuild removes the last build of the app, that was not released.
+func (p *dockerProvisioner) RemoveBuild(a provision.App) error {
+	return removeImage(buildImageName(a.GetName()))
+}
+
+// CancelDeploy stops the build of the app, if there is one running. The
+// container of the build is removed and the deploy fails.
+func (p *dockerProvisioner) CancelDeploy(a provision.App) error {
+	return cancelBuild(a.GetName())
+}
+
+// startUnits replaces the units of the app with new ones, created from the
+// given image. An app without units gets a fresh one.
+func startUnits(a provision.App, imageId string, w io.Writer) {
 	containers, err := listAppContainers(a.GetName())
 	started := make(chan bool, len(containers))
 	if err == nil && len(containers) > 0 {
@@ -145,7 +224,6 @@ func (p *dockerProvisioner) Deploy(a provision.App, version string, w io.Writer)
 	} else {
 		fmt.Fprint(w, "\n ---> App failed to start, please check its logs for more details...\n\n")
 	}
-	return nil
 }
 
 func (p *dockerProvisioner) Destroy(app provision.App) error {
@@ -249,9 +327,17 @@ func removeContainer(c *container) error {
 	if err != nil {
 		log.Errorf("error on remove container %s - %s", c.ID, err)
 	}
+	notifyDependents(c.AppName)
 	return err
 }
 
+// notifyDependents enqueues a message for refreshing the internal addresses
+// of the app in the environment of the apps that depend on it.
+func notifyDependents(appName string) {
+	msg := queue.Message{Action: app.UpdateDependents, Args: []string{appName}}
+	go app.Enqueue(msg)
+}
+
 func (*dockerProvisioner) InstallDeps(app provision.App, w io.Writer) error {
 	return nil
 }
@@ -265,7 +351,7 @@ func (*dockerProvisioner) ExecuteCommandOnce(stdout, stderr io.Writer, app provi
 		return errors.New("No containers for this app")
 	}
 	container := containers[0]
-	return container.ssh(stdout, stderr, cmd, args...)
+	return container.exec(stdout, stderr, "/bin/bash", "-c", joinCmd(cmd, args...))
 }
 
 func (*dockerProvisioner) ExecuteCommand(stdout, stderr io.Writer, app provision.App, cmd string, args ...string) error {
@@ -277,7 +363,7 @@ func (*dockerProvisioner) ExecuteCommand(stdout, stderr io.Writer, app provision
 		return errors.New("No containers for this app")
 	}
 	for _, c := range containers {
-		err = c.ssh(stdout, stderr, cmd, args...)
+		err = c.exec(stdout, stderr, "/bin/bash", "-c", joinCmd(cmd, args...))
 		if err != nil {
 			return err
 		}
@@ -301,12 +387,75 @@ func (p *dockerProvisioner) UnsetCName(app provision.App, cname string) error {
 	return r.UnsetCName(cname, app.GetName())
 }
 
+func getTLSRouter() (router.TLSRouter, error) {
+	r, err := getRouter()
+	if err != nil {
+		return nil, err
+	}
+	tlsRouter, ok := r.(router.TLSRouter)
+	if !ok {
+		return nil, provision.ErrTLSNotSupported
+	}
+	return tlsRouter, nil
+}
+
+func (p *dockerProvisioner) SetCertificate(app provision.App, cname, certificate, key string) error {
+	r, err := getTLSRouter()
+	if err != nil {
+		return err
+	}
+	return r.AddCertificate(cname, certificate, key)
+}
+
+func (p *dockerProvisioner) UnsetCertificate(app provision.App, cname string) error {
+	r, err := getTLSRouter()
+	if err != nil {
+		return err
+	}
+	return r.RemoveCertificate(cname)
+}
+
+func (p *dockerProvisioner) SplitTraffic(app, target provision.App, weight int) error {
+	r, err := getRouter()
+	if err != nil {
+		return err
+	}
+	splitter, ok := r.(router.TrafficSplitter)
+	if !ok {
+		return provision.ErrTrafficSplitNotSupported
+	}
+	var targetName string
+	if target != nil {
+		targetName = target.GetName()
+	}
+	err = splitter.SplitTraffic(app.GetName(), targetName, weight)
+	switch err {
+	case router.ErrInvalidWeight, router.ErrSplitToItself, router.ErrChainedSplit, router.ErrSplitNotFound:
+		return &tsuruErrors.ValidationError{Message: err.Error()}
+	}
+	return err
+}
+
+func (p *dockerProvisioner) InternalAddresses(app provision.App) ([]string, error) {
+	containers, err := listAppContainers(app.GetName())
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, c := range containers {
+		if c.IP != "" && c.Port != "" {
+			addresses = append(addresses, fmt.Sprintf("http://%s:%s", c.IP, c.Port))
+		}
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
 func (p *dockerProvisioner) Commands() []cmd.Command {
 	return []cmd.Command{
 		addNodeToSchedulerCmd{},
 		removeNodeFromSchedulerCmd{},
 		listNodesInTheSchedulerCmd{},
-		&sshAgentCmd{},
 	}
 }
 
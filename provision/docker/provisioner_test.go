@@ -6,24 +6,23 @@ package docker
 
 import (
 	"bytes"
-	"fmt"
 	dockerClient "github.com/fsouza/go-dockerclient"
 	"github.com/globocom/config"
 	"github.com/globocom/tsuru/app"
 	"github.com/globocom/tsuru/cmd"
 	"github.com/globocom/tsuru/db"
+	tsuruErrors "github.com/globocom/tsuru/errors"
 	"github.com/globocom/tsuru/exec"
 	etesting "github.com/globocom/tsuru/exec/testing"
 	"github.com/globocom/tsuru/provision"
 	"github.com/globocom/tsuru/queue"
+	"github.com/globocom/tsuru/router"
 	rtesting "github.com/globocom/tsuru/router/testing"
 	"github.com/globocom/tsuru/testing"
 	"labix.org/v2/mgo/bson"
 	"launchpad.net/gocheck"
-	"net"
-	"net/http/httptest"
 	"runtime"
-	"strconv"
+	"sort"
 	"time"
 )
 
@@ -49,33 +48,35 @@ func (s *S) TestProvisionerProvision(c *gocheck.C) {
 }
 
 func (s *S) TestProvisionerRestartCallsTheRestartHook(c *gocheck.C) {
-	var handler FakeSSHServer
-	handler.output = "caad7bbd5411"
-	server := httptest.NewServer(&handler)
-	defer server.Close()
-	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
-	portNumber, _ := strconv.Atoi(port)
-	config.Set("docker:ssh-agent-port", portNumber)
-	defer config.Unset("docker:ssh-agent-port")
+	handler, cleanup := startExecServer("restarted", "", 0)
+	defer cleanup()
 	var p dockerProvisioner
 	app := testing.NewFakeApp("almah", "static", 1)
-	newImage("tsuru/python", s.server.URL())
-	cont, err := s.newContainer(&newContainerOpts{AppName: app.GetName()})
-	c.Assert(err, gocheck.IsNil)
-	defer s.removeTestContainer(cont)
-	cont.HostAddr = host
 	coll := collection()
 	defer coll.Close()
-	err = coll.UpdateId(cont.ID, cont)
+	err := coll.Insert(container{ID: "c-1", AppName: app.GetName(), HostAddr: "127.0.0.1"})
 	c.Assert(err, gocheck.IsNil)
+	defer coll.RemoveId("c-1")
 	err = p.Restart(app)
 	c.Assert(err, gocheck.IsNil)
-	input := cmdInput{Cmd: "/var/lib/tsuru/restart"}
-	body := handler.bodies[0]
-	c.Assert(body, gocheck.DeepEquals, input)
-	ip, _, _ := cont.networkInfo()
-	path := fmt.Sprintf("/container/%s/cmd", ip)
-	c.Assert(handler.requests[0].URL.Path, gocheck.DeepEquals, path)
+	expected := []execCall{{Container: "c-1", Cmd: []string{"/var/lib/tsuru/restart"}}}
+	c.Assert(handler.calls, gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestProvisionerRestartFailure(c *gocheck.C) {
+	_, cleanup := startExecServer("", "failed to restart", 1)
+	defer cleanup()
+	var p dockerProvisioner
+	app := testing.NewFakeApp("almah", "static", 1)
+	coll := collection()
+	defer coll.Close()
+	err := coll.Insert(container{ID: "c-1", AppName: app.GetName(), HostAddr: "127.0.0.1"})
+	c.Assert(err, gocheck.IsNil)
+	defer coll.RemoveId("c-1")
+	err = p.Restart(app)
+	c.Assert(err, gocheck.NotNil)
+	_, ok := err.(*execError)
+	c.Assert(ok, gocheck.Equals, true)
 }
 
 func (s *S) stopContainers(n uint) {
@@ -102,7 +103,7 @@ func (s *S) stopContainers(n uint) {
 }
 
 func (s *S) TestDeploy(c *gocheck.C) {
-	go s.stopContainers(1)
+	go s.stopContainers(2)
 	err := newImage("tsuru/python", s.server.URL())
 	c.Assert(err, gocheck.IsNil)
 	fexec := &etesting.FakeExecutor{}
@@ -123,7 +124,7 @@ func (s *S) TestDeploy(c *gocheck.C) {
 	p.Provision(&a)
 	defer p.Destroy(&a)
 	w := writer{b: make([]byte, 2048)}
-	err = app.DeployApp(&a, "master", &w)
+	err = app.DeployApp(&a, "master", "", &w)
 	c.Assert(err, gocheck.IsNil)
 	w.b = nil
 	defer p.Destroy(&a)
@@ -140,6 +141,29 @@ func (s *S) TestDeploy(c *gocheck.C) {
 	}
 }
 
+func (s *S) TestDeployArchive(c *gocheck.C) {
+	go s.stopContainers(2)
+	err := newImage("tsuru/python", s.server.URL())
+	c.Assert(err, gocheck.IsNil)
+	p := dockerProvisioner{}
+	a := app.App{Name: "otherapp", Platform: "python"}
+	conn, err := db.Conn()
+	c.Assert(err, gocheck.IsNil)
+	defer conn.Close()
+	err = conn.Apps().Insert(a)
+	c.Assert(err, gocheck.IsNil)
+	defer conn.Apps().Remove(bson.M{"name": a.Name})
+	p.Provision(&a)
+	defer p.Destroy(&a)
+	var buf bytes.Buffer
+	err = p.DeployArchive(&a, "https://s3.amazonaws.com/wat/archive.tar.gz", &buf)
+	c.Assert(err, gocheck.IsNil)
+}
+
+func (s *S) TestProvisionerIsArchiveDeployer(c *gocheck.C) {
+	var _ provision.ArchiveDeployer = &dockerProvisioner{}
+}
+
 func getQueue() (queue.Q, error) {
 	queueName := "tsuru-app"
 	qfactory, err := queue.Factory()
@@ -170,7 +194,7 @@ func (s *S) TestDeployEnqueuesBindService(c *gocheck.C) {
 	p.Provision(&a)
 	defer p.Destroy(&a)
 	w := writer{b: make([]byte, 2048)}
-	err = app.DeployApp(&a, "master", &w)
+	err = app.DeployApp(&a, "master", "", &w)
 	c.Assert(err, gocheck.IsNil)
 	defer p.Destroy(&a)
 	q, err := getQueue()
@@ -223,7 +247,7 @@ func (s *S) TestDeployRemoveContainersEvenWhenTheyreNotInTheAppsCollection(c *go
 	setExecut(fexec)
 	defer setExecut(nil)
 	var w bytes.Buffer
-	err = app.DeployApp(&a, "master", &w)
+	err = app.DeployApp(&a, "master", "", &w)
 	c.Assert(err, gocheck.IsNil)
 	time.Sleep(1e9)
 	defer p.Destroy(&a)
@@ -432,71 +456,48 @@ func (s *S) TestRemoveUnitInSameHostAsAnotherUnitShouldEnqueueAnotherBind(c *goc
 }
 
 func (s *S) TestProvisionerExecuteCommand(c *gocheck.C) {
-	var handler FakeSSHServer
-	handler.output = ". .."
-	server := httptest.NewServer(&handler)
-	defer server.Close()
-	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
-	portNumber, _ := strconv.Atoi(port)
-	config.Set("docker:ssh-agent-port", portNumber)
-	defer config.Unset("docker:ssh-agent-port")
+	handler, cleanup := startExecServer(". ..", "", 0)
+	defer cleanup()
 	app := testing.NewFakeApp("starbreaker", "python", 1)
-	container, err := s.newContainer(&newContainerOpts{AppName: app.GetName()})
-	c.Assert(err, gocheck.IsNil)
-	defer s.removeTestContainer(container)
-	container.HostAddr = host
 	coll := collection()
 	defer coll.Close()
-	coll.UpdateId(container.ID, container)
+	err := coll.Insert(container{ID: "c-1", AppName: app.GetName(), HostAddr: "127.0.0.1"})
+	c.Assert(err, gocheck.IsNil)
+	defer coll.RemoveId("c-1")
 	var stdout, stderr bytes.Buffer
 	var p dockerProvisioner
 	err = p.ExecuteCommand(&stdout, &stderr, app, "ls", "-ar")
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(stderr.Bytes(), gocheck.IsNil)
 	c.Assert(stdout.String(), gocheck.Equals, ". ..")
-	body := handler.bodies[0]
-	input := cmdInput{Cmd: "ls", Args: []string{"-ar"}}
-	c.Assert(body, gocheck.DeepEquals, input)
-	ip, _, _ := container.networkInfo()
-	path := fmt.Sprintf("/container/%s/cmd", ip)
-	c.Assert(handler.requests[0].URL.Path, gocheck.DeepEquals, path)
+	expected := []execCall{{Container: "c-1", Cmd: []string{"/bin/bash", "-c", "ls -ar"}}}
+	c.Assert(handler.calls, gocheck.DeepEquals, expected)
 }
 
 func (s *S) TestProvisionerExecuteCommandMultipleContainers(c *gocheck.C) {
-	var handler FakeSSHServer
-	handler.output = ". .."
-	server := httptest.NewServer(&handler)
-	defer server.Close()
-	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
-	portNumber, _ := strconv.Atoi(port)
-	config.Set("docker:ssh-agent-port", portNumber)
-	defer config.Unset("docker:ssh-agent-port")
+	handler, cleanup := startExecServer(". ..", "", 0)
+	defer cleanup()
 	app := testing.NewFakeApp("starbreaker", "python", 1)
-	container1, err := s.newContainer(&newContainerOpts{AppName: app.GetName()})
-	c.Assert(err, gocheck.IsNil)
-	defer s.removeTestContainer(container1)
-	container1.HostAddr = host
 	coll := collection()
 	defer coll.Close()
-	coll.UpdateId(container1.ID, container1)
-	container2, err := s.newContainer(&newContainerOpts{AppName: app.GetName()})
+	err := coll.Insert(
+		container{ID: "c-1", AppName: app.GetName(), HostAddr: "127.0.0.1"},
+		container{ID: "c-2", AppName: app.GetName(), HostAddr: "127.0.0.1"},
+	)
 	c.Assert(err, gocheck.IsNil)
-	defer s.removeTestContainer(container2)
-	container2.HostAddr = host
-	coll.UpdateId(container2.ID, container2)
+	defer coll.RemoveAll(bson.M{"appname": app.GetName()})
 	var stdout, stderr bytes.Buffer
 	var p dockerProvisioner
 	err = p.ExecuteCommand(&stdout, &stderr, app, "ls", "-ar")
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(stderr.Bytes(), gocheck.IsNil)
-	input := cmdInput{Cmd: "ls", Args: []string{"-ar"}}
-	c.Assert(handler.bodies, gocheck.DeepEquals, []cmdInput{input, input})
-	ip1, _, _ := container1.networkInfo()
-	ip2, _, _ := container2.networkInfo()
-	path1 := fmt.Sprintf("/container/%s/cmd", ip1)
-	path2 := fmt.Sprintf("/container/%s/cmd", ip2)
-	c.Assert(handler.requests[0].URL.Path, gocheck.Equals, path1)
-	c.Assert(handler.requests[1].URL.Path, gocheck.Equals, path2)
+	c.Assert(stdout.String(), gocheck.Equals, ". ... ..")
+	c.Assert(handler.calls, gocheck.HasLen, 2)
+	containers := []string{handler.calls[0].Container, handler.calls[1].Container}
+	sort.Strings(containers)
+	c.Assert(containers, gocheck.DeepEquals, []string{"c-1", "c-2"})
+	c.Assert(handler.calls[0].Cmd, gocheck.DeepEquals, []string{"/bin/bash", "-c", "ls -ar"})
+	c.Assert(handler.calls[1].Cmd, gocheck.DeepEquals, []string{"/bin/bash", "-c", "ls -ar"})
 }
 
 func (s *S) TestProvisionerExecuteCommandNoContainers(c *gocheck.C) {
@@ -545,13 +546,115 @@ func (s *S) TestProvisionerIsCNameManager(c *gocheck.C) {
 	var _ provision.CNameManager = &dockerProvisioner{}
 }
 
+func (s *S) TestProvisionSetCertificate(c *gocheck.C) {
+	var p dockerProvisioner
+	app := testing.NewFakeApp("myapp", "python", 1)
+	err := p.SetCertificate(app, "mycname.com", "the-cert", "the-key")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(rtesting.FakeRouter.HasCertificate("mycname.com", "the-cert"), gocheck.Equals, true)
+}
+
+func (s *S) TestProvisionUnsetCertificate(c *gocheck.C) {
+	var p dockerProvisioner
+	app := testing.NewFakeApp("myapp", "python", 1)
+	err := p.SetCertificate(app, "mycname.com", "the-cert", "the-key")
+	c.Assert(err, gocheck.IsNil)
+	err = p.UnsetCertificate(app, "mycname.com")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(rtesting.FakeRouter.HasCertificate("mycname.com", "the-cert"), gocheck.Equals, false)
+}
+
+// noTLSRouter is a router that does not implement router.TLSRouter.
+type noTLSRouter struct {
+	router.Router
+}
+
+func (s *S) TestProvisionSetCertificateRouterWithoutTLS(c *gocheck.C) {
+	router.Register("notls", noTLSRouter{})
+	config.Set("docker:router", "notls")
+	defer config.Set("docker:router", "fake")
+	var p dockerProvisioner
+	app := testing.NewFakeApp("myapp", "python", 1)
+	err := p.SetCertificate(app, "mycname.com", "the-cert", "the-key")
+	c.Assert(err, gocheck.Equals, provision.ErrTLSNotSupported)
+}
+
+func (s *S) TestProvisionerIsTLSManager(c *gocheck.C) {
+	var _ provision.TLSManager = &dockerProvisioner{}
+}
+
+func (s *S) TestProvisionSplitTraffic(c *gocheck.C) {
+	var p dockerProvisioner
+	app := testing.NewFakeApp("myapp", "python", 1)
+	target := testing.NewFakeApp("myapp-canary", "python", 1)
+	rtesting.FakeRouter.AddBackend(app.GetName())
+	defer rtesting.FakeRouter.RemoveBackend(app.GetName())
+	rtesting.FakeRouter.AddBackend(target.GetName())
+	defer rtesting.FakeRouter.RemoveBackend(target.GetName())
+	err := p.SplitTraffic(app, target, 10)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(rtesting.FakeRouter.HasSplit("myapp", "myapp-canary", 10), gocheck.Equals, true)
+	err = p.SplitTraffic(app, nil, 0)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(rtesting.FakeRouter.HasSplit("myapp", "myapp-canary", 10), gocheck.Equals, false)
+}
+
+func (s *S) TestProvisionSplitTrafficInvalidWeight(c *gocheck.C) {
+	var p dockerProvisioner
+	app := testing.NewFakeApp("myapp", "python", 1)
+	target := testing.NewFakeApp("myapp-canary", "python", 1)
+	rtesting.FakeRouter.AddBackend(app.GetName())
+	defer rtesting.FakeRouter.RemoveBackend(app.GetName())
+	rtesting.FakeRouter.AddBackend(target.GetName())
+	defer rtesting.FakeRouter.RemoveBackend(target.GetName())
+	err := p.SplitTraffic(app, target, 100)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*tsuruErrors.ValidationError)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Message, gocheck.Equals, router.ErrInvalidWeight.Error())
+}
+
+func (s *S) TestProvisionSplitTrafficRouterWithoutSplit(c *gocheck.C) {
+	router.Register("notls", noTLSRouter{})
+	config.Set("docker:router", "notls")
+	defer config.Set("docker:router", "fake")
+	var p dockerProvisioner
+	app := testing.NewFakeApp("myapp", "python", 1)
+	err := p.SplitTraffic(app, nil, 0)
+	c.Assert(err, gocheck.Equals, provision.ErrTrafficSplitNotSupported)
+}
+
+func (s *S) TestProvisionerIsTrafficSplitter(c *gocheck.C) {
+	var _ provision.TrafficSplitter = &dockerProvisioner{}
+}
+
+func (s *S) TestProvisionerInternalAddresses(c *gocheck.C) {
+	coll := collection()
+	defer coll.Close()
+	coll.Insert(
+		container{ID: "c-1", AppName: "myapp", IP: "10.10.10.12", Port: "8888"},
+		container{ID: "c-2", AppName: "myapp", IP: "10.10.10.11", Port: "8888"},
+		container{ID: "c-3", AppName: "myapp"},
+		container{ID: "c-4", AppName: "otherapp", IP: "10.10.10.13", Port: "8888"},
+	)
+	defer coll.RemoveAll(bson.M{"appname": bson.M{"$in": []string{"myapp", "otherapp"}}})
+	var p dockerProvisioner
+	addresses, err := p.InternalAddresses(testing.NewFakeApp("myapp", "python", 0))
+	c.Assert(err, gocheck.IsNil)
+	expected := []string{"http://10.10.10.11:8888", "http://10.10.10.12:8888"}
+	c.Assert(addresses, gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestProvisionerIsInternalAddresser(c *gocheck.C) {
+	var _ provision.InternalAddresser = &dockerProvisioner{}
+}
+
 func (s *S) TestCommands(c *gocheck.C) {
 	var p dockerProvisioner
 	expected := []cmd.Command{
 		addNodeToSchedulerCmd{},
 		removeNodeFromSchedulerCmd{},
 		listNodesInTheSchedulerCmd{},
-		&sshAgentCmd{},
 	}
 	c.Assert(p.Commands(), gocheck.DeepEquals, expected)
 }
@@ -577,31 +680,25 @@ func (s *S) TestSwap(c *gocheck.C) {
 }
 
 func (s *S) TestExecuteCommandOnce(c *gocheck.C) {
-	var handler FakeSSHServer
-	handler.output = ". .."
-	server := httptest.NewServer(&handler)
-	defer server.Close()
-	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
-	portNumber, _ := strconv.Atoi(port)
-	config.Set("docker:ssh-agent-port", portNumber)
-	defer config.Unset("docker:ssh-agent-port")
+	handler, cleanup := startExecServer(". ..", "", 0)
+	defer cleanup()
 	app := testing.NewFakeApp("almah", "static", 1)
 	p := dockerProvisioner{}
-	container, err := s.newContainer(&newContainerOpts{AppName: app.GetName()})
-	c.Assert(err, gocheck.IsNil)
-	defer s.removeTestContainer(container)
-	container.HostAddr = host
 	coll := collection()
 	defer coll.Close()
-	coll.UpdateId(container.ID, container)
+	err := coll.Insert(
+		container{ID: "c-1", AppName: app.GetName(), HostAddr: "127.0.0.1"},
+		container{ID: "c-2", AppName: app.GetName(), HostAddr: "127.0.0.1"},
+	)
+	c.Assert(err, gocheck.IsNil)
+	defer coll.RemoveAll(bson.M{"appname": app.GetName()})
 	var stdout, stderr bytes.Buffer
 	err = p.ExecuteCommandOnce(&stdout, &stderr, app, "ls", "-lh")
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(stderr.Bytes(), gocheck.IsNil)
 	c.Assert(stdout.String(), gocheck.Equals, ". ..")
-	body := handler.bodies[0]
-	input := cmdInput{Cmd: "ls", Args: []string{"-lh"}}
-	c.Assert(body, gocheck.DeepEquals, input)
+	c.Assert(handler.calls, gocheck.HasLen, 1)
+	c.Assert(handler.calls[0].Cmd, gocheck.DeepEquals, []string{"/bin/bash", "-c", "ls -lh"})
 }
 
 func (s *S) TestExecuteCommandOnceWithoutContainers(c *gocheck.C) {
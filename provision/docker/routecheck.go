@@ -0,0 +1,237 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/router"
+	"labix.org/v2/mgo/bson"
+	"sort"
+)
+
+// CheckRoutes compares the routes that each app should have, according to
+// the containers stored in the database, with the routes registered in the
+// router, reporting missing, stale and orphaned entries. When fix is true,
+// it also repairs the router.
+func (p *dockerProvisioner) CheckRoutes(fix bool) ([]provision.RouteProblem, error) {
+	r, err := getRouter()
+	if err != nil {
+		return nil, err
+	}
+	expected, err := expectedRoutes()
+	if err != nil {
+		return nil, err
+	}
+	cnames, err := appsCNames()
+	if err != nil {
+		return nil, err
+	}
+	backends, err := router.List()
+	if err != nil {
+		return nil, err
+	}
+	var names, orphaned []string
+	for name := range expected {
+		names = append(names, name)
+	}
+	for name := range backends {
+		if _, ok := expected[name]; ok {
+			continue
+		}
+		if _, ok := cnames[name]; ok {
+			names = append(names, name)
+		} else {
+			orphaned = append(orphaned, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(orphaned)
+	var problems []provision.RouteProblem
+	for _, name := range names {
+		_, hasBackend := backends[name]
+		checker := routeChecker{router: r, app: name, fix: fix}
+		err := checker.check(expected[name], cnames[name], hasBackend)
+		problems = append(problems, checker.problems...)
+		if err != nil {
+			return problems, err
+		}
+	}
+	for _, name := range orphaned {
+		checker := routeChecker{router: r, app: name, fix: fix}
+		err := checker.report("orphaned", "backend", func() error {
+			return r.RemoveBackend(name)
+		})
+		problems = append(problems, checker.problems...)
+		if err != nil {
+			return problems, err
+		}
+	}
+	return problems, nil
+}
+
+// expectedRoutes returns the routes of each app that has running containers,
+// based on the host address and port of the containers. Containers that are
+// being built, or that were canceled, stopped or failed, are not routed.
+func expectedRoutes() (map[string][]string, error) {
+	var containers []container
+	coll := collection()
+	defer coll.Close()
+	query := bson.M{"status": bson.M{"$in": []string{"running", provision.StatusStarted.String()}}}
+	if err := coll.Find(query).All(&containers); err != nil {
+		return nil, err
+	}
+	routes := make(map[string][]string)
+	for _, c := range containers {
+		appRoutes := routes[c.AppName]
+		if c.HostAddr != "" && c.HostPort != "" {
+			appRoutes = append(appRoutes, c.getAddress())
+		}
+		routes[c.AppName] = appRoutes
+	}
+	return routes, nil
+}
+
+// appsCNames returns the cnames of all apps, keyed by the name of the app.
+func appsCNames() (map[string][]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var apps []struct {
+		Name  string
+		CName []string
+	}
+	err = conn.Apps().Find(nil).Select(bson.M{"name": 1, "cname": 1}).All(&apps)
+	if err != nil {
+		return nil, err
+	}
+	cnames := make(map[string][]string, len(apps))
+	for _, a := range apps {
+		cnames[a.Name] = a.CName
+	}
+	return cnames, nil
+}
+
+type routeChecker struct {
+	router   router.Router
+	app      string
+	fix      bool
+	problems []provision.RouteProblem
+}
+
+// report records a problem, calling fix to repair it when the checker is
+// supposed to fix problems.
+func (c *routeChecker) report(kind, entry string, fix func() error) error {
+	problem := provision.RouteProblem{App: c.app, Kind: kind, Entry: entry}
+	var err error
+	if c.fix {
+		err = fix()
+		problem.Fixed = err == nil
+	}
+	c.problems = append(c.problems, problem)
+	return err
+}
+
+func (c *routeChecker) check(expected, cnames []string, hasBackend bool) error {
+	var routes []string
+	var err error
+	if hasBackend {
+		if routes, err = c.router.Routes(c.app); err != nil {
+			return err
+		}
+	} else {
+		err = c.report("missing", "backend", func() error {
+			return c.router.AddBackend(c.app)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, route := range difference(expected, routes) {
+		route := route
+		err = c.report("missing", "route "+route, func() error {
+			return c.router.AddRoute(c.app, route)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, route := range difference(routes, expected) {
+		route := route
+		err = c.report("stale", "route "+route, func() error {
+			return c.router.RemoveRoute(c.app, route)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	lister, ok := c.router.(router.CNameLister)
+	if !ok {
+		return nil
+	}
+	var current map[string][]string
+	if hasBackend || c.fix {
+		if current, err = lister.CNames(c.app); err != nil {
+			return err
+		}
+	}
+	want := routes
+	if c.fix {
+		want = expected
+	}
+	for _, cname := range sorted(cnames) {
+		cname := cname
+		setCName := func() error {
+			return c.router.SetCName(cname, c.app)
+		}
+		if cnameRoutes, ok := current[cname]; !ok {
+			err = c.report("missing", "cname "+cname, setCName)
+		} else if len(difference(cnameRoutes, want)) > 0 || len(difference(want, cnameRoutes)) > 0 {
+			err = c.report("stale", "routes of cname "+cname, setCName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	var currentNames []string
+	for cname := range current {
+		currentNames = append(currentNames, cname)
+	}
+	for _, cname := range difference(sorted(currentNames), cnames) {
+		cname := cname
+		err = c.report("stale", "cname "+cname, func() error {
+			return c.router.UnsetCName(cname, c.app)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// difference returns the items of a that are not in b, preserving the order
+// of a.
+func difference(a, b []string) []string {
+	set := make(map[string]bool, len(b))
+	for _, item := range b {
+		set[item] = true
+	}
+	var result []string
+	for _, item := range a {
+		if !set[item] {
+			result = append(result, item)
+			set[item] = true
+		}
+	}
+	return result
+}
+
+func sorted(items []string) []string {
+	result := append([]string(nil), items...)
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,207 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/provision"
+	rtesting "github.com/globocom/tsuru/router/testing"
+	"launchpad.net/gocheck"
+)
+
+// setUpRouteCheck cleans up the apps, containers and router collections, and
+// creates an app with the given cnames and containers.
+func (s *S) setUpRouteCheck(c *gocheck.C, appName string, cnames []string, containers ...container) {
+	conn, err := db.Conn()
+	c.Assert(err, gocheck.IsNil)
+	defer conn.Close()
+	conn.Apps().RemoveAll(nil)
+	conn.Collection("routers").RemoveAll(nil)
+	rtesting.FakeRouter.Reset()
+	coll := collection()
+	defer coll.Close()
+	coll.RemoveAll(nil)
+	err = conn.Apps().Insert(map[string]interface{}{"name": appName, "cname": cnames})
+	c.Assert(err, gocheck.IsNil)
+	for _, cont := range containers {
+		err = coll.Insert(cont)
+		c.Assert(err, gocheck.IsNil)
+	}
+}
+
+func (s *S) tearDownRouteCheck() {
+	conn, _ := db.Conn()
+	defer conn.Close()
+	conn.Apps().RemoveAll(nil)
+	conn.Collection("routers").RemoveAll(nil)
+	coll := collection()
+	defer coll.Close()
+	coll.RemoveAll(nil)
+	rtesting.FakeRouter.Reset()
+}
+
+func (s *S) TestProvisionerIsRouteChecker(c *gocheck.C) {
+	var _ provision.RouteChecker = &dockerProvisioner{}
+}
+
+func (s *S) TestCheckRoutesConsistent(c *gocheck.C) {
+	cont := container{ID: "c1", AppName: "myapp", HostAddr: "10.0.0.1", HostPort: "49153", Status: "running"}
+	s.setUpRouteCheck(c, "myapp", []string{"myapp.com"}, cont)
+	defer s.tearDownRouteCheck()
+	rtesting.FakeRouter.AddBackend("myapp")
+	rtesting.FakeRouter.AddRoute("myapp", "http://10.0.0.1:49153")
+	rtesting.FakeRouter.SetCName("myapp.com", "myapp")
+	var p dockerProvisioner
+	problems, err := p.CheckRoutes(false)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(problems, gocheck.HasLen, 0)
+}
+
+func (s *S) TestCheckRoutesMissingAndStaleRoutes(c *gocheck.C) {
+	cont := container{ID: "c1", AppName: "myapp", HostAddr: "10.0.0.1", HostPort: "49153", Status: "running"}
+	s.setUpRouteCheck(c, "myapp", nil, cont)
+	defer s.tearDownRouteCheck()
+	rtesting.FakeRouter.AddBackend("myapp")
+	rtesting.FakeRouter.AddRoute("myapp", "http://10.0.0.2:49153")
+	var p dockerProvisioner
+	problems, err := p.CheckRoutes(false)
+	c.Assert(err, gocheck.IsNil)
+	expected := []provision.RouteProblem{
+		{App: "myapp", Kind: "missing", Entry: "route http://10.0.0.1:49153"},
+		{App: "myapp", Kind: "stale", Entry: "route http://10.0.0.2:49153"},
+	}
+	c.Assert(problems, gocheck.DeepEquals, expected)
+	c.Assert(rtesting.FakeRouter.HasRoute("myapp", "http://10.0.0.1:49153"), gocheck.Equals, false)
+	c.Assert(rtesting.FakeRouter.HasRoute("myapp", "http://10.0.0.2:49153"), gocheck.Equals, true)
+}
+
+func (s *S) TestCheckRoutesFixesMissingAndStaleRoutes(c *gocheck.C) {
+	cont := container{ID: "c1", AppName: "myapp", HostAddr: "10.0.0.1", HostPort: "49153", Status: "running"}
+	s.setUpRouteCheck(c, "myapp", nil, cont)
+	defer s.tearDownRouteCheck()
+	rtesting.FakeRouter.AddBackend("myapp")
+	rtesting.FakeRouter.AddRoute("myapp", "http://10.0.0.2:49153")
+	var p dockerProvisioner
+	problems, err := p.CheckRoutes(true)
+	c.Assert(err, gocheck.IsNil)
+	expected := []provision.RouteProblem{
+		{App: "myapp", Kind: "missing", Entry: "route http://10.0.0.1:49153", Fixed: true},
+		{App: "myapp", Kind: "stale", Entry: "route http://10.0.0.2:49153", Fixed: true},
+	}
+	c.Assert(problems, gocheck.DeepEquals, expected)
+	c.Assert(rtesting.FakeRouter.HasRoute("myapp", "http://10.0.0.1:49153"), gocheck.Equals, true)
+	c.Assert(rtesting.FakeRouter.HasRoute("myapp", "http://10.0.0.2:49153"), gocheck.Equals, false)
+}
+
+func (s *S) TestCheckRoutesIgnoresContainersWithoutHostPort(c *gocheck.C) {
+	cont := container{ID: "c1", AppName: "myapp", HostAddr: "10.0.0.1", Status: "running"}
+	s.setUpRouteCheck(c, "myapp", nil, cont)
+	defer s.tearDownRouteCheck()
+	rtesting.FakeRouter.AddBackend("myapp")
+	var p dockerProvisioner
+	problems, err := p.CheckRoutes(false)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(problems, gocheck.HasLen, 0)
+}
+
+func (s *S) TestCheckRoutesIgnoresContainersThatAreNotRunning(c *gocheck.C) {
+	containers := []container{
+		{ID: "c1", AppName: "myapp", HostAddr: "10.0.0.1", HostPort: "49153", Status: "running"},
+		{ID: "c2", AppName: "myapp", HostAddr: "10.0.0.1", HostPort: "49154", Status: "building"},
+		{ID: "c3", AppName: "myapp", HostAddr: "10.0.0.1", HostPort: "49155", Status: "canceled"},
+		{ID: "c4", AppName: "myapp", HostAddr: "10.0.0.1", HostPort: "49156", Status: "error"},
+		{ID: "c5", AppName: "myapp", HostAddr: "10.0.0.1", HostPort: "49157", Status: "created"},
+		{ID: "c6", AppName: "otherapp", HostAddr: "10.0.0.2", HostPort: "49153", Status: "building"},
+	}
+	s.setUpRouteCheck(c, "myapp", nil, containers...)
+	defer s.tearDownRouteCheck()
+	rtesting.FakeRouter.AddBackend("myapp")
+	rtesting.FakeRouter.AddRoute("myapp", "http://10.0.0.1:49153")
+	var p dockerProvisioner
+	problems, err := p.CheckRoutes(false)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(problems, gocheck.HasLen, 0)
+}
+
+func (s *S) TestCheckRoutesMissingBackend(c *gocheck.C) {
+	cont := container{ID: "c1", AppName: "myapp", HostAddr: "10.0.0.1", HostPort: "49153", Status: "running"}
+	s.setUpRouteCheck(c, "myapp", []string{"myapp.com"}, cont)
+	defer s.tearDownRouteCheck()
+	var p dockerProvisioner
+	problems, err := p.CheckRoutes(false)
+	c.Assert(err, gocheck.IsNil)
+	expected := []provision.RouteProblem{
+		{App: "myapp", Kind: "missing", Entry: "backend"},
+		{App: "myapp", Kind: "missing", Entry: "route http://10.0.0.1:49153"},
+		{App: "myapp", Kind: "missing", Entry: "cname myapp.com"},
+	}
+	c.Assert(problems, gocheck.DeepEquals, expected)
+	c.Assert(rtesting.FakeRouter.HasBackend("myapp"), gocheck.Equals, false)
+}
+
+func (s *S) TestCheckRoutesFixesMissingBackend(c *gocheck.C) {
+	cont := container{ID: "c1", AppName: "myapp", HostAddr: "10.0.0.1", HostPort: "49153", Status: "running"}
+	s.setUpRouteCheck(c, "myapp", []string{"myapp.com"}, cont)
+	defer s.tearDownRouteCheck()
+	var p dockerProvisioner
+	problems, err := p.CheckRoutes(true)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(problems, gocheck.HasLen, 3)
+	for _, problem := range problems {
+		c.Assert(problem.Fixed, gocheck.Equals, true)
+	}
+	c.Assert(rtesting.FakeRouter.HasRoute("myapp", "http://10.0.0.1:49153"), gocheck.Equals, true)
+	c.Assert(rtesting.FakeRouter.HasCName("myapp", "myapp.com"), gocheck.Equals, true)
+	c.Assert(rtesting.FakeRouter.HasRoute("myapp.com", "http://10.0.0.1:49153"), gocheck.Equals, true)
+}
+
+func (s *S) TestCheckRoutesMissingAndStaleCNames(c *gocheck.C) {
+	cont := container{ID: "c1", AppName: "myapp", HostAddr: "10.0.0.1", HostPort: "49153", Status: "running"}
+	s.setUpRouteCheck(c, "myapp", []string{"myapp.com"}, cont)
+	defer s.tearDownRouteCheck()
+	rtesting.FakeRouter.AddBackend("myapp")
+	rtesting.FakeRouter.AddRoute("myapp", "http://10.0.0.1:49153")
+	rtesting.FakeRouter.SetCName("old.myapp.com", "myapp")
+	var p dockerProvisioner
+	problems, err := p.CheckRoutes(true)
+	c.Assert(err, gocheck.IsNil)
+	expected := []provision.RouteProblem{
+		{App: "myapp", Kind: "missing", Entry: "cname myapp.com", Fixed: true},
+		{App: "myapp", Kind: "stale", Entry: "cname old.myapp.com", Fixed: true},
+	}
+	c.Assert(problems, gocheck.DeepEquals, expected)
+	c.Assert(rtesting.FakeRouter.HasCName("myapp", "myapp.com"), gocheck.Equals, true)
+	c.Assert(rtesting.FakeRouter.HasCName("myapp", "old.myapp.com"), gocheck.Equals, false)
+}
+
+func (s *S) TestCheckRoutesOrphanedBackend(c *gocheck.C) {
+	s.setUpRouteCheck(c, "myapp", nil)
+	defer s.tearDownRouteCheck()
+	rtesting.FakeRouter.AddBackend("ghost")
+	rtesting.FakeRouter.AddRoute("ghost", "http://10.0.0.3:49153")
+	var p dockerProvisioner
+	problems, err := p.CheckRoutes(false)
+	c.Assert(err, gocheck.IsNil)
+	expected := []provision.RouteProblem{{App: "ghost", Kind: "orphaned", Entry: "backend"}}
+	c.Assert(problems, gocheck.DeepEquals, expected)
+	c.Assert(rtesting.FakeRouter.HasBackend("ghost"), gocheck.Equals, true)
+	problems, err = p.CheckRoutes(true)
+	c.Assert(err, gocheck.IsNil)
+	expected[0].Fixed = true
+	c.Assert(problems, gocheck.DeepEquals, expected)
+	c.Assert(rtesting.FakeRouter.HasBackend("ghost"), gocheck.Equals, false)
+}
+
+func (s *S) TestCheckRoutesAppWithoutContainers(c *gocheck.C) {
+	s.setUpRouteCheck(c, "myapp", nil)
+	defer s.tearDownRouteCheck()
+	rtesting.FakeRouter.AddBackend("myapp")
+	rtesting.FakeRouter.AddRoute("myapp", "http://10.0.0.3:49153")
+	var p dockerProvisioner
+	problems, err := p.CheckRoutes(false)
+	c.Assert(err, gocheck.IsNil)
+	expected := []provision.RouteProblem{{App: "myapp", Kind: "stale", Entry: "route http://10.0.0.3:49153"}}
+	c.Assert(problems, gocheck.DeepEquals, expected)
+}
@@ -0,0 +1,89 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"fmt"
+	"github.com/dotcloud/docker"
+	dclient "github.com/fsouza/go-dockerclient"
+	"github.com/globocom/tsuru/log"
+	"github.com/globocom/tsuru/provision"
+	"io"
+	"strings"
+)
+
+// joinCmd joins the command and its arguments in a single command line, to be
+// interpreted by the shell.
+func joinCmd(cmd string, args ...string) string {
+	return strings.Join(append([]string{cmd}, args...), " ")
+}
+
+// oneOffConfig returns the configuration of a one-off container, created
+// from the current image of the app with its environment variables, that
+// runs the given command.
+func oneOffConfig(app provision.App, cmd []string) docker.Config {
+	var envs []string
+	for _, env := range app.Envs() {
+		envs = append(envs, fmt.Sprintf("%s=%s", env.Name, env.Value))
+	}
+	user := dockerUser()
+	return docker.Config{
+		Image:        getImage(app),
+		Cmd:          cmd,
+		Env:          envs,
+		User:         user,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+}
+
+// ExecuteCommandIsolated runs the command in a one-off container, streaming
+// its output until it exits. The container is removed afterwards, and the
+// exit status of the command is returned.
+func (p *dockerProvisioner) ExecuteCommandIsolated(stdout, stderr io.Writer, app provision.App, cmd string, args ...string) (int, error) {
+	cfg := oneOffConfig(app, []string{"/bin/bash", "-c", joinCmd(cmd, args...)})
+	return runOneOff(cfg, stdout, stderr)
+}
+
+// ExecuteCommandInBuild runs the command in a one-off container created from
+// the last build of the app, that units may not be running yet.
+func (p *dockerProvisioner) ExecuteCommandInBuild(stdout, stderr io.Writer, app provision.App, cmd string, args ...string) (int, error) {
+	cfg := oneOffConfig(app, []string{"/bin/bash", "-c", joinCmd(cmd, args...)})
+	cfg.Image = buildImageName(app.GetName())
+	return runOneOff(cfg, stdout, stderr)
+}
+
+// runOneOff runs a one-off container with the given configuration, streaming
+// its output until it exits, and removes it. It returns the exit status of
+// the command of the container.
+func runOneOff(cfg docker.Config, stdout, stderr io.Writer) (int, error) {
+	_, cont, err := dockerCluster().CreateContainer(&cfg)
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		if err := dockerCluster().RemoveContainer(cont.ID); err != nil {
+			log.Errorf("Failed to remove the one-off container %s: %s", cont.ID, err)
+		}
+	}()
+	err = dockerCluster().StartContainer(cont.ID, nil)
+	if err != nil {
+		return -1, err
+	}
+	opts := dclient.AttachToContainerOptions{
+		Container:    cont.ID,
+		OutputStream: stdout,
+		ErrorStream:  stderr,
+		Logs:         true,
+		Stream:       true,
+		Stdout:       true,
+		Stderr:       true,
+	}
+	err = dockerCluster().AttachToContainer(opts)
+	if err != nil {
+		return -1, err
+	}
+	return dockerCluster().WaitContainer(cont.ID)
+}
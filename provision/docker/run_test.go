@@ -0,0 +1,112 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bytes"
+	dockerClient "github.com/fsouza/go-dockerclient"
+	dtesting "github.com/fsouza/go-dockerclient/testing"
+	"github.com/globocom/config"
+	"github.com/globocom/docker-cluster/cluster"
+	"github.com/globocom/tsuru/app/bind"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/testing"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+func (s *S) TestJoinCmd(c *gocheck.C) {
+	c.Assert(joinCmd("ls"), gocheck.Equals, "ls")
+	c.Assert(joinCmd("ls", "-l", "-a"), gocheck.Equals, "ls -l -a")
+}
+
+func (s *S) TestOneOffConfig(c *gocheck.C) {
+	config.Set("docker:user", "ubuntu")
+	defer config.Unset("docker:user")
+	app := testing.NewFakeApp("almah", "python", 1)
+	app.SetEnv(bind.EnvVar{Name: "DATABASE_HOST", Value: "localhost"})
+	cfg := oneOffConfig(app, []string{"/bin/bash", "-c", "ls"})
+	c.Assert(cfg.Image, gocheck.Equals, getImage(app))
+	c.Assert(cfg.Cmd, gocheck.DeepEquals, []string{"/bin/bash", "-c", "ls"})
+	c.Assert(cfg.Env, gocheck.DeepEquals, []string{"DATABASE_HOST=localhost"})
+	c.Assert(cfg.User, gocheck.Equals, "ubuntu")
+	c.Assert(cfg.AttachStdout, gocheck.Equals, true)
+	c.Assert(cfg.AttachStderr, gocheck.Equals, true)
+	c.Assert(cfg.Tty, gocheck.Equals, false)
+}
+
+func (s *S) TestExecuteCommandIsolated(c *gocheck.C) {
+	var mut sync.Mutex
+	var requests []string
+	server, err := dtesting.NewServer(func(r *http.Request) {
+		mut.Lock()
+		defer mut.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+	})
+	c.Assert(err, gocheck.IsNil)
+	defer server.Stop()
+	cmutext.Lock()
+	oldDockerCluster := dCluster
+	dCluster, _ = cluster.New(nil, cluster.Node{ID: "server0", Address: server.URL()})
+	cmutext.Unlock()
+	defer func() {
+		cmutext.Lock()
+		defer cmutext.Unlock()
+		dCluster = oldDockerCluster
+	}()
+	app := testing.NewFakeApp("almah", "python", 1)
+	var buf bytes.Buffer
+	err = dCluster.PullImage(dockerClient.PullImageOptions{Repository: getImage(app)}, &buf)
+	c.Assert(err, gocheck.IsNil)
+	var p dockerProvisioner
+	var stdout, stderr bytes.Buffer
+	code, err := p.ExecuteCommandIsolated(&stdout, &stderr, app, "python", "manage.py", "migrate")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(code, gocheck.Equals, 0)
+	mut.Lock()
+	defer mut.Unlock()
+	var actions []string
+	for _, r := range requests {
+		switch {
+		case strings.HasSuffix(r, "/containers/create"):
+			actions = append(actions, "create")
+		case strings.HasSuffix(r, "/start"):
+			actions = append(actions, "start")
+		case strings.HasSuffix(r, "/wait"):
+			actions = append(actions, "wait")
+		case strings.HasPrefix(r, "DELETE /containers/"):
+			actions = append(actions, "remove")
+		}
+	}
+	c.Assert(actions, gocheck.DeepEquals, []string{"create", "start", "wait", "remove"})
+}
+
+func (s *S) TestExecuteCommandIsolatedCreateFailure(c *gocheck.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such image", http.StatusNotFound)
+	}))
+	defer server.Close()
+	cmutext.Lock()
+	oldDockerCluster := dCluster
+	dCluster, _ = cluster.New(nil, cluster.Node{ID: "server0", Address: server.URL})
+	cmutext.Unlock()
+	defer func() {
+		cmutext.Lock()
+		defer cmutext.Unlock()
+		dCluster = oldDockerCluster
+	}()
+	app := testing.NewFakeApp("almah", "python", 1)
+	var p dockerProvisioner
+	var stdout, stderr bytes.Buffer
+	_, err := p.ExecuteCommandIsolated(&stdout, &stderr, app, "ls")
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestProvisionerIsIsolatedRunner(c *gocheck.C) {
+	var _ provision.IsolatedRunner = &dockerProvisioner{}
+}
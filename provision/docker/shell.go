@@ -0,0 +1,120 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"errors"
+	"fmt"
+	dclient "github.com/fsouza/go-dockerclient"
+	"github.com/globocom/tsuru/log"
+	"github.com/globocom/tsuru/provision"
+	"strings"
+)
+
+var errUnitNotFound = errors.New("Unit not found.")
+
+func (p *dockerProvisioner) OpenShell(app provision.App, opts provision.ShellOptions) error {
+	if opts.Isolated {
+		return isolatedShell(app, opts)
+	}
+	containers, err := listAppContainers(app.GetName())
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return errors.New("No containers for this app")
+	}
+	c, err := chooseContainer(containers, opts.Unit)
+	if err != nil {
+		return err
+	}
+	return c.shell(opts)
+}
+
+// chooseContainer returns the container identified by the given unit name,
+// which may be a prefix of the ID of the container. An empty name chooses
+// the first container.
+func chooseContainer(containers []container, unit string) (*container, error) {
+	if unit == "" {
+		return &containers[0], nil
+	}
+	for i, c := range containers {
+		if strings.HasPrefix(c.ID, unit) {
+			return &containers[i], nil
+		}
+	}
+	return nil, errUnitNotFound
+}
+
+// shellCmd returns the command that starts a login shell, with the
+// terminal settings of the client.
+func shellCmd(opts provision.ShellOptions) []string {
+	term := opts.Term
+	if term == "" {
+		term = "xterm"
+	}
+	cmd := []string{"/usr/bin/env", "TERM=" + term}
+	if opts.Width > 0 && opts.Height > 0 {
+		cmd = append(cmd, fmt.Sprintf("COLUMNS=%d", opts.Width), fmt.Sprintf("LINES=%d", opts.Height))
+	}
+	return append(cmd, "bash", "-l")
+}
+
+// shell opens an interactive shell in the container, using a TTY, and
+// connects it to the connection in the given options.
+func (c *container) shell(opts provision.ShellOptions) error {
+	address, err := c.nodeAddress()
+	if err != nil {
+		return err
+	}
+	cfg := execConfig{
+		Cmd:          shellCmd(opts),
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+	}
+	id, err := c.createExec(address, cfg)
+	if err != nil {
+		return err
+	}
+	return startExec(address, id, true, opts.Conn, opts.Conn, opts.Conn)
+}
+
+// isolatedShell opens an interactive shell in a one-off container, created
+// from the image of the app with its environment variables. The container
+// is removed when the shell exits.
+func isolatedShell(app provision.App, opts provision.ShellOptions) error {
+	cfg := oneOffConfig(app, shellCmd(opts))
+	cfg.Tty = true
+	cfg.OpenStdin = true
+	cfg.StdinOnce = true
+	cfg.AttachStdin = true
+	_, cont, err := dockerCluster().CreateContainer(&cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := dockerCluster().RemoveContainer(cont.ID); err != nil {
+			log.Errorf("Failed to remove the shell container %s: %s", cont.ID, err)
+		}
+	}()
+	err = dockerCluster().StartContainer(cont.ID, nil)
+	if err != nil {
+		return err
+	}
+	attachOpts := dclient.AttachToContainerOptions{
+		Container:    cont.ID,
+		InputStream:  opts.Conn,
+		OutputStream: opts.Conn,
+		ErrorStream:  opts.Conn,
+		Stdin:        true,
+		Stdout:       true,
+		Stderr:       true,
+		Stream:       true,
+		RawTerminal:  true,
+	}
+	return dockerCluster().AttachToContainer(attachOpts)
+}
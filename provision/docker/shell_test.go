@@ -0,0 +1,88 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bytes"
+	"github.com/globocom/tsuru/provision"
+	"github.com/globocom/tsuru/testing"
+	"io"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"strings"
+)
+
+func (s *S) TestShellCmd(c *gocheck.C) {
+	opts := provision.ShellOptions{Width: 80, Height: 24, Term: "screen"}
+	expected := []string{"/usr/bin/env", "TERM=screen", "COLUMNS=80", "LINES=24", "bash", "-l"}
+	c.Assert(shellCmd(opts), gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestShellCmdDefaults(c *gocheck.C) {
+	expected := []string{"/usr/bin/env", "TERM=xterm", "bash", "-l"}
+	c.Assert(shellCmd(provision.ShellOptions{}), gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestChooseContainer(c *gocheck.C) {
+	containers := []container{{ID: "abc123"}, {ID: "def456"}}
+	cont, err := chooseContainer(containers, "def")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(cont.ID, gocheck.Equals, "def456")
+	cont, err = chooseContainer(containers, "")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(cont.ID, gocheck.Equals, "abc123")
+	_, err = chooseContainer(containers, "ghi")
+	c.Assert(err, gocheck.Equals, errUnitNotFound)
+}
+
+func (s *S) TestProvisionerOpenShell(c *gocheck.C) {
+	handler, cleanup := startExecServer("", "", 0)
+	defer cleanup()
+	app := testing.NewFakeApp("almah", "static", 1)
+	coll := collection()
+	defer coll.Close()
+	err := coll.Insert(
+		container{ID: "c-1", AppName: app.GetName(), HostAddr: "127.0.0.1"},
+		container{ID: "c-2", AppName: app.GetName(), HostAddr: "127.0.0.1"},
+	)
+	c.Assert(err, gocheck.IsNil)
+	defer coll.RemoveAll(bson.M{"appname": app.GetName()})
+	var output bytes.Buffer
+	conn := struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader("ls\n"), &output}
+	var p dockerProvisioner
+	err = p.OpenShell(app, provision.ShellOptions{Conn: conn, Unit: "c-2"})
+	c.Assert(err, gocheck.IsNil)
+	expected := []execCall{{Container: "c-2", Cmd: []string{"/usr/bin/env", "TERM=xterm", "bash", "-l"}}}
+	c.Assert(handler.calls, gocheck.DeepEquals, expected)
+	c.Assert(output.String(), gocheck.Equals, "ls\n")
+}
+
+func (s *S) TestProvisionerOpenShellUnknownUnit(c *gocheck.C) {
+	app := testing.NewFakeApp("almah", "static", 1)
+	coll := collection()
+	defer coll.Close()
+	err := coll.Insert(container{ID: "c-1", AppName: app.GetName()})
+	c.Assert(err, gocheck.IsNil)
+	defer coll.RemoveId("c-1")
+	var conn bytes.Buffer
+	var p dockerProvisioner
+	err = p.OpenShell(app, provision.ShellOptions{Conn: &conn, Unit: "c-2"})
+	c.Assert(err, gocheck.Equals, errUnitNotFound)
+}
+
+func (s *S) TestProvisionerOpenShellWithoutContainers(c *gocheck.C) {
+	app := testing.NewFakeApp("almah", "static", 1)
+	var conn bytes.Buffer
+	var p dockerProvisioner
+	err := p.OpenShell(app, provision.ShellOptions{Conn: &conn})
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestProvisionerIsShellOpener(c *gocheck.C) {
+	var _ provision.ShellOpener = &dockerProvisioner{}
+}
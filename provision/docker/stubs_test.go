@@ -5,18 +5,23 @@
 package docker
 
 import (
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"github.com/dotcloud/docker"
-	"github.com/globocom/config"
 	"github.com/globocom/docker-cluster/cluster"
 	etesting "github.com/globocom/tsuru/exec/testing"
 	rtesting "github.com/globocom/tsuru/router/testing"
+	"io"
+	"io/ioutil"
 	"labix.org/v2/mgo/bson"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 var inspectOut = `
@@ -157,16 +162,104 @@ func startDockerTestServer(containerPort string, calls *int) (func(), *httptest.
 	}, server
 }
 
-func startSSHAgentServer(output string) (*FakeSSHServer, func()) {
-	var handler FakeSSHServer
-	handler.output = output
+type execCall struct {
+	Container string
+	Cmd       []string
+}
+
+// fakeExecServer is a fake docker API server that handles the exec
+// endpoints, answering all commands with the same output and exit code.
+// Like docker, it answers the start of a command with 101 UPGRADED when the
+// client asks for the upgrade of the connection, unless noUpgrade is set,
+// and then streams the output in the hijacked connection. The input of
+// commands that attach stdin is read until EOF and echoed in the output.
+type fakeExecServer struct {
+	calls     []execCall
+	execs     []execConfig
+	stdout    string
+	stderr    string
+	exitCode  int
+	noUpgrade bool
+	mut       sync.Mutex
+}
+
+var (
+	execCreateRegexp = regexp.MustCompile(`/containers/([^/]+)/exec$`)
+	execStartRegexp  = regexp.MustCompile(`/exec/exec-(\d+)/start$`)
+)
+
+func (s *fakeExecServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "POST" && execCreateRegexp.MatchString(r.URL.Path):
+		var cfg execConfig
+		json.NewDecoder(r.Body).Decode(&cfg)
+		s.mut.Lock()
+		s.calls = append(s.calls, execCall{
+			Container: execCreateRegexp.FindStringSubmatch(r.URL.Path)[1],
+			Cmd:       cfg.Cmd,
+		})
+		s.execs = append(s.execs, cfg)
+		id := len(s.calls)
+		s.mut.Unlock()
+		fmt.Fprintf(w, `{"Id":"exec-%d"}`, id)
+	case r.Method == "POST" && execStartRegexp.MatchString(r.URL.Path):
+		id, _ := strconv.Atoi(execStartRegexp.FindStringSubmatch(r.URL.Path)[1])
+		s.mut.Lock()
+		cfg := s.execs[id-1]
+		s.mut.Unlock()
+		ioutil.ReadAll(r.Body)
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if r.Header.Get("Upgrade") == "tcp" && !s.noUpgrade {
+			rw.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		} else {
+			rw.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/vnd.docker.raw-stream\r\n\r\n")
+		}
+		rw.Flush()
+		var input []byte
+		if cfg.AttachStdin {
+			input, _ = ioutil.ReadAll(rw)
+		}
+		if cfg.Tty {
+			rw.WriteString(s.stdout + string(input))
+		} else {
+			writeFrame(rw, 1, s.stdout+string(input))
+			writeFrame(rw, 2, s.stderr)
+		}
+		rw.Flush()
+	case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/json"):
+		fmt.Fprintf(w, `{"ExitCode":%d}`, s.exitCode)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// writeFrame writes data to w using the multiplexed stream format of the
+// docker API.
+func writeFrame(w io.Writer, stream byte, data string) {
+	if data == "" {
+		return
+	}
+	header := []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	w.Write(header)
+	io.WriteString(w, data)
+}
+
+func startExecServer(stdout, stderr string, exitCode int) (*fakeExecServer, func()) {
+	handler := fakeExecServer{stdout: stdout, stderr: stderr, exitCode: exitCode}
 	server := httptest.NewServer(&handler)
-	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
-	portNumber, _ := strconv.Atoi(port)
-	config.Set("docker:ssh-agent-port", portNumber)
+	oldCluster := dockerCluster()
+	oldClusterNodes := clusterNodes
+	dCluster, _ = cluster.New(nil, cluster.Node{ID: "server", Address: server.URL})
+	clusterNodes = map[string]string{"server": server.URL}
 	return &handler, func() {
 		server.Close()
-		config.Unset("docker:ssh-agent-port")
+		dCluster = oldCluster
+		clusterNodes = oldClusterNodes
 	}
 }
 
@@ -8,11 +8,9 @@ import (
 	dtesting "github.com/fsouza/go-dockerclient/testing"
 	"github.com/globocom/config"
 	"github.com/globocom/docker-cluster/cluster"
-	ftesting "github.com/globocom/tsuru/fs/testing"
 	"github.com/globocom/tsuru/provision"
 	_ "github.com/globocom/tsuru/testing"
 	"launchpad.net/gocheck"
-	"os"
 	"sort"
 	"testing"
 )
@@ -28,7 +26,7 @@ type S struct {
 	runBin        string
 	runArgs       string
 	port          string
-	sshUser       string
+	user          string
 	server        *dtesting.DockerServer
 }
 
@@ -39,7 +37,7 @@ func (s *S) SetUpSuite(c *gocheck.C) {
 	s.imageCollName = "docker_image"
 	s.gitHost = "my.gandalf.com"
 	s.repoNamespace = "tsuru"
-	s.sshUser = "root"
+	s.user = "root"
 	config.Set("git:ro-host", s.gitHost)
 	config.Set("database:url", "127.0.0.1:27017")
 	config.Set("database:name", "docker_provision_tests_s")
@@ -49,18 +47,13 @@ func (s *S) SetUpSuite(c *gocheck.C) {
 	config.Set("docker:deploy-cmd", "/var/lib/tsuru/deploy")
 	config.Set("docker:run-cmd:bin", "/usr/local/bin/circusd /etc/circus/circus.ini")
 	config.Set("docker:run-cmd:port", "8888")
-	config.Set("docker:ssh:add-key-cmd", "/var/lib/tsuru/add-key")
-	config.Set("docker:ssh:user", s.sshUser)
+	config.Set("docker:user", s.user)
 	config.Set("queue", "fake")
 	s.deployCmd = "/var/lib/tsuru/deploy"
 	s.runBin = "/usr/local/bin/circusd"
 	s.runArgs = "/etc/circus/circus.ini"
 	s.port = "8888"
-	fsystem = &ftesting.RecordingFs{}
-	f, err := fsystem.Create(os.ExpandEnv("${HOME}/.ssh/id_rsa.pub"))
-	c.Assert(err, gocheck.IsNil)
-	f.Write([]byte("key-content"))
-	f.Close()
+	var err error
 	s.server, err = dtesting.NewServer(nil)
 	c.Assert(err, gocheck.IsNil)
 	dCluster, _ = cluster.New(nil,
@@ -73,7 +66,6 @@ func (s *S) TearDownSuite(c *gocheck.C) {
 	defer coll.Close()
 	err := coll.Database.DropDatabase()
 	c.Assert(err, gocheck.IsNil)
-	fsystem = nil
 }
 
 func (s *S) SetUp(c *gocheck.C) {
@@ -65,9 +65,9 @@ func handle(msg *queue.Message) {
 				ok = append(ok, u)
 			}
 		}
-		if len(noID) == len(units) {
-			getQueue(queueName).Release(msg, 0)
-		} else {
+		// When all units are pending, the message is not deleted, so the
+		// queue handler puts it back in the queue with a backoff.
+		if len(noID) < len(units) {
 			router, _ := Router()
 			for _, u := range units {
 				router.AddRoute(a.GetName(), u.InstanceId)
@@ -132,10 +132,8 @@ func (s *ELBSuite) TestHandleMessagesAllPendingUnits(c *gocheck.C) {
 	c.Assert(resp.LoadBalancerDescriptions, gocheck.HasLen, 1)
 	instances := resp.LoadBalancerDescriptions[0].Instances
 	c.Assert(instances, gocheck.HasLen, 0)
-	got, err := getQueue(queueName).Get(1e6)
-	c.Assert(err, gocheck.IsNil)
-	c.Assert(*got, gocheck.DeepEquals, msg)
-	got.Delete()
+	_, err = getQueue(queueName).Get(1e6)
+	c.Assert(err, gocheck.NotNil)
 }
 
 func (s *ELBSuite) TestEnqueuePutMessagesInSpecificQueue(c *gocheck.C) {
@@ -7,6 +7,7 @@
 package provision
 
 import (
+	"errors"
 	"fmt"
 	"github.com/globocom/tsuru/action"
 	"github.com/globocom/tsuru/app/bind"
@@ -102,6 +103,211 @@ type CNameManager interface {
 	UnsetCName(app App, cname string) error
 }
 
+// ErrTLSNotSupported is returned by TLSManager implementations when the
+// router in use is not able to serve cnames using HTTPS.
+var ErrTLSNotSupported = errors.New("The router in use does not support TLS certificates.")
+
+// TLSManager is a provisioner that is able to manage TLS certificates for
+// the cnames of apps.
+type TLSManager interface {
+	SetCertificate(app App, cname, certificate, key string) error
+	UnsetCertificate(app App, cname string) error
+}
+
+// ErrTrafficSplitNotSupported is returned by TrafficSplitter
+// implementations when the router in use is not able to split the traffic
+// of an app.
+var ErrTrafficSplitNotSupported = errors.New("The router in use does not support traffic splitting.")
+
+// TrafficSplitter is a provisioner that is able to send a share of the
+// traffic of an app to the units of another app.
+type TrafficSplitter interface {
+	// SplitTraffic sends weight percent of the traffic of app to the
+	// units of target. A weight of 0 removes the split of the app, and
+	// target may be nil in this case.
+	SplitTraffic(app, target App, weight int) error
+}
+
+// InternalAddresser is a provisioner that is able to list the internal
+// addresses of the units of an app, i.e. the addresses that units of other
+// apps may use for reaching them directly, without going through the router.
+type InternalAddresser interface {
+	// InternalAddresses returns the URL of each unit of the app, in the
+	// form http://<ip>:<port>.
+	InternalAddresses(app App) ([]string, error)
+}
+
+// ErrArchiveDeployNotSupported is returned when the provisioner in use is
+// not able to deploy apps from archives.
+var ErrArchiveDeployNotSupported = errors.New("The provisioner in use does not support deploying from archives.")
+
+// ArchiveDeployer is a provisioner that is able to deploy apps from a tar.gz
+// archive available in a URL, instead of from the git repository of the app.
+type ArchiveDeployer interface {
+	DeployArchive(app App, archiveURL string, w io.Writer) error
+}
+
+// BuildOptions describe the code built by a Builder.
+type BuildOptions struct {
+	// Version is the version of the app in its git repository.
+	Version string
+
+	// ArchiveURL is the URL of a tar.gz archive with the code of the app.
+	// When set, the archive is built instead of the git repository.
+	ArchiveURL string
+}
+
+// Builder is a provisioner that deploys apps in two steps: building an
+// image of the app, and then releasing it, replacing the units of the app
+// with new ones running the build. tsuru runs the pre-deploy hooks of the
+// app in the build, between the steps, and the post-deploy hooks after
+// them.
+type Builder interface {
+	// Build builds the app, running the build hooks declared in its
+	// app.yaml, and returns its configuration.
+	Build(app App, opts BuildOptions, w io.Writer) (*AppConfig, error)
+
+	// ExecuteCommandInBuild runs the command in a one-off unit created
+	// from the last build of the app, even if it has not been released
+	// yet, returning the exit status of the command.
+	ExecuteCommandInBuild(stdout, stderr io.Writer, app App, cmd string, args ...string) (int, error)
+
+	// Release replaces the units of the app with units running its last
+	// build, and returns the identifier of the released build, e.g. the ID
+	// of its image. The identifier is recorded with the deploy.
+	Release(app App, w io.Writer) (string, error)
+
+	// RemoveBuild removes the last build of the app, that will not be
+	// released, e.g. because one of its pre-deploy hooks failed.
+	RemoveBuild(app App) error
+}
+
+// ErrPromoteNotSupported is returned when the provisioner in use is not
+// able to promote apps.
+var ErrPromoteNotSupported = errors.New("The provisioner in use does not support promoting apps.")
+
+// Promoter is a Builder that is able to use a build released in an app as
+// the last build of another app, so the build of e.g. a staging app can be
+// released in the production app without being built again. tsuru releases
+// the promoted build with Release, running the deploy hooks of the target app
+// in it.
+type Promoter interface {
+	Builder
+
+	// Promote copies the build with the given identifier, returned by
+	// Release in a deploy of another app, as the last build of target.
+	Promote(build string, target App, w io.Writer) error
+}
+
+// ErrImageDeployNotSupported is returned when the provisioner in use is not
+// able to deploy apps from prebuilt images.
+var ErrImageDeployNotSupported = errors.New("The provisioner in use does not support deploying from images.")
+
+// ImageDeployer is a provisioner that is able to deploy apps from prebuilt
+// images, skipping the build of the app.
+type ImageDeployer interface {
+	DeployImage(app App, image string, w io.Writer) error
+}
+
+// ErrDeployCancelNotSupported is returned when the provisioner in use is not
+// able to cancel running deploys.
+var ErrDeployCancelNotSupported = errors.New("The provisioner in use does not support canceling deploys.")
+
+// ErrDeployCanceled is returned by deploys interrupted by a cancelation.
+var ErrDeployCanceled = errors.New("The deploy was canceled.")
+
+// DeployCanceler is a provisioner that is able to interrupt the running
+// deploy of an app. Deploys interrupted by CancelDeploy must fail with
+// ErrDeployCanceled, undoing whatever they have done so far.
+type DeployCanceler interface {
+	CancelDeploy(app App) error
+}
+
+// ErrPlatformManagementNotSupported is returned when the provisioner in use
+// is not able to manage the images of platforms.
+var ErrPlatformManagementNotSupported = errors.New("The provisioner in use does not support managing platforms.")
+
+// PlatformOptions describes the base image of a platform. The image is either
+// built from the Dockerfile available in the given URL, or based on the given
+// image reference.
+type PlatformOptions struct {
+	Name       string
+	Dockerfile string
+	Image      string
+}
+
+// PlatformManager is a provisioner that manages the images of platforms,
+// building them and making them available to the units of apps.
+type PlatformManager interface {
+	PlatformAdd(opts PlatformOptions, w io.Writer) error
+	PlatformUpdate(opts PlatformOptions, w io.Writer) error
+	PlatformRemove(name string) error
+}
+
+// ErrIsolatedRunNotSupported is returned when the provisioner in use is not
+// able to run commands in one-off units.
+var ErrIsolatedRunNotSupported = errors.New("The provisioner in use does not support running commands in isolated units.")
+
+// IsolatedRunner is a provisioner that is able to run commands in one-off
+// units, created from the current image of the app with its environment
+// variables, instead of in the units serving the app. The unit is removed
+// when the command exits, and the exit status of the command is returned.
+type IsolatedRunner interface {
+	ExecuteCommandIsolated(stdout, stderr io.Writer, app App, cmd string, args ...string) (int, error)
+}
+
+// ErrShellNotSupported is returned when the provisioner in use is not able
+// to open interactive shells in units.
+var ErrShellNotSupported = errors.New("The provisioner in use does not support interactive shells.")
+
+// ShellOptions holds the parameters of an interactive shell session.
+type ShellOptions struct {
+	// Conn is the connection with the client: the input of the shell is
+	// read from it, and the output is written to it.
+	Conn io.ReadWriter
+
+	// Unit is the name of the unit where the shell runs. When empty, any
+	// unit of the app is used.
+	Unit string
+
+	// Isolated indicates that the shell runs in a fresh one-off unit,
+	// created from the image of the app and destroyed when the shell
+	// exits.
+	Isolated bool
+
+	// Width and Height are the dimensions of the terminal of the client,
+	// and Term is its type (like xterm).
+	Width  int
+	Height int
+	Term   string
+}
+
+// ShellOpener is a provisioner that is able to open interactive shells,
+// with a TTY, in units of apps.
+type ShellOpener interface {
+	OpenShell(app App, opts ShellOptions) error
+}
+
+// RouteProblem describes an inconsistency between the routes that an app
+// should have, according to its units, and the routes registered in the
+// router.
+//
+// Kind is one of "missing", "stale" or "orphaned", and Entry describes the
+// inconsistent entry, like "backend", "route http://10.10.10.10:49153" or
+// "cname myapp.com". Fixed indicates whether the problem has been repaired.
+type RouteProblem struct {
+	App   string
+	Kind  string
+	Entry string
+	Fixed bool
+}
+
+// RouteChecker is a provisioner that is able to check the consistency of the
+// router, and optionally repair it.
+type RouteChecker interface {
+	CheckRoutes(fix bool) ([]RouteProblem, error)
+}
+
 // Provisioner is the basic interface of this package.
 //
 // Any tsuru provisioner must implement this interface in order to provision
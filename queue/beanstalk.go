@@ -42,6 +42,9 @@ func (b *beanstalkdQ) Put(m *Message, delay time.Duration) error {
 	if err != nil {
 		return err
 	}
+	if m.FirstSeen.IsZero() {
+		m.FirstSeen = time.Now()
+	}
 	var buf bytes.Buffer
 	err = gob.NewEncoder(&buf).Encode(m)
 	if err != nil {
@@ -50,6 +53,7 @@ func (b *beanstalkdQ) Put(m *Message, delay time.Duration) error {
 	tube := beanstalk.Tube{Conn: conn, Name: b.name}
 	id, err := tube.Put(buf.Bytes(), 1, delay, ttr)
 	m.id = id
+	m.queue = b.name
 	return err
 }
 
@@ -92,15 +96,7 @@ func (b beanstalkdFactory) Handler(f func(*Message), name ...string) (Handler, e
 		inner: func() {
 			if message, err := get(5e9, name...); err == nil {
 				log.Debugf("Dispatching %q message to handler function.", message.Action)
-				go func(m *Message) {
-					f(m)
-					q := beanstalkdQ{}
-					if m.delete {
-						q.Delete(m)
-					} else {
-						q.Release(m, 0)
-					}
-				}(message)
+				go dispatch(b, f, message)
 			} else {
 				log.Debugf("Failed to get message from the queue: %s. Trying again...", err)
 				if e, ok := err.(*net.OpError); ok && e.Op == "dial" {
@@ -158,5 +154,10 @@ func get(timeout time.Duration, queues ...string) (*Message, error) {
 		return nil, fmt.Errorf("Invalid message: %q", body)
 	}
 	msg.id = id
+	if len(queues) == 1 {
+		msg.queue = queues[0]
+	} else if stats, err := conn.StatsJob(id); err == nil {
+		msg.queue = stats["tube"]
+	}
 	return &msg, nil
 }
@@ -94,6 +94,7 @@ func (s *BeanstalkSuite) TestPut(c *gocheck.C) {
 	err = gob.NewDecoder(buf).Decode(&got)
 	c.Assert(err, gocheck.IsNil)
 	got.id = msg.id
+	got.queue = msg.queue
 	c.Assert(got, gocheck.DeepEquals, msg)
 }
 
@@ -315,25 +316,30 @@ func (s *BeanstalkSuite) TestBeanstalkFactoryHandlerDeleteMessage(c *gocheck.C)
 	c.Assert(err, gocheck.NotNil)
 }
 
-func (s *BeanstalkSuite) TestBeanstalkFactoryHandlerReleaseMessage(c *gocheck.C) {
+func (s *BeanstalkSuite) TestBeanstalkFactoryHandlerRetriesMessage(c *gocheck.C) {
+	config.Set("queue-retry-delay", 0)
+	defer config.Unset("queue-retry-delay")
 	var factory beanstalkdFactory
 	msg := Message{
 		Action: "create-app",
 		Args:   []string{"something"},
-		id:     837826742,
 	}
 	q := beanstalkdQ{name: "default"}
 	q.Put(&msg, 0)
-	defer q.Delete(&msg)
 	handler, err := factory.Handler(func(m *Message) { time.Sleep(1e3) }, "default")
 	c.Assert(err, gocheck.IsNil)
 	handler.(*executor).inner()
-	time.Sleep(1e6)
-	cn, err := connection()
-	c.Assert(err, gocheck.IsNil)
-	id, _, err := cn.Reserve(1e6)
+	time.Sleep(1e8)
+	got, err := q.Get(1e6)
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(id, gocheck.Equals, msg.id)
+	defer q.Delete(got)
+	c.Assert(got.id, gocheck.Not(gocheck.Equals), msg.id)
+	c.Assert(got.Action, gocheck.Equals, "create-app")
+	c.Assert(got.Args, gocheck.DeepEquals, []string{"something"})
+	c.Assert(got.Attempts, gocheck.Equals, uint(1))
+	c.Assert(got.FirstSeen.Equal(msg.FirstSeen), gocheck.Equals, true)
+	err = q.Delete(&msg)
+	c.Assert(err, gocheck.NotNil)
 }
 
 func (s *BeanstalkSuite) TestBeanstalkFactoryIsInFactoriesMap(c *gocheck.C) {
@@ -0,0 +1,192 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"errors"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/log"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+const (
+	// Default number of times a message is handled before going to the
+	// dead-letter queue.
+	defaultMaxAttempts = 10
+
+	// Default delay before the first retry of a message.
+	defaultRetryDelay = 1e9
+
+	// Maximum delay between two attempts of handling a message.
+	maxRetryDelay = 10 * time.Minute
+)
+
+// ErrDeadLetterNotFound is returned when a dead letter can not be found in
+// the database.
+var ErrDeadLetterNotFound = errors.New("Dead letter not found.")
+
+// DeadLetter represents a message that could not be handled after the
+// maximum number of attempts. It's stored in the database so admins can
+// inspect it and put it back in the queue.
+type DeadLetter struct {
+	Id        bson.ObjectId `bson:"_id"`
+	Queue     string
+	Action    string
+	Args      []string
+	Attempts  uint
+	FirstSeen time.Time
+	Died      time.Time
+}
+
+// maxAttempts returns the number of attempts a message gets before going to
+// the dead-letter queue. It's read from the "queue-max-attempts" setting.
+func maxAttempts() uint {
+	if n, err := config.GetInt("queue-max-attempts"); err == nil && n > 0 {
+		return uint(n)
+	}
+	return defaultMaxAttempts
+}
+
+// retryDelay returns the delay before handling a message again, given the
+// number of attempts already made. The delay doubles on every attempt,
+// starting from the "queue-retry-delay" setting (in seconds), and never goes
+// beyond maxRetryDelay.
+func retryDelay(attempts uint) time.Duration {
+	delay := time.Duration(defaultRetryDelay)
+	if seconds, err := config.GetInt("queue-retry-delay"); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	}
+	for i := uint(1); i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// dispatch calls f with the given message, and then decides what to do with
+// it: messages deleted by f are removed from the queue, other messages are
+// put back in the queue with an exponential backoff, until they reach the
+// maximum number of attempts, when they go to the dead-letter queue.
+func dispatch(factory QFactory, f func(*Message), m *Message) {
+	f(m)
+	q, err := factory.Get(m.queue)
+	if err != nil {
+		log.Errorf("Failed to get queue %q: %s", m.queue, err)
+		return
+	}
+	if m.delete {
+		q.Delete(m)
+		return
+	}
+	m.Attempts++
+	if m.Attempts >= maxAttempts() {
+		if err := bury(m); err != nil {
+			log.Errorf("Failed to move message %q to the dead-letter queue: %s", m.Action, err)
+			q.Release(m, retryDelay(m.Attempts))
+			return
+		}
+		log.Errorf("Message %q reached the maximum number of attempts (%d). Moved to the dead-letter queue.", m.Action, m.Attempts)
+		q.Delete(m)
+		return
+	}
+	retry := Message{
+		Action:    m.Action,
+		Args:      m.Args,
+		Attempts:  m.Attempts,
+		FirstSeen: m.FirstSeen,
+	}
+	if err := q.Put(&retry, retryDelay(m.Attempts)); err != nil {
+		log.Errorf("Failed to retry message %q: %s", m.Action, err)
+		q.Release(m, retryDelay(m.Attempts))
+		return
+	}
+	q.Delete(m)
+}
+
+// bury stores the given message in the dead-letter collection.
+func bury(m *Message) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	letter := DeadLetter{
+		Id:        bson.NewObjectId(),
+		Queue:     m.queue,
+		Action:    m.Action,
+		Args:      m.Args,
+		Attempts:  m.Attempts,
+		FirstSeen: m.FirstSeen,
+		Died:      time.Now(),
+	}
+	return conn.DeadLetters().Insert(letter)
+}
+
+// ListDeadLetters returns all messages in the dead-letter queue, the most
+// recent first.
+func ListDeadLetters() ([]DeadLetter, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var letters []DeadLetter
+	err = conn.DeadLetters().Find(nil).Sort("-died").All(&letters)
+	return letters, err
+}
+
+// GetDeadLetter returns the dead letter identified by the given id.
+func GetDeadLetter(id string) (*DeadLetter, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrDeadLetterNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var letter DeadLetter
+	err = conn.DeadLetters().FindId(bson.ObjectIdHex(id)).One(&letter)
+	if err == mgo.ErrNotFound {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &letter, nil
+}
+
+// RequeueDeadLetter puts the dead letter identified by the given id back in
+// its original queue, as a fresh message, and removes it from the dead-letter
+// queue.
+func RequeueDeadLetter(id string) error {
+	letter, err := GetDeadLetter(id)
+	if err != nil {
+		return err
+	}
+	factory, err := Factory()
+	if err != nil {
+		return err
+	}
+	q, err := factory.Get(letter.Queue)
+	if err != nil {
+		return err
+	}
+	msg := Message{Action: letter.Action, Args: letter.Args}
+	if err = q.Put(&msg, 0); err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.DeadLetters().RemoveId(letter.Id)
+}
@@ -0,0 +1,96 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"github.com/globocom/config"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"time"
+)
+
+func (s *MongoDBSuite) TestMaxAttempts(c *gocheck.C) {
+	c.Assert(maxAttempts(), gocheck.Equals, uint(defaultMaxAttempts))
+	config.Set("queue-max-attempts", 3)
+	defer config.Unset("queue-max-attempts")
+	c.Assert(maxAttempts(), gocheck.Equals, uint(3))
+}
+
+func (s *MongoDBSuite) TestRetryDelay(c *gocheck.C) {
+	c.Assert(retryDelay(1), gocheck.Equals, time.Second)
+	c.Assert(retryDelay(2), gocheck.Equals, 2*time.Second)
+	c.Assert(retryDelay(4), gocheck.Equals, 8*time.Second)
+	c.Assert(retryDelay(100), gocheck.Equals, maxRetryDelay)
+}
+
+func (s *MongoDBSuite) TestRetryDelayFromConfig(c *gocheck.C) {
+	config.Set("queue-retry-delay", 5)
+	defer config.Unset("queue-retry-delay")
+	c.Assert(retryDelay(1), gocheck.Equals, 5*time.Second)
+	c.Assert(retryDelay(3), gocheck.Equals, 20*time.Second)
+}
+
+func (s *MongoDBSuite) TestListDeadLetters(c *gocheck.C) {
+	defer s.conn.DeadLetters().RemoveAll(nil)
+	old := DeadLetter{Id: bson.NewObjectId(), Action: "old", Died: time.Now().Add(-time.Hour)}
+	recent := DeadLetter{Id: bson.NewObjectId(), Action: "recent", Died: time.Now()}
+	err := s.conn.DeadLetters().Insert(old, recent)
+	c.Assert(err, gocheck.IsNil)
+	letters, err := ListDeadLetters()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(letters, gocheck.HasLen, 2)
+	c.Assert(letters[0].Action, gocheck.Equals, "recent")
+	c.Assert(letters[1].Action, gocheck.Equals, "old")
+}
+
+func (s *MongoDBSuite) TestGetDeadLetter(c *gocheck.C) {
+	defer s.conn.DeadLetters().RemoveAll(nil)
+	letter := DeadLetter{Id: bson.NewObjectId(), Queue: "default", Action: "create-app", Attempts: 10}
+	err := s.conn.DeadLetters().Insert(letter)
+	c.Assert(err, gocheck.IsNil)
+	got, err := GetDeadLetter(letter.Id.Hex())
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got.Queue, gocheck.Equals, "default")
+	c.Assert(got.Action, gocheck.Equals, "create-app")
+	c.Assert(got.Attempts, gocheck.Equals, uint(10))
+}
+
+func (s *MongoDBSuite) TestGetDeadLetterNotFound(c *gocheck.C) {
+	_, err := GetDeadLetter(bson.NewObjectId().Hex())
+	c.Assert(err, gocheck.Equals, ErrDeadLetterNotFound)
+	_, err = GetDeadLetter("invalid-id")
+	c.Assert(err, gocheck.Equals, ErrDeadLetterNotFound)
+}
+
+func (s *MongoDBSuite) TestRequeueDeadLetter(c *gocheck.C) {
+	config.Set("queue", "mongodb")
+	defer config.Unset("queue")
+	defer s.conn.DeadLetters().RemoveAll(nil)
+	letter := DeadLetter{
+		Id:       bson.NewObjectId(),
+		Queue:    "somequeue",
+		Action:   "create-app",
+		Args:     []string{"myapp"},
+		Attempts: 10,
+	}
+	err := s.conn.DeadLetters().Insert(letter)
+	c.Assert(err, gocheck.IsNil)
+	err = RequeueDeadLetter(letter.Id.Hex())
+	c.Assert(err, gocheck.IsNil)
+	n, err := s.conn.DeadLetters().FindId(letter.Id).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	q := mongodbQ{name: "somequeue"}
+	got, err := q.Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got.Action, gocheck.Equals, "create-app")
+	c.Assert(got.Args, gocheck.DeepEquals, []string{"myapp"})
+	c.Assert(got.Attempts, gocheck.Equals, uint(0))
+}
+
+func (s *MongoDBSuite) TestRequeueDeadLetterNotFound(c *gocheck.C) {
+	err := RequeueDeadLetter(bson.NewObjectId().Hex())
+	c.Assert(err, gocheck.Equals, ErrDeadLetterNotFound)
+}
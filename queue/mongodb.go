@@ -0,0 +1,161 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/log"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// Interval between two attempts to reserve a message in MongoDB.
+const pollInterval = 100e6
+
+// mongoMessage is the representation of a message in the MongoDB collection.
+//
+// A message is available for reservation when Visible is in the past. When a
+// message is reserved, its visibility is moved ttr into the future, so it
+// goes back to the queue if the consumer does not delete or release it in
+// time, just like beanstalkd does.
+type mongoMessage struct {
+	Id        bson.ObjectId `bson:"_id"`
+	Queue     string
+	Action    string
+	Args      []string
+	Attempts  uint
+	FirstSeen time.Time
+	Visible   time.Time
+}
+
+type mongodbQ struct {
+	name string
+}
+
+func (q *mongodbQ) Get(timeout time.Duration) (*Message, error) {
+	return mongoGet(timeout, q.name)
+}
+
+func (q *mongodbQ) Put(m *Message, delay time.Duration) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	now := bson.Now()
+	if m.FirstSeen.IsZero() {
+		m.FirstSeen = now
+	}
+	msg := mongoMessage{
+		Id:        bson.NewObjectId(),
+		Queue:     q.name,
+		Action:    m.Action,
+		Args:      m.Args,
+		Attempts:  m.Attempts,
+		FirstSeen: m.FirstSeen,
+		Visible:   now.Add(delay),
+	}
+	err = conn.Queue().Insert(msg)
+	if err != nil {
+		return err
+	}
+	m.mongoID = msg.Id
+	m.queue = q.name
+	return nil
+}
+
+func (q *mongodbQ) Delete(m *Message) error {
+	if m.mongoID == "" {
+		return errors.New("Unknown message.")
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err = conn.Queue().RemoveId(m.mongoID); err == mgo.ErrNotFound {
+		return errors.New("Message not found.")
+	}
+	return err
+}
+
+func (q *mongodbQ) Release(m *Message, delay time.Duration) error {
+	if m.mongoID == "" {
+		return errors.New("Unknown message.")
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	update := bson.M{"$set": bson.M{"visible": time.Now().Add(delay)}}
+	if err = conn.Queue().UpdateId(m.mongoID, update); err == mgo.ErrNotFound {
+		return errors.New("Message not found.")
+	}
+	return err
+}
+
+type mongodbFactory struct{}
+
+func (f mongodbFactory) Get(name string) (Q, error) {
+	return &mongodbQ{name: name}, nil
+}
+
+func (f mongodbFactory) Handler(fn func(*Message), name ...string) (Handler, error) {
+	return &executor{
+		inner: func() {
+			if message, err := mongoGet(5e9, name...); err == nil {
+				log.Debugf("Dispatching %q message to handler function.", message.Action)
+				go dispatch(f, fn, message)
+			} else {
+				log.Debugf("Failed to get message from the queue: %s. Trying again...", err)
+			}
+		},
+	}, nil
+}
+
+// mongoGet reserves the oldest visible message in one of the given queues,
+// using an atomic find-and-modify, so a message is never delivered to two
+// consumers at the same time. It keeps polling the database until a message
+// is available or the timeout is reached.
+func mongoGet(timeout time.Duration, queues ...string) (*Message, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(timeout)
+	for {
+		now := time.Now()
+		query := bson.M{"queue": bson.M{"$in": queues}, "visible": bson.M{"$lte": now}}
+		change := mgo.Change{
+			Update:    bson.M{"$set": bson.M{"visible": now.Add(ttr)}},
+			ReturnNew: true,
+		}
+		var msg mongoMessage
+		_, err = conn.Queue().Find(query).Sort("visible").Apply(change, &msg)
+		if err == nil {
+			m := Message{
+				Action:    msg.Action,
+				Args:      msg.Args,
+				Attempts:  msg.Attempts,
+				FirstSeen: msg.FirstSeen,
+				queue:     msg.Queue,
+				mongoID:   msg.Id,
+			}
+			return &m, nil
+		}
+		if err != mgo.ErrNotFound {
+			return nil, err
+		}
+		if !now.Before(deadline) {
+			return nil, fmt.Errorf("Timed out waiting for message after %s.", timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/db"
+	"labix.org/v2/mgo/bson"
+	"launchpad.net/gocheck"
+	"sync/atomic"
+	"time"
+)
+
+type MongoDBSuite struct {
+	conn *db.Storage
+}
+
+var _ = gocheck.Suite(&MongoDBSuite{})
+
+func (s *MongoDBSuite) SetUpSuite(c *gocheck.C) {
+	var err error
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_queue_mongodb_test")
+	s.conn, err = db.Conn()
+	c.Assert(err, gocheck.IsNil)
+}
+
+func (s *MongoDBSuite) TearDownSuite(c *gocheck.C) {
+	s.conn.Queue().Database.DropDatabase()
+	s.conn.Close()
+}
+
+func (s *MongoDBSuite) TearDownTest(c *gocheck.C) {
+	_, err := s.conn.Queue().RemoveAll(nil)
+	c.Assert(err, gocheck.IsNil)
+}
+
+func (s *MongoDBSuite) TestPut(c *gocheck.C) {
+	msg := Message{
+		Action: "regenerate-apprc",
+		Args:   []string{"myapp"},
+	}
+	q := mongodbQ{name: "default"}
+	err := q.Put(&msg, 0)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(msg.mongoID, gocheck.Not(gocheck.Equals), bson.ObjectId(""))
+	var got mongoMessage
+	err = s.conn.Queue().FindId(msg.mongoID).One(&got)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got.Queue, gocheck.Equals, "default")
+	c.Assert(got.Action, gocheck.Equals, "regenerate-apprc")
+	c.Assert(got.Args, gocheck.DeepEquals, []string{"myapp"})
+}
+
+func (s *MongoDBSuite) TestPutWithDelay(c *gocheck.C) {
+	msg := Message{Action: "do-something"}
+	q := mongodbQ{name: "default"}
+	err := q.Put(&msg, 1e9)
+	c.Assert(err, gocheck.IsNil)
+	_, err = q.Get(1e6)
+	c.Assert(err, gocheck.NotNil)
+	got, err := q.Get(2e9)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got.mongoID, gocheck.Equals, msg.mongoID)
+}
+
+func (s *MongoDBSuite) TestGet(c *gocheck.C) {
+	msg := Message{
+		Action: "regenerate-apprc",
+		Args:   []string{"myapprc"},
+	}
+	q := mongodbQ{name: "default"}
+	err := q.Put(&msg, 0)
+	c.Assert(err, gocheck.IsNil)
+	got, err := q.Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(*got, gocheck.DeepEquals, msg)
+}
+
+func (s *MongoDBSuite) TestGetRestoresQueueAndAttempts(c *gocheck.C) {
+	firstSeen := bson.Now().Add(-time.Hour)
+	msg := Message{Action: "create-app", Attempts: 3, FirstSeen: firstSeen}
+	q := mongodbQ{name: "here"}
+	err := q.Put(&msg, 0)
+	c.Assert(err, gocheck.IsNil)
+	got, err := q.Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got.queue, gocheck.Equals, "here")
+	c.Assert(got.Attempts, gocheck.Equals, uint(3))
+	c.Assert(got.FirstSeen.Equal(firstSeen), gocheck.Equals, true)
+}
+
+func (s *MongoDBSuite) TestGetReservesTheMessage(c *gocheck.C) {
+	msg := Message{Action: "regenerate-apprc"}
+	q := mongodbQ{name: "default"}
+	err := q.Put(&msg, 0)
+	c.Assert(err, gocheck.IsNil)
+	_, err = q.Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	_, err = q.Get(1e6)
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *MongoDBSuite) TestPutAndGetFromSpecificQueue(c *gocheck.C) {
+	msg := Message{
+		Action: "do-something",
+		Args:   []string{"everything"},
+	}
+	q := mongodbQ{name: "here"}
+	err := q.Put(&msg, 0)
+	c.Assert(err, gocheck.IsNil)
+	dQ := mongodbQ{name: "default"}
+	_, err = dQ.Get(1e6)
+	c.Assert(err, gocheck.NotNil)
+	got, err := q.Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got.Action, gocheck.Equals, "do-something")
+	c.Assert(got.Args, gocheck.DeepEquals, []string{"everything"})
+}
+
+func (s *MongoDBSuite) TestGetFromEmptyQueue(c *gocheck.C) {
+	q := mongodbQ{name: "default"}
+	msg, err := q.Get(1e6)
+	c.Assert(msg, gocheck.IsNil)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Timed out waiting for message after 1ms.")
+}
+
+func (s *MongoDBSuite) TestRelease(c *gocheck.C) {
+	msg := Message{Action: "do-something"}
+	q := mongodbQ{name: "default"}
+	err := q.Put(&msg, 0)
+	c.Assert(err, gocheck.IsNil)
+	got, err := q.Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	err = q.Release(got, 0)
+	c.Assert(err, gocheck.IsNil)
+	got, err = q.Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got.mongoID, gocheck.Equals, msg.mongoID)
+}
+
+func (s *MongoDBSuite) TestReleaseWithDelay(c *gocheck.C) {
+	msg := Message{Action: "do-something"}
+	q := mongodbQ{name: "default"}
+	err := q.Put(&msg, 0)
+	c.Assert(err, gocheck.IsNil)
+	got, err := q.Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	err = q.Release(got, 1e9)
+	c.Assert(err, gocheck.IsNil)
+	_, err = q.Get(1e6)
+	c.Assert(err, gocheck.NotNil)
+	got, err = q.Get(2e9)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got.mongoID, gocheck.Equals, msg.mongoID)
+}
+
+func (s *MongoDBSuite) TestReleaseMessageWithoutID(c *gocheck.C) {
+	msg := Message{Action: "do-something"}
+	q := mongodbQ{name: "default"}
+	err := q.Release(&msg, 0)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Unknown message.")
+}
+
+func (s *MongoDBSuite) TestReleaseMessageNotFound(c *gocheck.C) {
+	msg := Message{Action: "do-otherthing", mongoID: bson.NewObjectId()}
+	q := mongodbQ{name: "default"}
+	err := q.Release(&msg, 0)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Message not found.")
+}
+
+func (s *MongoDBSuite) TestDelete(c *gocheck.C) {
+	msg := Message{Action: "create-app"}
+	q := mongodbQ{name: "default"}
+	err := q.Put(&msg, 0)
+	c.Assert(err, gocheck.IsNil)
+	err = q.Delete(&msg)
+	c.Assert(err, gocheck.IsNil)
+	n, err := s.conn.Queue().FindId(msg.mongoID).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *MongoDBSuite) TestDeleteUnknownMessage(c *gocheck.C) {
+	msg := Message{Action: "create-app", mongoID: bson.NewObjectId()}
+	q := mongodbQ{name: "default"}
+	err := q.Delete(&msg)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Message not found.")
+}
+
+func (s *MongoDBSuite) TestDeleteMessageWithoutID(c *gocheck.C) {
+	msg := Message{Action: "create-app"}
+	q := mongodbQ{name: "default"}
+	err := q.Delete(&msg)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Unknown message.")
+}
+
+func (s *MongoDBSuite) TestMongoDBQSatisfiesQueue(c *gocheck.C) {
+	var _ Q = &mongodbQ{}
+}
+
+func (s *MongoDBSuite) TestMongoDBFactoryGet(c *gocheck.C) {
+	var factory mongodbFactory
+	q, err := factory.Get("someq")
+	c.Assert(err, gocheck.IsNil)
+	mq, ok := q.(*mongodbQ)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(mq.name, gocheck.Equals, "someq")
+}
+
+func (s *MongoDBSuite) TestMongoDBFactoryHandler(c *gocheck.C) {
+	msg := Message{Action: "create-app"}
+	q := mongodbQ{name: "default"}
+	q.Put(&msg, 0)
+	var called int32
+	var dumb = func(m *Message) {
+		atomic.StoreInt32(&called, 1)
+	}
+	var factory mongodbFactory
+	handler, err := factory.Handler(dumb, "default")
+	c.Assert(err, gocheck.IsNil)
+	exec, ok := handler.(*executor)
+	c.Assert(ok, gocheck.Equals, true)
+	exec.inner()
+	time.Sleep(1e6)
+	c.Assert(atomic.LoadInt32(&called), gocheck.Equals, int32(1))
+}
+
+func (s *MongoDBSuite) TestMongoDBFactoryHandlerDeleteMessage(c *gocheck.C) {
+	var factory mongodbFactory
+	msg := Message{Action: "create-app"}
+	q := mongodbQ{name: "default"}
+	q.Put(&msg, 0)
+	handler, err := factory.Handler(func(m *Message) { m.Delete() }, "default")
+	c.Assert(err, gocheck.IsNil)
+	handler.(*executor).inner()
+	time.Sleep(1e8)
+	n, err := s.conn.Queue().FindId(msg.mongoID).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *MongoDBSuite) TestMongoDBFactoryHandlerRetriesMessage(c *gocheck.C) {
+	config.Set("queue-retry-delay", 0)
+	defer config.Unset("queue-retry-delay")
+	var factory mongodbFactory
+	msg := Message{Action: "create-app"}
+	q := mongodbQ{name: "default"}
+	q.Put(&msg, 0)
+	handler, err := factory.Handler(func(m *Message) {}, "default")
+	c.Assert(err, gocheck.IsNil)
+	handler.(*executor).inner()
+	time.Sleep(1e8)
+	got, err := q.Get(1e6)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got.mongoID, gocheck.Not(gocheck.Equals), msg.mongoID)
+	c.Assert(got.Action, gocheck.Equals, "create-app")
+	c.Assert(got.Attempts, gocheck.Equals, uint(1))
+	c.Assert(got.FirstSeen.Equal(msg.FirstSeen), gocheck.Equals, true)
+	n, err := s.conn.Queue().FindId(msg.mongoID).Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+}
+
+func (s *MongoDBSuite) TestMongoDBFactoryHandlerMovesMessageToDeadLetters(c *gocheck.C) {
+	config.Set("queue-max-attempts", 2)
+	defer config.Unset("queue-max-attempts")
+	defer s.conn.DeadLetters().RemoveAll(nil)
+	var factory mongodbFactory
+	msg := Message{Action: "create-app", Args: []string{"myapp"}, Attempts: 1}
+	q := mongodbQ{name: "default"}
+	q.Put(&msg, 0)
+	handler, err := factory.Handler(func(m *Message) {}, "default")
+	c.Assert(err, gocheck.IsNil)
+	handler.(*executor).inner()
+	time.Sleep(1e8)
+	_, err = q.Get(1e6)
+	c.Assert(err, gocheck.NotNil)
+	letters, err := ListDeadLetters()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(letters, gocheck.HasLen, 1)
+	c.Assert(letters[0].Queue, gocheck.Equals, "default")
+	c.Assert(letters[0].Action, gocheck.Equals, "create-app")
+	c.Assert(letters[0].Args, gocheck.DeepEquals, []string{"myapp"})
+	c.Assert(letters[0].Attempts, gocheck.Equals, uint(2))
+	c.Assert(letters[0].FirstSeen.Equal(msg.FirstSeen), gocheck.Equals, true)
+}
+
+func (s *MongoDBSuite) TestMongoDBFactoryHandlerFailsMessageUntilMaxAttempts(c *gocheck.C) {
+	config.Set("queue-max-attempts", 3)
+	defer config.Unset("queue-max-attempts")
+	config.Set("queue-retry-delay", 0)
+	defer config.Unset("queue-retry-delay")
+	defer s.conn.DeadLetters().RemoveAll(nil)
+	var factory mongodbFactory
+	q, err := factory.Get("default")
+	c.Assert(err, gocheck.IsNil)
+	msg := Message{Action: "create-app", Args: []string{"myapp"}}
+	err = q.Put(&msg, 0)
+	c.Assert(err, gocheck.IsNil)
+	var calls int32
+	handler, err := factory.Handler(func(m *Message) { atomic.AddInt32(&calls, 1) }, "default")
+	c.Assert(err, gocheck.IsNil)
+	for i := 0; i < 3; i++ {
+		n, err := s.conn.DeadLetters().Count()
+		c.Assert(err, gocheck.IsNil)
+		c.Assert(n, gocheck.Equals, 0)
+		handler.(*executor).inner()
+		time.Sleep(1e8)
+	}
+	c.Assert(atomic.LoadInt32(&calls), gocheck.Equals, int32(3))
+	n, err := s.conn.Queue().Count()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 0)
+	var letters []DeadLetter
+	err = s.conn.DeadLetters().Find(nil).All(&letters)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(letters, gocheck.HasLen, 1)
+	c.Assert(letters[0].Queue, gocheck.Equals, "default")
+	c.Assert(letters[0].Action, gocheck.Equals, "create-app")
+	c.Assert(letters[0].Args, gocheck.DeepEquals, []string{"myapp"})
+	c.Assert(letters[0].Attempts, gocheck.Equals, uint(3))
+}
+
+func (s *MongoDBSuite) TestMongoDBFactoryIsInFactoriesMap(c *gocheck.C) {
+	f, ok := factories["mongodb"]
+	c.Assert(ok, gocheck.Equals, true)
+	_, ok = f.(mongodbFactory)
+	c.Assert(ok, gocheck.Equals, true)
+}
@@ -11,11 +11,16 @@
 //
 // It also provides a generic, thread safe, handler for messages, with start
 // and stop capability.
+//
+// Two queue implementations are available: beanstalkd (the default) and
+// mongodb, which stores messages in the tsuru database and does not depend on
+// any other server.
 package queue
 
 import (
 	"fmt"
 	"github.com/globocom/config"
+	"labix.org/v2/mgo/bson"
 	"time"
 )
 
@@ -71,6 +76,7 @@ type QFactory interface {
 
 var factories = map[string]QFactory{
 	"beanstalkd": beanstalkdFactory{},
+	"mongodb":    mongodbFactory{},
 }
 
 // Register registers a new queue factory. This is how one would add a new
@@ -101,11 +107,19 @@ func Factory() (QFactory, error) {
 //
 // For example, the action "regenerate apprc" could receive one argument: the
 // name of the app for which the apprc file will be regenerate.
+//
+// Messages that are not deleted by the handler are retried with an
+// exponential backoff. Attempts holds the number of times the message has
+// been handled, and FirstSeen the time when it was first put in the queue.
 type Message struct {
-	Action string
-	Args   []string
-	id     uint64
-	delete bool
+	Action    string
+	Args      []string
+	Attempts  uint
+	FirstSeen time.Time
+	id        uint64
+	mongoID   bson.ObjectId
+	queue     string
+	delete    bool
 }
 
 // Delete deletes the message from the queue.
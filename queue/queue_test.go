@@ -34,6 +34,15 @@ func (s *S) TestFactory(c *gocheck.C) {
 	c.Assert(ok, gocheck.Equals, true)
 }
 
+func (s *S) TestFactoryMongoDB(c *gocheck.C) {
+	config.Set("queue", "mongodb")
+	defer config.Unset("queue")
+	f, err := Factory()
+	c.Assert(err, gocheck.IsNil)
+	_, ok := f.(mongodbFactory)
+	c.Assert(ok, gocheck.Equals, true)
+}
+
 func (s *S) TestFactoryConfigUndefined(c *gocheck.C) {
 	f, err := Factory()
 	c.Assert(err, gocheck.IsNil)
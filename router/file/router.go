@@ -0,0 +1,429 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package file provides a router implementation that renders the routes in a
+// configuration file, for proxies like nginx and HAProxy.
+//
+// The state of the router (routes and cnames of each backend) is stored in
+// MongoDB. After each change, the whole configuration file is rendered from
+// this state, atomically replaced and the proxy is reloaded using a
+// configurable command. If the reload fails, both the file and the state are
+// rolled back.
+//
+// In order to use this router, you need to define the "file-router:domain"
+// and the "file-router:config-file" settings. Other settings are:
+//
+//   - file-router:reload-command: command used to reload the proxy (for
+//     example, "nginx -s reload"). When it's not defined, the proxy is not
+//     reloaded.
+//   - file-router:format: format of the configuration file, "nginx" (the
+//     default) or "haproxy".
+//   - file-router:template: path to a text/template file, used instead of
+//     the builtin formats.
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/exec"
+	"github.com/globocom/tsuru/router"
+	"io/ioutil"
+	"labix.org/v2/mgo"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	errBackendNotFound = errors.New("Backend not found")
+	errRouteNotFound   = errors.New("Route not found")
+	errCNameNotFound   = errors.New("CName not found")
+)
+
+var (
+	execut exec.Executor
+	emutex sync.Mutex
+)
+
+func executor() exec.Executor {
+	emutex.Lock()
+	defer emutex.Unlock()
+	if execut == nil {
+		execut = exec.OsExecutor{}
+	}
+	return execut
+}
+
+func init() {
+	router.Register("file", &fileRouter{})
+}
+
+// backend is the state of a backend, as stored in the database.
+type backend struct {
+	Name   string `bson:"_id"`
+	Routes []string
+	CNames []string
+}
+
+func (b *backend) copy() *backend {
+	return &backend{
+		Name:   b.Name,
+		Routes: append([]string(nil), b.Routes...),
+		CNames: append([]string(nil), b.CNames...),
+	}
+}
+
+func collection() (*db.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection("file_router_backends"), nil
+}
+
+type fileRouter struct {
+	// mut serializes changes in the state and in the configuration file.
+	mut sync.Mutex
+}
+
+// modify loads the given backends, calls fn for changing them and stores
+// the result, rendering the configuration file and reloading the proxy. A
+// backend that is not found is given to fn as nil, and a backend set to nil
+// by fn is removed.
+//
+// If anything fails after the state is changed, the original state is
+// restored.
+func (r *fileRouter) modify(names []string, fn func(backends map[string]*backend) error) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	coll, err := collection()
+	if err != nil {
+		return err
+	}
+	backends := make(map[string]*backend, len(names))
+	original := make(map[string]*backend, len(names))
+	for _, name := range names {
+		var b backend
+		err := coll.FindId(name).One(&b)
+		if err == mgo.ErrNotFound {
+			backends[name], original[name] = nil, nil
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		backends[name], original[name] = &b, b.copy()
+	}
+	if err = fn(backends); err != nil {
+		return err
+	}
+	if err = store(coll, backends); err != nil {
+		store(coll, original)
+		return err
+	}
+	if err = r.apply(coll); err != nil {
+		store(coll, original)
+		return err
+	}
+	return nil
+}
+
+func store(coll *db.Collection, backends map[string]*backend) error {
+	for name, b := range backends {
+		var err error
+		if b == nil {
+			err = coll.RemoveId(name)
+			if err == mgo.ErrNotFound {
+				err = nil
+			}
+		} else {
+			_, err = coll.UpsertId(name, b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply renders the configuration file with the state of all backends,
+// replaces the file and reloads the proxy. When the reload fails, the
+// previous file is restored.
+func (r *fileRouter) apply(coll *db.Collection) error {
+	path, err := config.GetString("file-router:config-file")
+	if err != nil {
+		return &routeError{"render", err}
+	}
+	var backends []backend
+	if err = coll.Find(nil).Sort("_id").All(&backends); err != nil {
+		return &routeError{"render", err}
+	}
+	content, err := render(backends)
+	if err != nil {
+		return &routeError{"render", err}
+	}
+	previous, readErr := ioutil.ReadFile(path)
+	if err = writeFile(path, content); err != nil {
+		return &routeError{"write", err}
+	}
+	if err = reload(); err != nil {
+		if readErr == nil {
+			writeFile(path, previous)
+		} else {
+			os.Remove(path)
+		}
+		return &routeError{"reload", err}
+	}
+	return nil
+}
+
+// writeFile atomically replaces the content of the given file, by writing
+// to a temporary file in the same directory and renaming it.
+func writeFile(path string, content []byte) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, "."+base+".")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func reload() error {
+	command, err := config.GetString("file-router:reload-command")
+	if err != nil {
+		return nil
+	}
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return nil
+	}
+	var out bytes.Buffer
+	err = executor().Execute(parts[0], parts[1:], nil, &out, &out)
+	if err != nil {
+		return fmt.Errorf("%q failed: %s. Output: %s", command, err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+func (r *fileRouter) AddBackend(name string) error {
+	err := r.modify([]string{name}, func(backends map[string]*backend) error {
+		if backends[name] != nil {
+			return &routeError{"add", errors.New("Backend already exists")}
+		}
+		backends[name] = &backend{Name: name}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return router.Store(name, name)
+}
+
+func (r *fileRouter) RemoveBackend(name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	err = r.modify([]string{backendName}, func(backends map[string]*backend) error {
+		if backends[backendName] == nil {
+			return &routeError{"remove", errBackendNotFound}
+		}
+		backends[backendName] = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return router.Remove(name)
+}
+
+// change is a helper for changing one backend, identified by the app name.
+func (r *fileRouter) change(name, op string, fn func(b *backend) error) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	return r.modify([]string{backendName}, func(backends map[string]*backend) error {
+		b := backends[backendName]
+		if b == nil {
+			return &routeError{op, errBackendNotFound}
+		}
+		return fn(b)
+	})
+}
+
+func (r *fileRouter) AddRoute(name, address string) error {
+	return r.change(name, "add", func(b *backend) error {
+		if index(b.Routes, address) < 0 {
+			b.Routes = append(b.Routes, address)
+		}
+		return nil
+	})
+}
+
+func (r *fileRouter) RemoveRoute(name, address string) error {
+	return r.change(name, "remove", func(b *backend) error {
+		i := index(b.Routes, address)
+		if i < 0 {
+			return &routeError{"remove", errRouteNotFound}
+		}
+		b.Routes = append(b.Routes[:i], b.Routes[i+1:]...)
+		return nil
+	})
+}
+
+func (r *fileRouter) SetCName(cname, name string) error {
+	domain, err := config.GetString("file-router:domain")
+	if err != nil {
+		return &routeError{"setCName", err}
+	}
+	if strings.Contains(cname, domain) {
+		err := fmt.Errorf("Invalid CNAME %s. You can't use Tsuru's application domain.", cname)
+		return &routeError{"setCName", err}
+	}
+	return r.change(name, "setCName", func(b *backend) error {
+		if index(b.CNames, cname) < 0 {
+			b.CNames = append(b.CNames, cname)
+			sort.Strings(b.CNames)
+		}
+		return nil
+	})
+}
+
+func (r *fileRouter) UnsetCName(cname, name string) error {
+	return r.change(name, "unsetCName", func(b *backend) error {
+		i := index(b.CNames, cname)
+		if i < 0 {
+			return &routeError{"unsetCName", errCNameNotFound}
+		}
+		b.CNames = append(b.CNames[:i], b.CNames[i+1:]...)
+		return nil
+	})
+}
+
+func (r *fileRouter) Addr(name string) (string, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return "", err
+	}
+	domain, err := config.GetString("file-router:domain")
+	if err != nil {
+		return "", &routeError{"get", err}
+	}
+	if _, err = getBackend(backendName); err != nil {
+		return "", err
+	}
+	return backendName + "." + domain, nil
+}
+
+func (r *fileRouter) Swap(backend1, backend2 string) error {
+	return router.Swap(r, backend1, backend2)
+}
+
+// SwapRoutes exchanges the routes of the given backends, rendering the
+// configuration file only once.
+func (r *fileRouter) SwapRoutes(backend1, backend2 string) error {
+	backendName1, err := router.Retrieve(backend1)
+	if err != nil {
+		return err
+	}
+	backendName2, err := router.Retrieve(backend2)
+	if err != nil {
+		return err
+	}
+	names := []string{backendName1, backendName2}
+	return r.modify(names, func(backends map[string]*backend) error {
+		b1, b2 := backends[backendName1], backends[backendName2]
+		if b1 == nil || b2 == nil {
+			return &routeError{"swap", errBackendNotFound}
+		}
+		b1.Routes, b2.Routes = b2.Routes, b1.Routes
+		return nil
+	})
+}
+
+func (r *fileRouter) Routes(name string) ([]string, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := getBackend(backendName)
+	if err != nil {
+		return nil, err
+	}
+	return b.Routes, nil
+}
+
+// CNames returns the cnames of the given backend. The routes of each cname
+// are the routes of the backend, as they share the same upstream.
+func (r *fileRouter) CNames(name string) (map[string][]string, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := getBackend(backendName)
+	if err != nil {
+		return nil, err
+	}
+	cnames := make(map[string][]string, len(b.CNames))
+	for _, cname := range b.CNames {
+		cnames[cname] = b.Routes
+	}
+	return cnames, nil
+}
+
+func getBackend(name string) (*backend, error) {
+	coll, err := collection()
+	if err != nil {
+		return nil, err
+	}
+	var b backend
+	err = coll.FindId(name).One(&b)
+	if err == mgo.ErrNotFound {
+		return nil, &routeError{"get", errBackendNotFound}
+	}
+	if err != nil {
+		return nil, &routeError{"get", err}
+	}
+	return &b, nil
+}
+
+func index(list []string, item string) int {
+	for i, v := range list {
+		if v == item {
+			return i
+		}
+	}
+	return -1
+}
+
+type routeError struct {
+	op  string
+	err error
+}
+
+func (e *routeError) Error() string {
+	return fmt.Sprintf("Could not %s route: %s", e.op, e.err)
+}
@@ -0,0 +1,264 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package file
+
+import (
+	"github.com/globocom/config"
+	etesting "github.com/globocom/tsuru/exec/testing"
+	"github.com/globocom/tsuru/router"
+	"io/ioutil"
+	"launchpad.net/gocheck"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func (s *S) readConfig(c *gocheck.C) string {
+	content, err := ioutil.ReadFile(s.configFile)
+	c.Assert(err, gocheck.IsNil)
+	return string(content)
+}
+
+func (s *S) TestShouldBeRegistered(c *gocheck.C) {
+	r, err := router.Get("file")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r, gocheck.FitsTypeOf, &fileRouter{})
+}
+
+func (s *S) TestFileRouterIsARouteSwapper(c *gocheck.C) {
+	var _ router.RouteSwapper = &fileRouter{}
+}
+
+func (s *S) TestFileRouterIsACNameLister(c *gocheck.C) {
+	var _ router.CNameLister = &fileRouter{}
+}
+
+func (s *S) TestAddBackend(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	name, err := router.Retrieve("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(name, gocheck.Equals, "myapp")
+	content := s.readConfig(c)
+	c.Assert(strings.Contains(content, "server_name myapp.tsuru.io;"), gocheck.Equals, true)
+	c.Assert(s.executor.ExecutedCmd("nginx", []string{"-s", "reload"}), gocheck.Equals, true)
+}
+
+func (s *S) TestAddBackendDuplicated(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddBackend("myapp")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Could not add route: Backend already exists")
+}
+
+func (s *S) TestRemoveBackend(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.RemoveBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(strings.Contains(s.readConfig(c), "myapp"), gocheck.Equals, false)
+	_, err = router.Retrieve("myapp")
+	c.Assert(err, gocheck.NotNil)
+	_, err = getBackend("myapp")
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestAddRoute(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("myapp", "http://10.10.10.1:49153")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("myapp", "http://10.10.10.1:49153")
+	c.Assert(err, gocheck.IsNil)
+	routes, err := r.Routes("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(routes, gocheck.DeepEquals, []string{"http://10.10.10.1:49153"})
+	content := s.readConfig(c)
+	c.Assert(strings.Contains(content, "server 10.10.10.1:49153;"), gocheck.Equals, true)
+	c.Assert(strings.Contains(content, "proxy_pass http://myapp;"), gocheck.Equals, true)
+}
+
+func (s *S) TestAddRouteBackendNotFound(c *gocheck.C) {
+	err := router.Store("myapp", "myapp")
+	c.Assert(err, gocheck.IsNil)
+	r := fileRouter{}
+	err = r.AddRoute("myapp", "http://10.10.10.1:49153")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Could not add route: Backend not found")
+}
+
+func (s *S) TestRemoveRoute(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("myapp", "http://10.10.10.1:49153")
+	c.Assert(err, gocheck.IsNil)
+	err = r.RemoveRoute("myapp", "http://10.10.10.1:49153")
+	c.Assert(err, gocheck.IsNil)
+	routes, err := r.Routes("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(routes, gocheck.HasLen, 0)
+	c.Assert(strings.Contains(s.readConfig(c), "10.10.10.1"), gocheck.Equals, false)
+}
+
+func (s *S) TestRemoveRouteNotFound(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.RemoveRoute("myapp", "http://10.10.10.1:49153")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Could not remove route: Route not found")
+}
+
+func (s *S) TestSetCName(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("myapp", "http://10.10.10.1:49153")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("www.myapp.com", "myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("myapp.com", "myapp")
+	c.Assert(err, gocheck.IsNil)
+	cnames, err := r.CNames("myapp")
+	c.Assert(err, gocheck.IsNil)
+	expected := map[string][]string{
+		"myapp.com":     {"http://10.10.10.1:49153"},
+		"www.myapp.com": {"http://10.10.10.1:49153"},
+	}
+	c.Assert(cnames, gocheck.DeepEquals, expected)
+	content := s.readConfig(c)
+	c.Assert(strings.Contains(content, "server_name myapp.tsuru.io myapp.com www.myapp.com;"), gocheck.Equals, true)
+}
+
+func (s *S) TestSetCNameInvalid(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("myapp.tsuru.io", "myapp")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Could not setCName route: Invalid CNAME myapp.tsuru.io. You can't use Tsuru's application domain.")
+}
+
+func (s *S) TestUnsetCName(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("myapp.com", "myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.UnsetCName("myapp.com", "myapp")
+	c.Assert(err, gocheck.IsNil)
+	cnames, err := r.CNames("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(cnames, gocheck.HasLen, 0)
+	c.Assert(strings.Contains(s.readConfig(c), "myapp.com"), gocheck.Equals, false)
+	err = r.UnsetCName("myapp.com", "myapp")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Could not unsetCName route: CName not found")
+}
+
+func (s *S) TestAddr(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	addr, err := r.Addr("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(addr, gocheck.Equals, "myapp.tsuru.io")
+}
+
+func (s *S) TestSwap(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("myapp", "http://10.10.10.1:49153")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddBackend("myapp-new")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("myapp-new", "http://10.10.10.2:49153")
+	c.Assert(err, gocheck.IsNil)
+	err = r.Swap("myapp", "myapp-new")
+	c.Assert(err, gocheck.IsNil)
+	routes, err := r.Routes("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(routes, gocheck.DeepEquals, []string{"http://10.10.10.1:49153"})
+	addr, err := r.Addr("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(addr, gocheck.Equals, "myapp-new.tsuru.io")
+	b, err := getBackend("myapp-new")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(b.Routes, gocheck.DeepEquals, []string{"http://10.10.10.1:49153"})
+}
+
+func (s *S) TestWriteIsAtomic(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("myapp", "http://10.10.10.1:49153")
+	c.Assert(err, gocheck.IsNil)
+	entries, err := ioutil.ReadDir(filepath.Dir(s.configFile))
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(entries, gocheck.HasLen, 1)
+	c.Assert(entries[0].Name(), gocheck.Equals, "tsuru.conf")
+	c.Assert(entries[0].Mode().Perm(), gocheck.Equals, os.FileMode(0644))
+}
+
+func (s *S) TestReloadFailureRollsBack(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("myapp", "http://10.10.10.1:49153")
+	c.Assert(err, gocheck.IsNil)
+	before := s.readConfig(c)
+	execut = &etesting.ErrorExecutor{}
+	err = r.AddRoute("myapp", "http://10.10.10.2:49153")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(strings.HasPrefix(err.Error(), "Could not reload route: "), gocheck.Equals, true)
+	c.Assert(s.readConfig(c), gocheck.Equals, before)
+	routes, err := r.Routes("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(routes, gocheck.DeepEquals, []string{"http://10.10.10.1:49153"})
+}
+
+func (s *S) TestReloadFailureOnFirstBackendRemovesFile(c *gocheck.C) {
+	execut = &etesting.ErrorExecutor{}
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.NotNil)
+	_, err = os.Stat(s.configFile)
+	c.Assert(os.IsNotExist(err), gocheck.Equals, true)
+	_, err = getBackend("myapp")
+	c.Assert(err, gocheck.NotNil)
+	_, err = router.Retrieve("myapp")
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestReloadFailureOnRemoveBackendRestoresBackend(c *gocheck.C) {
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("myapp", "http://10.10.10.1:49153")
+	c.Assert(err, gocheck.IsNil)
+	execut = &etesting.ErrorExecutor{}
+	err = r.RemoveBackend("myapp")
+	c.Assert(err, gocheck.NotNil)
+	routes, err := r.Routes("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(routes, gocheck.DeepEquals, []string{"http://10.10.10.1:49153"})
+	c.Assert(strings.Contains(s.readConfig(c), "10.10.10.1:49153"), gocheck.Equals, true)
+}
+
+func (s *S) TestWithoutReloadCommand(c *gocheck.C) {
+	config.Unset("file-router:reload-command")
+	defer config.Set("file-router:reload-command", "nginx -s reload")
+	r := fileRouter{}
+	err := r.AddBackend("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(s.executor.GetCommands("nginx"), gocheck.HasLen, 0)
+}
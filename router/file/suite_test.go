@@ -0,0 +1,51 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package file
+
+import (
+	"github.com/globocom/config"
+	"github.com/globocom/tsuru/db"
+	etesting "github.com/globocom/tsuru/exec/testing"
+	"launchpad.net/gocheck"
+	"path/filepath"
+	"testing"
+)
+
+func Test(t *testing.T) { gocheck.TestingT(t) }
+
+type S struct {
+	conn       *db.Storage
+	configFile string
+	executor   *etesting.FakeExecutor
+}
+
+var _ = gocheck.Suite(&S{})
+
+func (s *S) SetUpSuite(c *gocheck.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "router_file_tests")
+	config.Set("file-router:domain", "tsuru.io")
+	config.Set("file-router:reload-command", "nginx -s reload")
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, gocheck.IsNil)
+}
+
+func (s *S) TearDownSuite(c *gocheck.C) {
+	s.conn.Collection("file_router_backends").Database.DropDatabase()
+}
+
+func (s *S) SetUpTest(c *gocheck.C) {
+	s.configFile = filepath.Join(c.MkDir(), "tsuru.conf")
+	config.Set("file-router:config-file", s.configFile)
+	s.executor = &etesting.FakeExecutor{}
+	execut = s.executor
+}
+
+func (s *S) TearDownTest(c *gocheck.C) {
+	execut = nil
+	s.conn.Collection("file_router_backends").DropCollection()
+	s.conn.Collection("routers").DropCollection()
+}
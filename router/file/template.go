@@ -0,0 +1,117 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/globocom/config"
+	"net/url"
+	"text/template"
+)
+
+const nginxTemplate = `# This file is generated by tsuru. Do not edit it.
+{{range .}}
+{{if .Servers}}upstream {{.Name}} {
+{{range .Servers}}    server {{.}};
+{{end}}}
+
+{{end}}server {
+    listen 80;
+    server_name {{.Host}}{{range .CNames}} {{.}}{{end}};
+{{if .Servers}}    location / {
+        proxy_pass http://{{.Name}};
+        proxy_set_header Host $host;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+    }
+{{else}}    return 503;
+{{end}}}
+{{end}}`
+
+const haproxyTemplate = `# This file is generated by tsuru. Do not edit it.
+frontend tsuru
+    bind *:80
+    mode http
+{{range .}}    acl host_{{.Name}} hdr(host) -i {{.Host}}{{range .CNames}} {{.}}{{end}}
+    use_backend {{.Name}} if host_{{.Name}}
+{{end}}{{range .}}
+backend {{.Name}}
+    mode http
+    balance roundrobin
+{{$name := .Name}}{{range $i, $server := .Servers}}    server {{$name}}-{{$i}} {{$server}} check
+{{end}}{{end}}`
+
+var builtinTemplates = map[string]string{
+	"nginx":   nginxTemplate,
+	"haproxy": haproxyTemplate,
+}
+
+// upstream is the view of a backend used by the templates.
+type upstream struct {
+	// Name is the name of the backend.
+	Name string
+
+	// Host is the address of the backend, in the tsuru domain.
+	Host string
+
+	// CNames is the list of cnames of the backend.
+	CNames []string
+
+	// Servers is the list of routes of the backend, as host:port pairs.
+	Servers []string
+}
+
+func getTemplate() (*template.Template, error) {
+	if path, err := config.GetString("file-router:template"); err == nil {
+		return template.ParseFiles(path)
+	}
+	format, err := config.GetString("file-router:format")
+	if err != nil {
+		format = "nginx"
+	}
+	text, ok := builtinTemplates[format]
+	if !ok {
+		return nil, fmt.Errorf("Unknown format: %q.", format)
+	}
+	return template.New(format).Parse(text)
+}
+
+// render renders the configuration file for the given backends.
+func render(backends []backend) ([]byte, error) {
+	domain, err := config.GetString("file-router:domain")
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := getTemplate()
+	if err != nil {
+		return nil, err
+	}
+	upstreams := make([]upstream, len(backends))
+	for i, b := range backends {
+		upstreams[i] = upstream{
+			Name:    b.Name,
+			Host:    b.Name + "." + domain,
+			CNames:  b.CNames,
+			Servers: make([]string, len(b.Routes)),
+		}
+		for j, route := range b.Routes {
+			upstreams[i].Servers[j] = hostPort(route)
+		}
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, upstreams); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// hostPort returns the host:port part of the given route, that may be an
+// URL (like http://10.10.10.10:49153) or a host:port pair.
+func hostPort(route string) string {
+	if u, err := url.Parse(route); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return route
+}
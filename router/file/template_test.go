@@ -0,0 +1,101 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package file
+
+import (
+	"github.com/globocom/config"
+	"io/ioutil"
+	"launchpad.net/gocheck"
+	"path/filepath"
+)
+
+var testBackends = []backend{
+	{
+		Name:   "myapp",
+		Routes: []string{"http://10.10.10.1:49153", "http://10.10.10.2:49154"},
+		CNames: []string{"myapp.com", "www.myapp.com"},
+	},
+	{Name: "other"},
+}
+
+func (s *S) TestRenderNginx(c *gocheck.C) {
+	content, err := render(testBackends)
+	c.Assert(err, gocheck.IsNil)
+	expected := `# This file is generated by tsuru. Do not edit it.
+
+upstream myapp {
+    server 10.10.10.1:49153;
+    server 10.10.10.2:49154;
+}
+
+server {
+    listen 80;
+    server_name myapp.tsuru.io myapp.com www.myapp.com;
+    location / {
+        proxy_pass http://myapp;
+        proxy_set_header Host $host;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+    }
+}
+
+server {
+    listen 80;
+    server_name other.tsuru.io;
+    return 503;
+}
+`
+	c.Assert(string(content), gocheck.Equals, expected)
+}
+
+func (s *S) TestRenderHAProxy(c *gocheck.C) {
+	config.Set("file-router:format", "haproxy")
+	defer config.Unset("file-router:format")
+	content, err := render(testBackends)
+	c.Assert(err, gocheck.IsNil)
+	expected := `# This file is generated by tsuru. Do not edit it.
+frontend tsuru
+    bind *:80
+    mode http
+    acl host_myapp hdr(host) -i myapp.tsuru.io myapp.com www.myapp.com
+    use_backend myapp if host_myapp
+    acl host_other hdr(host) -i other.tsuru.io
+    use_backend other if host_other
+
+backend myapp
+    mode http
+    balance roundrobin
+    server myapp-0 10.10.10.1:49153 check
+    server myapp-1 10.10.10.2:49154 check
+
+backend other
+    mode http
+    balance roundrobin
+`
+	c.Assert(string(content), gocheck.Equals, expected)
+}
+
+func (s *S) TestRenderUnknownFormat(c *gocheck.C) {
+	config.Set("file-router:format", "apache")
+	defer config.Unset("file-router:format")
+	_, err := render(testBackends)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, `Unknown format: "apache".`)
+}
+
+func (s *S) TestRenderCustomTemplate(c *gocheck.C) {
+	path := filepath.Join(c.MkDir(), "custom.tmpl")
+	err := ioutil.WriteFile(path, []byte("{{range .}}{{.Host}} {{len .Servers}}\n{{end}}"), 0644)
+	c.Assert(err, gocheck.IsNil)
+	config.Set("file-router:template", path)
+	defer config.Unset("file-router:template")
+	content, err := render(testBackends)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(content), gocheck.Equals, "myapp.tsuru.io 2\nother.tsuru.io 0\n")
+}
+
+func (s *S) TestHostPort(c *gocheck.C) {
+	c.Assert(hostPort("http://10.10.10.1:49153"), gocheck.Equals, "10.10.10.1:49153")
+	c.Assert(hostPort("10.10.10.1:49153"), gocheck.Equals, "10.10.10.1:49153")
+}
@@ -72,3 +72,23 @@ func (c *resultCommandConn) Do(cmd string, args ...interface{}) (interface{}, er
 	}
 	return c.reply[cmd], nil
 }
+
+// keyedResultConn replies according to the command and its first argument
+// (for example, "LRANGE frontend:myapp.golang.org"), falling back to the
+// reply of the command.
+type keyedResultConn struct {
+	*fakeConn
+	reply map[string]interface{}
+}
+
+func (c *keyedResultConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c.fakeConn.Do(cmd, args...)
+	if len(args) > 0 {
+		if key, ok := args[0].(string); ok {
+			if reply, ok := c.reply[cmd+" "+key]; ok {
+				return reply, nil
+			}
+		}
+	}
+	return c.reply[cmd], nil
+}
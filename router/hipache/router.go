@@ -66,6 +66,9 @@ func (r hipacheRouter) RemoveBackend(name string) error {
 	if err != nil {
 		return err
 	}
+	if err = r.removeSplits(name); err != nil {
+		return err
+	}
 	domain, err := config.GetString("hipache:domain")
 	if err != nil {
 		return &routeError{"remove", err}
@@ -81,16 +84,18 @@ func (r hipacheRouter) RemoveBackend(name string) error {
 	if err != nil {
 		return &routeError{"remove", err}
 	}
-	cname, err := r.getCName(backendName)
+	cnames, err := r.getCNames(backendName)
 	if err != nil {
 		return err
 	}
-	if cname == "" {
+	if len(cnames) == 0 {
 		return nil
 	}
-	_, err = conn.Do("DEL", "frontend:"+cname)
-	if err != nil {
-		return &routeError{"remove", err}
+	for _, cname := range cnames {
+		_, err = conn.Do("DEL", "frontend:"+cname)
+		if err != nil {
+			return &routeError{"remove", err}
+		}
 	}
 	_, err = conn.Do("DEL", "cname:"+backendName)
 	if err != nil {
@@ -114,15 +119,17 @@ func (r hipacheRouter) AddRoute(name, address string) error {
 		log.Errorf("error on add route for %s - %s", backendName, address)
 		return &routeError{"add", err}
 	}
-	cname, err := r.getCName(backendName)
+	cnames, err := r.getCNames(backendName)
 	if err != nil {
 		log.Errorf("error on get cname in add route for %s - %s", backendName, address)
 		return err
 	}
-	if cname == "" {
-		return nil
+	for _, cname := range cnames {
+		if err := r.addRoute("frontend:"+cname, address); err != nil {
+			return err
+		}
 	}
-	return r.addRoute("frontend:"+cname, address)
+	return r.refreshSplits(name, "")
 }
 
 func (hipacheRouter) addRoute(name, address string) error {
@@ -149,24 +156,45 @@ func (r hipacheRouter) RemoveRoute(name, address string) error {
 	if err := r.removeElement(frontend, address); err != nil {
 		return err
 	}
-	cname, err := r.getCName(backendName)
+	cnames, err := r.getCNames(backendName)
 	if err != nil {
 		return &routeError{"remove", err}
 	}
-	if cname == "" {
-		return nil
+	for _, cname := range cnames {
+		if err := r.removeElement("frontend:"+cname, address); err != nil {
+			return err
+		}
 	}
-	return r.removeElement("frontend:"+cname, address)
+	return r.refreshSplits(name, address)
 }
 
-func (hipacheRouter) getCName(name string) (string, error) {
+// getCNames returns the list of cnames of the given backend. They're stored
+// in a Redis set, in the key "cname:<backend>".
+//
+// Older versions of tsuru stored only one cname, as a string, in the same
+// key. getCNames converts these keys to sets as it finds them.
+func (hipacheRouter) getCNames(name string) ([]string, error) {
 	conn := connect()
 	defer conn.Close()
-	cname, err := redis.String(conn.Do("GET", "cname:"+name))
-	if err != nil && err != redis.ErrNil {
-		return "", &routeError{"getCName", err}
+	key := "cname:" + name
+	cnames, err := redis.Strings(conn.Do("SMEMBERS", key))
+	if err == nil || err == redis.ErrNil {
+		return cnames, nil
+	}
+	cname, gerr := redis.String(conn.Do("GET", key))
+	if gerr != nil {
+		return nil, &routeError{"getCName", gerr}
+	}
+	if cname == "" {
+		return nil, nil
 	}
-	return cname, nil
+	if _, err = conn.Do("DEL", key); err != nil {
+		return nil, &routeError{"getCName", err}
+	}
+	if _, err = conn.Do("SADD", key, cname); err != nil {
+		return nil, &routeError{"getCName", err}
+	}
+	return []string{cname}, nil
 }
 
 // validCName returns true if the cname is not a subdomain of
@@ -199,17 +227,15 @@ func (r hipacheRouter) SetCName(cname, name string) error {
 	if err != nil {
 		return &routeError{"get", err}
 	}
-	if oldCName, err := redis.String(conn.Do("GET", "cname:"+backendName)); err == nil && oldCName != "" {
-		err = r.UnsetCName(oldCName, backendName)
-		if err != nil {
-			return &routeError{"setCName", err}
-		}
-	}
-	_, err = conn.Do("SET", "cname:"+backendName, cname)
+	_, err = conn.Do("SADD", "cname:"+backendName, cname)
 	if err != nil {
 		return &routeError{"set", err}
 	}
 	frontend = "frontend:" + cname
+	_, err = conn.Do("DEL", frontend)
+	if err != nil {
+		return &routeError{"setCName", err}
+	}
 	for _, r := range routes {
 		_, err := conn.Do("RPUSH", frontend, r)
 		if err != nil {
@@ -226,7 +252,7 @@ func (r hipacheRouter) UnsetCName(cname, name string) error {
 	}
 	conn := connect()
 	defer conn.Close()
-	_, err = conn.Do("DEL", "cname:"+backendName)
+	_, err = conn.Do("SREM", "cname:"+backendName, cname)
 	if err != nil {
 		return &routeError{"unsetCName", err}
 	}
@@ -260,7 +286,7 @@ func (hipacheRouter) Addr(name string) (string, error) {
 	return fmt.Sprintf("%s.%s", backendName, domain), nil
 }
 
-func (hipacheRouter) Routes(name string) ([]string, error) {
+func (r hipacheRouter) Routes(name string) ([]string, error) {
 	backendName, err := router.Retrieve(name)
 	if err != nil {
 		return nil, err
@@ -272,11 +298,37 @@ func (hipacheRouter) Routes(name string) ([]string, error) {
 	frontend := "frontend:" + backendName + "." + domain
 	conn := connect()
 	defer conn.Close()
-	routes, err := redis.Strings(conn.Do("LRANGE", frontend, 0, -1))
+	routes, err := redis.Strings(conn.Do("LRANGE", frontend, 1, -1))
 	if err != nil {
 		return nil, &routeError{"routes", err}
 	}
-	return routes, nil
+	return r.withoutSplit(name, routes)
+}
+
+// CNames returns the cnames of the given backend, along with the routes of
+// each cname.
+func (r hipacheRouter) CNames(name string) (map[string][]string, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	cnames, err := r.getCNames(backendName)
+	if err != nil {
+		return nil, err
+	}
+	conn := connect()
+	defer conn.Close()
+	result := make(map[string][]string, len(cnames))
+	for _, cname := range cnames {
+		routes, err := redis.Strings(conn.Do("LRANGE", "frontend:"+cname, 1, -1))
+		if err != nil {
+			return nil, &routeError{"routes", err}
+		}
+		if result[cname], err = r.withoutSplit(name, routes); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
 }
 
 func (hipacheRouter) removeElement(name, address string) error {
@@ -293,6 +345,299 @@ func (r hipacheRouter) Swap(backend1, backend2 string) error {
 	return router.Swap(r, backend1, backend2)
 }
 
+// SwapRoutes exchanges the routes of the frontends of the given backends,
+// and of their cnames, in a single Redis transaction. The first element of
+// each frontend (the backend identifier) is kept in place.
+//
+// The frontends are watched while the transaction is built, so a route
+// added or removed during the swap makes it fail, instead of being lost.
+func (r hipacheRouter) SwapRoutes(backend1, backend2 string) error {
+	backendName1, err := router.Retrieve(backend1)
+	if err != nil {
+		return err
+	}
+	backendName2, err := router.Retrieve(backend2)
+	if err != nil {
+		return err
+	}
+	domain, err := config.GetString("hipache:domain")
+	if err != nil {
+		return &routeError{"swap", err}
+	}
+	cnames1, err := r.getCNames(backendName1)
+	if err != nil {
+		return err
+	}
+	cnames2, err := r.getCNames(backendName2)
+	if err != nil {
+		return err
+	}
+	frontend1 := "frontend:" + backendName1 + "." + domain
+	frontend2 := "frontend:" + backendName2 + "." + domain
+	conn := connect()
+	defer conn.Close()
+	if _, err = conn.Do("WATCH", frontend1, frontend2); err != nil {
+		return &routeError{"swap", err}
+	}
+	routes1, err := redis.Strings(conn.Do("LRANGE", frontend1, 0, -1))
+	if err != nil {
+		conn.Do("UNWATCH")
+		return &routeError{"swap", err}
+	}
+	routes2, err := redis.Strings(conn.Do("LRANGE", frontend2, 0, -1))
+	if err != nil {
+		conn.Do("UNWATCH")
+		return &routeError{"swap", err}
+	}
+	if len(routes1) == 0 || len(routes2) == 0 {
+		conn.Do("UNWATCH")
+		return &routeError{"swap", errRouteNotFound}
+	}
+	swapped1 := append([]string{routes1[0]}, routes2[1:]...)
+	swapped2 := append([]string{routes2[0]}, routes1[1:]...)
+	if _, err = conn.Do("MULTI"); err != nil {
+		conn.Do("UNWATCH")
+		return &routeError{"swap", err}
+	}
+	for _, key := range append([]string{frontend1}, prefixed("frontend:", cnames1)...) {
+		if err = replaceList(conn, key, swapped1); err != nil {
+			conn.Do("DISCARD")
+			return &routeError{"swap", err}
+		}
+	}
+	for _, key := range append([]string{frontend2}, prefixed("frontend:", cnames2)...) {
+		if err = replaceList(conn, key, swapped2); err != nil {
+			conn.Do("DISCARD")
+			return &routeError{"swap", err}
+		}
+	}
+	_, err = redis.Values(conn.Do("EXEC"))
+	if err == redis.ErrNil {
+		err = errors.New("routes changed during the swap")
+	}
+	if err != nil {
+		return &routeError{"swap", err}
+	}
+	return nil
+}
+
+// replaceList queues the commands that replace the content of the given
+// list.
+func replaceList(conn redis.Conn, key string, values []string) error {
+	if _, err := conn.Do("DEL", key); err != nil {
+		return err
+	}
+	args := make([]interface{}, len(values)+1)
+	args[0] = key
+	for i, value := range values {
+		args[i+1] = value
+	}
+	_, err := conn.Do("RPUSH", args...)
+	return err
+}
+
+func prefixed(prefix string, values []string) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = prefix + value
+	}
+	return result
+}
+
+// SplitTraffic sends weight percent of the traffic of the backend name to
+// the routes of the backend target. As Hipache balances the requests evenly
+// between the routes of a frontend, the routes of both backends are
+// repeated in the frontend of name (and in the frontends of its cnames),
+// in the proportion given by the weight.
+//
+// A weight of 0 removes the split.
+func (r hipacheRouter) SplitTraffic(name, target string, weight int) error {
+	old, err := router.RetrieveSplit(name)
+	if err != nil && err != router.ErrSplitNotFound {
+		return err
+	}
+	if weight == 0 {
+		if old == nil {
+			return router.ErrSplitNotFound
+		}
+		if err = r.renderSplit(name, old, nil, ""); err != nil {
+			return err
+		}
+		return router.RemoveSplit(name)
+	}
+	if err = router.StoreSplit(name, target, weight); err != nil {
+		return err
+	}
+	split := &router.Split{App: name, Target: target, Weight: weight}
+	if err = r.renderSplit(name, old, split, ""); err != nil {
+		if old != nil {
+			router.StoreSplit(old.App, old.Target, old.Weight)
+		} else {
+			router.RemoveSplit(name)
+		}
+		return err
+	}
+	return nil
+}
+
+// withoutSplit removes the routes of the split target of the backend name
+// from the given list of routes, along with the repeated routes.
+func (r hipacheRouter) withoutSplit(name string, routes []string) ([]string, error) {
+	split, err := router.RetrieveSplit(name)
+	if err == router.ErrSplitNotFound {
+		return routes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.ownRoutes(routes, split, "")
+}
+
+// ownRoutes returns the distinct routes of the given list that don't belong
+// to the target of the split, nor are equal to exclude.
+func (r hipacheRouter) ownRoutes(routes []string, split *router.Split, exclude string) ([]string, error) {
+	skip := map[string]bool{exclude: true}
+	if split != nil {
+		targetRoutes, err := r.backendRoutes(split.Target)
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range targetRoutes {
+			skip[route] = true
+		}
+	}
+	var own []string
+	for _, route := range routes {
+		if !skip[route] {
+			own = append(own, route)
+			skip[route] = true
+		}
+	}
+	return own, nil
+}
+
+// backendRoutes returns the routes in the frontend of the backend name,
+// without the backend identifier.
+func (hipacheRouter) backendRoutes(name string) ([]string, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	domain, err := config.GetString("hipache:domain")
+	if err != nil {
+		return nil, &routeError{"split", err}
+	}
+	conn := connect()
+	defer conn.Close()
+	routes, err := redis.Strings(conn.Do("LRANGE", "frontend:"+backendName+"."+domain, 1, -1))
+	if err != nil {
+		return nil, &routeError{"split", err}
+	}
+	return routes, nil
+}
+
+// renderSplit rewrites the frontend of the backend name, and the frontends
+// of its cnames, according to the next split. The previous split is used
+// for telling the routes of the backend from the routes of the previous
+// target. A nil next split sends all the traffic to the routes of the
+// backend.
+func (r hipacheRouter) renderSplit(name string, previous, next *router.Split, exclude string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	domain, err := config.GetString("hipache:domain")
+	if err != nil {
+		return &routeError{"split", err}
+	}
+	cnames, err := r.getCNames(backendName)
+	if err != nil {
+		return err
+	}
+	frontend := "frontend:" + backendName + "." + domain
+	conn := connect()
+	defer conn.Close()
+	current, err := redis.Strings(conn.Do("LRANGE", frontend, 0, -1))
+	if err != nil {
+		return &routeError{"split", err}
+	}
+	if len(current) == 0 {
+		return &routeError{"split", errRouteNotFound}
+	}
+	routes, err := r.ownRoutes(current[1:], previous, exclude)
+	if err != nil {
+		return err
+	}
+	if next != nil {
+		targetRoutes, err := r.backendRoutes(next.Target)
+		if err != nil {
+			return err
+		}
+		routes = router.WeightedRoutes(routes, targetRoutes, next.Weight)
+	}
+	routes = append([]string{current[0]}, routes...)
+	if _, err = conn.Do("MULTI"); err != nil {
+		return &routeError{"split", err}
+	}
+	for _, key := range append([]string{frontend}, prefixed("frontend:", cnames)...) {
+		if err = replaceList(conn, key, routes); err != nil {
+			conn.Do("DISCARD")
+			return &routeError{"split", err}
+		}
+	}
+	if _, err = conn.Do("EXEC"); err != nil {
+		return &routeError{"split", err}
+	}
+	return nil
+}
+
+// refreshSplits rewrites the frontends of the splits that involve the
+// backend name, after one of its routes changes. The route given in
+// removed is no longer part of any backend.
+func (r hipacheRouter) refreshSplits(name, removed string) error {
+	split, err := router.RetrieveSplit(name)
+	if err == nil {
+		if err = r.renderSplit(name, split, split, removed); err != nil {
+			return err
+		}
+	} else if err != router.ErrSplitNotFound {
+		return err
+	}
+	sources, err := router.SplitSources(name)
+	if err != nil {
+		return err
+	}
+	for _, source := range sources {
+		split, err := router.RetrieveSplit(source)
+		if err != nil {
+			return err
+		}
+		if err = r.renderSplit(source, split, split, removed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeSplits removes the split of the backend name, and the splits that
+// send traffic to it.
+func (r hipacheRouter) removeSplits(name string) error {
+	sources, err := router.SplitSources(name)
+	if err != nil {
+		return err
+	}
+	for _, source := range sources {
+		if err = r.SplitTraffic(source, name, 0); err != nil {
+			return err
+		}
+	}
+	err = router.RemoveSplit(name)
+	if err != nil && err != router.ErrSplitNotFound {
+		return err
+	}
+	return nil
+}
+
 type routeError struct {
 	op  string
 	err error
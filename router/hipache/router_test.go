@@ -92,20 +92,20 @@ func (s *S) TestAddBackend(c *gocheck.C) {
 }
 
 func (s *S) TestRemoveBackend(c *gocheck.C) {
-	reply := map[string]interface{}{"GET": ""}
+	reply := map[string]interface{}{"SMEMBERS": []interface{}{}}
 	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
 	router := hipacheRouter{}
 	err := router.RemoveBackend("tip")
 	c.Assert(err, gocheck.IsNil)
 	expected := []command{
 		{cmd: "DEL", args: []interface{}{"frontend:tip.golang.org"}},
-		{cmd: "GET", args: []interface{}{"cname:tip"}},
+		{cmd: "SMEMBERS", args: []interface{}{"cname:tip"}},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
 
 func (s *S) TestRemoveBackendAlsoRemovesRelatedCNameBackendAndControlRecord(c *gocheck.C) {
-	reply := map[string]interface{}{"GET": "mycname.com"}
+	reply := map[string]interface{}{"SMEMBERS": []interface{}{[]byte("mycname.com"), []byte("www.mycname.com")}}
 	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
 	router := hipacheRouter{}
 	err := router.AddBackend("tip")
@@ -115,8 +115,9 @@ func (s *S) TestRemoveBackendAlsoRemovesRelatedCNameBackendAndControlRecord(c *g
 	expected := []command{
 		{cmd: "RPUSH", args: []interface{}{"frontend:tip.golang.org", "tip"}},
 		{cmd: "DEL", args: []interface{}{"frontend:tip.golang.org"}},
-		{cmd: "GET", args: []interface{}{"cname:tip"}},
+		{cmd: "SMEMBERS", args: []interface{}{"cname:tip"}},
 		{cmd: "DEL", args: []interface{}{"frontend:mycname.com"}},
+		{cmd: "DEL", args: []interface{}{"frontend:www.mycname.com"}},
 		{cmd: "DEL", args: []interface{}{"cname:tip"}},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
@@ -130,13 +131,13 @@ func (s *S) TestAddRouteWithoutAssemblingFrontend(c *gocheck.C) {
 }
 
 func (s *S) TestAddRoute(c *gocheck.C) {
-	conn = &resultCommandConn{defaultReply: "", fakeConn: s.fake}
+	conn = &resultCommandConn{reply: map[string]interface{}{}, fakeConn: s.fake}
 	router := hipacheRouter{}
 	err := router.AddRoute("tip", "http://10.10.10.10:8080")
 	c.Assert(err, gocheck.IsNil)
 	expected := []command{
 		{cmd: "RPUSH", args: []interface{}{"frontend:tip.golang.org", "http://10.10.10.10:8080"}},
-		{cmd: "GET", args: []interface{}{"cname:tip"}},
+		{cmd: "SMEMBERS", args: []interface{}{"cname:tip"}},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
@@ -149,7 +150,7 @@ func (s *S) TestAddTwoRoutes(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 	expected := []command{
 		{cmd: "RPUSH", args: []interface{}{"frontend:tip.golang.org", "http://10.10.10.10:8081"}},
-		{cmd: "GET", args: []interface{}{"cname:tip"}},
+		{cmd: "SMEMBERS", args: []interface{}{"cname:tip"}},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
@@ -187,7 +188,7 @@ func (s *S) TestAddRouteCommandFailure(c *gocheck.C) {
 }
 
 func (s *S) TestAddRouteAlsoUpdatesCNameRecordsWhenExists(c *gocheck.C) {
-	reply := map[string]interface{}{"GET": "mycname.com", "SET": "", "LRANGE": []interface{}{[]byte("http://10.10.10.10:8080")}, "RPUSH": []interface{}{[]byte{}}}
+	reply := map[string]interface{}{"SMEMBERS": []interface{}{[]byte("mycname.com")}, "LRANGE": []interface{}{[]byte("http://10.10.10.10:8080")}}
 	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
 	router := hipacheRouter{}
 	err := router.AddRoute("tip", "http://10.10.10.10:8080")
@@ -198,23 +199,35 @@ func (s *S) TestAddRouteAlsoUpdatesCNameRecordsWhenExists(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 	expected := []command{
 		{cmd: "RPUSH", args: []interface{}{"frontend:tip.golang.org", "http://10.10.10.10:8080"}}, // AddRoute
-		{cmd: "GET", args: []interface{}{"cname:tip"}},                                            // AddRoute
-		{cmd: "RPUSH", args: []interface{}{"frontend:mycname.com", "http://10.10.10.10:8080"}},    // AddRoute, collateral due to fixed redis GET output
+		{cmd: "SMEMBERS", args: []interface{}{"cname:tip"}},                                       // AddRoute
+		{cmd: "RPUSH", args: []interface{}{"frontend:mycname.com", "http://10.10.10.10:8080"}},    // AddRoute, collateral due to fixed redis SMEMBERS output
 		{cmd: "LRANGE", args: []interface{}{"frontend:tip.golang.org", 0, -1}},                    // SetCName
-		{cmd: "GET", args: []interface{}{"cname:tip"}},                                            // SetCName, collateral effect due to GET mock
-		{cmd: "DEL", args: []interface{}{"cname:tip"}},                                            // SetCName
-		{cmd: "DEL", args: []interface{}{"frontend:mycname.com"}},                                 // SetCName, collateral effect due to GET mock
-		{cmd: "SET", args: []interface{}{"cname:tip", "mycname.com"}},                             // SetCName
+		{cmd: "SADD", args: []interface{}{"cname:tip", "mycname.com"}},                            // SetCName
+		{cmd: "DEL", args: []interface{}{"frontend:mycname.com"}},                                 // SetCName
 		{cmd: "RPUSH", args: []interface{}{"frontend:mycname.com", "http://10.10.10.10:8080"}},    // SetCName
 		{cmd: "RPUSH", args: []interface{}{"frontend:tip.golang.org", "http://10.10.10.11:8080"}}, // AddRoute
-		{cmd: "GET", args: []interface{}{"cname:tip"}},                                            // AddRoute
+		{cmd: "SMEMBERS", args: []interface{}{"cname:tip"}},                                       // AddRoute
 		{cmd: "RPUSH", args: []interface{}{"frontend:mycname.com", "http://10.10.10.11:8080"}},    // AddRoute
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
 
+func (s *S) TestAddRouteUpdatesAllCNames(c *gocheck.C) {
+	reply := map[string]interface{}{"SMEMBERS": []interface{}{[]byte("mycname.com"), []byte("www.mycname.com")}}
+	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
+	err := hipacheRouter{}.AddRoute("tip", "http://10.10.10.10:8080")
+	c.Assert(err, gocheck.IsNil)
+	expected := []command{
+		{cmd: "RPUSH", args: []interface{}{"frontend:tip.golang.org", "http://10.10.10.10:8080"}},
+		{cmd: "SMEMBERS", args: []interface{}{"cname:tip"}},
+		{cmd: "RPUSH", args: []interface{}{"frontend:mycname.com", "http://10.10.10.10:8080"}},
+		{cmd: "RPUSH", args: []interface{}{"frontend:www.mycname.com", "http://10.10.10.10:8080"}},
+	}
+	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
+}
+
 func (s *S) TestRemoveRoute(c *gocheck.C) {
-	reply := map[string]interface{}{"GET": "", "LRANGE": []interface{}{[]byte("10.10.10.11")}}
+	reply := map[string]interface{}{"LRANGE": []interface{}{[]byte("10.10.10.11")}}
 	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
 	router := hipacheRouter{}
 	err := router.AddBackend("tip")
@@ -224,7 +237,7 @@ func (s *S) TestRemoveRoute(c *gocheck.C) {
 	expected := []command{
 		{cmd: "RPUSH", args: []interface{}{"frontend:tip.golang.org", "tip"}},
 		{cmd: "LREM", args: []interface{}{"frontend:tip.golang.org", 0, "tip.golang.org"}},
-		{cmd: "GET", args: []interface{}{"cname:tip"}},
+		{cmd: "SMEMBERS", args: []interface{}{"cname:tip"}},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
@@ -262,7 +275,7 @@ func (s *S) TestRemoveRouteCommandFailure(c *gocheck.C) {
 }
 
 func (s *S) TestRemoveRouteAlsoRemovesRespectiveCNameRecord(c *gocheck.C) {
-	reply := map[string]interface{}{"GET": "tip.cname.com", "LRANGE": []interface{}{[]byte("10.10.10.11")}}
+	reply := map[string]interface{}{"SMEMBERS": []interface{}{[]byte("tip.cname.com"), []byte("www.tip.cname.com")}, "LRANGE": []interface{}{[]byte("10.10.10.11")}}
 	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
 	router := hipacheRouter{}
 	err := router.AddBackend("tip")
@@ -272,29 +285,59 @@ func (s *S) TestRemoveRouteAlsoRemovesRespectiveCNameRecord(c *gocheck.C) {
 	expected := []command{
 		{cmd: "RPUSH", args: []interface{}{"frontend:tip.golang.org", "tip"}},
 		{cmd: "LREM", args: []interface{}{"frontend:tip.golang.org", 0, "tip.golang.org"}},
-		{cmd: "GET", args: []interface{}{"cname:tip"}},
+		{cmd: "SMEMBERS", args: []interface{}{"cname:tip"}},
 		{cmd: "LREM", args: []interface{}{"frontend:tip.cname.com", 0, "tip.golang.org"}},
+		{cmd: "LREM", args: []interface{}{"frontend:www.tip.cname.com", 0, "tip.golang.org"}},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
 
-func (s *S) TestGetCName(c *gocheck.C) {
-	conn = &resultCommandConn{defaultReply: "coolcname.com", fakeConn: s.fake}
-	cname, err := hipacheRouter{}.getCName("myapp")
+func (s *S) TestGetCNames(c *gocheck.C) {
+	conn = &resultCommandConn{defaultReply: []interface{}{[]byte("coolcname.com"), []byte("www.coolcname.com")}, fakeConn: s.fake}
+	cnames, err := hipacheRouter{}.getCNames("myapp")
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(cname, gocheck.Equals, "coolcname.com")
+	c.Assert(cnames, gocheck.DeepEquals, []string{"coolcname.com", "www.coolcname.com"})
 	expected := []command{
-		{cmd: "GET", args: []interface{}{"cname:myapp"}},
+		{cmd: "SMEMBERS", args: []interface{}{"cname:myapp"}},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
 
-func (s *S) TestGetCNameIgnoresErrNil(c *gocheck.C) {
-	reply := map[string]interface{}{"GET": nil}
+func (s *S) TestGetCNamesIgnoresErrNil(c *gocheck.C) {
+	reply := map[string]interface{}{"SMEMBERS": nil}
+	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
+	cnames, err := hipacheRouter{}.getCNames("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(cnames, gocheck.HasLen, 0)
+}
+
+func (s *S) TestGetCNamesReturnsTheErrorOfGet(c *gocheck.C) {
+	reply := map[string]interface{}{
+		"SMEMBERS": redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"),
+		"GET":      redis.Error("ERR connection lost"),
+	}
+	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
+	_, err := hipacheRouter{}.getCNames("myapp")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Could not getCName route: ERR connection lost")
+}
+
+func (s *S) TestGetCNamesConvertsLegacyStringKey(c *gocheck.C) {
+	reply := map[string]interface{}{
+		"SMEMBERS": redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"),
+		"GET":      "coolcname.com",
+	}
 	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
-	cname, err := hipacheRouter{}.getCName("myapp")
+	cnames, err := hipacheRouter{}.getCNames("myapp")
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(cname, gocheck.Equals, "")
+	c.Assert(cnames, gocheck.DeepEquals, []string{"coolcname.com"})
+	expected := []command{
+		{cmd: "SMEMBERS", args: []interface{}{"cname:myapp"}},
+		{cmd: "GET", args: []interface{}{"cname:myapp"}},
+		{cmd: "DEL", args: []interface{}{"cname:myapp"}},
+		{cmd: "SADD", args: []interface{}{"cname:myapp", "coolcname.com"}},
+	}
+	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
 
 func (s *S) TestSetCName(c *gocheck.C) {
@@ -307,15 +350,15 @@ func (s *S) TestSetCName(c *gocheck.C) {
 	expected := []command{
 		{cmd: "RPUSH", args: []interface{}{"frontend:myapp.golang.org", "myapp"}},
 		{cmd: "LRANGE", args: []interface{}{"frontend:myapp.golang.org", 0, -1}},
-		{cmd: "GET", args: []interface{}{"cname:myapp"}},
-		{cmd: "SET", args: []interface{}{"cname:myapp", "myapp.com"}},
+		{cmd: "SADD", args: []interface{}{"cname:myapp", "myapp.com"}},
+		{cmd: "DEL", args: []interface{}{"frontend:myapp.com"}},
 		{cmd: "RPUSH", args: []interface{}{"frontend:myapp.com", "10.10.10.10"}},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
 
 func (s *S) TestSetCNameWithPreviousRoutes(c *gocheck.C) {
-	reply := map[string]interface{}{"GET": "", "SET": "", "LRANGE": []interface{}{[]byte("10.10.10.10"), []byte("10.10.10.11")}, "RPUSH": []interface{}{[]byte{}}}
+	reply := map[string]interface{}{"LRANGE": []interface{}{[]byte("10.10.10.10"), []byte("10.10.10.11")}}
 	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
 	router := hipacheRouter{}
 	err := router.AddBackend("myapp")
@@ -329,59 +372,39 @@ func (s *S) TestSetCNameWithPreviousRoutes(c *gocheck.C) {
 	expected := []command{
 		{cmd: "RPUSH", args: []interface{}{"frontend:myapp.golang.org", "myapp"}},       // AddBackend call
 		{cmd: "RPUSH", args: []interface{}{"frontend:myapp.golang.org", "10.10.10.10"}}, // AddRoute call
-		{cmd: "GET", args: []interface{}{"cname:myapp"}},                                // AddRoute call
+		{cmd: "SMEMBERS", args: []interface{}{"cname:myapp"}},                           // AddRoute call
 		{cmd: "RPUSH", args: []interface{}{"frontend:myapp.golang.org", "10.10.10.11"}}, // AddRoute call
-		{cmd: "GET", args: []interface{}{"cname:myapp"}},                                // AddRoute call
+		{cmd: "SMEMBERS", args: []interface{}{"cname:myapp"}},                           // AddRoute call
 		{cmd: "LRANGE", args: []interface{}{"frontend:myapp.golang.org", 0, -1}},
-		{cmd: "GET", args: []interface{}{"cname:myapp"}},
-		{cmd: "SET", args: []interface{}{"cname:myapp", "mycname.com"}},
+		{cmd: "SADD", args: []interface{}{"cname:myapp", "mycname.com"}},
+		{cmd: "DEL", args: []interface{}{"frontend:mycname.com"}},
 		{cmd: "RPUSH", args: []interface{}{"frontend:mycname.com", "10.10.10.10"}},
 		{cmd: "RPUSH", args: []interface{}{"frontend:mycname.com", "10.10.10.11"}},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
 
-func (s *S) TestSetCNameShouldRecordAppAndCNameOnRedis(c *gocheck.C) {
-	conn = &resultCommandConn{defaultReply: []interface{}{[]byte("mycname.com")}, fakeConn: s.fake}
-	router := hipacheRouter{}
-	err := router.AddBackend("myapp")
-	c.Assert(err, gocheck.IsNil)
-	err = router.SetCName("mycname.com", "myapp")
-	c.Assert(err, gocheck.IsNil)
-	expected := command{cmd: "SET", args: []interface{}{"cname:myapp", "mycname.com"}}
-	c.Assert(s.fake.cmds[3], gocheck.DeepEquals, expected)
-}
-
-func (s *S) TestSetCNameRemovesPreviousDefinedCNamesAndKeepItsRoutes(c *gocheck.C) {
-	reply := map[string]interface{}{"GET": "mycname.com", "SET": "", "LRANGE": []interface{}{[]byte("10.10.10.10")}, "RPUSH": []interface{}{[]byte{}}}
+func (s *S) TestSetCNameKeepsPreviouslyDefinedCNames(c *gocheck.C) {
+	reply := map[string]interface{}{"LRANGE": []interface{}{[]byte("10.10.10.10")}}
 	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
 	router := hipacheRouter{}
 	err := router.AddBackend("myapp")
 	c.Assert(err, gocheck.IsNil)
-	err = router.AddRoute("myapp", "10.10.10.10")
-	c.Assert(err, gocheck.IsNil)
 	err = router.SetCName("mycname.com", "myapp")
 	c.Assert(err, gocheck.IsNil)
 	err = router.SetCName("myothercname.com", "myapp")
+	c.Assert(err, gocheck.IsNil)
 	expected := []command{
 		{cmd: "RPUSH", args: []interface{}{"frontend:myapp.golang.org", "myapp"}},
-		// addroute
-		{cmd: "RPUSH", args: []interface{}{"frontend:myapp.golang.org", "10.10.10.10"}},
-		{cmd: "GET", args: []interface{}{"cname:myapp"}},
-		{cmd: "RPUSH", args: []interface{}{"frontend:mycname.com", "10.10.10.10"}}, // collateral effect due to GET mock
 		// first setcname
 		{cmd: "LRANGE", args: []interface{}{"frontend:myapp.golang.org", 0, -1}},
-		{cmd: "GET", args: []interface{}{"cname:myapp"}},
-		{cmd: "DEL", args: []interface{}{"cname:myapp"}},          // collateral effect due to GET mock
-		{cmd: "DEL", args: []interface{}{"frontend:mycname.com"}}, // collateral effect due to GET mock
-		{cmd: "SET", args: []interface{}{"cname:myapp", "mycname.com"}},
+		{cmd: "SADD", args: []interface{}{"cname:myapp", "mycname.com"}},
+		{cmd: "DEL", args: []interface{}{"frontend:mycname.com"}},
 		{cmd: "RPUSH", args: []interface{}{"frontend:mycname.com", "10.10.10.10"}},
 		// second setcname
 		{cmd: "LRANGE", args: []interface{}{"frontend:myapp.golang.org", 0, -1}},
-		{cmd: "GET", args: []interface{}{"cname:myapp"}},
-		{cmd: "DEL", args: []interface{}{"cname:myapp"}},
-		{cmd: "DEL", args: []interface{}{"frontend:mycname.com"}},
-		{cmd: "SET", args: []interface{}{"cname:myapp", "myothercname.com"}},
+		{cmd: "SADD", args: []interface{}{"cname:myapp", "myothercname.com"}},
+		{cmd: "DEL", args: []interface{}{"frontend:myothercname.com"}},
 		{cmd: "RPUSH", args: []interface{}{"frontend:myothercname.com", "10.10.10.10"}},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
@@ -402,12 +425,18 @@ func (s *S) TestUnsetCName(c *gocheck.C) {
 	err := hipacheRouter{}.UnsetCName("myapp.com", "myapp")
 	c.Assert(err, gocheck.IsNil)
 	expected := []command{
-		{cmd: "DEL", args: []interface{}{"cname:myapp"}},
+		{cmd: "SREM", args: []interface{}{"cname:myapp", "myapp.com"}},
 		{cmd: "DEL", args: []interface{}{"frontend:myapp.com"}},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
 
+func (s *S) TestHipacheRouterIsNotATLSRouter(c *gocheck.C) {
+	var r router.Router = hipacheRouter{}
+	_, ok := r.(router.TLSRouter)
+	c.Assert(ok, gocheck.Equals, false)
+}
+
 func (s *S) TestAddr(c *gocheck.C) {
 	conn = &resultCommandConn{defaultReply: []interface{}{[]byte("10.10.10.10:8080")}, fakeConn: s.fake}
 	addr, err := hipacheRouter{}.Addr("tip")
@@ -475,7 +504,7 @@ func (s *S) TestRemoveElement(c *gocheck.C) {
 }
 
 func (s *S) TestRoutes(c *gocheck.C) {
-	reply := map[string]interface{}{"GET": "tip", "SET": "", "LRANGE": []interface{}{[]byte("http://10.10.10.10:8080")}}
+	reply := map[string]interface{}{"LRANGE": []interface{}{[]byte("http://10.10.10.10:8080")}}
 	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
 	router := hipacheRouter{}
 	err := router.AddRoute("tip", "http://10.10.10.10:8080")
@@ -483,6 +512,28 @@ func (s *S) TestRoutes(c *gocheck.C) {
 	routes, err := router.Routes("tip")
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(routes, gocheck.DeepEquals, []string{"http://10.10.10.10:8080"})
+	cmd := command{cmd: "LRANGE", args: []interface{}{"frontend:tip.golang.org", 1, -1}}
+	c.Assert(s.fake.cmds[len(s.fake.cmds)-1], gocheck.DeepEquals, cmd)
+}
+
+func (s *S) TestHipacheRouterIsACNameLister(c *gocheck.C) {
+	var _ router.CNameLister = hipacheRouter{}
+}
+
+func (s *S) TestCNames(c *gocheck.C) {
+	reply := map[string]interface{}{
+		"SMEMBERS": []interface{}{[]byte("mycname.com")},
+		"LRANGE":   []interface{}{[]byte("http://10.10.10.10:8080")},
+	}
+	conn = &resultCommandConn{reply: reply, fakeConn: s.fake}
+	cnames, err := hipacheRouter{}.CNames("tip")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(cnames, gocheck.DeepEquals, map[string][]string{"mycname.com": {"http://10.10.10.10:8080"}})
+	expected := []command{
+		{cmd: "SMEMBERS", args: []interface{}{"cname:tip"}},
+		{cmd: "LRANGE", args: []interface{}{"frontend:mycname.com", 1, -1}},
+	}
+	c.Assert(s.fake.cmds, gocheck.DeepEquals, expected)
 }
 
 func (s *S) TestSwap(c *gocheck.C) {
@@ -497,25 +548,242 @@ func (s *S) TestSwap(c *gocheck.C) {
 	router.AddRoute(backend1, "http://127.0.0.1")
 	router.AddBackend(backend2)
 	router.AddRoute(backend2, "http://10.10.10.10")
+	s.fake.cmds = nil
+	conn = &keyedResultConn{
+		reply: map[string]interface{}{
+			"LRANGE frontend:b1.golang.org": []interface{}{[]byte("b1"), []byte("http://127.0.0.1")},
+			"LRANGE frontend:b2.golang.org": []interface{}{[]byte("b2"), []byte("http://10.10.10.10")},
+			"EXEC":                          []interface{}{},
+		},
+		fakeConn: s.fake,
+	}
 	err := router.Swap(backend1, backend2)
 	c.Assert(err, gocheck.IsNil)
 	cmds := []command{
-		{cmd: "RPUSH", args: []interface{}{"frontend:b1.golang.org", "b1"}},
-		{cmd: "RPUSH", args: []interface{}{"frontend:b1.golang.org", "http://127.0.0.1"}},
-		{cmd: "GET", args: []interface{}{"cname:b1"}},
-		{cmd: "RPUSH", args: []interface{}{"frontend:b2.golang.org", "b2"}},
-		{cmd: "RPUSH", args: []interface{}{"frontend:b2.golang.org", "http://10.10.10.10"}},
-		{cmd: "GET", args: []interface{}{"cname:b2"}},
+		{cmd: "SMEMBERS", args: []interface{}{"cname:b1"}},
+		{cmd: "SMEMBERS", args: []interface{}{"cname:b2"}},
+		{cmd: "WATCH", args: []interface{}{"frontend:b1.golang.org", "frontend:b2.golang.org"}},
 		{cmd: "LRANGE", args: []interface{}{"frontend:b1.golang.org", 0, -1}},
 		{cmd: "LRANGE", args: []interface{}{"frontend:b2.golang.org", 0, -1}},
-		{cmd: "RPUSH", args: []interface{}{"frontend:b2.golang.org", "http://127.0.0.1"}},
-		{cmd: "GET", args: []interface{}{"cname:b2"}},
-		{cmd: "LREM", args: []interface{}{"frontend:b1.golang.org", 0, "http://127.0.0.1"}},
-		{cmd: "GET", args: []interface{}{"cname:b1"}},
-		{cmd: "RPUSH", args: []interface{}{"frontend:b1.golang.org", "http://127.0.0.1"}},
-		{cmd: "GET", args: []interface{}{"cname:b1"}},
-		{cmd: "LREM", args: []interface{}{"frontend:b2.golang.org", 0, "http://127.0.0.1"}},
-		{cmd: "GET", args: []interface{}{"cname:b2"}},
+		{cmd: "MULTI", args: []interface{}(nil)},
+		{cmd: "DEL", args: []interface{}{"frontend:b1.golang.org"}},
+		{cmd: "RPUSH", args: []interface{}{"frontend:b1.golang.org", "b1", "http://10.10.10.10"}},
+		{cmd: "DEL", args: []interface{}{"frontend:b2.golang.org"}},
+		{cmd: "RPUSH", args: []interface{}{"frontend:b2.golang.org", "b2", "http://127.0.0.1"}},
+		{cmd: "EXEC", args: []interface{}(nil)},
+	}
+	c.Assert(s.fake.cmds, gocheck.DeepEquals, cmds)
+}
+
+func (s *S) TestHipacheRouterIsARouteSwapper(c *gocheck.C) {
+	var r interface{} = hipacheRouter{}
+	_, ok := r.(router.RouteSwapper)
+	c.Assert(ok, gocheck.Equals, true)
+}
+
+func (s *S) TestSwapRoutesUpdatesCNames(c *gocheck.C) {
+	err := router.Store("sw1", "sw1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sw1")
+	err = router.Store("sw2", "sw2")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sw2")
+	conn = &keyedResultConn{
+		reply: map[string]interface{}{
+			"SMEMBERS cname:sw1":             []interface{}{[]byte("myapp.com")},
+			"LRANGE frontend:sw1.golang.org": []interface{}{[]byte("sw1"), []byte("http://127.0.0.1")},
+			"LRANGE frontend:sw2.golang.org": []interface{}{[]byte("sw2"), []byte("http://10.10.10.10"), []byte("http://10.10.10.11")},
+			"EXEC":                           []interface{}{},
+		},
+		fakeConn: s.fake,
+	}
+	err = hipacheRouter{}.SwapRoutes("sw1", "sw2")
+	c.Assert(err, gocheck.IsNil)
+	cmds := []command{
+		{cmd: "DEL", args: []interface{}{"frontend:sw1.golang.org"}},
+		{cmd: "RPUSH", args: []interface{}{"frontend:sw1.golang.org", "sw1", "http://10.10.10.10", "http://10.10.10.11"}},
+		{cmd: "DEL", args: []interface{}{"frontend:myapp.com"}},
+		{cmd: "RPUSH", args: []interface{}{"frontend:myapp.com", "sw1", "http://10.10.10.10", "http://10.10.10.11"}},
+		{cmd: "DEL", args: []interface{}{"frontend:sw2.golang.org"}},
+		{cmd: "RPUSH", args: []interface{}{"frontend:sw2.golang.org", "sw2", "http://127.0.0.1"}},
+		{cmd: "EXEC", args: []interface{}(nil)},
+	}
+	c.Assert(s.fake.cmds[6:], gocheck.DeepEquals, cmds)
+}
+
+func (s *S) TestSwapRoutesFailsWhenFrontendsChange(c *gocheck.C) {
+	err := router.Store("sw1", "sw1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sw1")
+	err = router.Store("sw2", "sw2")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sw2")
+	conn = &keyedResultConn{
+		reply: map[string]interface{}{
+			"LRANGE frontend:sw1.golang.org": []interface{}{[]byte("sw1"), []byte("http://127.0.0.1")},
+			"LRANGE frontend:sw2.golang.org": []interface{}{[]byte("sw2"), []byte("http://10.10.10.10")},
+		},
+		fakeConn: s.fake,
+	}
+	err = hipacheRouter{}.Swap("sw1", "sw2")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "Could not swap route: routes changed during the swap")
+	name, err := router.Retrieve("sw1")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(name, gocheck.Equals, "sw1")
+}
+
+func (s *S) TestSwapRoutesFailsWhenBackendIsMissing(c *gocheck.C) {
+	err := router.Store("sw1", "sw1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sw1")
+	err = router.Store("sw2", "sw2")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sw2")
+	conn = &keyedResultConn{
+		reply: map[string]interface{}{
+			"LRANGE frontend:sw1.golang.org": []interface{}{[]byte("sw1")},
+			"LRANGE frontend:sw2.golang.org": []interface{}{},
+		},
+		fakeConn: s.fake,
+	}
+	err = hipacheRouter{}.SwapRoutes("sw1", "sw2")
+	c.Assert(err, gocheck.NotNil)
+	last := s.fake.cmds[len(s.fake.cmds)-1]
+	c.Assert(last.cmd, gocheck.Equals, "UNWATCH")
+}
+
+func (s *S) TestHipacheRouterIsATrafficSplitter(c *gocheck.C) {
+	var r interface{} = hipacheRouter{}
+	_, ok := r.(router.TrafficSplitter)
+	c.Assert(ok, gocheck.Equals, true)
+}
+
+func (s *S) TestSplitTraffic(c *gocheck.C) {
+	err := router.Store("sp1", "sp1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sp1")
+	err = router.Store("sp2", "sp2")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sp2")
+	conn = &keyedResultConn{
+		reply: map[string]interface{}{
+			"SMEMBERS cname:sp1":             []interface{}{[]byte("myapp.com")},
+			"LRANGE frontend:sp1.golang.org": []interface{}{[]byte("sp1"), []byte("http://10.10.10.1")},
+			"LRANGE frontend:sp2.golang.org": []interface{}{[]byte("http://10.10.10.2")},
+		},
+		fakeConn: s.fake,
+	}
+	err = hipacheRouter{}.SplitTraffic("sp1", "sp2", 50)
+	c.Assert(err, gocheck.IsNil)
+	defer router.RemoveSplit("sp1")
+	cmds := []command{
+		{cmd: "SMEMBERS", args: []interface{}{"cname:sp1"}},
+		{cmd: "LRANGE", args: []interface{}{"frontend:sp1.golang.org", 0, -1}},
+		{cmd: "LRANGE", args: []interface{}{"frontend:sp2.golang.org", 1, -1}},
+		{cmd: "MULTI", args: []interface{}(nil)},
+		{cmd: "DEL", args: []interface{}{"frontend:sp1.golang.org"}},
+		{cmd: "RPUSH", args: []interface{}{"frontend:sp1.golang.org", "sp1", "http://10.10.10.1", "http://10.10.10.2"}},
+		{cmd: "DEL", args: []interface{}{"frontend:myapp.com"}},
+		{cmd: "RPUSH", args: []interface{}{"frontend:myapp.com", "sp1", "http://10.10.10.1", "http://10.10.10.2"}},
+		{cmd: "EXEC", args: []interface{}(nil)},
 	}
 	c.Assert(s.fake.cmds, gocheck.DeepEquals, cmds)
+	split, err := router.RetrieveSplit("sp1")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(*split, gocheck.DeepEquals, router.Split{App: "sp1", Target: "sp2", Weight: 50})
+}
+
+func (s *S) TestSplitTrafficInvalidWeight(c *gocheck.C) {
+	err := hipacheRouter{}.SplitTraffic("sp1", "sp2", 100)
+	c.Assert(err, gocheck.Equals, router.ErrInvalidWeight)
+	c.Assert(s.fake.cmds, gocheck.HasLen, 0)
+}
+
+func (s *S) TestSplitTrafficRemove(c *gocheck.C) {
+	err := router.Store("sp1", "sp1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sp1")
+	err = router.Store("sp2", "sp2")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sp2")
+	err = router.StoreSplit("sp1", "sp2", 10)
+	c.Assert(err, gocheck.IsNil)
+	defer router.RemoveSplit("sp1")
+	conn = &keyedResultConn{
+		reply: map[string]interface{}{
+			"LRANGE frontend:sp1.golang.org": []interface{}{
+				[]byte("sp1"), []byte("http://10.10.10.1"), []byte("http://10.10.10.1"), []byte("http://10.10.10.2"),
+			},
+			"LRANGE frontend:sp2.golang.org": []interface{}{[]byte("http://10.10.10.2")},
+		},
+		fakeConn: s.fake,
+	}
+	err = hipacheRouter{}.SplitTraffic("sp1", "", 0)
+	c.Assert(err, gocheck.IsNil)
+	expected := command{cmd: "RPUSH", args: []interface{}{"frontend:sp1.golang.org", "sp1", "http://10.10.10.1"}}
+	c.Assert(s.fake.cmds[len(s.fake.cmds)-2], gocheck.DeepEquals, expected)
+	_, err = router.RetrieveSplit("sp1")
+	c.Assert(err, gocheck.Equals, router.ErrSplitNotFound)
+}
+
+func (s *S) TestSplitTrafficRemoveNotFound(c *gocheck.C) {
+	err := hipacheRouter{}.SplitTraffic("sp1", "", 0)
+	c.Assert(err, gocheck.Equals, router.ErrSplitNotFound)
+}
+
+func (s *S) TestRoutesWithSplit(c *gocheck.C) {
+	err := router.Store("sp1", "sp1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sp1")
+	err = router.Store("sp2", "sp2")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sp2")
+	err = router.StoreSplit("sp1", "sp2", 50)
+	c.Assert(err, gocheck.IsNil)
+	defer router.RemoveSplit("sp1")
+	conn = &keyedResultConn{
+		reply: map[string]interface{}{
+			"LRANGE frontend:sp1.golang.org": []interface{}{
+				[]byte("http://10.10.10.1"), []byte("http://10.10.10.3"), []byte("http://10.10.10.2"),
+			},
+			"LRANGE frontend:sp2.golang.org": []interface{}{[]byte("http://10.10.10.2")},
+		},
+		fakeConn: s.fake,
+	}
+	routes, err := hipacheRouter{}.Routes("sp1")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(routes, gocheck.DeepEquals, []string{"http://10.10.10.1", "http://10.10.10.3"})
+}
+
+func (s *S) TestAddRouteToSplitTargetRefreshesSource(c *gocheck.C) {
+	err := router.Store("sp1", "sp1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sp1")
+	err = router.Store("sp2", "sp2")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sp2")
+	err = router.StoreSplit("sp1", "sp2", 50)
+	c.Assert(err, gocheck.IsNil)
+	defer router.RemoveSplit("sp1")
+	conn = &keyedResultConn{
+		reply: map[string]interface{}{
+			"LRANGE frontend:sp1.golang.org": []interface{}{
+				[]byte("sp1"), []byte("http://10.10.10.1"), []byte("http://10.10.10.2"),
+			},
+			"LRANGE frontend:sp2.golang.org": []interface{}{[]byte("http://10.10.10.2"), []byte("http://10.10.10.3")},
+		},
+		fakeConn: s.fake,
+	}
+	err = hipacheRouter{}.AddRoute("sp2", "http://10.10.10.3")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(s.fake.cmds[0], gocheck.DeepEquals, command{cmd: "RPUSH", args: []interface{}{"frontend:sp2.golang.org", "http://10.10.10.3"}})
+	expected := command{
+		cmd: "RPUSH",
+		args: []interface{}{
+			"frontend:sp1.golang.org", "sp1", "http://10.10.10.1", "http://10.10.10.1",
+			"http://10.10.10.2", "http://10.10.10.3",
+		},
+	}
+	c.Assert(s.fake.cmds[len(s.fake.cmds)-2], gocheck.DeepEquals, expected)
 }
@@ -46,6 +46,42 @@ type Router interface {
 	Routes(name string) ([]string, error)
 }
 
+// TLSRouter is a router that is able to serve cnames using HTTPS. Routers
+// that support TLS should implement this interface, in addition to Router.
+type TLSRouter interface {
+	Router
+
+	// AddCertificate adds the certificate and private key used to serve
+	// the given cname. Both certificate and key are PEM encoded. Adding a
+	// certificate to a cname that already has one replaces it.
+	AddCertificate(cname, certificate, key string) error
+
+	// RemoveCertificate removes the certificate of the given cname.
+	RemoveCertificate(cname string) error
+}
+
+// RouteSwapper is a router that is able to exchange the routes of two
+// backends, and of their cnames, in a single atomic operation. Swap uses it
+// when available, instead of moving the routes one at a time.
+type RouteSwapper interface {
+	Router
+
+	// SwapRoutes exchanges the routes of the given backends. Calling it
+	// twice with the same backends must restore the original routes.
+	SwapRoutes(backend1, backend2 string) error
+}
+
+// CNameLister is a router that is able to list the cnames of a backend,
+// along with their routes. It's used for checking the consistency of the
+// router.
+type CNameLister interface {
+	Router
+
+	// CNames returns the cnames of a backend, mapped to the routes of
+	// each cname.
+	CNames(name string) (map[string][]string, error)
+}
+
 func collection() (*db.Collection, error) {
 	conn, err := db.Conn()
 	if err != nil {
@@ -81,67 +117,29 @@ func Retrieve(appName string) (string, error) {
 	return data["router"], nil
 }
 
-func Remove(appName string) error {
-	coll, err := collection()
-	if err != nil {
-		return err
-	}
-	return coll.Remove(bson.M{"app": appName})
-}
-
-func swapBackendName(backend1, backend2 string) error {
+// List returns the names of all apps stored in the database, mapped to the
+// names of their backends in the router.
+func List() (map[string]string, error) {
 	coll, err := collection()
 	if err != nil {
-		return err
-	}
-	router1, err := Retrieve(backend1)
-	if err != nil {
-		return err
+		return nil, err
 	}
-	router2, err := Retrieve(backend2)
+	var data []map[string]string
+	err = coll.Find(nil).All(&data)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	update := bson.M{"$set": bson.M{"router": router2}}
-	err = coll.Update(bson.M{"app": backend1}, update)
-	if err != nil {
-		return err
+	backends := make(map[string]string, len(data))
+	for _, d := range data {
+		backends[d["app"]] = d["router"]
 	}
-	update = bson.M{"$set": bson.M{"router": router1}}
-	err = coll.Update(bson.M{"app": backend2}, update)
-	var result []interface{}
-	coll.Find(nil).All(&result)
-	return err
+	return backends, nil
 }
 
-func Swap(r Router, backend1, backend2 string) error {
-	routes1, err := r.Routes(backend1)
-	if err != nil {
-		return err
-	}
-	routes2, err := r.Routes(backend2)
+func Remove(appName string) error {
+	coll, err := collection()
 	if err != nil {
 		return err
 	}
-	for _, route := range routes1 {
-		err = r.AddRoute(backend2, route)
-		if err != nil {
-			return err
-		}
-		err = r.RemoveRoute(backend1, route)
-		if err != nil {
-			return err
-		}
-	}
-	for _, route := range routes2 {
-		err = r.AddRoute(backend1, route)
-		if err != nil {
-			return err
-		}
-		err = r.RemoveRoute(backend2, route)
-		if err != nil {
-			return err
-		}
-	}
-	return swapBackendName(backend1, backend2)
+	return coll.Remove(bson.M{"app": appName})
 }
@@ -28,6 +28,19 @@ func (s *S) TestStore(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 }
 
+func (s *S) TestList(c *gocheck.C) {
+	err := Store("appname", "routername")
+	c.Assert(err, gocheck.IsNil)
+	defer Remove("appname")
+	err = Store("appname2", "routername2")
+	c.Assert(err, gocheck.IsNil)
+	defer Remove("appname2")
+	backends, err := List()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(backends["appname"], gocheck.Equals, "routername")
+	c.Assert(backends["appname2"], gocheck.Equals, "routername2")
+}
+
 func (s *S) TestRetireveNotFound(c *gocheck.C) {
 	name, err := Retrieve("notfound")
 	c.Assert(err, gocheck.Not(gocheck.IsNil))
@@ -49,3 +62,33 @@ func (s *S) TestSwapBackendName(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(name, gocheck.Equals, "routername")
 }
+
+func (s *S) TestSwapBackendNameTwiceRestoresNames(c *gocheck.C) {
+	err := Store("appname", "routername")
+	c.Assert(err, gocheck.IsNil)
+	defer Remove("appname")
+	err = Store("appname2", "routername2")
+	c.Assert(err, gocheck.IsNil)
+	defer Remove("appname2")
+	err = swapBackendName("appname", "appname2")
+	c.Assert(err, gocheck.IsNil)
+	err = swapBackendName("appname", "appname2")
+	c.Assert(err, gocheck.IsNil)
+	name, err := Retrieve("appname")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(name, gocheck.Equals, "routername")
+	name, err = Retrieve("appname2")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(name, gocheck.Equals, "routername2")
+}
+
+func (s *S) TestSwapBackendNameNotFound(c *gocheck.C) {
+	err := Store("appname", "routername")
+	c.Assert(err, gocheck.IsNil)
+	defer Remove("appname")
+	err = swapBackendName("appname", "unknown")
+	c.Assert(err, gocheck.NotNil)
+	name, err := Retrieve("appname")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(name, gocheck.Equals, "routername")
+}
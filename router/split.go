@@ -0,0 +1,179 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"errors"
+	"github.com/globocom/tsuru/db"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+)
+
+var (
+	ErrSplitNotFound = errors.New("There is no traffic split for this app.")
+	ErrInvalidWeight = errors.New("The weight must be a percentage between 1 and 99.")
+	ErrSplitToItself = errors.New("An app can't send traffic to itself.")
+	ErrChainedSplit  = errors.New("Apps involved in a traffic split can't be the target of another split, nor split their own traffic.")
+	ErrSplitActive   = errors.New("Apps involved in a traffic split can't be swapped. Remove the split first.")
+)
+
+// Split describes a share of the traffic of an app that is sent to the
+// routes of another app. Weight is the percentage of the requests that goes
+// to the target app.
+type Split struct {
+	App    string `bson:"_id"`
+	Target string
+	Weight int
+}
+
+// TrafficSplitter is a router that is able to send a share of the traffic of
+// a backend to the routes of another backend. Routers that support traffic
+// splitting should implement this interface, in addition to Router.
+type TrafficSplitter interface {
+	Router
+
+	// SplitTraffic sends weight percent of the traffic of the backend
+	// name to the routes of the backend target. A weight of 0 removes
+	// the split, sending all the traffic back to the backend name.
+	SplitTraffic(name, target string, weight int) error
+}
+
+func splitsCollection() (*db.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection("router_splits"), nil
+}
+
+// StoreSplit validates and stores the traffic split of the given app,
+// replacing any previous split of the app.
+func StoreSplit(appName, target string, weight int) error {
+	if weight < 1 || weight > 99 {
+		return ErrInvalidWeight
+	}
+	if appName == target {
+		return ErrSplitToItself
+	}
+	if _, err := RetrieveSplit(target); err != ErrSplitNotFound {
+		if err == nil {
+			err = ErrChainedSplit
+		}
+		return err
+	}
+	sources, err := SplitSources(appName)
+	if err != nil {
+		return err
+	}
+	if len(sources) > 0 {
+		return ErrChainedSplit
+	}
+	coll, err := splitsCollection()
+	if err != nil {
+		return err
+	}
+	_, err = coll.UpsertId(appName, Split{App: appName, Target: target, Weight: weight})
+	return err
+}
+
+// RetrieveSplit returns the traffic split of the given app, or
+// ErrSplitNotFound if the app does not split its traffic.
+func RetrieveSplit(appName string) (*Split, error) {
+	coll, err := splitsCollection()
+	if err != nil {
+		return nil, err
+	}
+	var split Split
+	err = coll.FindId(appName).One(&split)
+	if err == mgo.ErrNotFound {
+		return nil, ErrSplitNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &split, nil
+}
+
+// RemoveSplit removes the traffic split of the given app.
+func RemoveSplit(appName string) error {
+	coll, err := splitsCollection()
+	if err != nil {
+		return err
+	}
+	err = coll.RemoveId(appName)
+	if err == mgo.ErrNotFound {
+		return ErrSplitNotFound
+	}
+	return err
+}
+
+// SplitSources returns the names of the apps that send part of their
+// traffic to the given app.
+func SplitSources(target string) ([]string, error) {
+	coll, err := splitsCollection()
+	if err != nil {
+		return nil, err
+	}
+	var splits []Split
+	err = coll.Find(bson.M{"target": target}).Sort("_id").All(&splits)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]string, len(splits))
+	for i, split := range splits {
+		sources[i] = split.App
+	}
+	return sources, nil
+}
+
+// hasSplit checks whether the given app is involved in a traffic split,
+// either as the source or as the target.
+func hasSplit(appName string) (bool, error) {
+	if _, err := RetrieveSplit(appName); err != ErrSplitNotFound {
+		return err == nil, err
+	}
+	sources, err := SplitSources(appName)
+	if err != nil {
+		return false, err
+	}
+	return len(sources) > 0, nil
+}
+
+// WeightedRoutes returns a list of routes in which the routes of the target
+// appear in the given proportion (in percent), for routers that balance the
+// traffic evenly between the routes of a backend. Each route of own and
+// target is repeated as needed.
+func WeightedRoutes(own, target []string, weight int) []string {
+	if len(own) == 0 || weight >= 100 {
+		return append([]string(nil), target...)
+	}
+	if len(target) == 0 || weight <= 0 {
+		return append([]string(nil), own...)
+	}
+	ownCopies := (100 - weight) * len(target)
+	targetCopies := weight * len(own)
+	d := gcd(ownCopies, targetCopies)
+	ownCopies /= d
+	targetCopies /= d
+	routes := make([]string, 0, ownCopies*len(own)+targetCopies*len(target))
+	for _, route := range own {
+		for i := 0; i < ownCopies; i++ {
+			routes = append(routes, route)
+		}
+	}
+	for _, route := range target {
+		for i := 0; i < targetCopies; i++ {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
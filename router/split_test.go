@@ -0,0 +1,114 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import "launchpad.net/gocheck"
+
+func (s *S) TestStoreSplit(c *gocheck.C) {
+	err := StoreSplit("myapp", "canary", 10)
+	c.Assert(err, gocheck.IsNil)
+	defer RemoveSplit("myapp")
+	split, err := RetrieveSplit("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(*split, gocheck.DeepEquals, Split{App: "myapp", Target: "canary", Weight: 10})
+}
+
+func (s *S) TestStoreSplitReplacesPreviousSplit(c *gocheck.C) {
+	err := StoreSplit("myapp", "canary", 10)
+	c.Assert(err, gocheck.IsNil)
+	defer RemoveSplit("myapp")
+	err = StoreSplit("myapp", "canary", 50)
+	c.Assert(err, gocheck.IsNil)
+	split, err := RetrieveSplit("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(split.Weight, gocheck.Equals, 50)
+}
+
+func (s *S) TestStoreSplitInvalidWeight(c *gocheck.C) {
+	for _, weight := range []int{-1, 0, 100, 150} {
+		err := StoreSplit("myapp", "canary", weight)
+		c.Check(err, gocheck.Equals, ErrInvalidWeight)
+	}
+}
+
+func (s *S) TestStoreSplitToItself(c *gocheck.C) {
+	err := StoreSplit("myapp", "myapp", 10)
+	c.Assert(err, gocheck.Equals, ErrSplitToItself)
+}
+
+func (s *S) TestStoreSplitChained(c *gocheck.C) {
+	err := StoreSplit("myapp", "canary", 10)
+	c.Assert(err, gocheck.IsNil)
+	defer RemoveSplit("myapp")
+	err = StoreSplit("canary", "other", 10)
+	c.Assert(err, gocheck.Equals, ErrChainedSplit)
+	err = StoreSplit("other", "myapp", 10)
+	c.Assert(err, gocheck.Equals, ErrChainedSplit)
+}
+
+func (s *S) TestRetrieveSplitNotFound(c *gocheck.C) {
+	split, err := RetrieveSplit("myapp")
+	c.Assert(split, gocheck.IsNil)
+	c.Assert(err, gocheck.Equals, ErrSplitNotFound)
+}
+
+func (s *S) TestRemoveSplit(c *gocheck.C) {
+	err := StoreSplit("myapp", "canary", 10)
+	c.Assert(err, gocheck.IsNil)
+	err = RemoveSplit("myapp")
+	c.Assert(err, gocheck.IsNil)
+	_, err = RetrieveSplit("myapp")
+	c.Assert(err, gocheck.Equals, ErrSplitNotFound)
+	err = RemoveSplit("myapp")
+	c.Assert(err, gocheck.Equals, ErrSplitNotFound)
+}
+
+func (s *S) TestSplitSources(c *gocheck.C) {
+	err := StoreSplit("myapp", "canary", 10)
+	c.Assert(err, gocheck.IsNil)
+	defer RemoveSplit("myapp")
+	err = StoreSplit("otherapp", "canary", 20)
+	c.Assert(err, gocheck.IsNil)
+	defer RemoveSplit("otherapp")
+	sources, err := SplitSources("canary")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(sources, gocheck.DeepEquals, []string{"myapp", "otherapp"})
+	sources, err = SplitSources("myapp")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(sources, gocheck.HasLen, 0)
+}
+
+func (s *S) TestSwapWithSplit(c *gocheck.C) {
+	err := StoreSplit("myapp", "canary", 10)
+	c.Assert(err, gocheck.IsNil)
+	defer RemoveSplit("myapp")
+	err = Swap(nil, "myapp", "other")
+	c.Assert(err, gocheck.Equals, ErrSplitActive)
+	err = Swap(nil, "other", "canary")
+	c.Assert(err, gocheck.Equals, ErrSplitActive)
+}
+
+func (s *S) TestWeightedRoutes(c *gocheck.C) {
+	own := []string{"10.0.0.1", "10.0.0.2"}
+	target := []string{"10.0.0.3"}
+	routes := WeightedRoutes(own, target, 10)
+	count := map[string]int{}
+	for _, route := range routes {
+		count[route]++
+	}
+	c.Assert(count, gocheck.DeepEquals, map[string]int{"10.0.0.1": 9, "10.0.0.2": 9, "10.0.0.3": 2})
+}
+
+func (s *S) TestWeightedRoutesHalf(c *gocheck.C) {
+	routes := WeightedRoutes([]string{"10.0.0.1"}, []string{"10.0.0.2"}, 50)
+	c.Assert(routes, gocheck.DeepEquals, []string{"10.0.0.1", "10.0.0.2"})
+}
+
+func (s *S) TestWeightedRoutesWithoutRoutes(c *gocheck.C) {
+	routes := WeightedRoutes(nil, []string{"10.0.0.2"}, 10)
+	c.Assert(routes, gocheck.DeepEquals, []string{"10.0.0.2"})
+	routes = WeightedRoutes([]string{"10.0.0.1"}, nil, 10)
+	c.Assert(routes, gocheck.DeepEquals, []string{"10.0.0.1"})
+}
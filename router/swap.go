@@ -0,0 +1,157 @@
+// Copyright 2013 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"github.com/globocom/tsuru/action"
+	"github.com/globocom/tsuru/log"
+	"labix.org/v2/mgo/bson"
+)
+
+// Swap exchanges the routes of two backends, and the names of the backends
+// in the database, using a pipeline: if any step fails, the routes and the
+// names are restored.
+//
+// Apps involved in a traffic split can't be swapped.
+//
+// Routers that implement RouteSwapper have their routes exchanged in a
+// single operation. For other routers, routes are moved one at a time.
+func Swap(r Router, backend1, backend2 string) error {
+	for _, backend := range []string{backend1, backend2} {
+		split, err := hasSplit(backend)
+		if err != nil {
+			return err
+		}
+		if split {
+			return ErrSplitActive
+		}
+	}
+	pipeline := action.NewPipeline(&swapRoutes, &swapBackendNames)
+	return pipeline.Execute(r, backend1, backend2)
+}
+
+// swappedRoutes stores the routes each backend had before the swap. It's
+// the result of the swapRoutes action, used to undo the swap.
+type swappedRoutes struct {
+	routes1 []string
+	routes2 []string
+}
+
+// swapRoutes exchanges the routes of the backends.
+//
+// It expects three parameters: the router and the names of the two backends.
+var swapRoutes = action.Action{
+	Name: "swap-routes",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		r := ctx.Params[0].(Router)
+		backend1 := ctx.Params[1].(string)
+		backend2 := ctx.Params[2].(string)
+		if swapper, ok := r.(RouteSwapper); ok {
+			return nil, swapper.SwapRoutes(backend1, backend2)
+		}
+		routes1, err := r.Routes(backend1)
+		if err != nil {
+			return nil, err
+		}
+		routes2, err := r.Routes(backend2)
+		if err != nil {
+			return nil, err
+		}
+		moved1, err := moveRoutes(r, backend1, backend2, routes1)
+		if err != nil {
+			moveRoutes(r, backend2, backend1, moved1)
+			return nil, err
+		}
+		moved2, err := moveRoutes(r, backend2, backend1, routes2)
+		if err != nil {
+			moveRoutes(r, backend1, backend2, moved2)
+			moveRoutes(r, backend2, backend1, moved1)
+			return nil, err
+		}
+		return swappedRoutes{routes1: routes1, routes2: routes2}, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		r := ctx.Params[0].(Router)
+		backend1 := ctx.Params[1].(string)
+		backend2 := ctx.Params[2].(string)
+		if swapper, ok := r.(RouteSwapper); ok {
+			if err := swapper.SwapRoutes(backend1, backend2); err != nil {
+				log.Errorf("Failed to restore the routes of %s and %s: %s", backend1, backend2, err)
+			}
+			return
+		}
+		swapped := ctx.FWResult.(swappedRoutes)
+		if _, err := moveRoutes(r, backend2, backend1, swapped.routes1); err != nil {
+			log.Errorf("Failed to restore the routes of %s: %s", backend1, err)
+		}
+		if _, err := moveRoutes(r, backend1, backend2, swapped.routes2); err != nil {
+			log.Errorf("Failed to restore the routes of %s: %s", backend2, err)
+		}
+	},
+	MinParams: 3,
+}
+
+// swapBackendNames exchanges the names of the backends in the database.
+//
+// It expects three parameters: the router and the names of the two backends.
+var swapBackendNames = action.Action{
+	Name: "swap-backend-names",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		return nil, swapBackendName(ctx.Params[1].(string), ctx.Params[2].(string))
+	},
+	Backward: func(ctx action.BWContext) {
+		backend1 := ctx.Params[1].(string)
+		backend2 := ctx.Params[2].(string)
+		if err := swapBackendName(backend1, backend2); err != nil {
+			log.Errorf("Failed to restore the names of %s and %s: %s", backend1, backend2, err)
+		}
+	},
+	MinParams: 3,
+}
+
+// moveRoutes moves the given routes from one backend to another, returning
+// the list of routes that were moved. In case of failure, the route being
+// moved is kept in the original backend.
+func moveRoutes(r Router, from, to string, routes []string) ([]string, error) {
+	moved := make([]string, 0, len(routes))
+	for _, route := range routes {
+		if err := r.AddRoute(to, route); err != nil {
+			return moved, err
+		}
+		if err := r.RemoveRoute(from, route); err != nil {
+			r.RemoveRoute(to, route)
+			return moved, err
+		}
+		moved = append(moved, route)
+	}
+	return moved, nil
+}
+
+func swapBackendName(backend1, backend2 string) error {
+	coll, err := collection()
+	if err != nil {
+		return err
+	}
+	router1, err := Retrieve(backend1)
+	if err != nil {
+		return err
+	}
+	router2, err := Retrieve(backend2)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"router": router2}}
+	err = coll.Update(bson.M{"app": backend1}, update)
+	if err != nil {
+		return err
+	}
+	update = bson.M{"$set": bson.M{"router": router1}}
+	err = coll.Update(bson.M{"app": backend2}, update)
+	if err != nil {
+		coll.Update(bson.M{"app": backend1}, bson.M{"$set": bson.M{"router": router1}})
+		return err
+	}
+	return nil
+}
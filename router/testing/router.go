@@ -12,15 +12,58 @@ import (
 
 var FakeRouter = fakeRouter{backends: make(map[string][]string)}
 
-var ErrBackendNotFound = errors.New("Backend not found")
+var (
+	ErrBackendNotFound = errors.New("Backend not found")
+	ErrForcedFailure   = errors.New("Forced failure")
+)
 
 func init() {
 	router.Register("fake", &FakeRouter)
 }
 
 type fakeRouter struct {
-	backends map[string][]string
-	mutex    sync.Mutex
+	backends     map[string][]string
+	cnames       map[string][]string
+	certificates map[string]string
+	failuresByIp map[string]bool
+	mutex        sync.Mutex
+}
+
+// FailForIp makes the router fail when adding a route to the given address,
+// until RemoveFailForIp is called.
+func (r *fakeRouter) FailForIp(ip string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.failuresByIp == nil {
+		r.failuresByIp = make(map[string]bool)
+	}
+	r.failuresByIp[ip] = true
+}
+
+func (r *fakeRouter) RemoveFailForIp(ip string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.failuresByIp, ip)
+}
+
+// HasCertificate checks whether the given cname has a certificate with the
+// given content.
+func (r *fakeRouter) HasCertificate(cname, certificate string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	cert, ok := r.certificates[cname]
+	return ok && cert == certificate
+}
+
+func (r *fakeRouter) HasCName(name, cname string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, c := range r.cnames[name] {
+		if c == cname {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *fakeRouter) HasBackend(name string) bool {
@@ -66,6 +109,12 @@ func (r *fakeRouter) RemoveBackend(name string) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	delete(r.backends, backendName)
+	for _, cname := range r.cnames[backendName] {
+		delete(r.backends, cname)
+		delete(r.certificates, cname)
+	}
+	delete(r.cnames, backendName)
+	router.RemoveSplit(name)
 	return nil
 }
 
@@ -79,9 +128,15 @@ func (r *fakeRouter) AddRoute(name, ip string) error {
 	}
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	if r.failuresByIp[ip] {
+		return ErrForcedFailure
+	}
 	routes := r.backends[backendName]
 	routes = append(routes, ip)
 	r.backends[backendName] = routes
+	for _, cname := range r.cnames[backendName] {
+		r.backends[cname] = append(r.backends[cname], ip)
+	}
 	return nil
 }
 
@@ -108,9 +163,21 @@ func (r *fakeRouter) RemoveRoute(name, ip string) error {
 	}
 	routes[index] = routes[len(routes)-1]
 	r.backends[backendName] = routes[:len(routes)-1]
+	for _, cname := range r.cnames[backendName] {
+		r.backends[cname] = removeItem(r.backends[cname], ip)
+	}
 	return nil
 }
 
+func removeItem(list []string, item string) []string {
+	for i := range list {
+		if list[i] == item {
+			return append(list[:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
 func (r *fakeRouter) SetCName(cname, name string) error {
 	backendName, err := router.Retrieve(name)
 	if err != nil {
@@ -119,15 +186,74 @@ func (r *fakeRouter) SetCName(cname, name string) error {
 	if !r.HasBackend(backendName) {
 		return nil
 	}
-	r.AddBackend(cname)
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	r.backends[cname] = append(r.backends[backendName])
+	if r.cnames == nil {
+		r.cnames = make(map[string][]string)
+	}
+	found := false
+	for _, c := range r.cnames[backendName] {
+		if c == cname {
+			found = true
+			break
+		}
+	}
+	if !found {
+		r.cnames[backendName] = append(r.cnames[backendName], cname)
+	}
+	r.backends[cname] = append([]string(nil), r.backends[backendName]...)
 	return nil
 }
 
+func (r *fakeRouter) CNames(name string) (map[string][]string, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	cnames := make(map[string][]string, len(r.cnames[backendName]))
+	for _, cname := range r.cnames[backendName] {
+		cnames[cname] = append([]string(nil), r.backends[cname]...)
+	}
+	return cnames, nil
+}
+
 func (r *fakeRouter) UnsetCName(cname, name string) error {
-	return r.RemoveBackend(cname)
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	cnames := removeItem(r.cnames[backendName], cname)
+	if len(cnames) == len(r.cnames[backendName]) {
+		return ErrBackendNotFound
+	}
+	r.cnames[backendName] = cnames
+	delete(r.backends, cname)
+	delete(r.certificates, cname)
+	return nil
+}
+
+func (r *fakeRouter) AddCertificate(cname, certificate, key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.certificates == nil {
+		r.certificates = make(map[string]string)
+	}
+	r.certificates[cname] = certificate
+	return nil
+}
+
+func (r *fakeRouter) RemoveCertificate(cname string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.certificates[cname]; !ok {
+		return ErrBackendNotFound
+	}
+	delete(r.certificates, cname)
+	return nil
 }
 
 func (r *fakeRouter) Addr(name string) (string, error) {
@@ -143,6 +269,9 @@ func (r *fakeRouter) Reset() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	r.backends = make(map[string][]string)
+	r.cnames = make(map[string][]string)
+	r.certificates = make(map[string]string)
+	r.failuresByIp = make(map[string]bool)
 }
 
 func (r *fakeRouter) Routes(name string) ([]string, error) {
@@ -156,6 +285,25 @@ func (r *fakeRouter) Routes(name string) ([]string, error) {
 	return routes, nil
 }
 
+// SplitTraffic stores the split in the database, without changing the
+// routes of the backends. Use HasSplit to check it.
+func (r *fakeRouter) SplitTraffic(name, target string, weight int) error {
+	if weight == 0 {
+		return router.RemoveSplit(name)
+	}
+	if !r.HasBackend(name) || !r.HasBackend(target) {
+		return ErrBackendNotFound
+	}
+	return router.StoreSplit(name, target, weight)
+}
+
+// HasSplit checks whether the backend name sends the given share of its
+// traffic to the backend target.
+func (r *fakeRouter) HasSplit(name, target string, weight int) bool {
+	split, err := router.RetrieveSplit(name)
+	return err == nil && split.Target == target && split.Weight == weight
+}
+
 func (r *fakeRouter) Swap(backend1, backend2 string) error {
 	return router.Swap(r, backend1, backend2)
 }
@@ -134,6 +134,86 @@ func (s *S) TestUnsetCName(c *gocheck.C) {
 	c.Assert(r.HasBackend("myapp.com"), gocheck.Equals, false)
 }
 
+func (s *S) TestSetMultipleCNames(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("name")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("name", "127.0.0.1")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("myapp.com", "name")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("www.myapp.com", "name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasCName("name", "myapp.com"), gocheck.Equals, true)
+	c.Assert(r.HasCName("name", "www.myapp.com"), gocheck.Equals, true)
+	c.Assert(r.HasRoute("myapp.com", "127.0.0.1"), gocheck.Equals, true)
+	c.Assert(r.HasRoute("www.myapp.com", "127.0.0.1"), gocheck.Equals, true)
+	err = r.UnsetCName("myapp.com", "name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasCName("name", "myapp.com"), gocheck.Equals, false)
+	c.Assert(r.HasCName("name", "www.myapp.com"), gocheck.Equals, true)
+}
+
+func (s *S) TestSetCNameTwiceResyncsRoutes(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("name")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("myapp.com", "name")
+	c.Assert(err, gocheck.IsNil)
+	r.backends["name"] = []string{"127.0.0.1"}
+	err = r.SetCName("myapp.com", "name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.cnames["name"], gocheck.DeepEquals, []string{"myapp.com"})
+	c.Assert(r.HasRoute("myapp.com", "127.0.0.1"), gocheck.Equals, true)
+}
+
+func (s *S) TestFakeRouterIsACNameLister(c *gocheck.C) {
+	var _ router.CNameLister = &fakeRouter{}
+}
+
+func (s *S) TestCNames(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("name")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("name", "127.0.0.1")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("myapp.com", "name")
+	c.Assert(err, gocheck.IsNil)
+	cnames, err := r.CNames("name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(cnames, gocheck.DeepEquals, map[string][]string{"myapp.com": {"127.0.0.1"}})
+}
+
+func (s *S) TestAddAndRemoveRouteUpdateCNames(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("name")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("myapp.com", "name")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("www.myapp.com", "name")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("name", "127.0.0.1")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasRoute("myapp.com", "127.0.0.1"), gocheck.Equals, true)
+	c.Assert(r.HasRoute("www.myapp.com", "127.0.0.1"), gocheck.Equals, true)
+	err = r.RemoveRoute("name", "127.0.0.1")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasRoute("myapp.com", "127.0.0.1"), gocheck.Equals, false)
+	c.Assert(r.HasRoute("www.myapp.com", "127.0.0.1"), gocheck.Equals, false)
+}
+
+func (s *S) TestRemoveBackendRemovesCNames(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("name")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("myapp.com", "name")
+	c.Assert(err, gocheck.IsNil)
+	err = r.RemoveBackend("name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasBackend("myapp.com"), gocheck.Equals, false)
+	c.Assert(r.HasCName("name", "myapp.com"), gocheck.Equals, false)
+}
+
 func (s *S) TestAddr(c *gocheck.C) {
 	r := fakeRouter{backends: make(map[string][]string)}
 	err := r.AddBackend("name")
@@ -148,6 +228,45 @@ func (s *S) TestAddr(c *gocheck.C) {
 	c.Assert(err, gocheck.Equals, ErrBackendNotFound)
 }
 
+func (s *S) TestFakeRouterIsATLSRouter(c *gocheck.C) {
+	var _ router.TLSRouter = &fakeRouter{}
+}
+
+func (s *S) TestAddCertificate(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddCertificate("myapp.com", "the-cert", "the-key")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasCertificate("myapp.com", "the-cert"), gocheck.Equals, true)
+	err = r.AddCertificate("myapp.com", "new-cert", "new-key")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasCertificate("myapp.com", "the-cert"), gocheck.Equals, false)
+	c.Assert(r.HasCertificate("myapp.com", "new-cert"), gocheck.Equals, true)
+}
+
+func (s *S) TestRemoveCertificate(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddCertificate("myapp.com", "the-cert", "the-key")
+	c.Assert(err, gocheck.IsNil)
+	err = r.RemoveCertificate("myapp.com")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasCertificate("myapp.com", "the-cert"), gocheck.Equals, false)
+	err = r.RemoveCertificate("myapp.com")
+	c.Assert(err, gocheck.Equals, ErrBackendNotFound)
+}
+
+func (s *S) TestUnsetCNameRemovesCertificate(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("name")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("myapp.com", "name")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddCertificate("myapp.com", "the-cert", "the-key")
+	c.Assert(err, gocheck.IsNil)
+	err = r.UnsetCName("myapp.com", "name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasCertificate("myapp.com", "the-cert"), gocheck.Equals, false)
+}
+
 func (s *S) TestReset(c *gocheck.C) {
 	r := fakeRouter{backends: make(map[string][]string)}
 	err := r.AddBackend("name")
@@ -210,3 +329,136 @@ func (s *S) TestSwap(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(addr, gocheck.Equals, "127.0.0.1")
 }
+
+func (s *S) TestSwapUpdatesCNames(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("b1")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("b1", "127.0.0.1")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("myapp.com", "b1")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("www.myapp.com", "b1")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddBackend("b2")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("b2", "127.0.0.2")
+	c.Assert(err, gocheck.IsNil)
+	err = r.Swap("b1", "b2")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.backends["myapp.com"], gocheck.DeepEquals, []string{"127.0.0.2"})
+	c.Assert(r.backends["www.myapp.com"], gocheck.DeepEquals, []string{"127.0.0.2"})
+}
+
+func (s *S) TestSwapRollsBackRoutesOnFailure(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("rb1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("rb1")
+	err = r.AddRoute("rb1", "127.0.0.1")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddRoute("rb1", "127.0.0.3")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddBackend("rb2")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("rb2")
+	err = r.AddRoute("rb2", "127.0.0.2")
+	c.Assert(err, gocheck.IsNil)
+	r.FailForIp("127.0.0.2")
+	err = r.Swap("rb1", "rb2")
+	c.Assert(err, gocheck.Equals, ErrForcedFailure)
+	routes, err := r.Routes("rb1")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(routes, gocheck.HasLen, 2)
+	c.Assert(r.HasRoute("rb1", "127.0.0.1"), gocheck.Equals, true)
+	c.Assert(r.HasRoute("rb1", "127.0.0.3"), gocheck.Equals, true)
+	routes, err = r.Routes("rb2")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(routes, gocheck.DeepEquals, []string{"127.0.0.2"})
+	name, err := router.Retrieve("rb1")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(name, gocheck.Equals, "rb1")
+	name, err = router.Retrieve("rb2")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(name, gocheck.Equals, "rb2")
+}
+
+func (s *S) TestSwapRollsBackCNamesOnFailure(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("cb1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("cb1")
+	err = r.AddRoute("cb1", "127.0.0.1")
+	c.Assert(err, gocheck.IsNil)
+	err = r.SetCName("myapp.com", "cb1")
+	c.Assert(err, gocheck.IsNil)
+	err = r.AddBackend("cb2")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("cb2")
+	err = r.AddRoute("cb2", "127.0.0.2")
+	c.Assert(err, gocheck.IsNil)
+	r.FailForIp("127.0.0.2")
+	err = r.Swap("cb1", "cb2")
+	c.Assert(err, gocheck.Equals, ErrForcedFailure)
+	c.Assert(r.backends["myapp.com"], gocheck.DeepEquals, []string{"127.0.0.1"})
+}
+
+func (s *S) TestFailForIp(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("fb1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("fb1")
+	r.FailForIp("127.0.0.1")
+	err = r.AddRoute("fb1", "127.0.0.1")
+	c.Assert(err, gocheck.Equals, ErrForcedFailure)
+	c.Assert(r.HasRoute("fb1", "127.0.0.1"), gocheck.Equals, false)
+	r.RemoveFailForIp("127.0.0.1")
+	err = r.AddRoute("fb1", "127.0.0.1")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasRoute("fb1", "127.0.0.1"), gocheck.Equals, true)
+}
+
+func (s *S) TestFakeRouterIsATrafficSplitter(c *gocheck.C) {
+	var r interface{} = &fakeRouter{}
+	_, ok := r.(router.TrafficSplitter)
+	c.Assert(ok, gocheck.Equals, true)
+}
+
+func (s *S) TestSplitTraffic(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("sb1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sb1")
+	err = r.AddBackend("sb2")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sb2")
+	err = r.SplitTraffic("sb1", "sb2", 10)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasSplit("sb1", "sb2", 10), gocheck.Equals, true)
+	c.Assert(r.HasSplit("sb1", "sb2", 20), gocheck.Equals, false)
+	err = r.SplitTraffic("sb1", "", 0)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(r.HasSplit("sb1", "sb2", 10), gocheck.Equals, false)
+}
+
+func (s *S) TestSplitTrafficBackendNotFound(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.SplitTraffic("sb1", "sb2", 10)
+	c.Assert(err, gocheck.Equals, ErrBackendNotFound)
+}
+
+func (s *S) TestRemoveBackendRemovesSplit(c *gocheck.C) {
+	r := fakeRouter{backends: make(map[string][]string)}
+	err := r.AddBackend("sb1")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sb1")
+	err = r.AddBackend("sb2")
+	c.Assert(err, gocheck.IsNil)
+	defer router.Remove("sb2")
+	err = r.SplitTraffic("sb1", "sb2", 10)
+	c.Assert(err, gocheck.IsNil)
+	err = r.RemoveBackend("sb1")
+	c.Assert(err, gocheck.IsNil)
+	_, err = router.RetrieveSplit("sb1")
+	c.Assert(err, gocheck.Equals, router.ErrSplitNotFound)
+}
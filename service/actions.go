@@ -5,10 +5,16 @@
 package service
 
 import (
-	"errors"
+	stderrors "errors"
+	"fmt"
 	"github.com/globocom/tsuru/action"
+	"github.com/globocom/tsuru/app/bind"
 	"github.com/globocom/tsuru/db"
+	"github.com/globocom/tsuru/errors"
+	"github.com/globocom/tsuru/log"
 	"labix.org/v2/mgo/bson"
+	"net/http"
+	"sync"
 )
 
 // createServiceInstance in an action that calls the service endpoint
@@ -21,7 +27,7 @@ var createServiceInstance = action.Action{
 	Forward: func(ctx action.FWContext) (action.Result, error) {
 		service, ok := ctx.Params[0].(Service)
 		if !ok {
-			return nil, errors.New("First parameter must be a Service.")
+			return nil, stderrors.New("First parameter must be a Service.")
 		}
 		endpoint, err := service.getClient("production")
 		if err != nil {
@@ -29,7 +35,7 @@ var createServiceInstance = action.Action{
 		}
 		instance, ok := ctx.Params[1].(ServiceInstance)
 		if !ok {
-			return nil, errors.New("Second parameter must be a ServiceInstance.")
+			return nil, stderrors.New("Second parameter must be a ServiceInstance.")
 		}
 		err = endpoint.Create(&instance)
 		if err != nil {
@@ -63,7 +69,7 @@ var insertServiceInstance = action.Action{
 	Forward: func(ctx action.FWContext) (action.Result, error) {
 		instance, ok := ctx.Params[1].(ServiceInstance)
 		if !ok {
-			return nil, errors.New("Second parameter must be a ServiceInstance.")
+			return nil, stderrors.New("Second parameter must be a ServiceInstance.")
 		}
 		conn, err := db.Conn()
 		if err != nil {
@@ -90,3 +96,195 @@ var insertServiceInstance = action.Action{
 	},
 	MinParams: 2,
 }
+
+// addAppToServiceInstance is an action that adds the app to the list of apps
+// bound to the service instance, saving it in the database.
+//
+// The first argument in the context must be a bind.App.
+// The second argument in the context must be a *ServiceInstance.
+var addAppToServiceInstance = action.Action{
+	Name: "add-app-to-service-instance",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		app, ok := ctx.Params[0].(bind.App)
+		if !ok {
+			return nil, stderrors.New("First parameter must be a bind.App.")
+		}
+		instance, ok := ctx.Params[1].(*ServiceInstance)
+		if !ok {
+			return nil, stderrors.New("Second parameter must be a *ServiceInstance.")
+		}
+		err := instance.AddApp(app.GetName())
+		if err != nil {
+			return nil, &errors.HTTP{Code: http.StatusConflict, Message: "This app is already bound to this service instance."}
+		}
+		err = instance.update()
+		if err != nil {
+			instance.RemoveApp(app.GetName())
+			return nil, err
+		}
+		return nil, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		app, ok := ctx.Params[0].(bind.App)
+		if !ok {
+			return
+		}
+		instance, ok := ctx.Params[1].(*ServiceInstance)
+		if !ok {
+			return
+		}
+		if err := instance.RemoveApp(app.GetName()); err == nil {
+			if err := instance.update(); err != nil {
+				log.Errorf("Failed to remove the app %q from the instance %q: %s", app.GetName(), instance.Name, err)
+			}
+		}
+	},
+	MinParams: 2,
+}
+
+// bindUnits is an action that binds all units of the app to the service
+// instance. It waits for all units to be bound, and fails if any of them
+// fails, unbinding the units that were successfully bound, so it never leaves
+// the app partially bound.
+//
+// It returns the environment variables returned by the service API for all
+// units, and fails, unbinding all units, if the API returns different values
+// of a variable for different units.
+//
+// The first argument in the context must be a bind.App.
+// The second argument in the context must be a *ServiceInstance.
+var bindUnits = action.Action{
+	Name: "bind-units",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		app, ok := ctx.Params[0].(bind.App)
+		if !ok {
+			return nil, stderrors.New("First parameter must be a bind.App.")
+		}
+		instance, ok := ctx.Params[1].(*ServiceInstance)
+		if !ok {
+			return nil, stderrors.New("Second parameter must be a *ServiceInstance.")
+		}
+		units := app.GetUnits()
+		envs := make([]map[string]string, len(units))
+		errs := make([]error, len(units))
+		var wg sync.WaitGroup
+		for i, unit := range units {
+			wg.Add(1)
+			go func(i int, unit bind.Unit) {
+				defer wg.Done()
+				envs[i], errs[i] = instance.BindUnit(app, unit)
+			}(i, unit)
+		}
+		wg.Wait()
+		var (
+			bound  []bind.Unit
+			failed []error
+			msg    string
+		)
+		for i, err := range errs {
+			if err != nil {
+				log.Errorf("Failed to bind the unit %q to the instance %q: %s", units[i].GetIp(), instance.Name, err)
+				failed = append(failed, err)
+				msg += fmt.Sprintf("\n- %s (%s)", units[i].GetIp(), err)
+				continue
+			}
+			bound = append(bound, units[i])
+		}
+		if len(failed) > 0 {
+			unbindUnits(instance, bound)
+			if len(failed) == 1 {
+				return nil, failed[0]
+			}
+			return nil, stderrors.New("Failed to bind the following units:" + msg)
+		}
+		result, err := mergeUnitEnvs(units, envs)
+		if err != nil {
+			unbindUnits(instance, bound)
+			return nil, err
+		}
+		return result, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		app, ok := ctx.Params[0].(bind.App)
+		if !ok {
+			return
+		}
+		instance, ok := ctx.Params[1].(*ServiceInstance)
+		if !ok {
+			return
+		}
+		unbindUnits(instance, app.GetUnits())
+	},
+	MinParams: 2,
+}
+
+// mergeUnitEnvs merges the environment variables returned by the service API
+// for each unit. The units share the variables of the app, so all of them
+// must get the same value of each variable.
+func mergeUnitEnvs(units []bind.Unit, envs []map[string]string) (map[string]string, error) {
+	var result map[string]string
+	source := make(map[string]int)
+	for i, unitEnvs := range envs {
+		for name, value := range unitEnvs {
+			if j, ok := source[name]; ok {
+				if result[name] != value {
+					return nil, fmt.Errorf("The service API returned conflicting values of %s for the units %s and %s.", name, units[j].GetIp(), units[i].GetIp())
+				}
+				continue
+			}
+			if result == nil {
+				result = make(map[string]string)
+			}
+			result[name] = value
+			source[name] = i
+		}
+	}
+	return result, nil
+}
+
+// unbindUnits unbinds the given units from the service instance, in parallel,
+// waiting for all of them to finish. Failures are only logged.
+func unbindUnits(instance *ServiceInstance, units []bind.Unit) {
+	var wg sync.WaitGroup
+	for _, unit := range units {
+		wg.Add(1)
+		go func(unit bind.Unit) {
+			defer wg.Done()
+			if err := instance.UnbindUnit(unit); err != nil {
+				log.Errorf("Failed to unbind the unit %q from the instance %q: %s", unit.GetIp(), instance.Name, err)
+			}
+		}(unit)
+	}
+	wg.Wait()
+}
+
+// setBindEnvs is an action that exports the environment variables returned by
+// the previous action in the app, as private variables of the instance.
+//
+// The first argument in the context must be a bind.App.
+// The second argument in the context must be a *ServiceInstance.
+var setBindEnvs = action.Action{
+	Name: "set-bind-envs",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		app, ok := ctx.Params[0].(bind.App)
+		if !ok {
+			return nil, stderrors.New("First parameter must be a bind.App.")
+		}
+		instance, ok := ctx.Params[1].(*ServiceInstance)
+		if !ok {
+			return nil, stderrors.New("Second parameter must be a *ServiceInstance.")
+		}
+		envs, _ := ctx.Previous.(map[string]string)
+		envVars := make([]bind.EnvVar, 0, len(envs))
+		for k, v := range envs {
+			envVars = append(envVars, bind.EnvVar{
+				Name:         k,
+				Value:        v,
+				Public:       false,
+				InstanceName: instance.Name,
+			})
+		}
+		return envVars, app.SetEnvs(envVars, false)
+	},
+	MinParams: 2,
+}
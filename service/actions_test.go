@@ -6,10 +6,14 @@ package service
 
 import (
 	"github.com/globocom/tsuru/action"
+	"github.com/globocom/tsuru/app/bind"
+	"github.com/globocom/tsuru/errors"
 	"labix.org/v2/mgo/bson"
 	"launchpad.net/gocheck"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"sync"
 	"sync/atomic"
 )
 
@@ -154,3 +158,181 @@ func (s *S) TestInsertServiceInstanceBackwardParams(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 	defer s.conn.ServiceInstances().Remove(bson.M{"name": instance.Name})
 }
+
+func (s *S) TestAddAppToServiceInstanceName(c *gocheck.C) {
+	c.Assert(addAppToServiceInstance.Name, gocheck.Equals, "add-app-to-service-instance")
+}
+
+func (s *S) TestAddAppToServiceInstanceMinParams(c *gocheck.C) {
+	c.Assert(addAppToServiceInstance.MinParams, gocheck.Equals, 2)
+}
+
+func (s *S) TestAddAppToServiceInstanceForward(c *gocheck.C) {
+	instance := ServiceInstance{Name: "mysql"}
+	err := s.conn.ServiceInstances().Insert(&instance)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.ServiceInstances().Remove(bson.M{"name": instance.Name})
+	a := FakeApp{name: "myapp", ip: "10.10.10.10"}
+	ctx := action.FWContext{Params: []interface{}{&a, &instance}}
+	_, err = addAppToServiceInstance.Forward(ctx)
+	c.Assert(err, gocheck.IsNil)
+	err = s.conn.ServiceInstances().Find(bson.M{"name": instance.Name}).One(&instance)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(instance.Apps, gocheck.DeepEquals, []string{"myapp"})
+}
+
+func (s *S) TestAddAppToServiceInstanceForwardAlreadyBound(c *gocheck.C) {
+	instance := ServiceInstance{Name: "mysql", Apps: []string{"myapp"}}
+	a := FakeApp{name: "myapp", ip: "10.10.10.10"}
+	ctx := action.FWContext{Params: []interface{}{&a, &instance}}
+	_, err := addAppToServiceInstance.Forward(ctx)
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Code, gocheck.Equals, http.StatusConflict)
+}
+
+func (s *S) TestAddAppToServiceInstanceBackward(c *gocheck.C) {
+	instance := ServiceInstance{Name: "mysql", Apps: []string{"myapp"}}
+	err := s.conn.ServiceInstances().Insert(&instance)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.ServiceInstances().Remove(bson.M{"name": instance.Name})
+	a := FakeApp{name: "myapp", ip: "10.10.10.10"}
+	ctx := action.BWContext{Params: []interface{}{&a, &instance}}
+	addAppToServiceInstance.Backward(ctx)
+	err = s.conn.ServiceInstances().Find(bson.M{"name": instance.Name}).One(&instance)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(instance.Apps, gocheck.HasLen, 0)
+}
+
+func (s *S) TestBindUnitsName(c *gocheck.C) {
+	c.Assert(bindUnits.Name, gocheck.Equals, "bind-units")
+}
+
+func (s *S) TestBindUnitsMinParams(c *gocheck.C) {
+	c.Assert(bindUnits.MinParams, gocheck.Equals, 2)
+}
+
+func (s *S) TestBindUnitsForward(c *gocheck.C) {
+	h := TestHandler{}
+	ts := httptest.NewServer(&h)
+	defer ts.Close()
+	srv := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().RemoveId(srv.Name)
+	instance := ServiceInstance{Name: "my-mysql", ServiceName: srv.Name}
+	a := FakeApp{name: "myapp", ip: "10.10.10.10"}
+	ctx := action.FWContext{Params: []interface{}{&a, &instance}}
+	r, err := bindUnits.Forward(ctx)
+	c.Assert(err, gocheck.IsNil)
+	expected := map[string]string{
+		"MYSQL_DATABASE_NAME": "CHICO",
+		"MYSQL_HOST":          "localhost",
+		"MYSQL_PORT":          "3306",
+	}
+	c.Assert(r, gocheck.DeepEquals, expected)
+}
+
+// multiUnitApp is a FakeApp with one unit for each of the given ips.
+type multiUnitApp struct {
+	FakeApp
+	ips []string
+}
+
+func (a *multiUnitApp) GetUnits() []bind.Unit {
+	units := make([]bind.Unit, len(a.ips))
+	for i, ip := range a.ips {
+		units[i] = &FakeUnit{ip: ip}
+	}
+	return units
+}
+
+func (s *S) TestBindUnitsForwardMergesTheEnvsOfAllUnits(c *gocheck.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		r.ParseForm()
+		w.WriteHeader(http.StatusCreated)
+		if r.Form.Get("unit-host") == "10.10.10.10" {
+			w.Write([]byte(`{"MYSQL_HOST":"localhost"}`))
+		} else {
+			w.Write([]byte(`{"MYSQL_HOST":"localhost","MYSQL_PORT":"3306"}`))
+		}
+	}))
+	defer ts.Close()
+	srv := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().RemoveId(srv.Name)
+	instance := ServiceInstance{Name: "my-mysql", ServiceName: srv.Name}
+	a := multiUnitApp{FakeApp: FakeApp{name: "myapp", ip: "10.10.10.1"}, ips: []string{"10.10.10.10", "10.10.10.11"}}
+	ctx := action.FWContext{Params: []interface{}{&a, &instance}}
+	r, err := bindUnits.Forward(ctx)
+	c.Assert(err, gocheck.IsNil)
+	expected := map[string]string{"MYSQL_HOST": "localhost", "MYSQL_PORT": "3306"}
+	c.Assert(r, gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestBindUnitsForwardConflictingEnvs(c *gocheck.C) {
+	var unbound []string
+	var mut sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			mut.Lock()
+			unbound = append(unbound, r.URL.Path)
+			mut.Unlock()
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		r.ParseForm()
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"MYSQL_HOST":"` + r.Form.Get("unit-host") + `"}`))
+	}))
+	defer ts.Close()
+	srv := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().RemoveId(srv.Name)
+	instance := ServiceInstance{Name: "my-mysql", ServiceName: srv.Name}
+	a := multiUnitApp{FakeApp: FakeApp{name: "myapp", ip: "10.10.10.1"}, ips: []string{"10.10.10.10", "10.10.10.11"}}
+	ctx := action.FWContext{Params: []interface{}{&a, &instance}}
+	r, err := bindUnits.Forward(ctx)
+	c.Assert(r, gocheck.IsNil)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "The service API returned conflicting values of MYSQL_HOST for the units 10.10.10.10 and 10.10.10.11.")
+	sort.Strings(unbound)
+	expected := []string{
+		"/resources/my-mysql/hostname/10.10.10.10",
+		"/resources/my-mysql/hostname/10.10.10.11",
+	}
+	c.Assert(unbound, gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestBindUnitsBackward(c *gocheck.C) {
+	h := TestHandler{}
+	ts := httptest.NewServer(&h)
+	defer ts.Close()
+	srv := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().RemoveId(srv.Name)
+	instance := ServiceInstance{Name: "my-mysql", ServiceName: srv.Name}
+	a := FakeApp{name: "myapp", ip: "10.10.10.10"}
+	ctx := action.BWContext{Params: []interface{}{&a, &instance}}
+	bindUnits.Backward(ctx)
+	h.Lock()
+	defer h.Unlock()
+	c.Assert(h.method, gocheck.Equals, "DELETE")
+	c.Assert(h.url, gocheck.Equals, "/resources/my-mysql/hostname/10.10.10.10")
+}
+
+func (s *S) TestSetBindEnvsName(c *gocheck.C) {
+	c.Assert(setBindEnvs.Name, gocheck.Equals, "set-bind-envs")
+}
+
+func (s *S) TestSetBindEnvsMinParams(c *gocheck.C) {
+	c.Assert(setBindEnvs.MinParams, gocheck.Equals, 2)
+}
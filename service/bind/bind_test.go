@@ -17,6 +17,7 @@ import (
 	"launchpad.net/gocheck"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -208,6 +209,91 @@ func (s *S) TestBindAppMultiUnits(c *gocheck.C) {
 	c.Assert(calls, gocheck.Equals, int32(2))
 }
 
+func (s *S) TestBindAppUnbindsBoundUnitsWhenAnyUnitFails(c *gocheck.C) {
+	var unbound []string
+	var mut sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			mut.Lock()
+			unbound = append(unbound, r.URL.Path)
+			mut.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		r.ParseForm()
+		if r.Form.Get("unit-host") == "128.0.0.1" {
+			http.Error(w, "unit not allowed", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"DATABASE_USER":"root","DATABASE_PASSWORD":"s3cr3t"}`))
+	}))
+	defer ts.Close()
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := srvc.Create()
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().Remove(bson.M{"_id": "mysql"})
+	instance := service.ServiceInstance{
+		Name:        "my-mysql",
+		ServiceName: "mysql",
+		Teams:       []string{s.team.Name},
+	}
+	err = instance.Create()
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.ServiceInstances().Remove(bson.M{"name": "my-mysql"})
+	a, err := createTestApp(s.conn, "painkiller", "", []string{s.team.Name}, []app.Unit{{Ip: "127.0.0.1"}, {Ip: "128.0.0.1"}})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	err = instance.BindApp(&a)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err, gocheck.ErrorMatches, "^Failed to bind instance my-mysql to the unit 128.0.0.1: unit not allowed\n$")
+	mut.Lock()
+	c.Assert(unbound, gocheck.DeepEquals, []string{"/resources/my-mysql/hostname/127.0.0.1"})
+	mut.Unlock()
+	c.Assert(instance.Apps, gocheck.HasLen, 0)
+	err = s.conn.ServiceInstances().Find(bson.M{"name": instance.Name}).One(&instance)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(instance.Apps, gocheck.HasLen, 0)
+	newApp := app.App{Name: a.Name}
+	err = newApp.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(newApp.InstanceEnv(instance.Name), gocheck.HasLen, 0)
+}
+
+func (s *S) TestBindAppReportsAllFailures(c *gocheck.C) {
+	var unbinds int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			atomic.AddInt32(&unbinds, 1)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(w, "out of connections", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := srvc.Create()
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().Remove(bson.M{"_id": "mysql"})
+	instance := service.ServiceInstance{
+		Name:        "my-mysql",
+		ServiceName: "mysql",
+		Teams:       []string{s.team.Name},
+	}
+	err = instance.Create()
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.ServiceInstances().Remove(bson.M{"name": "my-mysql"})
+	a, err := createTestApp(s.conn, "painkiller", "", []string{s.team.Name}, []app.Unit{{Ip: "127.0.0.1"}, {Ip: "128.0.0.1"}})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	err = instance.BindApp(&a)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Matches, "(?s)^Failed to bind the following units:\n- 127.0.0.1 .*\n- 128.0.0.1 .*$")
+	c.Assert(atomic.LoadInt32(&unbinds), gocheck.Equals, int32(0))
+	err = s.conn.ServiceInstances().Find(bson.M{"name": instance.Name}).One(&instance)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(instance.Apps, gocheck.HasLen, 0)
+}
+
 func (s *S) TestBindReturnConflictIfTheAppIsAlreadyBound(c *gocheck.C) {
 	srvc := service.Service{Name: "mysql"}
 	err := srvc.Create()
@@ -257,6 +343,31 @@ func (s *S) TestBindReturnsPreconditionFailedIfTheAppDoesNotHaveAnUnitAndService
 	c.Assert(e.Message, gocheck.Equals, "This app does not have an IP yet.")
 }
 
+func (s *S) TestBindAddsTheAppWithoutUnitsToTheInstance(c *gocheck.C) {
+	called := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer ts.Close()
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := srvc.Create()
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().Remove(bson.M{"_id": "mysql"})
+	instance := service.ServiceInstance{Name: "my-mysql", ServiceName: "mysql", Teams: []string{s.team.Name}}
+	err = instance.Create()
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.ServiceInstances().Remove(bson.M{"_id": "my-mysql"})
+	a, err := createTestApp(s.conn, "painkiller", "", []string{s.team.Name}, []app.Unit{})
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	err = instance.BindApp(&a)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(called, gocheck.Equals, false)
+	err = s.conn.ServiceInstances().Find(bson.M{"_id": "my-mysql"}).One(&instance)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(instance.Apps, gocheck.DeepEquals, []string{a.Name})
+}
+
 func (s *S) TestUnbindUnit(c *gocheck.C) {
 	called := false
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -91,6 +91,46 @@ func (c *Client) Destroy(instance *ServiceInstance) error {
 	return err
 }
 
+// Update sends the new plan, description and tags of the service instance to
+// the service API. The api should be prepared to receive the request, like
+// below:
+// PUT /resources/<name>
+//
+// The API may respond with a JSON object containing the new environment
+// variables of the instance, or with an empty body when nothing changes for
+// the bound apps.
+func (c *Client) Update(instance *ServiceInstance) (map[string]string, error) {
+	log.Debugf("Attempting to call update of service instance %q at %q api", instance.Name, instance.ServiceName)
+	params := map[string][]string{
+		"plan":        {instance.PlanName},
+		"description": {instance.Description},
+		"tag":         instance.Tags,
+	}
+	resp, err := c.issueRequest("/resources/"+instance.Name, "PUT", params)
+	if err == nil && resp.StatusCode < 300 {
+		var result map[string]string
+		if resp.StatusCode == http.StatusNoContent {
+			resp.Body.Close()
+			return result, nil
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(strings.TrimSpace(string(body))) > 0 {
+			err = json.Unmarshal(body, &result)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	msg := "Failed to update the instance " + instance.Name + ": " + c.buildErrorMessage(err, resp)
+	log.Error(msg)
+	return nil, &errors.HTTP{Code: http.StatusInternalServerError, Message: msg}
+}
+
 func (c *Client) Bind(instance *ServiceInstance, app bind.App, unit bind.Unit) (map[string]string, error) {
 	log.Debugf("Calling bind of instance %q and unit %q at %q API",
 		instance.Name, unit.GetIp(), instance.ServiceName)
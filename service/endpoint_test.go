@@ -152,6 +152,49 @@ func (s *S) TestDestroyShouldReturnErrorIfTheRequestFails(c *gocheck.C) {
 	c.Assert(err, gocheck.ErrorMatches, "^Failed to destroy the instance "+instance.Name+": Server failed to do its job.$")
 }
 
+func (s *S) TestUpdateShouldSendAPUTToTheResourceURL(c *gocheck.C) {
+	h := TestHandler{}
+	ts := httptest.NewServer(&h)
+	defer ts.Close()
+	instance := ServiceInstance{Name: "her-redis", ServiceName: "redis", PlanName: "large", Tags: []string{"a", "b"}}
+	client := &Client{endpoint: ts.URL}
+	envs, err := client.Update(&instance)
+	h.Lock()
+	defer h.Unlock()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(h.url, gocheck.Equals, "/resources/"+instance.Name)
+	c.Assert(h.method, gocheck.Equals, "PUT")
+	v, err := url.ParseQuery(string(h.body))
+	c.Assert(err, gocheck.IsNil)
+	expected := map[string][]string{"plan": {"large"}, "description": {""}, "tag": {"a", "b"}}
+	c.Assert(map[string][]string(v), gocheck.DeepEquals, expected)
+	expectedEnvs := map[string]string{
+		"MYSQL_DATABASE_NAME": "CHICO",
+		"MYSQL_HOST":          "localhost",
+		"MYSQL_PORT":          "3306",
+	}
+	c.Assert(envs, gocheck.DeepEquals, expectedEnvs)
+}
+
+func (s *S) TestUpdateWithEmptyResponse(c *gocheck.C) {
+	ts := httptest.NewServer(http.HandlerFunc(noContentHandler))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "her-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL}
+	envs, err := client.Update(&instance)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(envs, gocheck.HasLen, 0)
+}
+
+func (s *S) TestUpdateShouldReturnErrorIfTheRequestFails(c *gocheck.C) {
+	ts := httptest.NewServer(http.HandlerFunc(failHandler))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "her-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL}
+	_, err := client.Update(&instance)
+	c.Assert(err, gocheck.ErrorMatches, "^Failed to update the instance her-redis: Server failed to do its job.$")
+}
+
 func (s *S) TestBindWithEndpointDown(c *gocheck.C) {
 	instance := ServiceInstance{Name: "her-redis", ServiceName: "redis"}
 	a := FakeApp{
@@ -7,6 +7,7 @@ package service
 import (
 	"encoding/json"
 	stderrors "errors"
+	"fmt"
 	"github.com/globocom/tsuru/action"
 	"github.com/globocom/tsuru/app/bind"
 	"github.com/globocom/tsuru/auth"
@@ -30,6 +31,9 @@ var (
 type ServiceInstance struct {
 	Name        string
 	ServiceName string `bson:"service_name"`
+	PlanName    string `bson:"plan_name"`
+	Description string
+	Tags        []string
 	Apps        []string
 	Teams       []string
 }
@@ -63,6 +67,9 @@ func (si *ServiceInstance) MarshalJSON() ([]byte, error) {
 		"Teams":       si.Teams,
 		"Apps":        si.Apps,
 		"ServiceName": si.ServiceName,
+		"PlanName":    si.PlanName,
+		"Description": si.Description,
+		"Tags":        si.Tags,
 		"Info":        info,
 	}
 	return json.Marshal(&data)
@@ -144,48 +151,102 @@ func (si *ServiceInstance) update() error {
 	return conn.ServiceInstances().Update(bson.M{"name": si.Name}, si)
 }
 
-// BindApp makes the bind between the service instance and an app.
-func (si *ServiceInstance) BindApp(app bind.App) error {
-	err := si.AddApp(app.GetName())
+// ServiceInstanceUpdate holds the changes to a service instance. Fields that
+// are nil are left unchanged.
+type ServiceInstanceUpdate struct {
+	PlanName    *string
+	Description *string
+	Tags        *[]string
+}
+
+// Update changes the plan, the description and the tags of the service
+// instance, only the ones given in updateData. It calls the service API to
+// apply the changes, saves the changed fields in the database and sends the
+// environment variables returned by the API to all bound apps, so they can be
+// serialized again in the app units.
+func (si *ServiceInstance) Update(updateData ServiceInstanceUpdate, apps []bind.App) error {
+	endpoint, err := si.Service().getClient("production")
 	if err != nil {
-		return &errors.HTTP{Code: http.StatusConflict, Message: "This app is already bound to this service instance."}
+		return err
 	}
-	err = si.update()
+	instance := *si
+	fields := bson.M{}
+	if updateData.PlanName != nil {
+		instance.PlanName = *updateData.PlanName
+		fields["plan_name"] = instance.PlanName
+	}
+	if updateData.Description != nil {
+		instance.Description = *updateData.Description
+		fields["description"] = instance.Description
+	}
+	if updateData.Tags != nil {
+		instance.Tags = *updateData.Tags
+		fields["tags"] = instance.Tags
+	}
+	envs, err := endpoint.Update(&instance)
 	if err != nil {
 		return err
 	}
-	if len(app.GetUnits()) == 0 {
-		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: "This app does not have an IP yet."}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
 	}
-	envsChan := make(chan map[string]string, len(app.GetUnits())+1)
-	errChan := make(chan error, len(app.GetUnits())+1)
-	for _, unit := range app.GetUnits() {
-		go func(unit bind.Unit) {
-			vars, err := si.BindUnit(app, unit)
-			if err != nil {
-				errChan <- err
-				return
-			}
-			envsChan <- vars
-		}(unit)
+	defer conn.Close()
+	if len(fields) > 0 {
+		err = conn.ServiceInstances().Update(bson.M{"name": si.Name}, bson.M{"$set": fields})
+		if err != nil {
+			return err
+		}
+	}
+	*si = instance
+	if len(envs) == 0 {
+		return nil
 	}
-	var envVars []bind.EnvVar
-	select {
-	case envs := <-envsChan:
-		envVars = make([]bind.EnvVar, 0, len(envs))
-		for k, v := range envs {
-			envVars = append(envVars, bind.EnvVar{
-				Name:         k,
-				Value:        v,
-				Public:       false,
-				InstanceName: si.Name,
-			})
+	envVars := make([]bind.EnvVar, 0, len(envs))
+	for k, v := range envs {
+		envVars = append(envVars, bind.EnvVar{
+			Name:         k,
+			Value:        v,
+			Public:       false,
+			InstanceName: si.Name,
+		})
+	}
+	var msg string
+	for _, app := range apps {
+		if si.FindApp(app.GetName()) < 0 {
+			continue
 		}
-		return app.SetEnvs(envVars, false)
-	case err = <-errChan:
-		log.Error(err.Error())
+		if err := app.SetEnvs(envVars, false); err != nil {
+			log.Errorf("Failed to update env vars of the app %q: %s", app.GetName(), err)
+			msg += fmt.Sprintf("\n- %s (%s)", app.GetName(), err)
+		}
+	}
+	if msg != "" {
+		return stderrors.New("Failed to update the environment variables of the following apps:" + msg)
 	}
-	return err
+	return nil
+}
+
+// BindApp makes the bind between the service instance and an app.
+//
+// The bind is all-or-nothing: all units of the app are bound to the instance,
+// and if any of them fails, the units that were bound are unbound and the app
+// is removed from the instance.
+//
+// An app without units is still added to the instance, and its units are
+// bound as they are added, but BindApp reports that the app does not have an
+// IP yet.
+func (si *ServiceInstance) BindApp(app bind.App) error {
+	if len(app.GetUnits()) == 0 {
+		pipeline := action.NewPipeline(&addAppToServiceInstance)
+		if err := pipeline.Execute(app, si); err != nil {
+			return err
+		}
+		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: "This app does not have an IP yet."}
+	}
+	actions := []*action.Action{&addAppToServiceInstance, &bindUnits, &setBindEnvs}
+	pipeline := action.NewPipeline(actions...)
+	return pipeline.Execute(app, si)
 }
 
 // BindUnit makes the bind between the binder and an unit.
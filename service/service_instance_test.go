@@ -11,10 +11,12 @@ import (
 	"github.com/globocom/tsuru/auth"
 	"github.com/globocom/tsuru/db"
 	"github.com/globocom/tsuru/testing"
+	"io/ioutil"
 	"labix.org/v2/mgo/bson"
 	"launchpad.net/gocheck"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"sync/atomic"
 )
 
@@ -319,6 +321,9 @@ func (s *InstanceSuite) TestMarshalJSON(c *gocheck.C) {
 		"Teams":       nil,
 		"Apps":        nil,
 		"ServiceName": "mysql",
+		"PlanName":    "",
+		"Description": "",
+		"Tags":        nil,
 		"Info":        map[string]interface{}{"key": "value"},
 	}
 	c.Assert(result, gocheck.DeepEquals, expected)
@@ -340,6 +345,9 @@ func (s *InstanceSuite) TestMarshalJSONWithoutInfo(c *gocheck.C) {
 		"Teams":       nil,
 		"Apps":        nil,
 		"ServiceName": "mysql",
+		"PlanName":    "",
+		"Description": "",
+		"Tags":        nil,
 		"Info":        nil,
 	}
 	c.Assert(result, gocheck.DeepEquals, expected)
@@ -361,6 +369,9 @@ func (s *InstanceSuite) TestMarshalJSONWithoutEndpoint(c *gocheck.C) {
 		"Teams":       nil,
 		"Apps":        nil,
 		"ServiceName": "mysql",
+		"PlanName":    "",
+		"Description": "",
+		"Tags":        nil,
 		"Info":        nil,
 	}
 	c.Assert(result, gocheck.DeepEquals, expected)
@@ -533,3 +544,128 @@ func (s *InstanceSuite) TestGetServiceInstance(c *gocheck.C) {
 	c.Assert(instance, gocheck.IsNil)
 	c.Assert(err, gocheck.Equals, ErrAccessNotAllowed)
 }
+
+type envRecorderApp struct {
+	FakeApp
+	envs []bind.EnvVar
+}
+
+func (a *envRecorderApp) SetEnvs(vars []bind.EnvVar, public bool) error {
+	a.envs = append(a.envs, vars...)
+	return nil
+}
+
+func (s *InstanceSuite) TestUpdate(c *gocheck.C) {
+	var method, path, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.Write([]byte(`{"DATABASE_HOST":"10.10.10.10"}`))
+	}))
+	defer ts.Close()
+	srv := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().RemoveId(srv.Name)
+	si := ServiceInstance{Name: "my-mysql", ServiceName: srv.Name, PlanName: "small", Apps: []string{"myapp"}}
+	err = s.conn.ServiceInstances().Insert(&si)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.ServiceInstances().Remove(bson.M{"name": si.Name})
+	a := envRecorderApp{FakeApp: FakeApp{name: "myapp", ip: "10.0.0.1"}}
+	plan, description, tags := "large", "my db", []string{"prod"}
+	updateData := ServiceInstanceUpdate{PlanName: &plan, Description: &description, Tags: &tags}
+	err = si.Update(updateData, []bind.App{&a})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(method, gocheck.Equals, "PUT")
+	c.Assert(path, gocheck.Equals, "/resources/my-mysql")
+	v, err := url.ParseQuery(body)
+	c.Assert(err, gocheck.IsNil)
+	expectedParams := map[string][]string{"plan": {"large"}, "description": {"my db"}, "tag": {"prod"}}
+	c.Assert(map[string][]string(v), gocheck.DeepEquals, expectedParams)
+	c.Assert(si.PlanName, gocheck.Equals, "large")
+	var instance ServiceInstance
+	err = s.conn.ServiceInstances().Find(bson.M{"name": si.Name}).One(&instance)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(instance.PlanName, gocheck.Equals, "large")
+	c.Assert(instance.Description, gocheck.Equals, "my db")
+	c.Assert(instance.Tags, gocheck.DeepEquals, []string{"prod"})
+	expectedEnvs := []bind.EnvVar{
+		{Name: "DATABASE_HOST", Value: "10.10.10.10", Public: false, InstanceName: si.Name},
+	}
+	c.Assert(a.envs, gocheck.DeepEquals, expectedEnvs)
+}
+
+func (s *InstanceSuite) TestUpdateOnlyTheGivenFields(c *gocheck.C) {
+	ts := httptest.NewServer(http.HandlerFunc(noContentHandler))
+	defer ts.Close()
+	srv := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().RemoveId(srv.Name)
+	si := ServiceInstance{
+		Name:        "my-mysql",
+		ServiceName: srv.Name,
+		PlanName:    "small",
+		Description: "my db",
+		Tags:        []string{"prod"},
+		Apps:        []string{"myapp"},
+	}
+	err = s.conn.ServiceInstances().Insert(&si)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.ServiceInstances().Remove(bson.M{"name": si.Name})
+	stale := si
+	err = s.conn.ServiceInstances().Update(bson.M{"name": si.Name}, bson.M{"$push": bson.M{"apps": "otherapp"}})
+	c.Assert(err, gocheck.IsNil)
+	tags := []string{"staging"}
+	err = stale.Update(ServiceInstanceUpdate{Tags: &tags}, nil)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(stale.Tags, gocheck.DeepEquals, tags)
+	var instance ServiceInstance
+	err = s.conn.ServiceInstances().Find(bson.M{"name": si.Name}).One(&instance)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(instance.PlanName, gocheck.Equals, "small")
+	c.Assert(instance.Description, gocheck.Equals, "my db")
+	c.Assert(instance.Tags, gocheck.DeepEquals, []string{"staging"})
+	c.Assert(instance.Apps, gocheck.DeepEquals, []string{"myapp", "otherapp"})
+}
+
+func (s *InstanceSuite) TestUpdateWithoutEnvs(c *gocheck.C) {
+	ts := httptest.NewServer(http.HandlerFunc(noContentHandler))
+	defer ts.Close()
+	srv := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().RemoveId(srv.Name)
+	si := ServiceInstance{Name: "my-mysql", ServiceName: srv.Name, Apps: []string{"myapp"}}
+	err = s.conn.ServiceInstances().Insert(&si)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.ServiceInstances().Remove(bson.M{"name": si.Name})
+	a := envRecorderApp{FakeApp: FakeApp{name: "myapp", ip: "10.0.0.1"}}
+	plan := "large"
+	err = si.Update(ServiceInstanceUpdate{PlanName: &plan}, []bind.App{&a})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(a.envs, gocheck.HasLen, 0)
+}
+
+func (s *InstanceSuite) TestUpdateEndpointFailure(c *gocheck.C) {
+	ts := httptest.NewServer(http.HandlerFunc(failHandler))
+	defer ts.Close()
+	srv := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.Services().RemoveId(srv.Name)
+	si := ServiceInstance{Name: "my-mysql", ServiceName: srv.Name, PlanName: "small"}
+	err = s.conn.ServiceInstances().Insert(&si)
+	c.Assert(err, gocheck.IsNil)
+	defer s.conn.ServiceInstances().Remove(bson.M{"name": si.Name})
+	plan := "large"
+	err = si.Update(ServiceInstanceUpdate{PlanName: &plan}, nil)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(si.PlanName, gocheck.Equals, "small")
+	var instance ServiceInstance
+	err = s.conn.ServiceInstances().Find(bson.M{"name": si.Name}).One(&instance)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(instance.PlanName, gocheck.Equals, "small")
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/globocom/tsuru/provision"
 	"io"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -227,7 +228,9 @@ type FakeProvisioner struct {
 	cmdMut           sync.Mutex
 	outputs          chan []byte
 	failures         chan failure
+	exitCodes        chan int
 	apps             map[string]provisionedApp
+	platforms        map[string]provision.PlatformOptions
 	mut              sync.RWMutex
 	executedPipeline bool
 	CustomPipeline   bool
@@ -237,7 +240,9 @@ func NewFakeProvisioner() *FakeProvisioner {
 	p := FakeProvisioner{}
 	p.outputs = make(chan []byte, 8)
 	p.failures = make(chan failure, 8)
+	p.exitCodes = make(chan int, 8)
 	p.apps = make(map[string]provisionedApp)
+	p.platforms = make(map[string]provision.PlatformOptions)
 	return &p
 }
 
@@ -346,6 +351,13 @@ func (p *FakeProvisioner) PrepareFailure(method string, err error) {
 	p.failures <- failure{method, err}
 }
 
+// PrepareExitCode prepares the exit status of the next command executed with
+// ExecuteCommandIsolated. Commands without a prepared exit status exit with
+// status 0.
+func (p *FakeProvisioner) PrepareExitCode(code int) {
+	p.exitCodes <- code
+}
+
 // Reset cleans up the FakeProvisioner, deleting all apps and their data. It
 // also deletes prepared failures and output. It's like calling
 // NewFakeProvisioner again, without all the allocations.
@@ -356,12 +368,14 @@ func (p *FakeProvisioner) Reset() {
 
 	p.mut.Lock()
 	p.apps = make(map[string]provisionedApp)
+	p.platforms = make(map[string]provision.PlatformOptions)
 	p.mut.Unlock()
 
 	for {
 		select {
 		case <-p.outputs:
 		case <-p.failures:
+		case <-p.exitCodes:
 		default:
 			return
 		}
@@ -388,6 +402,135 @@ func (p *FakeProvisioner) Deploy(app provision.App, version string, w io.Writer)
 	return nil
 }
 
+// DeployArchive pretends to deploy the app from the given archive, recording
+// its URL.
+func (p *FakeProvisioner) DeployArchive(app provision.App, archiveURL string, w io.Writer) error {
+	if err := p.getError("DeployArchive"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	w.Write([]byte("Archive deploy called"))
+	pApp.archiveURL = archiveURL
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
+// ArchiveURL returns the URL of the last archive deployed in the given app.
+func (p *FakeProvisioner) ArchiveURL(app provision.App) string {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[app.GetName()].archiveURL
+}
+
+// DeployImage pretends to deploy the app from the given image, recording its
+// name.
+func (p *FakeProvisioner) DeployImage(app provision.App, image string, w io.Writer) error {
+	if err := p.getError("DeployImage"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	w.Write([]byte("Image deploy called"))
+	pApp.image = image
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
+// DeployedImage returns the name of the last image deployed in the given app.
+func (p *FakeProvisioner) DeployedImage(app provision.App) string {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[app.GetName()].image
+}
+
+// CancelDeploy pretends to cancel the running deploy of the app, counting
+// the cancelations.
+func (p *FakeProvisioner) CancelDeploy(app provision.App) error {
+	if err := p.getError("CancelDeploy"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	pApp.cancels++
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
+// Cancels returns the number of times the deploy of the given app has been
+// canceled.
+func (p *FakeProvisioner) Cancels(app provision.App) int {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[app.GetName()].cancels
+}
+
+// PlatformAdd pretends to build the image of the platform, recording its
+// options.
+func (p *FakeProvisioner) PlatformAdd(opts provision.PlatformOptions, w io.Writer) error {
+	if err := p.getError("PlatformAdd"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if _, ok := p.platforms[opts.Name]; ok {
+		return errors.New("duplicate platform")
+	}
+	w.Write([]byte("Platform add called"))
+	p.platforms[opts.Name] = opts
+	return nil
+}
+
+// PlatformUpdate pretends to rebuild the image of the platform, recording
+// its new options.
+func (p *FakeProvisioner) PlatformUpdate(opts provision.PlatformOptions, w io.Writer) error {
+	if err := p.getError("PlatformUpdate"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if _, ok := p.platforms[opts.Name]; !ok {
+		return errors.New("platform not found")
+	}
+	w.Write([]byte("Platform update called"))
+	p.platforms[opts.Name] = opts
+	return nil
+}
+
+func (p *FakeProvisioner) PlatformRemove(name string) error {
+	if err := p.getError("PlatformRemove"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if _, ok := p.platforms[name]; !ok {
+		return errors.New("platform not found")
+	}
+	delete(p.platforms, name)
+	return nil
+}
+
+// GetPlatform returns the options of the given platform, and whether it was
+// added to the provisioner.
+func (p *FakeProvisioner) GetPlatform(name string) (provision.PlatformOptions, bool) {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	opts, ok := p.platforms[name]
+	return opts, ok
+}
+
 func (p *FakeProvisioner) Provision(app provision.App) error {
 	if err := p.getError("Provision"); err != nil {
 		return err
@@ -657,7 +800,7 @@ func (p *FakeProvisioner) SetCName(app provision.App, cname string) error {
 	if !ok {
 		return errNotProvisioned
 	}
-	pApp.cname = cname
+	pApp.cnames = append(pApp.cnames, cname)
 	p.apps[app.GetName()] = pApp
 	return nil
 }
@@ -672,7 +815,12 @@ func (p *FakeProvisioner) UnsetCName(app provision.App, cname string) error {
 	if !ok {
 		return errNotProvisioned
 	}
-	pApp.cname = ""
+	for i, c := range pApp.cnames {
+		if c == cname {
+			pApp.cnames = append(pApp.cnames[:i], pApp.cnames[i+1:]...)
+			break
+		}
+	}
 	p.apps[app.GetName()] = pApp
 	return nil
 }
@@ -681,17 +829,300 @@ func (p *FakeProvisioner) HasCName(app provision.App, cname string) bool {
 	p.mut.RLock()
 	pApp, ok := p.apps[app.GetName()]
 	p.mut.RUnlock()
-	return ok && pApp.cname == cname
+	if !ok {
+		return false
+	}
+	for _, c := range pApp.cnames {
+		if c == cname {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *FakeProvisioner) SetCertificate(app provision.App, cname, certificate, key string) error {
+	if err := p.getError("SetCertificate"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	if pApp.certificates == nil {
+		pApp.certificates = make(map[string]string)
+	}
+	pApp.certificates[cname] = certificate
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
+func (p *FakeProvisioner) UnsetCertificate(app provision.App, cname string) error {
+	if err := p.getError("UnsetCertificate"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	delete(pApp.certificates, cname)
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
+// HasCertificate checks whether the given cname of the app has a
+// certificate with the given content.
+func (p *FakeProvisioner) HasCertificate(app provision.App, cname, certificate string) bool {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return false
+	}
+	cert, ok := pApp.certificates[cname]
+	return ok && cert == certificate
+}
+
+func (p *FakeProvisioner) SplitTraffic(app, target provision.App, weight int) error {
+	if err := p.getError("SplitTraffic"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	pApp.splitTarget, pApp.splitWeight = "", 0
+	if weight > 0 {
+		pApp.splitTarget, pApp.splitWeight = target.GetName(), weight
+	}
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
+// Split returns the name of the app that receives part of the traffic of
+// the given app, and the weight of the split.
+func (p *FakeProvisioner) Split(app provision.App) (string, int) {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	pApp := p.apps[app.GetName()]
+	return pApp.splitTarget, pApp.splitWeight
+}
+
+// InternalAddresses returns the address of each unit of the app, built from
+// the IP of the unit.
+func (p *FakeProvisioner) InternalAddresses(app provision.App) ([]string, error) {
+	if err := p.getError("InternalAddresses"); err != nil {
+		return nil, err
+	}
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return nil, errNotProvisioned
+	}
+	var addresses []string
+	for _, unit := range pApp.units {
+		if unit.Ip != "" {
+			addresses = append(addresses, "http://"+unit.Ip+":8888")
+		}
+	}
+	return addresses, nil
+}
+
+// OpenShell records the shell session and writes a greeting to the
+// connection, returning immediately.
+func (p *FakeProvisioner) OpenShell(app provision.App, opts provision.ShellOptions) error {
+	if err := p.getError("OpenShell"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	pApp.shells = append(pApp.shells, opts)
+	p.apps[app.GetName()] = pApp
+	_, err := fmt.Fprintf(opts.Conn, "shell for %s\n", app.GetName())
+	return err
+}
+
+// Shells returns the options of the shell sessions opened in the given app.
+func (p *FakeProvisioner) Shells(app provision.App) []provision.ShellOptions {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[app.GetName()].shells
+}
+
+// ExecuteCommandIsolated pretends to run the command in a one-off unit,
+// recording it. The prepared output, if any, is sent to the standard output,
+// and the prepared exit status is returned.
+func (p *FakeProvisioner) ExecuteCommandIsolated(stdout, stderr io.Writer, app provision.App, cmd string, args ...string) (int, error) {
+	if err := p.getError("ExecuteCommandIsolated"); err != nil {
+		return -1, err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return -1, errNotProvisioned
+	}
+	pApp.isolatedCmds = append(pApp.isolatedCmds, Cmd{Cmd: cmd, Args: args, App: app})
+	p.apps[app.GetName()] = pApp
+	select {
+	case output := <-p.outputs:
+		stdout.Write(output)
+	default:
+	}
+	select {
+	case code := <-p.exitCodes:
+		return code, nil
+	default:
+	}
+	return 0, nil
+}
+
+// IsolatedCmds returns the commands executed in one-off units of the given
+// app.
+func (p *FakeProvisioner) IsolatedCmds(app provision.App) []Cmd {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[app.GetName()].isolatedCmds
 }
 
 type provisionedApp struct {
-	units       []provision.Unit
-	app         provision.App
-	restarts    int
-	installDeps int
-	version     string
-	cname       string
-	unitLen     int
+	units        []provision.Unit
+	app          provision.App
+	restarts     int
+	installDeps  int
+	version      string
+	archiveURL   string
+	image        string
+	cancels      int
+	cnames       []string
+	certificates map[string]string
+	splitTarget  string
+	splitWeight  int
+	shells       []provision.ShellOptions
+	isolatedCmds []Cmd
+	unitLen      int
+}
+
+// BuilderProvisioner is a fake provisioner that deploys apps in two steps,
+// building and then releasing them, like a provision.Builder. Each step, and
+// each command executed in builds, is recorded in order.
+type BuilderProvisioner struct {
+	*FakeProvisioner
+
+	// AppConfig is the configuration of the app returned by Build.
+	AppConfig *provision.AppConfig
+
+	stepsMut sync.Mutex
+	steps    []string
+	builds   []provision.BuildOptions
+	releases int
+}
+
+func (p *BuilderProvisioner) step(step string) {
+	p.stepsMut.Lock()
+	defer p.stepsMut.Unlock()
+	p.steps = append(p.steps, step)
+}
+
+// Build pretends to build the app, recording the options of the build, and
+// returns the configured AppConfig.
+func (p *BuilderProvisioner) Build(app provision.App, opts provision.BuildOptions, w io.Writer) (*provision.AppConfig, error) {
+	if err := p.getError("Build"); err != nil {
+		return nil, err
+	}
+	p.step("build")
+	p.stepsMut.Lock()
+	p.builds = append(p.builds, opts)
+	p.stepsMut.Unlock()
+	w.Write([]byte("Build called"))
+	if p.AppConfig == nil {
+		return &provision.AppConfig{}, nil
+	}
+	return p.AppConfig, nil
+}
+
+// ExecuteCommandInBuild pretends to run the command in a one-off unit of the
+// build of the app. The prepared output, if any, is sent to the standard
+// output, and the prepared exit status is returned.
+func (p *BuilderProvisioner) ExecuteCommandInBuild(stdout, stderr io.Writer, app provision.App, cmd string, args ...string) (int, error) {
+	if err := p.getError("ExecuteCommandInBuild"); err != nil {
+		return -1, err
+	}
+	p.step("run " + strings.TrimSpace(cmd+" "+strings.Join(args, " ")))
+	select {
+	case output := <-p.outputs:
+		stdout.Write(output)
+	default:
+	}
+	select {
+	case code := <-p.exitCodes:
+		return code, nil
+	default:
+	}
+	return 0, nil
+}
+
+// Release pretends to replace the units of the app with units running its
+// last build. The identifier of the build is the name of the app followed
+// by the number of releases made by the provisioner, e.g. "myapp-1".
+func (p *BuilderProvisioner) Release(app provision.App, w io.Writer) (string, error) {
+	if err := p.getError("Release"); err != nil {
+		return "", err
+	}
+	p.step("release")
+	p.stepsMut.Lock()
+	p.releases++
+	build := fmt.Sprintf("%s-%d", app.GetName(), p.releases)
+	p.stepsMut.Unlock()
+	w.Write([]byte("Release called"))
+	return build, nil
+}
+
+// RemoveBuild pretends to remove the last build of the app, recording the
+// step as "remove build".
+func (p *BuilderProvisioner) RemoveBuild(app provision.App) error {
+	if err := p.getError("RemoveBuild"); err != nil {
+		return err
+	}
+	p.step("remove build")
+	return nil
+}
+
+// Promote pretends to copy the given build to the target app, recording the
+// step as "promote <build>".
+func (p *BuilderProvisioner) Promote(build string, target provision.App, w io.Writer) error {
+	if err := p.getError("Promote"); err != nil {
+		return err
+	}
+	p.step("promote " + build)
+	w.Write([]byte("Promote called"))
+	return nil
+}
+
+// Steps returns the steps executed by the provisioner, in order: "build",
+// "release", "remove build", "promote <build>", and "run <cmd>" for each
+// command executed in builds.
+func (p *BuilderProvisioner) Steps() []string {
+	p.stepsMut.Lock()
+	defer p.stepsMut.Unlock()
+	return p.steps
+}
+
+// Builds returns the options of all builds.
+func (p *BuilderProvisioner) Builds() []provision.BuildOptions {
+	p.stepsMut.Lock()
+	defer p.stepsMut.Unlock()
+	return p.builds
 }
 
 type CommandableProvisioner struct {
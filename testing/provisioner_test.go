@@ -185,6 +185,113 @@ func (s *S) TestDeploy(c *gocheck.C) {
 	c.Assert(p.apps[app.GetName()].version, gocheck.Equals, "1.0")
 }
 
+func (s *S) TestDeployArchive(c *gocheck.C) {
+	var buf bytes.Buffer
+	app := NewFakeApp("soul", "arch", 1)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	err := p.DeployArchive(app, "http://example.com/soul.tar.gz", &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Equals, "Archive deploy called")
+	c.Assert(p.ArchiveURL(app), gocheck.Equals, "http://example.com/soul.tar.gz")
+}
+
+func (s *S) TestDeployArchiveUnknownApp(c *gocheck.C) {
+	var buf bytes.Buffer
+	app := NewFakeApp("soul", "arch", 1)
+	p := NewFakeProvisioner()
+	err := p.DeployArchive(app, "http://example.com/soul.tar.gz", &buf)
+	c.Assert(err, gocheck.Equals, errNotProvisioned)
+}
+
+func (s *S) TestDeployArchiveWithPreparedFailure(c *gocheck.C) {
+	var buf bytes.Buffer
+	app := NewFakeApp("soul", "arch", 1)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	p.PrepareFailure("DeployArchive", errors.New("not really"))
+	err := p.DeployArchive(app, "http://example.com/soul.tar.gz", &buf)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "not really")
+}
+
+func (s *S) TestDeployImage(c *gocheck.C) {
+	var buf bytes.Buffer
+	app := NewFakeApp("soul", "arch", 1)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	err := p.DeployImage(app, "registry.example.com/soul:1.0", &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Equals, "Image deploy called")
+	c.Assert(p.DeployedImage(app), gocheck.Equals, "registry.example.com/soul:1.0")
+}
+
+func (s *S) TestDeployImageUnknownApp(c *gocheck.C) {
+	var buf bytes.Buffer
+	app := NewFakeApp("soul", "arch", 1)
+	p := NewFakeProvisioner()
+	err := p.DeployImage(app, "registry.example.com/soul:1.0", &buf)
+	c.Assert(err, gocheck.Equals, errNotProvisioned)
+}
+
+func (s *S) TestCancelDeploy(c *gocheck.C) {
+	app := NewFakeApp("soul", "arch", 1)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	err := p.CancelDeploy(app)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(p.Cancels(app), gocheck.Equals, 1)
+}
+
+func (s *S) TestCancelDeployUnknownApp(c *gocheck.C) {
+	app := NewFakeApp("soul", "arch", 1)
+	p := NewFakeProvisioner()
+	err := p.CancelDeploy(app)
+	c.Assert(err, gocheck.Equals, errNotProvisioned)
+}
+
+func (s *S) TestPlatformAdd(c *gocheck.C) {
+	var buf bytes.Buffer
+	p := NewFakeProvisioner()
+	opts := provision.PlatformOptions{Name: "ruby", Image: "tsuru/ruby"}
+	err := p.PlatformAdd(opts, &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Equals, "Platform add called")
+	got, ok := p.GetPlatform("ruby")
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(got, gocheck.DeepEquals, opts)
+	err = p.PlatformAdd(opts, &buf)
+	c.Assert(err, gocheck.NotNil)
+}
+
+func (s *S) TestPlatformUpdate(c *gocheck.C) {
+	var buf bytes.Buffer
+	p := NewFakeProvisioner()
+	opts := provision.PlatformOptions{Name: "ruby", Image: "tsuru/ruby"}
+	err := p.PlatformUpdate(opts, &buf)
+	c.Assert(err, gocheck.NotNil)
+	p.PlatformAdd(opts, &buf)
+	buf.Reset()
+	opts.Image = "tsuru/ruby2"
+	err = p.PlatformUpdate(opts, &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Equals, "Platform update called")
+	got, _ := p.GetPlatform("ruby")
+	c.Assert(got.Image, gocheck.Equals, "tsuru/ruby2")
+}
+
+func (s *S) TestPlatformRemove(c *gocheck.C) {
+	var buf bytes.Buffer
+	p := NewFakeProvisioner()
+	err := p.PlatformRemove("ruby")
+	c.Assert(err, gocheck.NotNil)
+	p.PlatformAdd(provision.PlatformOptions{Name: "ruby", Image: "tsuru/ruby"}, &buf)
+	err = p.PlatformRemove("ruby")
+	c.Assert(err, gocheck.IsNil)
+	_, ok := p.GetPlatform("ruby")
+	c.Assert(ok, gocheck.Equals, false)
+}
+
 func (s *S) TestDeployUnknownApp(c *gocheck.C) {
 	var buf bytes.Buffer
 	app := NewFakeApp("soul", "arch", 1)
@@ -508,7 +615,7 @@ func (s *S) TestSetCName(c *gocheck.C) {
 	p.Provision(app)
 	err := p.SetCName(app, "cname.com")
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(p.apps[app.GetName()].cname, gocheck.Equals, "cname.com")
+	c.Assert(p.apps[app.GetName()].cnames, gocheck.DeepEquals, []string{"cname.com"})
 }
 
 func (s *S) TestSetCNameNotProvisioned(c *gocheck.C) {
@@ -533,12 +640,24 @@ func (s *S) TestUnsetCName(c *gocheck.C) {
 	p.Provision(app)
 	err := p.SetCName(app, "cname.com")
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(p.apps[app.GetName()].cname, gocheck.Equals, "cname.com")
+	c.Assert(p.apps[app.GetName()].cnames, gocheck.DeepEquals, []string{"cname.com"})
 	err = p.UnsetCName(app, "cname.com")
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(p.HasCName(app, "cname.com"), gocheck.Equals, false)
 }
 
+func (s *S) TestUnsetCNameKeepsOtherCNames(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	p.SetCName(app, "cname.com")
+	p.SetCName(app, "www.cname.com")
+	err := p.UnsetCName(app, "cname.com")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(p.HasCName(app, "cname.com"), gocheck.Equals, false)
+	c.Assert(p.HasCName(app, "www.cname.com"), gocheck.Equals, true)
+}
+
 func (s *S) TestUnsetCNameNotProvisioned(c *gocheck.C) {
 	app := NewFakeApp("jean", "mj", 0)
 	p := NewFakeProvisioner()
@@ -567,6 +686,50 @@ func (s *S) TestHasCName(c *gocheck.C) {
 	c.Assert(p.HasCName(app, "cname.com"), gocheck.Equals, false)
 }
 
+func (s *S) TestSetCertificate(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	err := p.SetCertificate(app, "cname.com", "the-cert", "the-key")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(p.HasCertificate(app, "cname.com", "the-cert"), gocheck.Equals, true)
+	c.Assert(p.HasCertificate(app, "cname.com", "other-cert"), gocheck.Equals, false)
+}
+
+func (s *S) TestSetCertificateNotProvisioned(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	err := p.SetCertificate(app, "cname.com", "the-cert", "the-key")
+	c.Assert(err, gocheck.Equals, errNotProvisioned)
+}
+
+func (s *S) TestSetCertificateFailure(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.PrepareFailure("SetCertificate", errors.New("wut"))
+	err := p.SetCertificate(app, "cname.com", "the-cert", "the-key")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "wut")
+}
+
+func (s *S) TestUnsetCertificate(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	err := p.SetCertificate(app, "cname.com", "the-cert", "the-key")
+	c.Assert(err, gocheck.IsNil)
+	err = p.UnsetCertificate(app, "cname.com")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(p.HasCertificate(app, "cname.com", "the-cert"), gocheck.Equals, false)
+}
+
+func (s *S) TestUnsetCertificateNotProvisioned(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	err := p.UnsetCertificate(app, "cname.com")
+	c.Assert(err, gocheck.Equals, errNotProvisioned)
+}
+
 func (s *S) TestCommandableProvisioner(c *gocheck.C) {
 	var p CommandableProvisioner
 	commands := p.Commands()
@@ -603,3 +766,173 @@ func (s *S) TestExecutedPipeline(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(p.ExecutedPipeline(), gocheck.Equals, true)
 }
+
+func (s *S) TestSplitTraffic(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	target := NewFakeApp("jean-canary", "mj", 0)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	err := p.SplitTraffic(app, target, 10)
+	c.Assert(err, gocheck.IsNil)
+	name, weight := p.Split(app)
+	c.Assert(name, gocheck.Equals, "jean-canary")
+	c.Assert(weight, gocheck.Equals, 10)
+	err = p.SplitTraffic(app, nil, 0)
+	c.Assert(err, gocheck.IsNil)
+	name, weight = p.Split(app)
+	c.Assert(name, gocheck.Equals, "")
+	c.Assert(weight, gocheck.Equals, 0)
+}
+
+func (s *S) TestSplitTrafficNotProvisioned(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	err := p.SplitTraffic(app, nil, 0)
+	c.Assert(err, gocheck.Equals, errNotProvisioned)
+}
+
+func (s *S) TestSplitTrafficFailure(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.PrepareFailure("SplitTraffic", errors.New("wut"))
+	err := p.SplitTraffic(app, nil, 0)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "wut")
+}
+
+func (s *S) TestInternalAddresses(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	addresses, err := p.InternalAddresses(app)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(addresses, gocheck.DeepEquals, []string{"http://10.10.10.1:8888"})
+}
+
+func (s *S) TestInternalAddressesNotProvisioned(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	_, err := p.InternalAddresses(app)
+	c.Assert(err, gocheck.Equals, errNotProvisioned)
+}
+
+func (s *S) TestInternalAddressesFailure(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.PrepareFailure("InternalAddresses", errors.New("wut"))
+	_, err := p.InternalAddresses(app)
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "wut")
+}
+
+func (s *S) TestOpenShell(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	var buf bytes.Buffer
+	opts := provision.ShellOptions{Conn: &buf, Unit: "jean/0", Width: 80, Height: 24}
+	err := p.OpenShell(app, opts)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(buf.String(), gocheck.Equals, "shell for jean\n")
+	c.Assert(p.Shells(app), gocheck.DeepEquals, []provision.ShellOptions{opts})
+}
+
+func (s *S) TestOpenShellNotProvisioned(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	var buf bytes.Buffer
+	err := p.OpenShell(app, provision.ShellOptions{Conn: &buf})
+	c.Assert(err, gocheck.Equals, errNotProvisioned)
+}
+
+func (s *S) TestOpenShellFailure(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.PrepareFailure("OpenShell", errors.New("wut"))
+	var buf bytes.Buffer
+	err := p.OpenShell(app, provision.ShellOptions{Conn: &buf})
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "wut")
+}
+
+func (s *S) TestExecuteCommandIsolated(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	p.PrepareOutput([]byte("migrated"))
+	var stdout, stderr bytes.Buffer
+	code, err := p.ExecuteCommandIsolated(&stdout, &stderr, app, "python", "manage.py", "migrate")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(code, gocheck.Equals, 0)
+	c.Assert(stdout.String(), gocheck.Equals, "migrated")
+	expected := []Cmd{{Cmd: "python", Args: []string{"manage.py", "migrate"}, App: app}}
+	c.Assert(p.IsolatedCmds(app), gocheck.DeepEquals, expected)
+}
+
+func (s *S) TestExecuteCommandIsolatedExitCode(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	p.PrepareExitCode(2)
+	var stdout, stderr bytes.Buffer
+	code, err := p.ExecuteCommandIsolated(&stdout, &stderr, app, "false")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(code, gocheck.Equals, 2)
+}
+
+func (s *S) TestExecuteCommandIsolatedNotProvisioned(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	var stdout, stderr bytes.Buffer
+	_, err := p.ExecuteCommandIsolated(&stdout, &stderr, app, "ls")
+	c.Assert(err, gocheck.Equals, errNotProvisioned)
+}
+
+func (s *S) TestExecuteCommandIsolatedFailure(c *gocheck.C) {
+	app := NewFakeApp("jean", "mj", 0)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	p.PrepareFailure("ExecuteCommandIsolated", errors.New("wut"))
+	var stdout, stderr bytes.Buffer
+	_, err := p.ExecuteCommandIsolated(&stdout, &stderr, app, "ls")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.Error(), gocheck.Equals, "wut")
+}
+
+func (s *S) TestBuilderProvisioner(c *gocheck.C) {
+	var buf bytes.Buffer
+	app := NewFakeApp("soul", "arch", 1)
+	config := provision.AppConfig{Hooks: provision.Hooks{PreDeploy: []string{"migrate"}}}
+	p := BuilderProvisioner{FakeProvisioner: NewFakeProvisioner(), AppConfig: &config}
+	got, err := p.Build(app, provision.BuildOptions{Version: "f1a2b3"}, &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(got, gocheck.Equals, &config)
+	p.PrepareOutput([]byte("migrated"))
+	p.PrepareExitCode(1)
+	code, err := p.ExecuteCommandInBuild(&buf, &buf, app, "migrate", "--all")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(code, gocheck.Equals, 1)
+	build, err := p.Release(app, &buf)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(build, gocheck.Equals, "soul-1")
+	c.Assert(buf.String(), gocheck.Equals, "Build calledmigratedRelease called")
+	c.Assert(p.Steps(), gocheck.DeepEquals, []string{"build", "run migrate --all", "release"})
+	c.Assert(p.Builds(), gocheck.DeepEquals, []provision.BuildOptions{{Version: "f1a2b3"}})
+}
+
+func (s *S) TestBuilderProvisionerBuildFailure(c *gocheck.C) {
+	app := NewFakeApp("soul", "arch", 1)
+	p := BuilderProvisioner{FakeProvisioner: NewFakeProvisioner()}
+	p.PrepareFailure("Build", errors.New("build failed"))
+	_, err := p.Build(app, provision.BuildOptions{Version: "f1a2b3"}, &bytes.Buffer{})
+	c.Assert(err, gocheck.ErrorMatches, "build failed")
+	c.Assert(p.Steps(), gocheck.HasLen, 0)
+}
+
+func (s *S) TestBuilderProvisionerRemoveBuild(c *gocheck.C) {
+	app := NewFakeApp("soul", "arch", 1)
+	p := BuilderProvisioner{FakeProvisioner: NewFakeProvisioner()}
+	err := p.RemoveBuild(app)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(p.Steps(), gocheck.DeepEquals, []string{"remove build"})
+}